@@ -0,0 +1,1257 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/mmcloughlin/geohash"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/one1zero1one/tractive_exporter/pkg/collector"
+	"github.com/one1zero1one/tractive_exporter/pkg/tractive"
+)
+
+// Key for unique geohash/tracker map
+type uniqueGeoStates struct {
+	tracker string
+	geohash string
+}
+
+// Value for the unique geohash/tracker map
+type uniqueGeoStatesValue struct {
+	counter       int32
+	lastTimestamp int64
+	created       time.Time
+}
+
+// Unique geohash/tracker combo
+var mapOfUniqueGeoStates map[uniqueGeoStates]uniqueGeoStatesValue
+
+// Value for the map of tracker geo memory
+type geoMemory struct {
+	prevLat     float64
+	prevLon     float64
+	prevGeohash string
+	lat         float64
+	lon         float64
+	geohash     string
+	distance    float64
+	updateTime  time.Time
+	age         time.Duration
+
+	// jitter filtering, see distance.go
+	recent      [][2]float64
+	rawDistance float64
+	suppressed  int64
+
+	// everything walked since the exporter started
+	odometer float64
+	created  time.Time
+}
+
+// Map of previous location (with tracker id as key)
+var mapOfTrackerGeoMemory map[string]geoMemory
+
+// Value for the map of tracker poll state, the exporter's own heartbeat
+type pollState struct {
+	polls        int64
+	created      time.Time
+	lastHit      time.Time
+	lastSuccess  time.Time
+	lastPosition *Position
+	lastGood     *Position
+	lastReport   int64
+}
+
+/*  the /info endpoint
+{
+    "name": "XXXX",
+    "tracker_id": "XXXXXXXX",
+    "image_url": "https://cdn.tractive.com/3/media/resource/XXXXXXXX.jpg",
+    "owner_name": "XXXXX"
+}
+*/
+
+// Info is the library's, with the error fields when the call went wrong
+type Info struct {
+	tractive.Info
+	apiErrorFields
+}
+
+/*  the /position endpoint
+{
+    "time": 1609533659,
+    "lat": XX.XXXXXXX,
+    "lon": XX.XXXXXXX,
+    "speed": 0.2,
+    "alt": 4,
+	"lt_active": true,
+	"battery_level": 87
+}
+... or...
+{
+    "code": 3555,
+    "category": "PUBLIC SHARE",
+    "message": "The public share does not exist.",
+    "detail": null
+}
+*/
+
+// Position is the library's, with the error instead when there's one
+type Position struct {
+	tractive.Position
+	Code     int    `json:"code"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+
+	// always null so far, kept so it doesn't count as an unknown field
+	Detail json.RawMessage `json:"detail,omitempty"`
+}
+
+var (
+
+	// What to monitor
+	trackersList = flag.String("trackers.list", "",
+		"Comma separated list of IDs from the public URLs, or tracker IDs with -tractive.email (all of the account's when empty)")
+
+	// Http client
+	tr = &http.Transport{
+		TLSClientConfig: &tls.Config{},
+	}
+	client = &http.Client{Transport: statsTransport{next: retryTransport{next: rateLimitTransport{next: chaosTransport{next: budgetTransport{next: tr}}}}}}
+	api    = tractive.NewClient(client)
+
+	// Windows only: install, uninstall, start, stop
+	serviceAction = flag.String("service", "",
+		"Control the Windows service: install, uninstall, start or stop")
+
+	// Serve Metrics
+	listenAddress = flag.String("web.port", ":9101",
+		"Address to listen on for telemetry")
+	metricsPath = flag.String("web.path", "/metrics",
+		"Path under which to expose metrics")
+
+	// Deprecation period for the badly named metrics
+	legacyMetrics = flag.Bool("compat.legacy-metrics", true,
+		"Also expose the deprecated tractive_age and tractive_distance_time (nanoseconds) metrics")
+
+	// Privacy, geohash and distance are still there
+	hideCoordinates = flag.Bool("metrics.hide-coordinates", false,
+		"Do not expose the exact latitude and longitude of the trackers")
+
+	// Metrics Description, the core ones come with the library
+	up                     = collector.Up
+	lastReceivedTime       = collector.LastTime
+	lastReceivedAgeSeconds = collector.Age
+	trackerLatitude        = collector.Latitude
+	trackerLongitude       = collector.Longitude
+	trackerSpeed           = collector.Speed
+	trackerAltitude        = collector.Altitude
+	trackerIsLive          = collector.Live
+
+	lastReceivedAge = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "age"),
+		"Age of the last reported message in seconds (deprecated, see age_seconds)",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerGeohash = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "geohash_total"),
+		"Geohash count",
+		[]string{"tracker", "geohash"}, nil,
+	)
+
+	trackerDistance = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "distance"),
+		"Distance from last location in meters",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerDistanceAge = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "distance_time"),
+		"Time in which the distance from last location was done, in nanoseconds (deprecated, see distance_interval_seconds)",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerDistanceInterval = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "distance_interval_seconds"),
+		"Seconds in which the distance from last location was done",
+		[]string{"tracker"}, nil,
+	)
+
+	apiIsPissed = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "code"),
+		"API response code, replaced by tractive_api_response_total and tractive_share_valid, gone with -metrics.set=v2",
+		[]string{"tracker", "category", "message"}, nil,
+	)
+
+	trackerPolls = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "polls_total"),
+		"Number of times the exporter polled the tracker",
+		[]string{"tracker"}, nil,
+	)
+
+	positionStale = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "position_stale"),
+		"Whether the last poll failed and the position metrics are the last known good ones (1) or fresh (0)",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerLastSuccessfulPoll = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "last_successful_poll_timestamp"),
+		"Timestamp of the last poll that returned a position in seconds since epoch",
+		[]string{"tracker"}, nil,
+	)
+
+	// Overlapping scrapes (several Prometheis) get the same result
+	scrapeCache = flag.Duration("web.scrape-cache", 5*time.Second,
+		"Scrapes arriving within this long of the previous one are served its cached result")
+
+	// Be nice to the public shares
+	minInterval = flag.Duration("tractive.min-interval", 30*time.Second,
+		"Minimum time between two API calls for the same tracker, scrapes in between reuse the last response")
+)
+
+// Custom exporters require 4 stubs
+
+// Exporter ...
+type Exporter struct {
+	mutex                 sync.Mutex
+	lastCollect           time.Time
+	lastMetrics           []prometheus.Metric
+	shareMutex            sync.RWMutex
+	shareList             []string
+	mapOfUniqueGeoStates  map[uniqueGeoStates]uniqueGeoStatesValue
+	mapOfTrackerGeoMemory map[string]geoMemory
+	batteryHistory        *batteryHistory
+	distanceHistory       *distanceHistory
+	activityDays          *activityDays
+	mapOfPollState        map[string]pollState
+	webhook               *positionWebhook
+	mqtt                  *mqttOutput
+	influx                *influxOutput
+	execHook              *execHook
+	notifier              *notifier
+	summary               *summaryOutput
+	trips                 *tripShares
+	flaps                 *flapCorrelator
+	alerts                *alertRules
+	geofences             []geofence
+	tractiveFences        []geofence
+	intervals             pollIntervals
+	mapOfInfo             map[string]infoState
+	mapOfZoneVisits       map[string]map[string]zoneVisit
+	mapOfLive             map[string]liveSession
+	tracks                *trackStore
+	bus                   *eventBus
+	pairs                 [][2]string
+	mapOfPairs            map[[2]string]bool
+	mapOfAltitude         map[string]altitudeFilter
+	mapOfAreaGeoStates    map[uniqueGeoStates]uniqueGeoStatesValue
+	mapOfHistograms       map[string]trackerHistograms
+	mapOfTrips            map[string]tripState
+	mapOfBackfill         map[string]backfillState
+	mapOfShares           map[string]shareState
+	speedBuckets          []float64
+	hopBuckets            []float64
+	tripDistanceBuckets   []float64
+	tripDurationBuckets   []float64
+	reloads               reloadState
+	stopped               bool
+}
+
+// NewExporter ...
+func NewExporter(shareList []string,
+	mapOfUniqueGeoStates map[uniqueGeoStates]uniqueGeoStatesValue,
+	mapOfTrackerGeoMemory map[string]geoMemory) *Exporter {
+	return &Exporter{
+		shareList:             shareList,
+		mapOfUniqueGeoStates:  mapOfUniqueGeoStates,
+		mapOfTrackerGeoMemory: mapOfTrackerGeoMemory,
+		batteryHistory:        newBatteryHistory(*batteryWindow),
+		distanceHistory:       newDistanceHistory(),
+		activityDays:          newActivityDays(*activityDailyGoal),
+		mapOfPollState:        make(map[string]pollState),
+		mapOfInfo:             make(map[string]infoState),
+		mapOfZoneVisits:       make(map[string]map[string]zoneVisit),
+		mapOfLive:             make(map[string]liveSession),
+		tracks:                newTrackStore(*trackRetention),
+		bus:                   newEventBus(),
+		mapOfPairs:            make(map[[2]string]bool),
+		mapOfAltitude:         make(map[string]altitudeFilter),
+		mapOfAreaGeoStates:    make(map[uniqueGeoStates]uniqueGeoStatesValue),
+		mapOfHistograms:       make(map[string]trackerHistograms),
+		mapOfTrips:            make(map[string]tripState),
+		mapOfBackfill:         make(map[string]backfillState),
+		mapOfShares:           make(map[string]shareState),
+		reloads:               reloadState{succeeded: time.Now()},
+	}
+}
+
+// Describe ...
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- up
+	ch <- lastPoll
+	ch <- lastReceivedTime
+	ch <- lastReceivedAge
+	ch <- lastReceivedAgeSeconds
+	ch <- trackerLatitude
+	ch <- trackerLongitude
+	ch <- trackerGeohash
+	ch <- geohashEvicted
+	ch <- trackerDistance
+	ch <- trackerDistanceAge
+	ch <- trackerDistanceInterval
+	ch <- trackerDistanceRaw
+	ch <- trackerDistanceSuppressed
+	ch <- trackerDistanceWindow
+	ch <- trackerDistanceToday
+	ch <- trackerDistanceWeek
+	ch <- activityGoalMet
+	ch <- activityStreak
+	ch <- trackerSpeed
+	ch <- trackerComputedSpeed
+	ch <- wellnessActivityMinutes
+	ch <- wellnessRestMinutes
+	ch <- wellnessCalories
+	ch <- wellnessSleepMinutes
+	ch <- wellnessGoalMinutes
+	ch <- wellnessTimestamp
+	ch <- sensorEvents
+	ch <- sensorLastEvent
+	ch <- flapPassages
+	unitMetricSet.describe(ch)
+	ch <- trackerAltitude
+	ch <- trackerIsLive
+	ch <- apiIsPissed
+	ch <- trackerBattery
+	ch <- trackerBatteryTimeToEmpty
+	ch <- trackerPolls
+	ch <- trackerLastSuccessfulPoll
+	ch <- positionStale
+	ch <- trackerPaused
+	ch <- stateUnknown
+	ch <- trackerStale
+	ch <- trackerInfo
+	ch <- trackerBatteryPercent
+	ch <- trackerCharging
+	ch <- trackerHardwareInfo
+	ch <- trackerGSMSignal
+	ch <- trackerGPSSignal
+	ch <- trackersConfigured
+	ch <- trackerConfigInfo
+	ch <- configReloadSuccessful
+	ch <- configReloadTime
+	ch <- alertFiring
+	ch <- geofenceInfo
+	ch <- zoneVisits
+	ch <- zoneLastVisit
+	ch <- zoneInside
+	ch <- trackerDistanceFromHome
+	ch <- trackerOdometer
+	ch <- pairDistance
+	ch <- pairSeparated
+	ch <- trackerAltitudeRaw
+	ch <- trackerAltitudeRejected
+	ch <- trackerAltitudeGain
+	ch <- trackerAltitudeLoss
+	ch <- trackerGeohashArea
+	ch <- trackerSpeedHistogram
+	ch <- trackerHopHistogram
+	ch <- trackerTrips
+	ch <- trackerTripDistance
+	ch <- trackerTripDuration
+	ch <- trackerTripActive
+	ch <- locationInfo
+	ch <- buildInfo
+	ch <- backfilledPositions
+	ch <- archiveWrites
+	ch <- archivePruned
+	ch <- streamClients
+	ch <- streamDropped
+	ch <- geocodeRequests
+	ch <- apiCallsTotal
+	ch <- apiCallsWindow
+	ch <- apiBudgetRemaining
+	ch <- pollIntervalStretch
+	ch <- sinkDeliveries
+	ch <- sinkRetriesTotal
+	ch <- sinkQueueLength
+	ch <- apiRetries
+	ch <- rateLimited
+	ch <- rateLimitRefused
+	ch <- rateLimitWait
+	ch <- rateLimitedUntil
+	ch <- pushes
+	ch <- pushLastSuccess
+	ch <- apiFailures
+	ch <- chaosInjected
+	ch <- historyPositions
+	ch <- historyOldest
+	ch <- historyNewest
+	ch <- historyDays
+	ch <- historySize
+	ch <- historyPruned
+	ch <- zoneSeconds
+	ch <- liveSessions
+	ch <- liveTransitions
+	ch <- liveSessionSeconds
+	ch <- liveSessionDuration
+	ch <- apiUnknownFields
+	ch <- scrapeDuration
+	ch <- apiRequestsTotal
+	ch <- apiErrorsTotal
+	ch <- apiRequestEnds
+	ch <- apiResponses
+	ch <- shareValid
+	ch <- shareLastCheck
+	ch <- apiRequestDeadline
+	ch <- memoryBudgetBytes
+	ch <- memoryUsedBytes
+	ch <- memoryDegradations
+	ch <- memoryGeohashReduction
+	describeV2(ch)
+}
+
+// Collect ...
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+
+	// one scrape at a time, the state maps are not meant for sharing
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// the poller did the work already
+	if *pollEvery > 0 {
+		e.collectCached(ch, nil)
+		return
+	}
+
+	// someone just asked, hand out the same answer
+	if time.Since(e.lastCollect) < *scrapeCache {
+		for _, m := range e.lastMetrics {
+			ch <- m
+		}
+		return
+	}
+
+	e.lastMetrics = e.collectTrackers(ch, e.trackers())
+	e.lastCollect = time.Now()
+}
+
+// collectTrackers runs a collection for some trackers, with the metric
+// names translated on the way out, and returns a copy of what was sent.
+// A nil ch only collects. Callers hold the mutex.
+func (e *Exporter) collectTrackers(ch chan<- prometheus.Metric, trackers []string) []prometheus.Metric {
+	return e.collectFetched(ch, trackers, fetchDue(e.dueFetches(trackers)))
+}
+
+// collectFetched is collectTrackers with what's been fetched already.
+// Callers hold the mutex.
+func (e *Exporter) collectFetched(ch chan<- prometheus.Metric, trackers []string, f pollFetch) []prometheus.Metric {
+	metrics := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	var collected []prometheus.Metric
+	go func() {
+		for m := range metrics {
+			for _, t := range translateMetric(m) {
+				collected = append(collected, t)
+				if ch != nil {
+					ch <- t
+				}
+			}
+		}
+		close(done)
+	}()
+	e.collect(metrics, trackers, f)
+	close(metrics)
+	<-done
+	return collected
+}
+
+// collect does the actual work behind Collect
+func (e *Exporter) collect(ch chan<- prometheus.Metric, trackers []string, f pollFetch) {
+
+	// What we were told to do, reachable or not
+	collectBuildInfo(ch)
+	e.collectConfigInfo(ch, trackers)
+	e.collectReload(ch)
+
+	//Can we reach the endpoint at all?
+	if f.reachable != nil {
+		ch <- prometheus.MustNewConstMetric(
+			up, prometheus.GaugeValue, 0,
+		)
+		slog.Warn("Tractive API unreachable", "err", f.reachable)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(
+		up, prometheus.GaugeValue, 1,
+	)
+
+	//Go get'em
+	e.HitTractiveApisAndUpdateMetrics(ch, trackers, f)
+	expireGeohashes(e.mapOfUniqueGeoStates, time.Now())
+	expireGeohashes(e.mapOfAreaGeoStates, time.Now())
+	collectGeohashEvictions(ch)
+	e.collectPairs(ch, trackers)
+	e.flaps.collect(ch, trackers)
+	e.tracks.collect(ch, trackers)
+	drift.collect(ch)
+	apiBudget.collect(ch)
+	rateLimit.collect(ch)
+	metricsPusher.collect(ch)
+	geocoder.collect(ch)
+	archive.collect(ch)
+	streams.collect(ch)
+	apiCallFailures.collect(ch)
+	chaos.collect(ch)
+	apiStats.collect(ch, trackers)
+	collectSinks(ch)
+	e.enforceMemoryBudget()
+	memory.collect(ch)
+
+	ch <- prometheus.MustNewConstMetric(scrapeDuration, prometheus.GaugeValue, time.Since(f.started).Seconds())
+}
+
+// HitTractiveApisAndUpdateMetrics ...
+func (e *Exporter) HitTractiveApisAndUpdateMetrics(ch chan<- prometheus.Metric, trackers []string, f pollFetch) {
+	e.refreshInfo(f.infos)
+
+	// For each tracker
+	for _, id := range trackers {
+
+		// heartbeat, so "exporter stopped polling" and "tracker stopped
+		// reporting" don't look the same
+		poll := e.mapOfPollState[id]
+
+		// don't hammer the share, however often we get scraped
+		p, ok := f.positions[id]
+		if !ok {
+			p = poll.lastPosition
+		} else {
+			poll.lastHit = f.started
+			if poll.created.IsZero() {
+				poll.created = time.Now()
+			}
+			poll.polls++
+			poll.lastPosition = p
+			apiStats.response(id, p)
+			if p.Code != -1 {
+				e.updateShare(id, p.Code, p.Message)
+			}
+			if p.Code == 0 {
+				poll.lastSuccess = time.Now()
+				poll.lastGood = p
+
+				// something the tracker hasn't told us before
+				if p.Time != poll.lastReport {
+					e.backfill(id, f.gaps[id])
+					poll.lastReport = p.Time
+					e.publishPosition(id, p)
+				}
+			} else {
+				apiErr := describeAPIError(p)
+				if p.Code > 0 {
+					apiStats.fail(id, apiErr.category)
+				}
+				slog.Warn("API error", "tracker", id, "code", p.Code, "category", apiErr.category, "explanation", apiErr.explanation)
+			}
+			e.mapOfPollState[id] = poll
+			ready.polled(id, poll.lastHit, poll.lastSuccess)
+		}
+
+		e.collectInfo(ch, id)
+		e.collectShare(ch, id)
+		collectPaused(ch, id)
+
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+			trackerPolls, prometheus.CounterValue, float64(poll.polls), poll.created, id,
+		)
+		if !poll.lastSuccess.IsZero() {
+			ch <- prometheus.MustNewConstMetric(
+				trackerLastSuccessfulPoll, prometheus.GaugeValue, float64(poll.lastSuccess.Unix()), id,
+			)
+		}
+
+		// paused before it was ever polled, nothing to show
+		if p == nil {
+			continue
+		}
+
+		// an API tantrum shouldn't blank the map, carry on with the last
+		// good position and say so
+		failed := false
+		if p.Code != 0 {
+			apiErr := describeAPIError(p)
+			ch <- prometheus.MustNewConstMetric(
+				apiIsPissed, prometheus.GaugeValue, float64(p.Code), id, apiErr.category, apiErr.explanation,
+			)
+			if poll.lastGood != nil {
+				p = poll.lastGood
+				failed = true
+				ch <- prometheus.MustNewConstMetric(positionStale, prometheus.GaugeValue, 1, id)
+			}
+		} else {
+			ch <- prometheus.MustNewConstMetric(positionStale, prometheus.GaugeValue, 0, id)
+		}
+
+		// expose them metrics ONLY when there is something to expose
+		if p.Code == 0 {
+
+			// last reported measurement's timestamp
+			ch <- prometheus.MustNewConstMetric(
+				lastReceivedTime, prometheus.GaugeValue, float64(p.Time), id,
+			)
+
+			// age is duration from the last received timestamp
+			age := time.Now().Unix() - p.Time
+			stale := isStale(failed, age)
+			ch <- prometheus.MustNewConstMetric(
+				lastReceivedAgeSeconds, prometheus.GaugeValue, float64(age), id,
+			)
+			if *legacyMetrics {
+				ch <- prometheus.MustNewConstMetric(
+					lastReceivedAge, prometheus.GaugeValue, float64(age), id,
+				)
+			}
+
+			collectStale(ch, id, stale)
+
+			// lat and long (not necesarily useful to be sent as metrics, but there they are)
+			hidePosition := stale && *staleHidePosition
+			if !*hideCoordinates && !hidePosition {
+				ch <- prometheus.MustNewConstMetric(
+					trackerLatitude, prometheus.GaugeValue, p.Lat, id,
+				)
+				ch <- prometheus.MustNewConstMetric(
+					trackerLongitude, prometheus.GaugeValue, p.Lon, id,
+				)
+			}
+
+			if !hidePosition {
+				collectHome(ch, id, p)
+			}
+
+			// geohash is a much better fit for sending as context
+			encoded := geohash.Encode(p.Lat, p.Lon)
+
+			// if different geohash, update state and compute distance and age.
+			newLocation := false
+			if encoded != e.mapOfTrackerGeoMemory[id].geohash {
+				newLocation = true
+				e.mapOfTrackerGeoMemory[id] = e.mapOfTrackerGeoMemory[id].move(p.Lat, p.Lon, encoded)
+
+				// the very first hop is from 0,0, nobody walked that
+				if memory := e.mapOfTrackerGeoMemory[id]; memory.prevGeohash != "" {
+					e.histograms(id).hop.observe(memory.distance)
+				}
+				if memory := e.mapOfTrackerGeoMemory[id]; memory.prevGeohash != "" && memory.distance > 0 {
+					memory.odometer += memory.distance
+					e.mapOfTrackerGeoMemory[id] = memory
+					e.addHop(id, p.Time, memory.distance)
+				}
+				stateWAL.move(id, e.mapOfTrackerGeoMemory[id])
+				e.mapOfTrackerGeoMemory[id].collectHop(ch, id)
+			}
+			e.distanceHistory.collect(ch, id)
+			e.activityDays.collect(ch, id)
+			ch <- prometheus.MustNewConstMetric(
+				trackerDistanceSuppressed, prometheus.CounterValue, float64(e.mapOfTrackerGeoMemory[id].suppressed), id,
+			)
+			ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+				trackerOdometer, prometheus.CounterValue, e.mapOfTrackerGeoMemory[id].odometer, e.mapOfTrackerGeoMemory[id].created, id,
+			)
+
+			// geohash as metric label for a counter
+			encoded = geohashLabel(id, p.Lat, p.Lon)
+			if uniqueGeo, counted := countGeohash(e.mapOfUniqueGeoStates, id, encoded, p.Time, newLocation); counted {
+				stateWAL.geohash("geohash", id, encoded, uniqueGeo)
+				ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+					trackerGeohash, prometheus.CounterValue, float64(uniqueGeo.counter), uniqueGeo.created, id, encoded,
+				)
+			}
+			e.collectGeohashArea(ch, id, p, newLocation)
+
+			e.collectSpeed(ch, id, p)
+			e.collectHistograms(ch, id)
+			e.collectAltitude(ch, id, p)
+
+			// bool to float64, we do what we must because we can
+			if isLiveNumber, ok := booleanValue(p.Live, stale); ok {
+				ch <- prometheus.MustNewConstMetric(
+					trackerIsLive, prometheus.GaugeValue, isLiveNumber, id,
+				)
+			}
+			collectUnknown(ch, id, "live", stale)
+
+			// battery is only there when the api bothers to send it
+			if p.Battery > 0 {
+				e.batteryHistory.add(id, p.Time, float64(p.Battery))
+			}
+			e.batteryHistory.collect(ch, id)
+			accountOf(id).collectHardware(ch, id)
+			accountOf(id).collectWellness(ch, id)
+			e.collectZones(ch, id, stale)
+			geocoder.collectPlace(ch, id, p)
+			e.collectLive(ch, id)
+			e.collectTrips(ch, id)
+			e.collectBackfill(ch, id)
+
+			// a collar in the drawer has nothing to alert about
+			if !pauses.paused(id) {
+				e.evaluateAlerts(ch, id, e.trackerState(id, p))
+			}
+		}
+
+	}
+}
+
+// fetchPosition hits the public share position endpoint
+func fetchPosition(ctx context.Context, id string) *Position {
+
+	// logged in, the account knows better
+	if a := accountOf(id); a != nil {
+		return a.position(ctx, id)
+	}
+
+	// Make request, a bad share or a slow API is no reason to die
+	path := "public_share/" + id + "/position"
+	status, body, err := api.Get(ctx, path, nil)
+	if err != nil {
+		slog.Warn("Position request failed", "tracker", id, "err", err)
+		return exporterError(err)
+	}
+	slog.Debug("Position response", "tracker", id, "status", status, "body", string(body))
+	capture.add(api.URL(path), status, body)
+
+	// counted as http_5xx or rate limited already, no point decoding the error page
+	if status >= 500 || status == http.StatusTooManyRequests {
+		return exporterError(fmt.Errorf("HTTP %d %s", status, http.StatusText(status)))
+	}
+
+	// New variable to unmarshal to
+	p := new(Position)
+
+	// Unmarshal response, whatever Tractive added since
+	err = decodeTolerant("position", body, p)
+	if err != nil {
+		slog.Warn("Could not decode position", "tracker", id, "err", err)
+		apiStats.fail(id, "decode")
+		return exporterError(err)
+	}
+
+	slog.Debug("Position", "tracker", id, "position", nicePrint(p))
+	return p
+}
+
+func hsin(theta float64) float64 {
+	return math.Pow(math.Sin(theta/2), 2)
+}
+
+// Distance ... https://gist.github.com/cdipaolo/d3f8db3848278b49db68
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	var la1, lo1, la2, lo2, r float64
+	la1 = lat1 * math.Pi / 180
+	lo1 = lon1 * math.Pi / 180
+	la2 = lat2 * math.Pi / 180
+	lo2 = lon2 * math.Pi / 180
+	r = 6378100 // Earth radius in METERS
+	h := hsin(la2-la1) + math.Cos(la1)*math.Cos(la2)*hsin(lo2-lo1)
+	return 2 * r * math.Asin(math.Sqrt(h))
+}
+
+func nicePrint(i interface{}) string {
+	s, _ := json.Marshal(i)
+	return string(s)
+}
+
+func prettyPrint(i interface{}) string {
+	s, _ := json.MarshalIndent(i, "", "\t")
+	return string(s)
+}
+
+// delteEmpty ... https://dabase.com/e/15006/
+func deleteEmpty(s []string) []string {
+	var r []string
+	for _, str := range s {
+		if str != "" {
+			r = append(r, str)
+		}
+	}
+	return r
+}
+
+// listedTrackers are the ones from TRACTIVE_PUBLIC_SHARES and -trackers.list
+func listedTrackers() []string {
+	return deleteEmpty(append(strings.Split(os.Getenv("TRACTIVE_PUBLIC_SHARES"), ","), strings.Split(*trackersList, ",")...))
+}
+
+func main() {
+
+	// maps used to keep state of things will be passed to exporter
+	mapOfUniqueGeoStates := make(map[uniqueGeoStates]uniqueGeoStatesValue)
+	mapOfTrackerGeoMemory := make(map[string]geoMemory)
+
+	// started by the Windows service manager? it starts us in System32,
+	// the .env file lives next to the binary
+	asService := isService()
+	if asService {
+		if exe, err := os.Executable(); err == nil {
+			os.Chdir(filepath.Dir(exe))
+		}
+	}
+
+	// deal with params
+	envErr := godotenv.Load()
+
+	flag.Parse()
+	if *showVersion {
+		printVersion()
+	}
+	err := setupLogging()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if envErr != nil {
+		slog.Info("Error loading .env file, assume env variables are set.")
+	}
+
+	// the file fills in whatever the command line didn't say
+	var configured []string
+	if *configFile != "" {
+		configured, err = loadConfig(*configFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := setupTimezone(); err != nil {
+		log.Fatal(err)
+	}
+	memory = newMemoryGuard()
+	chaos, err = parseChaos(*chaosSpec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// docker HEALTHCHECK, ask the running one and leave
+	if *healthcheck {
+		runHealthcheck()
+	}
+
+	// the running one's positions in Tractive's layout and leave
+	if flag.Arg(0) == "export" {
+		runExport(flag.Args()[1:])
+	}
+
+	// install, uninstall, start or stop the Windows service and leave
+	if *serviceAction != "" {
+		if err := controlService(*serviceAction); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// how we reach Tractive
+	if err := configureOutbound(); err != nil {
+		log.Fatal(err)
+	}
+	configureWake()
+
+	// public shares unless there are credentials
+	if flag.Arg(0) != "benchmark" && !*demoMode {
+		account, err = newTractiveAccount()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// list of trackers from env and params
+	shareList := append(listedTrackers(), configured...)
+	flagNames = parseMapping(*trackersNames)
+
+	// all of the account's trackers unless told otherwise
+	if account != nil && len(shareList) == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), fetchDeadline())
+		shareList, err = account.trackers(ctx)
+		cancel()
+		if err != nil {
+			log.Fatal(err)
+		}
+		slog.Info("Found trackers in the Tractive account", "count", len(shareList), "trackers", strings.Join(shareList, ","))
+		addDiscovery("", account, shareList)
+	}
+
+	// family members with their own shares or accounts
+	grouped, err := setupGroups()
+	if err != nil {
+		log.Fatal(err)
+	}
+	listed := make(map[string]bool)
+	for _, id := range shareList {
+		listed[id] = true
+	}
+	for _, id := range grouped {
+		if !listed[id] {
+			shareList = append(shareList, id)
+		}
+	}
+
+	// synthetic trackers that walk a minute on every call
+	if flag.Arg(0) == "benchmark" {
+		shareList = setupBenchmark()
+	}
+
+	// made up trackers that walk in real time
+	if *demoMode && flag.Arg(0) != "benchmark" {
+		shareList, err = setupDemo()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// prometheus rules for these trackers and leave
+	if flag.Arg(0) == "generate-rules" {
+		runGenerateRules(shareList)
+	}
+
+	exporter := NewExporter(shareList, mapOfUniqueGeoStates,
+		mapOfTrackerGeoMemory)
+
+	exporter.intervals, err = parseIntervals(*trackerIntervals)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pauses.configure(trackerConfig)
+
+	// outputs
+	exporter.webhook, err = newPositionWebhook()
+	if err != nil {
+		log.Fatal(err)
+	}
+	exporter.mqtt, err = newMQTTOutput()
+	if err != nil {
+		log.Fatal(err)
+	}
+	exporter.influx, err = newInfluxOutput()
+	if err != nil {
+		log.Fatal(err)
+	}
+	notifications, err = newNotificationTexts(*notifyLocale)
+	if err != nil {
+		log.Fatal(err)
+	}
+	exporter.execHook = newExecHook()
+	exporter.notifier, err = newNotifier()
+	if err != nil {
+		log.Fatal(err)
+	}
+	exporter.summary, err = newSummaryOutput()
+	if err != nil {
+		log.Fatal(err)
+	}
+	exporter.trips, err = newTripShares()
+	if err != nil {
+		log.Fatal(err)
+	}
+	exporter.flaps, err = newFlapCorrelator()
+	if err != nil {
+		log.Fatal(err)
+	}
+	exporter.alerts, err = newAlertRules()
+	if err != nil {
+		log.Fatal(err)
+	}
+	audit, err = newAuditLog(*auditFile, *auditSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+	geocoder, err = newReverseGeocoder()
+	if err != nil {
+		log.Fatal(err)
+	}
+	archive, err = newPositionArchive()
+	if err != nil {
+		log.Fatal(err)
+	}
+	exporter.subscribe()
+	if err := exporter.subscribeLiveControl(); err != nil {
+		log.Fatal(err)
+	}
+
+	// every integration once and leave
+	if flag.Arg(0) == "selftest" {
+		runSelftest(exporter)
+	}
+
+	if _, ok := unitProfiles[*webUnits]; !ok {
+		log.Fatalf("-web.units must be metric or imperial, not %q", *webUnits)
+	}
+	if err := setupMetricsUnits(); err != nil {
+		log.Fatal(err)
+	}
+	if err := setupStaticLabels(); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkStaleBooleans(); err != nil {
+		log.Fatal(err)
+	}
+	exporter.speedBuckets, err = parseBuckets(*speedBuckets)
+	if err != nil {
+		log.Fatal("-histogram.speed-buckets: ", err)
+	}
+	exporter.hopBuckets, err = parseBuckets(*hopBuckets)
+	if err != nil {
+		log.Fatal("-histogram.hop-buckets: ", err)
+	}
+	exporter.tripDistanceBuckets, err = parseBuckets(*tripDistanceBuckets)
+	if err != nil {
+		log.Fatal("-trips.distance-buckets: ", err)
+	}
+	exporter.tripDurationBuckets, err = parseBuckets(*tripDurationBuckets)
+	if err != nil {
+		log.Fatal("-trips.duration-buckets: ", err)
+	}
+	exporter.pairs, err = parsePairs(*proximityPairs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *homeLearnWindow > 0 {
+		learnedHomes, err = newHomeLearner(*homeLearnWindow, *homeNight)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// zones
+	if *geofenceFile != "" {
+		exporter.geofences, err = loadGeofences(*geofenceFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		slog.Info("Loaded geofences", "count", len(exporter.geofences), "file", *geofenceFile)
+	}
+	exporter.geofences = append(exporter.geofences, configZones...)
+	if err := uniqueZoneNames(exporter.geofences); err != nil {
+		log.Fatal(err)
+	}
+	if *geofenceTractive {
+		if account == nil && len(groupAccounts) == 0 {
+			log.Fatal("-geofence.tractive needs the account, see -tractive.email")
+		}
+		exporter.syncFences()
+	}
+
+	// timings and sizes with the synthetic trackers and leave
+	if flag.Arg(0) == "benchmark" {
+		runBenchmark(exporter)
+	}
+
+	// all of the above went fine, whether Tractive agrees and leave
+	if flag.Arg(0) == "check-config" {
+		runCheckConfig(exporter)
+	}
+
+	// counters carry on where the last run left them
+	if *statePath != "" {
+		if err := exporter.loadState(*statePath); err != nil {
+			log.Fatal(err)
+		}
+		if err := exporter.replayWAL(*statePath + ".wal"); err != nil {
+			log.Fatal(err)
+		}
+		stateWAL, err = openWAL(*statePath + ".wal")
+		if err != nil {
+			log.Fatal(err)
+		}
+		go stateWAL.runSync(*stateWALSyncInterval)
+		go exporter.runStateFlusher(*statePath, *stateFlushInterval)
+	}
+
+	// one poll to stdout and leave, for cron
+	if flag.Arg(0) == "once" {
+		runOnce(exporter)
+	}
+
+	prometheus.MustRegister(exporter)
+	if *pollEvery > 0 {
+		go exporter.runPoller()
+	}
+	if len(publicShares(shareList)) > 0 && *shareCheckInterval > 0 {
+		go exporter.runShareChecker(*shareCheckInterval)
+	}
+	if len(discoveries) > 0 && *discoverInterval > 0 && flag.Arg(0) != "benchmark" {
+		go exporter.runDiscovery(*discoverInterval)
+	}
+	if *geofenceTractive && *geofenceTractiveInterval > 0 && flag.Arg(0) != "benchmark" {
+		go exporter.runFenceSync(*geofenceTractiveInterval)
+	}
+	go exporter.reloadOnHUP()
+	if exporter.summary != nil {
+		go exporter.runSummary()
+	}
+	if err := startWellness(); err != nil {
+		log.Fatal(err)
+	}
+
+	// derived metrics from the user's script
+	if *scriptFile != "" {
+		script, err := newScriptCollector(exporter, *scriptFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		prometheus.MustRegister(script)
+	}
+
+	// pushed to an OpenTelemetry collector as well
+	if err := startOTel(exposedGatherer(prometheus.DefaultGatherer)); err != nil {
+		log.Fatal(err)
+	}
+	if err := startPush(exposedGatherer(prometheus.DefaultGatherer)); err != nil {
+		log.Fatal(err)
+	}
+
+	var web *webConfig
+	if *webConfigFile != "" {
+		web, err = loadWebConfig(*webConfigFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	mux := http.NewServeMux()
+
+	mux.Handle(*metricsPath, promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		metricsHandler(exposedGatherer(prometheus.DefaultGatherer)),
+	))
+
+	exporter.handleTenants(mux)
+
+	mux.HandleFunc("/api/v1/trackers/", exporter.trackerAPIHandler)
+	mux.HandleFunc("/api/v1/trackers/{id}/live", web.requireBasicAuth(exporter.liveHandler))
+	mux.HandleFunc("/api/v1/trackers/{id}/pause", web.requireBasicAuth(exporter.pauseHandler))
+	mux.HandleFunc("/api/v1/tracks/", exporter.tracksHandler)
+	mux.HandleFunc("/api/v1/history/{tracker}", exporter.historyTrackerHandler)
+	mux.HandleFunc("/api/v1/archive/{tracker}", exporter.archiveHandler)
+	mux.HandleFunc("/api/v1/positions", exporter.positionsHandler)
+	mux.HandleFunc("/api/v1/summary.csv", exporter.summaryHandler)
+	mux.HandleFunc("/api/v1/positions.geojson", exporter.positionsGeoJSONHandler)
+	mux.HandleFunc("/api/v1/stream", exporter.streamHandler)
+	mux.HandleFunc("/api/v1/geofences/preview", web.requireBasicAuth(exporter.geofencePreviewHandler))
+	mux.HandleFunc("/api/v1/audit", web.requireBasicAuth(auditHandler))
+
+	mux.HandleFunc("/-/healthy", healthyHandler)
+	mux.HandleFunc("/healthz", healthyHandler)
+	mux.HandleFunc("/readyz", exporter.readyHandler)
+	if *enableLifecycle {
+		mux.HandleFunc("/-/reload", exporter.reloadHandler)
+	}
+
+	mux.HandleFunc("/grafana/dashboard.json", exporter.grafanaHandler)
+
+	mux.HandleFunc("/tiles/", exporter.tilesHandler)
+	if *webMap {
+		mux.HandleFunc("/map", exporter.mapHandler)
+	}
+
+	capture = newResponseCapture(*captureSize)
+	mux.HandleFunc("/debug/responses", captureHandler)
+	mux.HandleFunc("/debug/responses/dump", captureDumpHandler)
+
+	mux.HandleFunc("/probe", exporter.probeHandler)
+	if *fileSDPath != "" {
+		if err := exporter.writeFileSD(*fileSDPath); err != nil {
+			slog.Error("Could not write file_sd targets", "err", err)
+		}
+	}
+
+	setupPublic(web)
+	if *webPublic {
+		mux.HandleFunc("/public", exporter.publicHandler)
+	}
+
+	setupSitters()
+	mux.HandleFunc("/api/v1/trackers/{id}/sitter", web.requireBasicAuth(exporter.sitterLinkHandler))
+	mux.HandleFunc("/sitter/{token}", exporter.sitterHandler)
+	mux.HandleFunc("/sitter/{token}/position", exporter.sitterPositionHandler)
+	mux.HandleFunc("/trips/{token}", exporter.tripHandler)
+	mux.HandleFunc("/api/v1/sensors/{sensor}", web.requireBasicAuth(exporter.sensorHandler))
+
+	mux.HandleFunc("/", exporter.landingHandler)
+
+	server := &http.Server{
+		Addr:    *listenAddress,
+		Handler: withRoutePrefix(newBasicAuth(web.basicAuthUsers(), mux)),
+	}
+	server.RegisterOnShutdown(streams.close)
+	done := make(chan struct{})
+	serve := func() {
+		serveUntilShutdown(server.ListenAndServe, done)
+	}
+
+	// https from the web config, same reloading as -web.tls-cert-file
+	if web.tls() {
+		if *tlsCertFile != "" || *acmeDomains != "" {
+			log.Fatal("tls_server_config in -web.config.file can't be used with -web.tls-cert-file or -web.acme-domains")
+		}
+		reloader, err := newCertReloader(web.TLSConfig.CertFile, web.TLSConfig.KeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go reloader.watch(*tlsReloadInterval)
+		server.TLSConfig, err = web.serverTLSConfig(reloader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		serve = func() {
+			serveUntilShutdown(func() error { return server.ListenAndServeTLS("", "") }, done)
+		}
+	}
+
+	// https, with certificates picked up again when renewed
+	if *tlsCertFile != "" {
+		reloader, err := newCertReloader(*tlsCertFile, *tlsKeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go reloader.watch(*tlsReloadInterval)
+		server.TLSConfig = &tls.Config{GetCertificate: reloader.GetCertificate}
+		serve = func() {
+			serveUntilShutdown(func() error { return server.ListenAndServeTLS("", "") }, done)
+		}
+	}
+
+	// or certificates straight from Let's Encrypt
+	if *acmeDomains != "" {
+		if *tlsCertFile != "" {
+			log.Fatal("-web.acme-domains and -web.tls-cert-file can't be used together")
+		}
+		server.TLSConfig = acmeTLSConfig()
+		serve = func() {
+			serveUntilShutdown(func() error { return server.ListenAndServeTLS("", "") }, done)
+		}
+	}
+	if asService {
+		if err := runService(serve); err != nil {
+			log.Fatal(err)
+		}
+		exporter.shutdown(server)
+		return
+	}
+	go exporter.shutdownOnSignal(server, done)
+	serve()
+}
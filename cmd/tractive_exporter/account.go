@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/one1zero1one/tractive_exporter/pkg/tractive"
+)
+
+var (
+
+	// The richer API behind the app login, public shares stay the default
+	tractiveEmail = flag.String("tractive.email", "",
+		"Tractive account email, enables the authenticated API (or TRACTIVE_EMAIL)")
+	tractivePassword = flag.String("tractive.password", "",
+		"Tractive account password (or TRACTIVE_PASSWORD)")
+	tractiveToken = flag.String("tractive.token", "",
+		"Tractive access token instead of email and password, can't be refreshed (or TRACTIVE_TOKEN)")
+	tractiveUserID = flag.String("tractive.user-id", "",
+		"Tractive user ID that goes with -tractive.token (or TRACTIVE_USER_ID)")
+)
+
+// tractiveAccount talks to the API as a logged in user, the library does
+// the logging in
+type tractiveAccount struct {
+	sync.Mutex
+	api      *tractive.Account
+	hardware map[string]deviceHwReport
+
+	// -tractive.wellness, by tracker, and the tracker of every pet
+	wellness map[string]wellnessOverview
+	pets     map[string]string
+}
+
+// Nil in public share mode, set up in main
+var account *tractiveAccount
+
+// devicePosReport is a position as the authenticated API has it
+type devicePosReport struct {
+	ID             string     `json:"_id"`
+	Type           string     `json:"_type"`
+	Version        string     `json:"_version"`
+	Time           int64      `json:"time"`
+	TimeReceived   int64      `json:"time_rcvd"`
+	LatLong        [2]float64 `json:"latlong"`
+	Speed          float64    `json:"speed"`
+	Altitude       int        `json:"altitude"`
+	Course         float64    `json:"course"`
+	Uncertainty    float64    `json:"pos_uncertainty"`
+	SensorUsed     string     `json:"sensor_used"`
+	ReportID       string     `json:"report_id"`
+	PowerSavingZID string     `json:"power_saving_zone_id"`
+	apiErrorFields
+}
+
+// deviceHwReport ...
+type deviceHwReport struct {
+	ID             string `json:"_id"`
+	Type           string `json:"_type"`
+	Version        string `json:"_version"`
+	Time           int64  `json:"time"`
+	BatteryLevel   int    `json:"battery_level"`
+	BatteryState   string `json:"battery_state"`
+	ChargingState  string `json:"charging_state"`
+	ClipMounted    *bool  `json:"clip_mounted_state"`
+	HwStatus       string `json:"hw_status"`
+	PowerSavingZID string `json:"power_saving_zone_id"`
+	ReportID       string `json:"report_id"`
+
+	// not in every report, only there when the tracker sent them
+	GSMSignal *float64 `json:"gsm_signal_strength"`
+	GPSSignal *float64 `json:"gps_signal_strength"`
+	apiErrorFields
+}
+
+// trackerReport is the tracker itself, for whether it's in live tracking,
+// lt_active like in the public share
+type trackerReport struct {
+	ID       string `json:"_id"`
+	Type     string `json:"_type"`
+	Version  string `json:"_version"`
+	State    string `json:"state"`
+	LtActive bool   `json:"lt_active"`
+	apiErrorFields
+}
+
+// apiErrorFields come instead of the data when something's wrong
+type apiErrorFields struct {
+	Code     int             `json:"code"`
+	Category string          `json:"category"`
+	Message  string          `json:"message"`
+	Detail   json.RawMessage `json:"detail,omitempty"`
+}
+
+// newTractiveAccount is nil unless credentials are configured
+func newTractiveAccount() (*tractiveAccount, error) {
+	return newAccountWith(
+		firstNonEmpty(*tractiveEmail, os.Getenv("TRACTIVE_EMAIL")),
+		firstNonEmpty(*tractivePassword, os.Getenv("TRACTIVE_PASSWORD")),
+		firstNonEmpty(*tractiveToken, os.Getenv("TRACTIVE_TOKEN")),
+		firstNonEmpty(*tractiveUserID, os.Getenv("TRACTIVE_USER_ID")),
+	)
+}
+
+// newAccountWith logs in, nil without an email or token
+func newAccountWith(email, password, token, userID string) (*tractiveAccount, error) {
+	a := &tractiveAccount{
+		hardware: make(map[string]deviceHwReport),
+		wellness: make(map[string]wellnessOverview),
+	}
+	switch {
+	case email == "" && token == "":
+		return nil, nil
+	case token != "" && userID == "":
+		return nil, errors.New("-tractive.token needs -tractive.user-id")
+	case token == "" && password == "":
+		return nil, errors.New("-tractive.email needs -tractive.password")
+	case token != "":
+		a.api = api.WithToken(token, userID)
+		return a, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchDeadline())
+	defer cancel()
+	var err error
+	if a.api, err = api.Login(ctx, email, password); err != nil {
+		return nil, fmt.Errorf("tractive login: %v", err)
+	}
+	slog.Info("Logged into Tractive", "user", a.api.UserID(), "valid_until", a.api.Expires().Format(time.RFC3339))
+	return a, nil
+}
+
+// get fetches an API path into v, the library logs in again on a 401
+func (a *tractiveAccount) get(ctx context.Context, endpoint, path string, v interface{}) error {
+	status, body, err := a.api.Get(ctx, path)
+	if err != nil {
+		return err
+	}
+	capture.add(api.URL(path), status, body)
+	if status >= 500 || status == http.StatusTooManyRequests {
+		return fmt.Errorf("HTTP %d %s", status, http.StatusText(status))
+	}
+	if err := decodeTolerant(endpoint, body, v); err != nil {
+		apiStats.fail(trackerFromContext(ctx), "decode")
+		return err
+	}
+	return nil
+}
+
+// trackers lists the trackers of the account
+func (a *tractiveAccount) trackers(ctx context.Context) ([]string, error) {
+	var list []struct {
+		ID      string `json:"_id"`
+		Type    string `json:"_type"`
+		Version string `json:"_version"`
+	}
+	if err := a.get(ctx, "trackers", "user/"+a.api.UserID()+"/trackers", &list); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, t := range list {
+		ids = append(ids, t.ID)
+	}
+	return ids, nil
+}
+
+// position puts the position and hardware reports together into what
+// the public share would have said
+func (a *tractiveAccount) position(ctx context.Context, id string) *Position {
+	var pos devicePosReport
+	if err := a.get(ctx, "device_pos_report", "device_pos_report/"+id, &pos); err != nil {
+		return exporterError(err)
+	}
+	if pos.Code != 0 {
+		return &Position{Code: pos.Code, Category: pos.Category, Message: pos.Message}
+	}
+
+	p := &Position{Position: tractive.Position{
+		Time:  pos.Time,
+		Lat:   pos.LatLong[0],
+		Lon:   pos.LatLong[1],
+		Speed: pos.Speed,
+		Alt:   pos.Altitude,
+	}}
+
+	// battery is nice to have, no reason to drop the position for it
+	var hw deviceHwReport
+	if err := a.get(ctx, "device_hw_report", "device_hw_report/"+id, &hw); err != nil {
+		slog.Warn("Hardware report failed", "tracker", id, "err", err)
+	} else if hw.Code == 0 {
+		p.Battery = hw.BatteryLevel
+		a.Lock()
+		a.hardware[id] = hw
+		a.Unlock()
+	}
+
+	// the same for live tracking, without it the tracker counts as not live
+	var tracker trackerReport
+	if err := a.get(ctx, "tracker", "tracker/"+id, &tracker); err != nil {
+		slog.Warn("Tracker report failed", "tracker", id, "err", err)
+	} else if tracker.Code == 0 {
+		p.Live = tracker.LtActive
+	}
+	return p
+}
+
+// exporterError is a Position for failures on our side of the wire
+func exporterError(err error) *Position {
+	return &Position{Code: -1, Category: "exporter", Message: err.Error()}
+}
+
+// firstNonEmpty ...
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
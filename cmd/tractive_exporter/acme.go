@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var (
+
+	// Let's Encrypt for the ones exposing the UI on a public hostname
+	acmeDomains = flag.String("web.acme-domains", "",
+		"Comma separated hostnames to get certificates for via ACME (Let's Encrypt), enables TLS")
+	acmeEmail = flag.String("web.acme-email", "",
+		"Contact email for the ACME account")
+	acmeCacheDir = flag.String("web.acme-cache-dir", "acme-cache",
+		"Directory where ACME account and certificates are kept")
+	acmeHTTPAddress = flag.String("web.acme-http-address", ":80",
+		"Address answering the ACME HTTP-01 challenges, must be reachable on port 80 from the internet")
+	acmeDirectoryURL = flag.String("web.acme-directory-url", "",
+		"ACME directory, defaults to Let's Encrypt production (use their staging one while testing)")
+)
+
+// acmeTLSConfig sets up the autocert manager and its challenge listener,
+// renewals happen on their own
+func acmeTLSConfig() *tls.Config {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(deleteEmpty(strings.Split(*acmeDomains, ","))...),
+		Cache:      autocert.DirCache(*acmeCacheDir),
+		Email:      *acmeEmail,
+	}
+	if *acmeDirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: *acmeDirectoryURL}
+	}
+
+	// HTTP-01, anything else on that port goes to https
+	go func() {
+		log.Fatal(http.ListenAndServe(*acmeHTTPAddress, m.HTTPHandler(nil)))
+	}()
+
+	// TLS-ALPN-01 comes for free with this config
+	return m.TLSConfig()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Our own take on goals, no subscription needed
+	activityDailyGoal = flag.Float64("activity.daily-goal", 0,
+		"Meters a tracker should cover per day, enables the goal and streak metrics, 0 is off")
+
+	activityGoalMet = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "activity", "goal_met"),
+		"Whether today's distance reached -activity.daily-goal (1) or not yet (0)",
+		[]string{"tracker"}, nil,
+	)
+
+	activityStreak = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "activity", "streak_days"),
+		"Consecutive days the daily goal was met, today counts once it's met",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// Streaks longer than this are impressive enough
+const activityMaxDays = 400
+
+// activityDays adds up the distance per calendar day of -timezone
+type activityDays struct {
+	sync.Mutex
+	goal float64
+	days map[string]map[string]float64
+}
+
+// newActivityDays is nil without a goal
+func newActivityDays(goal float64) *activityDays {
+	if goal <= 0 {
+		return nil
+	}
+	return &activityDays{goal: goal, days: make(map[string]map[string]float64)}
+}
+
+// add puts a hop on the day it was reported, safe to call on nil
+func (a *activityDays) add(tracker string, t int64, distance float64) {
+	if a == nil {
+		return
+	}
+	a.Lock()
+	defer a.Unlock()
+
+	days := a.days[tracker]
+	if days == nil {
+		days = make(map[string]float64)
+		a.days[tracker] = days
+	}
+	days[localTime(t).Format("2006-01-02")] += distance
+
+	oldest := localNow().AddDate(0, 0, -activityMaxDays).Format("2006-01-02")
+	for day := range days {
+		if day < oldest {
+			delete(days, day)
+		}
+	}
+}
+
+// saved is what goes into -state.path, safe to call on nil
+func (a *activityDays) saved() map[string]map[string]float64 {
+	if a == nil {
+		return nil
+	}
+	a.Lock()
+	defer a.Unlock()
+	saved := make(map[string]map[string]float64)
+	for tracker, days := range a.days {
+		saved[tracker] = make(map[string]float64)
+		for day, meters := range days {
+			saved[tracker][day] = meters
+		}
+	}
+	return saved
+}
+
+// load takes the days back, safe to call on nil
+func (a *activityDays) load(saved map[string]map[string]float64) {
+	if a == nil {
+		return
+	}
+	a.Lock()
+	defer a.Unlock()
+	for tracker, days := range saved {
+		a.days[tracker] = days
+	}
+}
+
+// collect ...
+func (a *activityDays) collect(ch chan<- prometheus.Metric, tracker string) {
+	if a == nil {
+		return
+	}
+	a.Lock()
+	defer a.Unlock()
+
+	days := a.days[tracker]
+	day := localNow()
+	met := days[day.Format("2006-01-02")] >= a.goal
+
+	// today isn't over, the streak is still alive if yesterday was fine
+	streak := 0
+	if !met {
+		day = day.AddDate(0, 0, -1)
+	}
+	for ; streak < activityMaxDays && days[day.Format("2006-01-02")] >= a.goal; day = day.AddDate(0, 0, -1) {
+		streak++
+	}
+
+	var metValue float64
+	if met {
+		metValue = 1
+	}
+	ch <- prometheus.MustNewConstMetric(activityGoalMet, prometheus.GaugeValue, metValue, tracker)
+	ch <- prometheus.MustNewConstMetric(activityStreak, prometheus.GaugeValue, float64(streak), tracker)
+}
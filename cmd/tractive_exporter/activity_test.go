@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The goal counts for today once it's met, the streak runs on from
+// yesterday until then
+func TestActivityStreak(t *testing.T) {
+	day := func(ago int) string {
+		return time.Now().AddDate(0, 0, -ago).Format("2006-01-02")
+	}
+	for _, tc := range []struct {
+		name string
+		days map[string]float64
+		want []string
+	}{
+		{name: "nothing yet", days: nil, want: []string{
+			"tractive_activity_goal_met{tracker=rex} 0", "tractive_activity_streak_days{tracker=rex} 0",
+		}},
+		{name: "today is met", days: map[string]float64{day(0): 5000}, want: []string{
+			"tractive_activity_goal_met{tracker=rex} 1", "tractive_activity_streak_days{tracker=rex} 1",
+		}},
+		{name: "today isn't over", days: map[string]float64{day(0): 100, day(1): 3000, day(2): 3000}, want: []string{
+			"tractive_activity_goal_met{tracker=rex} 0", "tractive_activity_streak_days{tracker=rex} 2",
+		}},
+		{name: "a lazy day ends it", days: map[string]float64{day(0): 3000, day(1): 3000, day(2): 10, day(3): 3000}, want: []string{
+			"tractive_activity_goal_met{tracker=rex} 1", "tractive_activity_streak_days{tracker=rex} 2",
+		}},
+	} {
+		a := newActivityDays(3000)
+		if tc.days != nil {
+			a.days["rex"] = tc.days
+		}
+		ch := make(chan prometheus.Metric, 10)
+		a.collect(ch, "rex")
+		close(ch)
+		var got []string
+		for m := range ch {
+			got = append(got, exposed(t, m))
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// Hops go on the day they were reported, old days are forgotten
+func TestActivityDays(t *testing.T) {
+	a := newActivityDays(3000)
+	now := time.Now()
+	a.add("rex", now.Unix(), 100)
+	a.add("rex", now.Unix(), 50)
+	a.add("rex", now.AddDate(0, 0, -1).Unix(), 70)
+	a.add("rex", now.AddDate(0, 0, -activityMaxDays-2).Unix(), 1000)
+
+	want := map[string]float64{
+		now.Format("2006-01-02"):                   150,
+		now.AddDate(0, 0, -1).Format("2006-01-02"): 70,
+	}
+	if got := a.days["rex"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("days %v, want %v", got, want)
+	}
+
+	var off *activityDays
+	off.add("rex", now.Unix(), 100)
+	if newActivityDays(0) != nil {
+		t.Errorf("no goal, no days")
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Conditions without a new rule type for each of them
+	alertsFile = flag.String("alerts.file", "",
+		"File with one alert per line as name: CEL expression over the tracker state, e.g. too_fast: speed > 10")
+	alertsStaleAfter = flag.Duration("alerts.stale-after", 0,
+		"Built-in alert stale when the last position is older than this, 0 is off")
+	alertsBatteryBelow = flag.Int("alerts.battery-below", 0,
+		"Built-in alert low_battery when the battery percentage is below this, 0 is off")
+
+	alertFiring = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "alert_firing"),
+		"Whether the alert condition holds for the tracker (1) or not (0)",
+		[]string{"tracker", "alert"}, nil,
+	)
+)
+
+// alertRule is a compiled condition
+type alertRule struct {
+	name       string
+	expression string
+	program    cel.Program
+}
+
+// alertRules evaluates the rules and remembers what fires
+type alertRules struct {
+	rules  []alertRule
+	firing map[string]map[string]bool
+}
+
+// alertEvent goes to the hooks when an alert starts or stops firing
+type alertEvent struct {
+	Tracker    string                 `json:"tracker"`
+	Alert      string                 `json:"alert"`
+	Expression string                 `json:"expression"`
+	Status     string                 `json:"status"`
+	Time       int64                  `json:"time"`
+	State      map[string]interface{} `json:"state"`
+}
+
+// alertEnv declares everything trackerState has, and lets age > 3600
+// compare without writing 3600.0
+func alertEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.CrossTypeNumericComparisons(true),
+		cel.Variable("tracker", cel.StringType),
+		cel.Variable("time", cel.IntType),
+		cel.Variable("age", cel.DoubleType),
+		cel.Variable("lat", cel.DoubleType),
+		cel.Variable("lon", cel.DoubleType),
+		cel.Variable("speed", cel.DoubleType),
+		cel.Variable("alt", cel.DoubleType),
+		cel.Variable("live", cel.BoolType),
+		cel.Variable("battery", cel.IntType),
+		cel.Variable("geohash", cel.StringType),
+		cel.Variable("distance", cel.DoubleType),
+		cel.Variable("distance_interval", cel.DoubleType),
+		cel.Variable("zones", cel.ListType(cel.StringType)),
+		cel.Variable("distance_from_home", cel.DoubleType),
+	)
+}
+
+// newAlertRules is nil when there is no file and no built-in alert
+func newAlertRules() (*alertRules, error) {
+	if *alertsFile == "" && *alertsStaleAfter <= 0 && *alertsBatteryBelow <= 0 {
+		return nil, nil
+	}
+	env, err := alertEnv()
+	if err != nil {
+		return nil, err
+	}
+	a := &alertRules{firing: make(map[string]map[string]bool)}
+
+	// the built-in ones are rules like any other
+	if *alertsStaleAfter > 0 {
+		if err := a.add(env, "stale", fmt.Sprintf("age > %d", int64(alertsStaleAfter.Seconds()))); err != nil {
+			return nil, fmt.Errorf("-alerts.stale-after: %v", err)
+		}
+	}
+	if *alertsBatteryBelow > 0 {
+		if err := a.add(env, "low_battery", fmt.Sprintf("battery < %d", *alertsBatteryBelow)); err != nil {
+			return nil, fmt.Errorf("-alerts.battery-below: %v", err)
+		}
+	}
+	if *alertsFile == "" {
+		return a, nil
+	}
+
+	f, err := os.Open(*alertsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		kv := strings.SplitN(text, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%s:%d: want name: expression", *alertsFile, line)
+		}
+		if err := a.add(env, strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", *alertsFile, line, err)
+		}
+	}
+	return a, scanner.Err()
+}
+
+// add compiles a rule, it has to be bool
+func (a *alertRules) add(env *cel.Env, name, expression string) error {
+	rule := alertRule{name: name, expression: expression}
+	ast, issues := env.Compile(rule.expression)
+	if issues != nil && issues.Err() != nil {
+		return issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType {
+		return fmt.Errorf("%s is %s, want bool", rule.name, ast.OutputType())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return err
+	}
+	rule.program = program
+	a.rules = append(a.rules, rule)
+	return nil
+}
+
+// evaluateAlerts runs every rule on the state, exposes the result and tells
+// the hooks about changes
+func (e *Exporter) evaluateAlerts(ch chan<- prometheus.Metric, id string, state map[string]interface{}) {
+	a := e.alerts
+	if a == nil {
+		return
+	}
+	state["tracker"] = id
+
+	if a.firing[id] == nil {
+		a.firing[id] = make(map[string]bool)
+	}
+	for _, rule := range a.rules {
+		out, _, err := rule.program.Eval(state)
+		if err != nil {
+			slog.Warn("Alert failed", "alert", rule.name, "tracker", id, "err", err)
+			continue
+		}
+		firing, _ := out.Value().(bool)
+
+		var value float64
+		if firing {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(alertFiring, prometheus.GaugeValue, value, id, rule.name)
+
+		if firing == a.firing[id][rule.name] {
+			continue
+		}
+		a.firing[id][rule.name] = firing
+
+		event := alertEvent{
+			Tracker:    id,
+			Alert:      rule.name,
+			Expression: rule.expression,
+			Status:     "resolved",
+			Time:       time.Now().Unix(),
+			State:      state,
+		}
+		if firing {
+			event.Status = "firing"
+		}
+		slog.Info("Alert "+event.Status, "alert", rule.name, "tracker", id)
+		e.bus.publish("alert", id, event)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useAlertsFile points -alerts.file at a file with rules until the test
+// is over
+func useAlertsFile(t *testing.T, rules string) {
+	path := filepath.Join(t.TempDir(), "alerts")
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file := *alertsFile
+	*alertsFile = path
+	t.Cleanup(func() { *alertsFile = file })
+}
+
+// What fires for which state, ints and doubles compare either way
+func TestAlertRules(t *testing.T) {
+	useAlertsFile(t, `
+# comments and empty lines are fine
+too_fast: speed > 10
+stale: age > 3600
+flat: battery < 15 && !live
+rex_out: tracker == "rex" && geohash != "u2edk"
+`)
+	a, err := newAlertRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		state  map[string]interface{}
+		firing string
+	}{
+		{"calm", map[string]interface{}{"speed": 1.0, "age": 60.0, "battery": int64(80), "live": false, "tracker": "rex", "geohash": "u2edk"}, ""},
+		{"running", map[string]interface{}{"speed": 12.5, "age": 60.0, "battery": int64(80), "live": true, "tracker": "rex", "geohash": "u2edk"}, "too_fast"},
+		{"old and flat", map[string]interface{}{"speed": 0.0, "age": 7200.0, "battery": int64(10), "live": false, "tracker": "milo", "geohash": "u2edk"}, "stale,flat"},
+		{"live keeps it charged", map[string]interface{}{"speed": 0.0, "age": 5.0, "battery": int64(10), "live": true, "tracker": "milo", "geohash": "u2edk"}, ""},
+		{"rex is out", map[string]interface{}{"speed": 0.0, "age": 5.0, "battery": int64(50), "live": false, "tracker": "rex", "geohash": "u2edm"}, "rex_out"},
+	} {
+		var firing []string
+		for _, rule := range a.rules {
+			out, _, err := rule.program.Eval(tc.state)
+			if err != nil {
+				t.Fatalf("%s: %s: %v", tc.name, rule.name, err)
+			}
+			if out.Value() == true {
+				firing = append(firing, rule.name)
+			}
+		}
+		if got := strings.Join(firing, ","); got != tc.firing {
+			t.Errorf("%s: firing %q, want %q", tc.name, got, tc.firing)
+		}
+	}
+}
+
+// Lines that aren't a rule stop the start, with the line they're on
+func TestAlertRulesReject(t *testing.T) {
+	for _, tc := range []struct {
+		rules string
+		err   string
+	}{
+		{"too_fast speed > 10", ":1: want name: expression"},
+		{"ok: speed > 1\nbroken: speed >", ":2: "},
+		{"number: speed * 2.0", "number is double, want bool"},
+		{"unknown: weight > 10", "undeclared reference to 'weight'"},
+	} {
+		useAlertsFile(t, tc.rules)
+		if _, err := newAlertRules(); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%q: error %v, want one with %q", tc.rules, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// GPS altitude jumps around a lot more than the pet does
+	altitudeMin = flag.Float64("altitude.min", -450,
+		"Reported altitudes below this many meters are implausible and ignored")
+	altitudeMax = flag.Float64("altitude.max", 9000,
+		"Reported altitudes above this many meters are implausible and ignored")
+	altitudeMedianWindow = flag.Int("altitude.median-window", 5,
+		"Number of recent plausible altitudes the exported altitude is the median of, 1 is off")
+	altitudeRaw = flag.Bool("altitude.raw", false,
+		"Also expose the altitude as reported, as tractive_altitude_raw")
+	altitudeGainThreshold = flag.Float64("altitude.gain-threshold", 5,
+		"Meters the filtered altitude has to climb or drop before it counts as gain or loss, smaller changes are noise")
+
+	trackerAltitudeRaw = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "altitude_raw"),
+		"Altitude of the tracker in meters as reported, before plausibility bounds and median filtering",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerAltitudeRejected = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "altitude_rejected_total"),
+		"Reported altitudes outside -altitude.min and -altitude.max that were ignored",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerAltitudeGain = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "altitude_gain_meters_total"),
+		"Meters climbed in steps of at least -altitude.gain-threshold, from the filtered altitude",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerAltitudeLoss = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "altitude_loss_meters_total"),
+		"Meters descended in steps of at least -altitude.gain-threshold, from the filtered altitude",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// altitudeFilter keeps the last plausible altitudes of a tracker
+type altitudeFilter struct {
+	recent   []float64
+	rejected int64
+	created  time.Time
+
+	// where gain and loss were last counted from
+	level      float64
+	leveled    bool
+	gain, loss float64
+}
+
+// updateAltitude is called with every new report of a tracker
+func (e *Exporter) updateAltitude(id string, p *Position) {
+	filter := e.mapOfAltitude[id]
+	if filter.created.IsZero() {
+		filter.created = time.Now()
+	}
+
+	alt := float64(p.Alt)
+	if alt < *altitudeMin || alt > *altitudeMax {
+		filter.rejected++
+		e.mapOfAltitude[id] = filter
+		return
+	}
+	filter.recent = append(filter.recent[:len(filter.recent):len(filter.recent)], alt)
+	if window := *altitudeMedianWindow; window > 0 && len(filter.recent) > window {
+		filter.recent = filter.recent[len(filter.recent)-window:]
+	}
+	e.mapOfAltitude[id] = filter
+
+	// a climb counts once it's more than noise, from where the last one ended
+	level := e.altitude(id, p)
+	switch {
+	case !filter.leveled:
+		filter.level, filter.leveled = level, true
+	case level-filter.level >= *altitudeGainThreshold:
+		filter.gain += level - filter.level
+		filter.level = level
+	case filter.level-level >= *altitudeGainThreshold:
+		filter.loss += filter.level - level
+		filter.level = level
+	}
+	e.mapOfAltitude[id] = filter
+}
+
+// altitude is the median of the recent plausible altitudes, or the reported
+// one until there are any
+func (e *Exporter) altitude(id string, p *Position) float64 {
+	recent := e.mapOfAltitude[id].recent
+	if len(recent) == 0 {
+		return float64(p.Alt)
+	}
+	sorted := append([]float64(nil), recent...)
+	sort.Float64s(sorted)
+	if n := len(sorted); n%2 == 0 {
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return sorted[len(sorted)/2]
+}
+
+// plausibleAltitude is false while nothing plausible came in yet, better
+// no altitude than a silly one
+func (e *Exporter) plausibleAltitude(id string, p *Position) (float64, bool) {
+	if filter, ok := e.mapOfAltitude[id]; ok && len(filter.recent) == 0 {
+		return 0, false
+	}
+	return e.altitude(id, p), true
+}
+
+// collectAltitude ...
+func (e *Exporter) collectAltitude(ch chan<- prometheus.Metric, id string, p *Position) {
+	if alt, ok := e.plausibleAltitude(id, p); ok {
+		ch <- prometheus.MustNewConstMetric(
+			trackerAltitude, prometheus.GaugeValue, alt, id,
+		)
+	}
+	if *altitudeRaw {
+		ch <- prometheus.MustNewConstMetric(
+			trackerAltitudeRaw, prometheus.GaugeValue, float64(p.Alt), id,
+		)
+	}
+	if filter, ok := e.mapOfAltitude[id]; ok {
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+			trackerAltitudeRejected, prometheus.CounterValue, float64(filter.rejected), filter.created, id,
+		)
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+			trackerAltitudeGain, prometheus.CounterValue, filter.gain, filter.created, id,
+		)
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+			trackerAltitudeLoss, prometheus.CounterValue, filter.loss, filter.created, id,
+		)
+	}
+}
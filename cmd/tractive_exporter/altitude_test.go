@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+// altitudes feeds reports to a fresh exporter
+func altitudes(alts ...int) (*Exporter, *Position) {
+	e := NewExporter([]string{"rex"}, make(map[uniqueGeoStates]uniqueGeoStatesValue), make(map[string]geoMemory))
+	var p *Position
+	for _, alt := range alts {
+		p = &Position{}
+		p.Alt = alt
+		e.updateAltitude("rex", p)
+	}
+	return e, p
+}
+
+// Implausible altitudes are dropped, the rest goes through a median
+func TestAltitudeFilter(t *testing.T) {
+	window := *altitudeMedianWindow
+	defer func() { *altitudeMedianWindow = window }()
+
+	for _, tc := range []struct {
+		name      string
+		window    int
+		alts      []int
+		want      float64
+		plausible bool
+		rejected  int64
+	}{
+		{name: "one", window: 5, alts: []int{34}, want: 34, plausible: true},
+		{name: "a spike", window: 5, alts: []int{34, 36, 800, 35, 33}, want: 35, plausible: true},
+		{name: "even", window: 4, alts: []int{30, 40, 36, 34}, want: 35, plausible: true},
+		{name: "window moves on", window: 3, alts: []int{900, 900, 900, 50, 52, 51}, want: 51, plausible: true},
+		{name: "off", window: 1, alts: []int{34, 800}, want: 800, plausible: true},
+		{name: "below the Dead Sea", window: 5, alts: []int{34, -1000}, want: 34, plausible: true, rejected: 1},
+		{name: "above Everest", window: 5, alts: []int{34, 12000, 36}, want: 35, plausible: true, rejected: 1},
+		{name: "nothing plausible yet", window: 5, alts: []int{20000}, plausible: false, rejected: 1},
+	} {
+		*altitudeMedianWindow = tc.window
+		e, p := altitudes(tc.alts...)
+		got, plausible := e.plausibleAltitude("rex", p)
+		if plausible != tc.plausible || got != tc.want {
+			t.Errorf("%s: %g %v, want %g %v", tc.name, got, plausible, tc.want, tc.plausible)
+		}
+		if rejected := e.mapOfAltitude["rex"].rejected; rejected != tc.rejected {
+			t.Errorf("%s: %d rejected, want %d", tc.name, rejected, tc.rejected)
+		}
+	}
+}
+
+// Climbs and descents count from where the last one ended, noise doesn't
+func TestAltitudeGain(t *testing.T) {
+	window, threshold := *altitudeMedianWindow, *altitudeGainThreshold
+	defer func() { *altitudeMedianWindow, *altitudeGainThreshold = window, threshold }()
+	*altitudeMedianWindow, *altitudeGainThreshold = 1, 5
+
+	for _, tc := range []struct {
+		name string
+		alts []int
+		gain float64
+		loss float64
+	}{
+		{name: "noise", alts: []int{100, 103, 98, 101, 104}},
+		{name: "climb", alts: []int{100, 110, 120}, gain: 20},
+		{name: "slow climb", alts: []int{100, 103, 106, 109}, gain: 6},
+		{name: "up and down", alts: []int{100, 130, 100}, gain: 30, loss: 30},
+		{name: "down", alts: []int{300, 250, 240, 238}, loss: 60},
+	} {
+		e, _ := altitudes(tc.alts...)
+		filter := e.mapOfAltitude["rex"]
+		if filter.gain != tc.gain || filter.loss != tc.loss {
+			t.Errorf("%s: gain %g loss %g, want %g and %g", tc.name, filter.gain, filter.loss, tc.gain, tc.loss)
+		}
+	}
+}
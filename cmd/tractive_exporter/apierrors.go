@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+)
+
+// apiError is a decoded Tractive error code
+type apiError struct {
+	category    string
+	explanation string
+}
+
+// Codes we've met in the wild, with what they mean for the user
+var knownAPIErrors = map[int]apiError{
+	3555: {
+		category:    "share_not_found",
+		explanation: "the public share does not exist, it was disabled in the app or the ID is wrong",
+	},
+}
+
+// describeAPIError prefers our own explanation, then whatever the API said
+func describeAPIError(p *Position) apiError {
+	if known, ok := knownAPIErrors[p.Code]; ok {
+		return known
+	}
+
+	unknown := apiError{
+		category:    "unknown",
+		explanation: p.Message,
+	}
+	if p.Category != "" {
+		unknown.category = strings.ToLower(strings.Replace(p.Category, " ", "_", -1))
+	}
+	if unknown.explanation == "" {
+		unknown.explanation = "no explanation from the API"
+	}
+	return unknown
+}
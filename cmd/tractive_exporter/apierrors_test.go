@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+// Known codes get our explanation, the rest whatever the API sent along
+func TestDescribeAPIError(t *testing.T) {
+	for _, tc := range []struct {
+		code        int
+		category    string
+		message     string
+		want        string
+		explanation string
+	}{
+		{3555, "", "", "share_not_found", knownAPIErrors[3555].explanation},
+		{3555, "Something Else", "ignored", "share_not_found", knownAPIErrors[3555].explanation},
+		{4000, "Access Denied", "Token expired", "access_denied", "Token expired"},
+		{4001, "", "Nope", "unknown", "Nope"},
+		{4002, "Bad Request", "", "bad_request", "no explanation from the API"},
+	} {
+		p := &Position{}
+		p.Code, p.Category, p.Message = tc.code, tc.category, tc.message
+		got := describeAPIError(p)
+		if got.category != tc.want || got.explanation != tc.explanation {
+			t.Errorf("%d %q %q: got %+v, want %s %q", tc.code, tc.category, tc.message, got, tc.want, tc.explanation)
+		}
+	}
+}
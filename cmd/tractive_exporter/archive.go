@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Prometheus keeps weeks, the family wants years
+	archivePath = flag.String("archive.path", "",
+		"SQLite file every reported position is kept in, for /api/v1/archive/{tracker}, off when empty")
+	archiveRetention = flag.Duration("archive.retention", 0,
+		"How long positions are kept in -archive.path, 0 is forever")
+
+	archiveWrites = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "archive", "writes_total"),
+		"Number of positions written to -archive.path, by result, dropped when the queue was full",
+		[]string{"result"}, nil,
+	)
+
+	archivePruned = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "archive", "pruned_total"),
+		"Number of positions deleted from -archive.path for being older than -archive.retention",
+		nil, nil,
+	)
+)
+
+// How many positions wait for the disk, and how many go in one transaction
+const (
+	archiveQueueSize = 1000
+	archiveBatchSize = 200
+)
+
+// positionArchive writes the positions in the background, the bus
+// mustn't wait for the disk
+type positionArchive struct {
+	sync.Mutex
+	db      *sql.DB
+	queue   chan archivedPosition
+	closed  chan struct{}
+	results map[string]int64
+	pruned  int64
+	created time.Time
+}
+
+// archivedPosition ...
+type archivedPosition struct {
+	tracker string
+	point   trackPoint
+}
+
+// Set up in main with -archive.path, nil otherwise
+var archive *positionArchive
+
+// newPositionArchive opens or creates the file, nil without -archive.path
+func newPositionArchive() (*positionArchive, error) {
+	if *archivePath == "" {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite3", *archivePath+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS positions (
+		tracker TEXT NOT NULL,
+		time INTEGER NOT NULL,
+		lat REAL NOT NULL,
+		lon REAL NOT NULL,
+		speed REAL NOT NULL,
+		alt INTEGER NOT NULL,
+		PRIMARY KEY (tracker, time)
+	) WITHOUT ROWID`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	a := &positionArchive{
+		db:      db,
+		queue:   make(chan archivedPosition, archiveQueueSize),
+		closed:  make(chan struct{}),
+		results: make(map[string]int64),
+		created: time.Now(),
+	}
+	go a.run()
+	if *archiveRetention > 0 {
+		go a.runPruner()
+	}
+	return a, nil
+}
+
+// add queues a position, safe to call on nil
+func (a *positionArchive) add(tracker string, p *Position) {
+	if a == nil {
+		return
+	}
+	select {
+	case a.queue <- archivedPosition{tracker, trackPoint{Time: p.Time, Lat: p.Lat, Lon: p.Lon, Speed: p.Speed, Alt: p.Alt}}:
+	default:
+		a.Lock()
+		a.results["dropped"]++
+		a.Unlock()
+	}
+}
+
+// run writes what's queued, in batches while a lot comes in
+func (a *positionArchive) run() {
+	defer close(a.closed)
+	for first := range a.queue {
+		batch := []archivedPosition{first}
+	drain:
+		for len(batch) < archiveBatchSize {
+			select {
+			case next, ok := <-a.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+
+		err := a.write(batch)
+		a.Lock()
+		if err != nil {
+			a.results["error"] += int64(len(batch))
+		} else {
+			a.results["ok"] += int64(len(batch))
+		}
+		a.Unlock()
+		if err != nil {
+			slog.Warn("Could not archive positions", "file", *archivePath, "count", len(batch), "err", err)
+		}
+	}
+}
+
+// write ...
+func (a *positionArchive) write(batch []archivedPosition) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO positions (tracker, time, lat, lon, speed, alt) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, position := range batch {
+		p := position.point
+		if _, err := stmt.Exec(position.tracker, p.Time, p.Lat, p.Lon, p.Speed, p.Alt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// runPruner deletes what's past -archive.retention every hour
+func (a *positionArchive) runPruner() {
+	ticks, _ := wakeEvery(time.Hour)
+	for {
+		oldest := time.Now().Add(-*archiveRetention).Unix()
+		result, err := a.db.Exec("DELETE FROM positions WHERE time < ?", oldest)
+		if err != nil {
+			slog.Warn("Could not prune the archive", "file", *archivePath, "err", err)
+		} else if n, err := result.RowsAffected(); err == nil {
+			a.Lock()
+			a.pruned += n
+			a.Unlock()
+		}
+		<-ticks
+	}
+}
+
+// close writes what's still queued, safe to call on nil
+func (a *positionArchive) close(ctx context.Context) {
+	if a == nil {
+		return
+	}
+	close(a.queue)
+	select {
+	case <-a.closed:
+	case <-ctx.Done():
+		slog.Warn("Archive didn't finish its queue at shutdown", "queued", len(a.queue))
+	}
+	a.db.Close()
+}
+
+// points pages through the archive like trackStore.points, oldest first
+func (a *positionArchive) points(ctx context.Context, tracker string, after, to int64, limit int) ([]trackPoint, error) {
+	rows, err := a.db.QueryContext(ctx,
+		"SELECT time, lat, lon, speed, alt FROM positions WHERE tracker = ? AND time > ? AND time <= ? ORDER BY time LIMIT ?",
+		tracker, after, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var points []trackPoint
+	for rows.Next() {
+		var p trackPoint
+		if err := rows.Scan(&p.Time, &p.Lat, &p.Lon, &p.Speed, &p.Alt); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// archiveHandler serves /api/v1/archive/{tracker}, the same as
+// /api/v1/history/{tracker} but from the archive
+func (e *Exporter) archiveHandler(w http.ResponseWriter, r *http.Request) {
+	if archive == nil {
+		http.Error(w, "the archive is off, see -archive.path", http.StatusNotFound)
+		return
+	}
+	id := r.PathValue("tracker")
+	if !e.isConfigured(id) {
+		http.NotFound(w, r)
+		return
+	}
+	q, err := parseHistoryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// one more than asked for says whether there's another page
+	points, err := archive.points(r.Context(), id, q.after, q.to, q.limit+1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page := historyPage{Positions: []map[string]interface{}{}, Units: q.units}
+	if len(points) > q.limit {
+		points = points[:q.limit]
+		page.NextCursor = strconv.FormatInt(points[len(points)-1].Time, 36)
+	}
+	for _, point := range points {
+		if q.units != nil {
+			point.Speed = q.units.speed(point.Speed)
+			point.Alt = int(math.Round(q.units.altitude(float64(point.Alt))))
+		}
+		position := make(map[string]interface{}, len(q.fields))
+		for _, field := range q.fields {
+			position[field] = historyFields[field](point)
+		}
+		page.Positions = append(page.Positions, position)
+	}
+	writeJSON(w, page)
+}
+
+// collect ...
+func (a *positionArchive) collect(ch chan<- prometheus.Metric) {
+	if a == nil {
+		return
+	}
+	a.Lock()
+	defer a.Unlock()
+	for _, result := range []string{"ok", "error", "dropped"} {
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(archiveWrites, prometheus.CounterValue, float64(a.results[result]), a.created, result)
+	}
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(archivePruned, prometheus.CounterValue, float64(a.pruned), a.created)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+
+	// The exporter changes things now, who did what and when
+	auditFile = flag.String("audit.file", "",
+		"Append-only JSON lines file every control and admin action is written to, only kept in memory when empty")
+	auditSize = flag.Int("audit.size", 1000,
+		"How many of the last audit entries /api/v1/audit serves")
+)
+
+// auditEntry is one action, who asked and what came of it
+type auditEntry struct {
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor"`
+	Remote  string    `json:"remote,omitempty"`
+	Action  string    `json:"action"`
+	Tracker string    `json:"tracker,omitempty"`
+	Detail  string    `json:"detail,omitempty"`
+	Result  string    `json:"result"`
+}
+
+// auditLog keeps the last entries for the endpoint and appends every one
+// to -audit.file, nothing is ever changed or removed there
+type auditLog struct {
+	sync.Mutex
+	size    int
+	entries []auditEntry
+	file    *os.File
+}
+
+// Set up in main, before anything can act
+var audit *auditLog
+
+// newAuditLog opens the file and reads back its last entries
+func newAuditLog(path string, size int) (*auditLog, error) {
+	if size < 1 {
+		size = 1
+	}
+	a := &auditLog{size: size}
+	if path == "" {
+		return a, nil
+	}
+
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var entry auditEntry
+			if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+				a.keep(entry)
+			}
+		}
+		f.Close()
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("-audit.file: %v", err)
+	}
+	a.file = f
+	slog.Info("Audit log", "file", path, "entries", len(a.entries))
+	return a, nil
+}
+
+// keep adds to the ring, the caller holds the lock or nobody else has a
+// reference yet
+func (a *auditLog) keep(entry auditEntry) {
+	a.entries = append(a.entries, entry)
+	if len(a.entries) > a.size {
+		a.entries = a.entries[len(a.entries)-a.size:]
+	}
+}
+
+// record writes an entry, safe to call on nil. A write that fails is
+// logged loudly but doesn't stop the action, it already happened.
+func (a *auditLog) record(entry auditEntry) {
+	if a == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	slog.Info("Audit", "actor", entry.Actor, "action", entry.Action, "tracker", entry.Tracker, "result", entry.Result)
+
+	a.Lock()
+	defer a.Unlock()
+	a.keep(entry)
+	if a.file == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err == nil {
+		_, err = a.file.Write(append(line, '\n'))
+	}
+	if err == nil {
+		err = a.file.Sync()
+	}
+	if err != nil {
+		slog.Error("Could not write the audit log", "file", a.file.Name(), "err", err)
+	}
+}
+
+// snapshot ...
+func (a *auditLog) snapshot() []auditEntry {
+	a.Lock()
+	defer a.Unlock()
+	return append([]auditEntry{}, a.entries...)
+}
+
+// auditRequest is an entry for an action asked for over HTTP, the actor is
+// the basic auth user
+func auditRequest(r *http.Request, action, tracker string) auditEntry {
+	actor := "anonymous"
+	if user, _, ok := r.BasicAuth(); ok {
+		actor = user
+	}
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	return auditEntry{Actor: actor, Remote: remote, Action: action, Tracker: tracker}
+}
+
+// auditResult is "ok" or the error
+func auditResult(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+// auditHandler serves /api/v1/audit, newest last, ?since= and ?limit= to
+// page through
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	if audit == nil {
+		http.Error(w, "no audit log", http.StatusNotFound)
+		return
+	}
+	values := r.URL.Query()
+	var since int64
+	if v := values.Get("since"); v != "" {
+		var err error
+		if since, err = historyTime(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 0
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	entries := []auditEntry{}
+	for _, entry := range audit.snapshot() {
+		if entry.Time.Unix() >= since {
+			entries = append(entries, entry)
+		}
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	writeJSON(w, entries)
+}
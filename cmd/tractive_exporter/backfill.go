@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/one1zero1one/tractive_exporter/pkg/tractive"
+)
+
+var (
+
+	// A restart shouldn't cost the walk of the day
+	backfillMaxGap = flag.Duration("backfill.max-gap", 0,
+		"Fill gaps in the reports from Tractive's position history, this far back at most, 0 is off")
+	backfillMinGap = flag.Duration("backfill.min-gap", 15*time.Minute,
+		"Gaps between reports shorter than this aren't filled")
+	backfillPush = flag.Bool("backfill.push", false,
+		"Also send the filled in positions to -push.url with their own timestamps, with -push.mode=remote_write")
+
+	backfilledPositions = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "backfilled_positions_total"),
+		"Number of positions filled in from the position history after a restart or an outage",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// backfillState ...
+type backfillState struct {
+	positions int64
+	created   time.Time
+}
+
+// historyPoint is a position of the history, which comes in segments
+type historyPoint struct {
+	Time    int64      `json:"time"`
+	LatLong [2]float64 `json:"latlong"`
+	Speed   *float64   `json:"speed"`
+	Alt     int        `json:"alt"`
+}
+
+// fetchPositionHistory is what the tracker reported between two unix
+// times, oldest first
+func fetchPositionHistory(ctx context.Context, id string, from, to int64) ([]*Position, error) {
+	query := "/positions?time_from=" + strconv.FormatInt(from, 10) + "&time_to=" + strconv.FormatInt(to, 10) + "&format=json_segments"
+	path := "public_share/" + id + query
+	var status int
+	var body []byte
+	var err error
+	if a := accountOf(id); a != nil {
+		path = "tracker/" + id + query
+		status, body, err = a.api.Get(ctx, path)
+	} else {
+		status, body, err = api.Get(ctx, path, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	capture.add(api.URL(path), status, body)
+	if status >= 500 || status == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("HTTP %d %s", status, http.StatusText(status))
+	}
+
+	var apiErr apiErrorFields
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Code != 0 {
+		return nil, apiCodeError{code: apiErr.Code, message: apiErr.Message}
+	}
+	var segments [][]historyPoint
+	if err := json.Unmarshal(body, &segments); err != nil {
+		apiStats.fail(id, "decode")
+		return nil, err
+	}
+	var positions []*Position
+	for _, segment := range segments {
+		for _, point := range segment {
+			if point.Time <= from || point.Time >= to {
+				continue
+			}
+			p := &Position{Position: tractive.Position{Time: point.Time, Lat: point.LatLong[0], Lon: point.LatLong[1], Alt: point.Alt}}
+			if point.Speed != nil {
+				p.Speed = *point.Speed
+			}
+			positions = append(positions, p)
+		}
+	}
+	return positions, nil
+}
+
+// filledGap is what the position history had before a new report
+type filledGap struct {
+	from      int64
+	positions []*Position
+}
+
+// gapStart is where a gap before the tracker's next report would start, 0
+// when there's nothing to fill. Callers hold the mutex.
+func (e *Exporter) gapStart(id string) int64 {
+	if *backfillMaxGap <= 0 {
+		return 0
+	}
+	if last := e.mapOfPollState[id].lastReport; last != 0 {
+		return last
+	}
+
+	// just started, the state knows when it last moved
+	if moved := e.mapOfTrackerGeoMemory[id].updateTime; !moved.IsZero() {
+		return moved.Unix()
+	}
+	return 0
+}
+
+// fetchGap gets the positions between last and a new report from the
+// position history, nil when the gap is too short to bother. It runs
+// without the mutex, with the other fetches of a poll.
+func fetchGap(id string, last int64, p *Position) *filledGap {
+	if last == 0 || p == nil || p.Code != 0 || p.Time <= last || time.Duration(p.Time-last)*time.Second < *backfillMinGap {
+		return nil
+	}
+	from := max(last, p.Time-int64(backfillMaxGap.Seconds()))
+
+	ctx, cancel := context.WithTimeout(withTracker(context.Background(), id), fetchDeadline())
+	defer cancel()
+	positions, err := fetchPositionHistory(ctx, id, from, p.Time)
+	if err != nil {
+		slog.Warn("Could not fill the gap from the position history", "tracker", id, "since", time.Unix(from, 0), "err", err)
+		return nil
+	}
+	return &filledGap{from: from, positions: positions}
+}
+
+// backfill puts a fetched gap into the track, the odometer and the
+// distance windows. The outputs and alerts only get the new report.
+// Callers hold the mutex.
+func (e *Exporter) backfill(id string, gap *filledGap) {
+	if gap == nil {
+		return
+	}
+	for _, point := range gap.positions {
+		encoded := geohash.Encode(point.Lat, point.Lon)
+		if encoded != e.mapOfTrackerGeoMemory[id].geohash {
+			memory := e.mapOfTrackerGeoMemory[id].move(point.Lat, point.Lon, encoded)
+			if memory.prevGeohash != "" && memory.distance > 0 {
+				memory.odometer += memory.distance
+				e.addHop(id, point.Time, memory.distance)
+			}
+			e.mapOfTrackerGeoMemory[id] = memory
+			stateWAL.move(id, memory)
+		}
+		e.tracks.add(id, point)
+		archive.add(id, point)
+	}
+
+	state := e.mapOfBackfill[id]
+	if state.created.IsZero() {
+		state.created = time.Now()
+	}
+	state.positions += int64(len(gap.positions))
+	e.mapOfBackfill[id] = state
+	slog.Info("Filled a gap from the position history", "tracker", id, "since", time.Unix(gap.from, 0), "positions", len(gap.positions))
+
+	if *backfillPush && len(gap.positions) > 0 {
+		go metricsPusher.backfill(id, gap.positions)
+	}
+}
+
+// backfill sends the filled in positions with their report times
+func (p *pusher) backfill(id string, positions []*Position) {
+	if p == nil {
+		return
+	}
+	var request []byte
+	sample := func(name string, value float64, t int64) {
+		pairs := append([][2]string{{"__name__", name}, {"job", *pushJob}, {exposedLabel("tracker"), id}}, staticPairs()...)
+		request = appendTimeSeries(request, pairs, value, t*1000)
+	}
+	for _, position := range positions {
+		if !*hideCoordinates {
+			sample(exposedName("tractive_latitude", "tractive_latitude_degrees"), position.Lat, position.Time)
+			sample(exposedName("tractive_longitude", "tractive_longitude_degrees"), position.Lon, position.Time)
+		}
+		sample(exposedName("tractive_speed", "tractive_speed_meters_per_second"), position.Speed, position.Time)
+		sample(exposedName("tractive_altitude", "tractive_altitude_meters"), float64(position.Alt), position.Time)
+	}
+	if err := p.remoteWrite(request); err != nil {
+		slog.Warn("Could not push the filled in positions", "tracker", id, "url", redactURL(*pushURL), "err", err)
+	}
+}
+
+// collectBackfill ...
+func (e *Exporter) collectBackfill(ch chan<- prometheus.Metric, id string) {
+	if state, ok := e.mapOfBackfill[id]; ok {
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+			backfilledPositions, prometheus.CounterValue, float64(state.positions), state.created, id,
+		)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Where a gap starts, the last report or else when the state last moved
+func TestGapStart(t *testing.T) {
+	maxGap := *backfillMaxGap
+	defer func() { *backfillMaxGap = maxGap }()
+
+	moved := time.Now().Add(-time.Hour)
+	e := NewExporter([]string{"rex", "milo", "new"}, make(map[uniqueGeoStates]uniqueGeoStatesValue), map[string]geoMemory{
+		"rex":  {updateTime: moved},
+		"milo": {updateTime: moved},
+	})
+	e.mapOfPollState["rex"] = pollState{lastReport: 1700000000}
+
+	for _, tc := range []struct {
+		maxGap  time.Duration
+		tracker string
+		want    int64
+	}{
+		{0, "rex", 0},
+		{time.Hour, "rex", 1700000000},
+		{time.Hour, "milo", moved.Unix()},
+		{time.Hour, "new", 0},
+	} {
+		*backfillMaxGap = tc.maxGap
+		if got := e.gapStart(tc.tracker); got != tc.want {
+			t.Errorf("%s with -backfill.max-gap=%s: %d, want %d", tc.tracker, tc.maxGap, got, tc.want)
+		}
+	}
+}
+
+// Gaps that aren't worth it don't ask Tractive for the history
+func TestFetchGapSkips(t *testing.T) {
+	maxGap, minGap := *backfillMaxGap, *backfillMinGap
+	defer func() { *backfillMaxGap, *backfillMinGap = maxGap, minGap }()
+	*backfillMaxGap, *backfillMinGap = 6*time.Hour, 15*time.Minute
+
+	position := func(at int64, code int) *Position {
+		p := &Position{}
+		p.Time, p.Code = at, code
+		return p
+	}
+	for _, tc := range []struct {
+		name string
+		last int64
+		p    *Position
+	}{
+		{"nothing before", 0, position(1700003600, 0)},
+		{"no answer", 1700000000, nil},
+		{"an error", 1700000000, position(0, 3555)},
+		{"nothing new", 1700000000, position(1700000000, 0)},
+		{"too short", 1700000000, position(1700000600, 0)},
+	} {
+		if gap := fetchGap("rex", tc.last, tc.p); gap != nil {
+			t.Errorf("%s: filled %+v, want nothing", tc.name, gap)
+		}
+	}
+}
+
+// A filled gap walks the odometer and the track, and is counted
+func TestBackfill(t *testing.T) {
+	e := NewExporter([]string{"rex"}, make(map[uniqueGeoStates]uniqueGeoStatesValue), make(map[string]geoMemory))
+	now := time.Now().Unix()
+	var positions []*Position
+	for i, lat := range []float64{52.5200, 52.5210, 52.5220} {
+		p := &Position{}
+		p.Time, p.Lat, p.Lon = now-int64(300*(3-i)), lat, 13.405
+		positions = append(positions, p)
+	}
+
+	e.backfill("rex", nil)
+	if _, ok := e.mapOfBackfill["rex"]; ok {
+		t.Errorf("nothing filled is counted")
+	}
+	e.backfill("rex", &filledGap{from: now - 3600, positions: positions})
+	if n := e.mapOfBackfill["rex"].positions; n != 3 {
+		t.Errorf("%d positions counted, want 3", n)
+	}
+	if points := e.tracks.points("rex", now-3600); len(points) != 3 {
+		t.Errorf("%d points in the track, want 3", len(points))
+	}
+
+	// the first position is where it starts, two hops of about 111m each
+	if odometer := e.mapOfTrackerGeoMemory["rex"].odometer; odometer < 200 || odometer > 250 {
+		t.Errorf("odometer at %.0fm, want about 222m", odometer)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/one1zero1one/tractive_exporter/pkg/collector"
+)
+
+var (
+
+	// How far back battery readings are kept for the trend
+	batteryWindow = flag.Duration("battery.window", 48*time.Hour,
+		"How long battery readings are kept to compute the trend")
+
+	trackerBattery = collector.Battery
+
+	trackerBatteryTimeToEmpty = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "battery_time_to_empty_seconds"),
+		"Predicted seconds until the battery is empty, based on the recent trend",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// batteryReading ...
+type batteryReading struct {
+	Time  int64   `json:"time"`
+	Level float64 `json:"level"`
+}
+
+// batteryTrend is what the API returns for a tracker
+type batteryTrend struct {
+	Tracker        string           `json:"tracker"`
+	Readings       []batteryReading `json:"readings"`
+	SlopePerHour   float64          `json:"slope_per_hour"`
+	PredictedEmpty int64            `json:"predicted_empty,omitempty"`
+	TimeToEmpty    float64          `json:"time_to_empty_seconds,omitempty"`
+}
+
+// batteryHistory keeps the recent battery readings per tracker
+type batteryHistory struct {
+	sync.Mutex
+	window   time.Duration
+	readings map[string][]batteryReading
+}
+
+func newBatteryHistory(window time.Duration) *batteryHistory {
+	return &batteryHistory{
+		window:   window,
+		readings: make(map[string][]batteryReading),
+	}
+}
+
+// add stores a reading and drops the ones that fell out of the window
+func (b *batteryHistory) add(tracker string, t int64, level float64) {
+	b.Lock()
+	defer b.Unlock()
+
+	readings := b.readings[tracker]
+
+	// same report seen again, nothing new
+	if len(readings) > 0 && readings[len(readings)-1].Time == t {
+		return
+	}
+	readings = append(readings, batteryReading{Time: t, Level: level})
+
+	oldest := t - int64(b.window.Seconds())
+	for len(readings) > 0 && readings[0].Time < oldest {
+		readings = readings[1:]
+	}
+	b.readings[tracker] = readings
+}
+
+// trend fits a line through the readings (least squares) and
+// predicts when it crosses zero
+func (b *batteryHistory) trend(tracker string) batteryTrend {
+	b.Lock()
+	readings := append([]batteryReading(nil), b.readings[tracker]...)
+	b.Unlock()
+
+	t := batteryTrend{Tracker: tracker, Readings: readings}
+	if len(readings) < 2 {
+		return t
+	}
+
+	// relative to the first reading, otherwise unix time squared gets silly
+	var sumX, sumY, sumXY, sumXX float64
+	n := float64(len(readings))
+	for _, r := range readings {
+		x := float64(r.Time - readings[0].Time)
+		sumX += x
+		sumY += r.Level
+		sumXY += x * r.Level
+		sumXX += x * x
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return t
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	intercept := (sumY - slope*sumX) / n
+	t.SlopePerHour = slope * 3600
+
+	// only discharging batteries get a prediction
+	if slope < 0 {
+		t.PredictedEmpty = readings[0].Time + int64(-intercept/slope)
+		t.TimeToEmpty = float64(t.PredictedEmpty - time.Now().Unix())
+		if t.TimeToEmpty < 0 {
+			t.TimeToEmpty = 0
+		}
+	}
+	return t
+}
+
+// collect exposes the latest level and the prediction
+func (b *batteryHistory) collect(ch chan<- prometheus.Metric, tracker string) {
+	t := b.trend(tracker)
+	if len(t.Readings) == 0 {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(
+		trackerBattery, prometheus.GaugeValue, t.Readings[len(t.Readings)-1].Level, tracker,
+	)
+	if t.PredictedEmpty != 0 {
+		ch <- prometheus.MustNewConstMetric(
+			trackerBatteryTimeToEmpty, prometheus.GaugeValue, t.TimeToEmpty, tracker,
+		)
+	}
+}
+
+// trackerAPIHandler serves /api/v1/trackers/{id}/...
+func (e *Exporter) trackerAPIHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/trackers/"), "/"), "/")
+	if len(parts) != 2 || !e.isConfigured(parts[0]) {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch parts[1] {
+	case "battery":
+		writeJSON(w, e.batteryHistory.trend(parts[0]))
+	case "track.geojson":
+		if *hideCoordinates {
+			http.Error(w, "coordinates are hidden", http.StatusForbidden)
+			return
+		}
+		e.exportGeoJSON(w, r, parts[0])
+	case "home":
+		if *hideCoordinates {
+			http.Error(w, "coordinates are hidden", http.StatusForbidden)
+			return
+		}
+		homeHandler(w, r, parts[0])
+	case "positions":
+		e.historyHandler(w, r, parts[0])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// isConfigured ...
+func (e *Exporter) isConfigured(tracker string) bool {
+	for _, id := range e.trackers() {
+		if id == tracker {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, i interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(i)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// A straight line through the readings, and when it hits 0
+func TestBatteryTrend(t *testing.T) {
+	now := time.Now().Unix()
+	hour := int64(3600)
+	for _, tc := range []struct {
+		name     string
+		readings [][2]float64
+		slope    float64
+		empty    time.Duration
+		count    int
+	}{
+		{name: "discharging", readings: [][2]float64{{-10, 100}, {-5, 95}, {0, 90}}, slope: -1, empty: 90 * time.Hour, count: 3},
+		{name: "charging", readings: [][2]float64{{-2, 50}, {-1, 70}, {0, 90}}, slope: 20, count: 3},
+		{name: "flat", readings: [][2]float64{{-2, 80}, {0, 80}}, slope: 0, count: 2},
+		{name: "one reading", readings: [][2]float64{{0, 80}}, count: 1},
+		{name: "the same report twice", readings: [][2]float64{{0, 80}, {0, 80}}, count: 1},
+		{name: "older than the window", readings: [][2]float64{{-72, 100}, {-1, 60}, {0, 50}}, slope: -10, empty: 5 * time.Hour, count: 2},
+		{name: "should be empty by now", readings: [][2]float64{{-3, 20}, {-2, 10}}, slope: -10, count: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newBatteryHistory(48 * time.Hour)
+			for _, r := range tc.readings {
+				b.add("abc", now+int64(r[0])*hour, r[1])
+			}
+			trend := b.trend("abc")
+			if len(trend.Readings) != tc.count {
+				t.Errorf("%d readings, want %d", len(trend.Readings), tc.count)
+			}
+			if math.Abs(trend.SlopePerHour-tc.slope) > 1e-9 {
+				t.Errorf("slope %g/h, want %g/h", trend.SlopePerHour, tc.slope)
+			}
+			if got := time.Duration(trend.TimeToEmpty) * time.Second; math.Abs((got - tc.empty).Seconds()) > 5 {
+				t.Errorf("empty in %s, want %s", got, tc.empty)
+			}
+			if tc.slope >= 0 && trend.PredictedEmpty != 0 {
+				t.Errorf("predicted empty at %d without discharging", trend.PredictedEmpty)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/one1zero1one/tractive_exporter/pkg/tractive"
+)
+
+var (
+
+	// Numbers before guesses about cardinality and persistence
+	benchmarkTrackers = flag.Int("benchmark.trackers", 1000,
+		"benchmark: number of synthetic trackers")
+	benchmarkRounds = flag.Int("benchmark.rounds", 20,
+		"benchmark: collections to time, every one has a new position for every tracker")
+)
+
+// benchmarkIDs are the synthetic trackers
+func benchmarkIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("bench%05d", i)
+	}
+	return ids
+}
+
+// syntheticWalk is where a synthetic tracker is
+type syntheticWalk struct {
+	time     int64
+	lat, lon float64
+	alt      float64
+	battery  int
+}
+
+// syntheticTransport answers like the public share API, with every
+// tracker a minute and up to 50m further on every call
+type syntheticTransport struct {
+	sync.Mutex
+	walks map[string]*syntheticWalk
+	rand  *rand.Rand
+}
+
+// newSyntheticTransport ...
+func newSyntheticTransport() *syntheticTransport {
+	return &syntheticTransport{walks: make(map[string]*syntheticWalk), rand: rand.New(rand.NewSource(1))}
+}
+
+// RoundTrip ...
+func (t *syntheticTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("synthetic API doesn't know %s", r.URL.Path)
+	}
+	id, endpoint := parts[len(parts)-2], parts[len(parts)-1]
+
+	var body interface{}
+	switch endpoint {
+	case "info":
+		body = tractive.Info{Name: "Pet " + id, TrackerID: strings.ToUpper(id), OwnerName: "Benchmark"}
+	case "position":
+		t.Lock()
+		walk := t.walks[id]
+		if walk == nil {
+			walk = &syntheticWalk{
+				time:    time.Now().Unix() - int64(*benchmarkRounds)*60,
+				lat:     48.2 + t.rand.Float64()*0.1,
+				lon:     16.3 + t.rand.Float64()*0.1,
+				alt:     200,
+				battery: 100,
+			}
+			t.walks[id] = walk
+		}
+		heading, meters := t.rand.Float64()*2*math.Pi, t.rand.Float64()*50
+		walk.time += 60
+		walk.lat += meters * math.Cos(heading) / 111320
+		walk.lon += meters * math.Sin(heading) / (111320 * math.Cos(walk.lat*math.Pi/180))
+		walk.alt += t.rand.Float64()*4 - 2
+		if t.rand.Intn(20) == 0 && walk.battery > 1 {
+			walk.battery--
+		}
+		body = tractive.Position{
+			Time:    walk.time,
+			Lat:     walk.lat,
+			Lon:     walk.lon,
+			Speed:   meters / 60,
+			Alt:     int(walk.alt),
+			Live:    t.rand.Intn(10) == 0,
+			Battery: walk.battery,
+		}
+		t.Unlock()
+	default:
+		return nil, fmt.Errorf("synthetic API doesn't know %s", r.URL.Path)
+	}
+
+	b, _ := json.Marshal(body)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(b)),
+		Request:    r,
+	}, nil
+}
+
+// setupBenchmark points the client at the synthetic API and returns the
+// synthetic trackers
+func setupBenchmark() []string {
+	client.Transport = statsTransport{next: newSyntheticTransport()}
+	reachTractive = func(context.Context) error { return nil }
+	return benchmarkIDs(*benchmarkTrackers)
+}
+
+// discardSink counts what it gets, to time the queues without a backend
+type discardSink struct {
+	delivered *int64
+}
+
+// deliver ...
+func (s discardSink) deliver(batch []sinkEvent) error {
+	atomic.AddInt64(s.delivered, int64(len(batch)))
+	return nil
+}
+
+// runBenchmark collects -benchmark.rounds times, every tracker due every
+// time, and prints timings, series, memory, state size and how fast a
+// queue takes the positions, then leaves
+func runBenchmark(e *Exporter) {
+	*scrapeCache = 0
+	for _, id := range e.trackers() {
+		e.intervals.trackers[id] = trackerInterval{}
+	}
+
+	var delivered, published int64
+	queue := newSinkQueue("benchmark", discardSink{delivered: &delivered}, 0)
+	e.bus.subscribe("position", func(event busEvent) {
+		body, _ := json.Marshal(event.Payload)
+		published++
+		queue.enqueue(sinkEvent{Event: event.Type, Tracker: event.Tracker, Body: body})
+	})
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	var durations []time.Duration
+	series := 0
+	started := time.Now()
+	for round := 0; round < *benchmarkRounds; round++ {
+		collected := time.Now()
+		families, err := registry.Gather()
+		durations = append(durations, time.Since(collected))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "gather:", err)
+			os.Exit(1)
+		}
+		series = 0
+		for _, family := range families {
+			series += len(family.GetMetric())
+		}
+	}
+	total := time.Since(started)
+
+	// the queue had all the time of the rounds, give it a moment for the rest
+	queue.Lock()
+	dropped := queue.dropped
+	queue.Unlock()
+	for deadline := time.Now().Add(10 * time.Second); atomic.LoadInt64(&delivered)+dropped < published && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	drained := time.Since(started) - total
+
+	var after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	statePath := filepath.Join(os.TempDir(), fmt.Sprintf("tractive-benchmark-%d.json", os.Getpid()))
+	saved := time.Now()
+	stateErr := e.saveState(statePath)
+	saveTime := time.Since(saved)
+	var stateSize int64
+	if info, err := os.Stat(statePath); err == nil {
+		stateSize = info.Size()
+	}
+	os.Remove(statePath)
+	os.Remove(statePath + ".tmp")
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	trackers := len(e.trackers())
+	fmt.Printf("trackers          %d\n", trackers)
+	fmt.Printf("rounds            %d in %v, %.0f positions/s\n", len(durations), total.Round(time.Millisecond),
+		float64(trackers*len(durations))/total.Seconds())
+	fmt.Printf("collect           min %v, median %v, max %v\n",
+		durations[0].Round(time.Microsecond), durations[len(durations)/2].Round(time.Microsecond), durations[len(durations)-1].Round(time.Microsecond))
+	fmt.Printf("series            %d, %.1f per tracker\n", series, float64(series)/float64(trackers))
+	fmt.Printf("heap              %.1f MiB, %.1f KiB per tracker\n",
+		float64(after.HeapAlloc)/(1<<20), (float64(after.HeapAlloc)-float64(before.HeapAlloc))/1024/float64(trackers))
+	fmt.Printf("geohashes         %d tracked\n", len(e.mapOfUniqueGeoStates))
+	positions := 0
+	for _, id := range e.trackers() {
+		positions += len(e.tracks.points(id, 0))
+	}
+	fmt.Printf("history           %d positions\n", positions)
+	if stateErr != nil {
+		fmt.Printf("state             %v\n", stateErr)
+	} else {
+		fmt.Printf("state             %.1f KiB, saved in %v\n", float64(stateSize)/1024, saveTime.Round(time.Microsecond))
+	}
+	fmt.Printf("queue             %d of %d delivered, %d dropped, empty %v after the last round\n",
+		atomic.LoadInt64(&delivered), published, dropped, drained.Round(time.Millisecond))
+	os.Exit(0)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// A 0 from a position nobody trusts anymore reads like a real "no"
+	staleBooleans = flag.String("metrics.stale-booleans", "last",
+		"What tractive_live and tractive_in_zone say while the position is stale: last (the last known value), zero, omit (no series) or unknown (no series, tractive_state_unknown is 1 instead)")
+	staleAfter = flag.Duration("metrics.stale-after", 0,
+		"Age of the last position that makes it stale for tractive_stale and -metrics.stale-booleans, 0 for only while the API fails")
+	staleHidePosition = flag.Bool("metrics.stale-hide-position", false,
+		"Leave out latitude, longitude and distance from home while the position is stale, so the pet doesn't sit frozen on the map")
+
+	trackerStale = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "stale"),
+		"Whether the position is stale (1), from a failed poll or older than -metrics.stale-after",
+		[]string{"tracker"}, nil,
+	)
+
+	stateUnknown = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "state", "unknown"),
+		"Whether a yes/no metric of the tracker is left out because the position is stale (1) or not (0), with -metrics.stale-booleans=unknown",
+		[]string{"tracker", "metric"}, nil,
+	)
+)
+
+// checkStaleBooleans ...
+func checkStaleBooleans() error {
+	switch *staleBooleans {
+	case "last", "zero", "omit", "unknown":
+		return nil
+	}
+	return fmt.Errorf("-metrics.stale-booleans must be last, zero, omit or unknown, not %q", *staleBooleans)
+}
+
+// isStale is a position from a failed poll or older than -metrics.stale-after
+func isStale(failed bool, age int64) bool {
+	return failed || (*staleAfter > 0 && float64(age) > staleAfter.Seconds())
+}
+
+// collectStale ...
+func collectStale(ch chan<- prometheus.Metric, id string, stale bool) {
+	var value float64
+	if stale {
+		value = 1
+	}
+	ch <- prometheus.MustNewConstMetric(trackerStale, prometheus.GaugeValue, value, id)
+}
+
+// booleanValue is what a yes/no metric says, false when it's left out
+func booleanValue(value, stale bool) (float64, bool) {
+	if stale {
+		switch *staleBooleans {
+		case "zero":
+			return 0, true
+		case "omit", "unknown":
+			return 0, false
+		}
+	}
+	if value {
+		return 1, true
+	}
+	return 0, true
+}
+
+// collectUnknown says whether the yes/no metric was left out, only with
+// -metrics.stale-booleans=unknown
+func collectUnknown(ch chan<- prometheus.Metric, id, metric string, stale bool) {
+	if *staleBooleans != "unknown" {
+		return
+	}
+	var unknown float64
+	if stale {
+		unknown = 1
+	}
+	ch <- prometheus.MustNewConstMetric(stateUnknown, prometheus.GaugeValue, unknown, id, metric)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// What a yes/no metric says while the position is stale, per
+// -metrics.stale-booleans
+func TestBooleanValue(t *testing.T) {
+	mode := *staleBooleans
+	defer func() { *staleBooleans = mode }()
+
+	for _, tc := range []struct {
+		mode    string
+		value   bool
+		stale   bool
+		want    float64
+		exposed bool
+	}{
+		{"last", true, false, 1, true},
+		{"last", true, true, 1, true},
+		{"zero", true, false, 1, true},
+		{"zero", true, true, 0, true},
+		{"omit", false, false, 0, true},
+		{"omit", true, true, 0, false},
+		{"unknown", true, true, 0, false},
+	} {
+		*staleBooleans = tc.mode
+		if err := checkStaleBooleans(); err != nil {
+			t.Fatal(err)
+		}
+		got, exposed := booleanValue(tc.value, tc.stale)
+		if got != tc.want || exposed != tc.exposed {
+			t.Errorf("%s %v stale %v: %g %v, want %g %v", tc.mode, tc.value, tc.stale, got, exposed, tc.want, tc.exposed)
+		}
+	}
+
+	*staleBooleans = "maybe"
+	if err := checkStaleBooleans(); err == nil {
+		t.Errorf("-metrics.stale-booleans=maybe went through")
+	}
+}
+
+// Stale is a failed poll, or too old with -metrics.stale-after
+func TestIsStale(t *testing.T) {
+	after := *staleAfter
+	defer func() { *staleAfter = after }()
+
+	for _, tc := range []struct {
+		after  time.Duration
+		failed bool
+		age    int64
+		want   bool
+	}{
+		{0, false, 86400, false},
+		{0, true, 5, true},
+		{time.Hour, false, 3600, false},
+		{time.Hour, false, 3601, true},
+	} {
+		*staleAfter = tc.after
+		if got := isStale(tc.failed, tc.age); got != tc.want {
+			t.Errorf("after %s, failed %v, %ds old: %v, want %v", tc.after, tc.failed, tc.age, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Tractive doesn't publish limits, be a good citizen anyway
+	budgetHourly = flag.Int("tractive.budget-hourly", 0,
+		"API calls allowed per rolling hour, 0 is no budget")
+	budgetDaily = flag.Int("tractive.budget-daily", 0,
+		"API calls allowed per rolling day, 0 is no budget")
+	budgetStretchBelow = flag.Float64("tractive.budget-stretch-below", 0.2,
+		"Stretch poll intervals once less than this share of a budget is left, up to 10 times as long when it's all used")
+
+	apiCallsTotal = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "api", "calls_total"),
+		"Number of calls made to the Tractive API",
+		nil, nil,
+	)
+
+	apiCallsWindow = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "api", "calls"),
+		"Number of calls made to the Tractive API in the rolling window",
+		[]string{"window"}, nil,
+	)
+
+	apiBudgetRemaining = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "api", "budget_remaining"),
+		"API calls left in the rolling window's budget",
+		[]string{"window"}, nil,
+	)
+
+	pollIntervalStretch = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "poll_interval_stretch"),
+		"Factor poll intervals are stretched by to stay within the API budget, 1 is not at all",
+		nil, nil,
+	)
+)
+
+// Most a budget stretches intervals
+const maxStretch = 10
+
+// callBudget remembers when calls were made, for a day
+type callBudget struct {
+	sync.Mutex
+	calls   []time.Time
+	total   int64
+	created time.Time
+}
+
+// Every call to Tractive goes through here, see budgetTransport
+var apiBudget = &callBudget{created: time.Now()}
+
+// budgetTransport counts requests on their way out
+type budgetTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip ...
+func (t budgetTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	apiBudget.add(time.Now())
+	return t.next.RoundTrip(r)
+}
+
+// add ...
+func (b *callBudget) add(t time.Time) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.total++
+	b.calls = append(b.calls, t)
+	oldest := t.Add(-24 * time.Hour)
+	for len(b.calls) > 0 && b.calls[0].Before(oldest) {
+		b.calls = b.calls[1:]
+	}
+}
+
+// since counts calls in the window, the caller holds the lock
+func (b *callBudget) since(t time.Time) int {
+	n := 0
+	for i := len(b.calls) - 1; i >= 0 && !b.calls[i].Before(t); i-- {
+		n++
+	}
+	return n
+}
+
+// budgetWindows pairs the windows with their budgets
+func (b *callBudget) budgetWindows(now time.Time) []struct {
+	name   string
+	calls  int
+	budget int
+} {
+	return []struct {
+		name   string
+		calls  int
+		budget int
+	}{
+		{"1h", b.since(now.Add(-time.Hour)), *budgetHourly},
+		{"24h", b.since(now.Add(-24 * time.Hour)), *budgetDaily},
+	}
+}
+
+// stretch is how much longer intervals get, from the tightest budget
+func (b *callBudget) stretch() float64 {
+	b.Lock()
+	defer b.Unlock()
+
+	factor := 1.0
+	for _, w := range b.budgetWindows(time.Now()) {
+		if w.budget <= 0 {
+			continue
+		}
+		left := float64(w.budget-w.calls) / float64(w.budget)
+		if left >= *budgetStretchBelow {
+			continue
+		}
+		f := maxStretch
+		if left > 0 {
+			f = int(*budgetStretchBelow/left + 0.5)
+		}
+		if float64(f) > factor {
+			factor = float64(f)
+		}
+	}
+	if factor > maxStretch {
+		factor = maxStretch
+	}
+	return factor
+}
+
+// collect ...
+func (b *callBudget) collect(ch chan<- prometheus.Metric) {
+	stretch := b.stretch()
+
+	b.Lock()
+	defer b.Unlock()
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(apiCallsTotal, prometheus.CounterValue, float64(b.total), b.created)
+	for _, w := range b.budgetWindows(time.Now()) {
+		ch <- prometheus.MustNewConstMetric(apiCallsWindow, prometheus.GaugeValue, float64(w.calls), w.name)
+		if w.budget > 0 {
+			ch <- prometheus.MustNewConstMetric(apiBudgetRemaining, prometheus.GaugeValue, float64(w.budget-w.calls), w.name)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(pollIntervalStretch, prometheus.GaugeValue, stretch)
+}
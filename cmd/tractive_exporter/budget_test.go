@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Intervals stretch once the tightest budget runs low, 10 times at most
+func TestBudgetStretch(t *testing.T) {
+	hourly, daily, below := *budgetHourly, *budgetDaily, *budgetStretchBelow
+	defer func() { *budgetHourly, *budgetDaily, *budgetStretchBelow = hourly, daily, below }()
+	*budgetStretchBelow = 0.2
+
+	for _, tc := range []struct {
+		name   string
+		hourly int
+		daily  int
+		recent int
+		older  int
+		want   float64
+	}{
+		{name: "no budget", recent: 1000, want: 1},
+		{name: "plenty left", hourly: 100, recent: 50, want: 1},
+		{name: "a tenth left", hourly: 100, recent: 90, want: 2},
+		{name: "a twentieth left", hourly: 100, recent: 95, want: 4},
+		{name: "all used", hourly: 100, recent: 100, want: 10},
+		{name: "over", hourly: 100, recent: 150, want: 10},
+		{name: "the day is tighter", hourly: 100, daily: 200, recent: 10, older: 180, want: 4},
+		{name: "older calls don't count for the hour", hourly: 100, recent: 10, older: 500, want: 1},
+	} {
+		*budgetHourly, *budgetDaily = tc.hourly, tc.daily
+		b := &callBudget{created: time.Now()}
+		now := time.Now()
+		for i := 0; i < tc.older; i++ {
+			b.add(now.Add(-2 * time.Hour))
+		}
+		for i := 0; i < tc.recent; i++ {
+			b.add(now.Add(-time.Minute))
+		}
+		if got := b.stretch(); got != tc.want {
+			t.Errorf("%s: stretched %g times, want %g", tc.name, got, tc.want)
+		}
+		if b.total != int64(tc.recent+tc.older) {
+			t.Errorf("%s: %d calls in total, want %d", tc.name, b.total, tc.recent+tc.older)
+		}
+	}
+}
+
+// Calls older than a day are forgotten, the total keeps them
+func TestBudgetForgets(t *testing.T) {
+	b := &callBudget{created: time.Now()}
+	now := time.Now()
+	b.add(now.Add(-25 * time.Hour))
+	b.add(now.Add(-23 * time.Hour))
+	b.add(now)
+	if len(b.calls) != 2 || b.total != 3 {
+		t.Errorf("%d calls kept of %d, want 2 of 3", len(b.calls), b.total)
+	}
+}
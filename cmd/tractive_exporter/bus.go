@@ -0,0 +1,35 @@
+package main
+
+// busEvent is something that happened to a tracker: a new position, live
+// tracking starting or stopping, a geofence entered or left, an alert, a
+// pair or a share changing state
+type busEvent struct {
+	Type    string
+	Tracker string
+	Payload interface{}
+}
+
+// eventBus hands events to whoever subscribed to their type, in order of
+// subscription and on the publisher's goroutine. Publishers hold the
+// exporter's mutex, so consumers can touch its state but must not block,
+// the outputs have their own queues for that.
+type eventBus struct {
+	subscribers map[string][]func(busEvent)
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[string][]func(busEvent))}
+}
+
+// subscribe only before polling starts, there's no locking
+func (b *eventBus) subscribe(eventType string, fn func(busEvent)) {
+	b.subscribers[eventType] = append(b.subscribers[eventType], fn)
+}
+
+// publish ...
+func (b *eventBus) publish(eventType, tracker string, payload interface{}) {
+	event := busEvent{Type: eventType, Tracker: tracker, Payload: payload}
+	for _, fn := range b.subscribers[eventType] {
+		fn(event)
+	}
+}
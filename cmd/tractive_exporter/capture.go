@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+
+	// For when Tractive changes the undocumented API under our feet
+	captureSize = flag.Int("debug.capture-responses", 0,
+		"Keep the last N raw API responses at /debug/responses, 0 is off")
+	captureRedact = flag.Bool("debug.capture-redact", true,
+		"Replace coordinates and personal fields in captured responses")
+	captureDir = flag.String("debug.capture-dir", ".",
+		"Where POST /debug/responses/dump writes the captured responses")
+)
+
+// Keys that say where the pet (and so its humans) live or who they are
+var redactedKeys = map[string]bool{
+	"lat": true, "lon": true, "latlong": true, "pos": true,
+	"email": true, "name": true, "first_name": true, "last_name": true,
+	"phone": true, "address": true, "access_token": true, "token": true,
+}
+
+// capturedResponse ...
+type capturedResponse struct {
+	Time   time.Time       `json:"time"`
+	URL    string          `json:"url"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// responseCapture is a ring buffer of the last raw responses
+type responseCapture struct {
+	sync.Mutex
+	size      int
+	responses []capturedResponse
+}
+
+// Nil when capturing is off, set up in main
+var capture *responseCapture
+
+// add keeps a copy of a response, safe to call on nil
+func (c *responseCapture) add(url string, status int, body []byte) {
+	if c == nil {
+		return
+	}
+	if *captureRedact {
+		body = redactJSON(body)
+	}
+	if !json.Valid(body) {
+		body, _ = json.Marshal(string(body))
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.responses = append(c.responses, capturedResponse{Time: time.Now(), URL: url, Status: status, Body: body})
+	if len(c.responses) > c.size {
+		c.responses = c.responses[len(c.responses)-c.size:]
+	}
+}
+
+// snapshot ...
+func (c *responseCapture) snapshot() []capturedResponse {
+	c.Lock()
+	defer c.Unlock()
+	return append([]capturedResponse{}, c.responses...)
+}
+
+// redactJSON masks redactedKeys anywhere in the document, non JSON is left alone
+func redactJSON(body []byte) []byte {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return body
+	}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, inner := range v {
+				if redactedKeys[k] {
+					v[k] = "REDACTED"
+					continue
+				}
+				walk(inner)
+			}
+		case []interface{}:
+			for _, inner := range v {
+				walk(inner)
+			}
+		}
+	}
+	walk(doc)
+	redacted, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+// captureHandler serves /debug/responses
+func captureHandler(w http.ResponseWriter, r *http.Request) {
+	if capture == nil {
+		http.Error(w, "Response capture is off, start with -debug.capture-responses=N", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, capture.snapshot())
+}
+
+// captureDumpHandler writes the buffer to -debug.capture-dir on POST /debug/responses/dump
+func captureDumpHandler(w http.ResponseWriter, r *http.Request) {
+	if capture == nil {
+		http.Error(w, "Response capture is off, start with -debug.capture-responses=N", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST to dump", http.StatusMethodNotAllowed)
+		return
+	}
+
+	b, err := json.MarshalIndent(capture.snapshot(), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	path := filepath.Join(*captureDir, fmt.Sprintf("tractive-responses-%s.json", time.Now().Format("20060102-150405")))
+	err = ioutil.WriteFile(path, b, 0600)
+	entry := auditRequest(r, "capture_dump", "")
+	entry.Detail = path
+	entry.Result = auditResult(err)
+	audit.record(entry)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"path": path})
+}
+
+// newResponseCapture is nil when size is 0
+func newResponseCapture(size int) *responseCapture {
+	if size <= 0 {
+		return nil
+	}
+	return &responseCapture{size: size}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+
+	// Serve over https
+	tlsCertFile = flag.String("web.tls-cert-file", "",
+		"Certificate to serve the web endpoints over TLS, reloaded when it changes")
+	tlsKeyFile = flag.String("web.tls-key-file", "",
+		"Key of the certificate in -web.tls-cert-file")
+	tlsReloadInterval = flag.Duration("web.tls-reload-interval", time.Minute,
+		"How often to check the certificate files for changes, SIGHUP also reloads them")
+)
+
+// certReloader hands out the current certificate and swaps it when
+// the files change, so renewals don't need a restart
+type certReloader struct {
+	sync.RWMutex
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// reload loads the pair from disk
+func (c *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	c.cert = &cert
+	c.modTime = c.lastModified()
+	c.Unlock()
+	return nil
+}
+
+// lastModified is the newest of the two files
+func (c *certReloader) lastModified() time.Time {
+	var newest time.Time
+	for _, f := range []string{c.certFile, c.keyFile} {
+		if info, err := os.Stat(f); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest
+}
+
+// watch reloads on file changes and SIGHUP, keeps the old pair on errors
+func (c *certReloader) watch(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticks, stop := wakeEvery(interval)
+	defer stop()
+
+	for {
+		select {
+		case <-hup:
+		case <-ticks:
+			c.RLock()
+			unchanged := !c.lastModified().After(c.modTime)
+			c.RUnlock()
+			if unchanged {
+				continue
+			}
+		}
+		if err := c.reload(); err != nil {
+			slog.Error("Could not reload TLS certificate, keeping the old one", "err", err)
+			continue
+		}
+		slog.Info("Reloaded TLS certificate", "file", c.certFile)
+	}
+}
+
+// GetCertificate ...
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.cert, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Tractive misbehaving on purpose, to see the retries and the stale
+	// positions do their job
+	chaosSpec = flag.String("chaos", "",
+		"Inject faults into the Tractive API calls, e.g. error=0.1,timeout=0.05,reset=0.05,malformed=0.05,ratelimit=0.05,latency=2s,status=503,seed=1. Never in production.")
+
+	chaosInjected = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "chaos", "injected_total"),
+		"Faults injected into Tractive API calls by -chaos, by fault",
+		[]string{"fault"}, nil,
+	)
+)
+
+// Faults -chaos knows, in the order they're rolled for
+var chaosFaults = []string{"reset", "timeout", "error", "ratelimit", "malformed"}
+
+// chaosConfig is a parsed -chaos, rates are per attempt
+type chaosConfig struct {
+	sync.Mutex
+	rates    map[string]float64
+	latency  time.Duration
+	status   int
+	rand     *rand.Rand
+	injected map[string]int64
+	created  time.Time
+}
+
+// Nil without -chaos, set up in main
+var chaos *chaosConfig
+
+// parseChaos reads -chaos, nil when empty
+func parseChaos(spec string) (*chaosConfig, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	c := &chaosConfig{
+		rates:    make(map[string]float64),
+		status:   http.StatusServiceUnavailable,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		injected: make(map[string]int64),
+		created:  time.Now(),
+	}
+	for key, value := range parseMapping(spec) {
+		var err error
+		switch key {
+		case "latency":
+			c.latency, err = time.ParseDuration(value)
+		case "status":
+			c.status, err = strconv.Atoi(value)
+			if err == nil && (c.status < 400 || c.status > 599) {
+				err = errors.New("want a 4xx or 5xx")
+			}
+		case "seed":
+			var seed int64
+			seed, err = strconv.ParseInt(value, 10, 64)
+			c.rand = rand.New(rand.NewSource(seed))
+		default:
+			known := false
+			for _, fault := range chaosFaults {
+				known = known || fault == key
+			}
+			if !known {
+				return nil, fmt.Errorf("-chaos: unknown fault %s, want one of %s, latency, status or seed", key, strings.Join(chaosFaults, ", "))
+			}
+			c.rates[key], err = strconv.ParseFloat(value, 64)
+			if err == nil && (c.rates[key] < 0 || c.rates[key] > 1) {
+				err = errors.New("want a rate from 0 to 1")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("-chaos: %s=%s: %v", key, value, err)
+		}
+	}
+	slog.Warn("Injecting faults into the Tractive API calls", "chaos", spec)
+	return c, nil
+}
+
+// roll picks this attempt's fault, if any, and its extra latency
+func (c *chaosConfig) roll() (string, time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	var delay time.Duration
+	if c.latency > 0 {
+		delay = time.Duration(c.rand.Int63n(int64(c.latency)))
+		c.injected["latency"]++
+	}
+	for _, fault := range chaosFaults {
+		if c.rand.Float64() < c.rates[fault] {
+			c.injected[fault]++
+			return fault, delay
+		}
+	}
+	return "", delay
+}
+
+// collect ...
+func (c *chaosConfig) collect(ch chan<- prometheus.Metric) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	for _, fault := range append(chaosFaults, "latency") {
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(chaosInjected, prometheus.CounterValue, float64(c.injected[fault]), c.created, fault)
+	}
+}
+
+// chaosTransport sits under the retries, so every attempt rolls again
+type chaosTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip ...
+func (t chaosTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if chaos == nil {
+		return t.next.RoundTrip(r)
+	}
+	fault, delay := chaos.roll()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+	}
+
+	switch fault {
+	case "reset":
+		return nil, errors.New("chaos: connection reset by peer")
+	case "timeout":
+		// hangs like a dead connection until the attempt gives up
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	case "error":
+		return chaosResponse(r, chaos.status, []byte("chaos: "+http.StatusText(chaos.status))), nil
+	case "ratelimit":
+		resp := chaosResponse(r, http.StatusTooManyRequests, []byte("chaos: "+http.StatusText(http.StatusTooManyRequests)))
+		resp.Header.Set("Retry-After", "30")
+		return resp, nil
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil || fault != "malformed" {
+		return resp, err
+	}
+	// what came back, cut off halfway
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(b[:len(b)/2]))
+	resp.ContentLength = int64(len(b) / 2)
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+// chaosResponse ...
+func chaosResponse(r *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		StatusCode:    status,
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// -chaos is fault=rate, latency, status and seed
+func TestParseChaos(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		err  string
+	}{
+		{spec: ""},
+		{spec: "error=0.1,timeout=0.05,reset=0.05,malformed=0.05,ratelimit=0.05,latency=2s,status=502,seed=1"},
+		{spec: "explode=0.1", err: "unknown fault explode"},
+		{spec: "error=2", err: "error=2: want a rate from 0 to 1"},
+		{spec: "error=often", err: "error=often: "},
+		{spec: "status=200", err: "status=200: want a 4xx or 5xx"},
+		{spec: "latency=slow", err: "latency=slow: "},
+	} {
+		c, err := parseChaos(tc.spec)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%q: error %v, want one with %q", tc.spec, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || (c == nil) != (tc.spec == "") {
+			t.Errorf("%q: %v %v", tc.spec, c, err)
+		}
+	}
+}
+
+// Every fault does what it says, what's left goes to Tractive
+func TestChaosTransport(t *testing.T) {
+	previous := chaos
+	defer func() { chaos = previous }()
+
+	for _, tc := range []struct {
+		spec   string
+		status int
+		body   string
+		err    bool
+	}{
+		{spec: "", status: 200, body: `{}`},
+		{spec: "error=1,status=502", status: 502, body: "chaos: Bad Gateway"},
+		{spec: "ratelimit=1", status: 429, body: "chaos: Too Many Requests"},
+		{spec: "reset=1", err: true},
+		{spec: "malformed=1", status: 200, body: `{`},
+		{spec: "error=0,malformed=0", status: 200, body: `{}`},
+	} {
+		var err error
+		if chaos, err = parseChaos(tc.spec); err != nil {
+			t.Fatal(err)
+		}
+		r, _ := http.NewRequest(http.MethodGet, "https://graph.tractive.com/4/public_share/rex", nil)
+		resp, err := chaosTransport{next: answerWith{status: 200}}.RoundTrip(r)
+		if (err != nil) != tc.err {
+			t.Errorf("%q: error %v", tc.spec, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != tc.status || string(body) != tc.body {
+			t.Errorf("%q: HTTP %d %s, want %d %s", tc.spec, resp.StatusCode, body, tc.status, tc.body)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+
+	// Flags and one env var don't scale to a pack of pets
+	configFile = flag.String("config.file", "",
+		"YAML file with exporter settings and per-tracker settings, flags given on the command line win")
+)
+
+// fileConfig is the layout of -config.file
+//
+//	settings:
+//	  tractive.poll-interval: 1m
+//	trackers:
+//	  - id: 6a7235da65
+//	    name: Rex
+//	    home: {lat: 48.2, lon: 16.37}
+//	    geohash_precision: 7
+//	    interval: 5m
+//	zones:
+//	  - name: garden
+//	    polygon: [{lat: 48.2, lon: 16.37}, {lat: 48.21, lon: 16.37}, {lat: 48.21, lon: 16.38}]
+//	  - name: dog park
+//	    center: {lat: 48.19, lon: 16.35}
+//	    radius: 150
+//	groups:
+//	  - name: ben
+//	    email: ben@example.com
+//	    password_env: BEN_TRACTIVE_PASSWORD
+//	labels:
+//	  site: cabin
+type fileConfig struct {
+	Settings map[string]interface{} `yaml:"settings"`
+	Trackers []trackerSettings      `yaml:"trackers"`
+	Zones    []zoneSettings         `yaml:"zones"`
+	Groups   []groupSettings        `yaml:"groups"`
+	Labels   map[string]string      `yaml:"labels"`
+}
+
+// trackerSettings ...
+type trackerSettings struct {
+	ID               string  `yaml:"id"`
+	Name             string  `yaml:"name"`
+	Home             *latLon `yaml:"home"`
+	GeohashPrecision uint    `yaml:"geohash_precision"`
+	Interval         string  `yaml:"interval"`
+	Paused           bool    `yaml:"paused"`
+}
+
+// zoneSettings is a polygon or a center with a radius in meters
+type zoneSettings struct {
+	Name    string   `yaml:"name"`
+	Polygon []latLon `yaml:"polygon"`
+	Center  *latLon  `yaml:"center"`
+	Radius  float64  `yaml:"radius"`
+}
+
+// latLon ...
+type latLon struct {
+	Lat float64 `yaml:"lat"`
+	Lon float64 `yaml:"lon"`
+}
+
+// Per-tracker settings from -config.file by ID, empty without one. A
+// reload replaces it while holding the exporter's mutex.
+var trackerConfig = make(map[string]trackerSettings)
+
+// Zones from -config.file, they go next to the ones from -geofence.file
+var configZones []geofence
+
+// readConfig ...
+func readConfig(path string) (fileConfig, error) {
+	var config fileConfig
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+	return config, nil
+}
+
+// loadConfig reads -config.file, sets every flag that wasn't given on the
+// command line from settings and returns the trackers in file order
+func loadConfig(path string) ([]string, error) {
+	config, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, value := range config.Settings {
+		if flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("%s: unknown setting %s", path, name)
+		}
+		if given[name] {
+			continue
+		}
+		if err := flag.Set(name, fmt.Sprint(value)); err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", path, name, err)
+		}
+	}
+
+	configGroups = config.Groups
+	configLabels = config.Labels
+	var ids []string
+	trackerConfig, configZones, ids, err = config.trackersAndZones(path)
+	return ids, err
+}
+
+// trackersAndZones is the part of the file a reload changes
+func (config fileConfig) trackersAndZones(path string) (map[string]trackerSettings, []geofence, []string, error) {
+	var zones []geofence
+	for _, z := range config.Zones {
+		fence, err := z.geofence()
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: zone %s: %v", path, z.Name, err)
+		}
+		zones = append(zones, fence)
+	}
+
+	trackers := make(map[string]trackerSettings)
+	var ids []string
+	for _, t := range config.Trackers {
+		if t.ID == "" {
+			return nil, nil, nil, fmt.Errorf("%s: tracker without an id", path)
+		}
+		trackers[t.ID] = t
+		ids = append(ids, t.ID)
+	}
+	return trackers, zones, ids, nil
+}
+
+// geofence turns a zone from the config into what the GeoJSON file gives
+func (z zoneSettings) geofence() (geofence, error) {
+	fence := geofence{name: z.Name, source: "config"}
+	switch {
+	case z.Name == "":
+		return fence, fmt.Errorf("needs a name")
+	case z.Center != nil:
+		if z.Radius <= 0 {
+			return fence, fmt.Errorf("center needs a radius in meters")
+		}
+		fence.center = [2]float64{z.Center.Lon, z.Center.Lat}
+		fence.radius = z.Radius
+	case len(z.Polygon) >= 3:
+		ring := make([][2]float64, len(z.Polygon))
+		for i, point := range z.Polygon {
+			ring[i] = [2]float64{point.Lon, point.Lat}
+		}
+		fence.polygons = [][][][2]float64{{ring}}
+	default:
+		return fence, fmt.Errorf("needs a center and radius or a polygon of at least 3 points")
+	}
+	return fence, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Settings become flags, trackers come back in file order with theirs
+func TestLoadConfig(t *testing.T) {
+	interval, config := *minInterval, trackerConfig
+	defer func() { *minInterval, trackerConfig = interval, config }()
+
+	for _, tc := range []struct {
+		name string
+		file string
+		ids  []string
+		err  string
+	}{
+		{name: "trackers", file: `
+settings:
+  tractive.min-interval: 45s
+trackers:
+  - id: milo
+    name: Milo
+  - id: rex
+    name: Rex
+    home: {lat: 48.2, lon: 16.37}
+    geohash_precision: 7
+    interval: 5m
+`, ids: []string{"milo", "rex"}},
+		{name: "unknown setting", file: "settings:\n  tractive.speed: 11\n", err: "unknown setting tractive.speed"},
+		{name: "bad setting", file: "settings:\n  web.scrape-cache: soon\n", err: "web.scrape-cache: "},
+		{name: "no id", file: "trackers:\n  - name: Rex\n", err: "tracker without an id"},
+		{name: "not YAML", file: "trackers: [", err: "config.yml: "},
+	} {
+		path := filepath.Join(t.TempDir(), "config.yml")
+		if err := os.WriteFile(path, []byte(tc.file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		*minInterval, trackerConfig = 30*time.Second, make(map[string]trackerSettings)
+		ids, err := loadConfig(path)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: error %v, want one with %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(ids, tc.ids) {
+			t.Errorf("%s: trackers %v, want %v", tc.name, ids, tc.ids)
+		}
+		rex := trackerConfig["rex"]
+		if *minInterval != 45*time.Second || rex.Name != "Rex" || rex.Home == nil || rex.Home.Lat != 48.2 || rex.GeohashPrecision != 7 || rex.Interval != "5m" {
+			t.Errorf("%s: -tractive.min-interval %s and rex %+v", tc.name, *minInterval, rex)
+		}
+	}
+}
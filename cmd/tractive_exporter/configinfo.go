@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	trackersConfigured = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "trackers_configured"),
+		"Number of trackers the exporter is configured to poll, as seen by this endpoint",
+		nil, nil,
+	)
+
+	trackerConfigInfo = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "tracker_config_info"),
+		"Per-tracker configuration, always 1, to spot drift between instances",
+		[]string{"tracker", "name", "poll_interval", "geohash_precision", "hide_coordinates"}, nil,
+	)
+)
+
+// collectConfigInfo ...
+func (e *Exporter) collectConfigInfo(ch chan<- prometheus.Metric, trackers []string) {
+	ch <- prometheus.MustNewConstMetric(
+		trackersConfigured, prometheus.GaugeValue, float64(len(trackers)),
+	)
+
+	hidden := "false"
+	if *hideCoordinates {
+		hidden = "true"
+	}
+	for _, id := range trackers {
+		ch <- prometheus.MustNewConstMetric(
+			trackerConfigInfo, prometheus.GaugeValue, 1, id, trackerConfig[id].Name, e.baseInterval(id).String(),
+			strconv.FormatUint(uint64(trackerGeohashPrecision(id)), 10), hidden,
+		)
+	}
+	e.collectGeofences(ch)
+}
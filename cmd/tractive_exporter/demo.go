@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/one1zero1one/tractive_exporter/pkg/tractive"
+)
+
+var (
+
+	// Dashboards get built before the dog arrives
+	demoMode = flag.Bool("demo", false,
+		"Make up -demo.trackers pets walking around -demo.center instead of asking Tractive, everything else works as usual")
+	demoTrackers = flag.Int("demo.trackers", 3,
+		"demo: number of made up trackers")
+	demoCenter = flag.String("demo.center", "48.2082,16.3738",
+		"demo: lat,lon the pets walk around")
+	demoRadius = flag.Float64("demo.radius", 800,
+		"demo: meters from -demo.center the pets turn back at")
+)
+
+// Names of the made up pets, the rest go by number
+var demoNames = []string{"Rex", "Milo", "Luna", "Bella", "Nala", "Oscar"}
+
+// How often a made up pet takes a step, and how much of its walk the
+// position history keeps
+const (
+	demoStep    = 10 * time.Second
+	demoHistory = 24 * time.Hour
+)
+
+// demoWalk is where a made up pet is and what it's up to
+type demoWalk struct {
+	syntheticWalk
+	heading float64
+	speed   float64
+	until   int64
+	charge  float64
+	track   []historyPoint
+}
+
+// demoTransport answers like the public share API, with pets that walk
+// and rest in real time
+type demoTransport struct {
+	sync.Mutex
+	lat, lon float64
+	walks    map[string]*demoWalk
+	rand     *rand.Rand
+}
+
+// demoIDs are the made up trackers
+func demoIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("demo%d", i+1)
+	}
+	return ids
+}
+
+// setupDemo points the client at the made up pets and returns them
+func setupDemo() ([]string, error) {
+	var lat, lon float64
+	if _, err := fmt.Sscanf(*demoCenter, "%g,%g", &lat, &lon); err != nil || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return nil, fmt.Errorf("-demo.center must be lat,lon, not %q", *demoCenter)
+	}
+	if *demoTrackers < 1 {
+		return nil, fmt.Errorf("-demo.trackers must be at least 1")
+	}
+	t := &demoTransport{lat: lat, lon: lon, walks: make(map[string]*demoWalk), rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	client.Transport = statsTransport{next: retryTransport{next: rateLimitTransport{next: chaosTransport{next: budgetTransport{next: t}}}}}
+	reachTractive = func(context.Context) error { return nil }
+	ids := demoIDs(*demoTrackers)
+	slog.Warn("Demo mode, the trackers are made up", "trackers", strings.Join(ids, ","), "center", *demoCenter)
+	return ids, nil
+}
+
+// walk catches a pet up with the clock, the first time with the last
+// couple of hours so the history has something in it
+func (t *demoTransport) walk(id string) *demoWalk {
+	now := time.Now().Unix()
+	w := t.walks[id]
+	if w == nil {
+		w = &demoWalk{
+			syntheticWalk: syntheticWalk{
+				time: now - int64((2 * time.Hour).Seconds()),
+				lat:  t.lat + (t.rand.Float64()-0.5)**demoRadius/111320,
+				lon:  t.lon + (t.rand.Float64()-0.5)**demoRadius/(111320*math.Cos(t.lat*math.Pi/180)),
+				alt:  180 + t.rand.Float64()*40,
+			},
+			heading: t.rand.Float64() * 2 * math.Pi,
+			charge:  60 + t.rand.Float64()*40,
+		}
+		t.walks[id] = w
+	}
+
+	step := int64(demoStep.Seconds())
+	for w.time+step <= now {
+		w.time += step
+
+		// rest a few minutes, then walk a few, turning back when too far
+		if w.time >= w.until {
+			if w.speed > 0 {
+				w.speed = 0
+				w.until = w.time + 60 + t.rand.Int63n(600)
+			} else {
+				w.speed = 0.8 + t.rand.Float64()*2
+				w.until = w.time + 120 + t.rand.Int63n(900)
+				w.heading = t.rand.Float64() * 2 * math.Pi
+			}
+		}
+		north := (w.lat - t.lat) * 111320
+		east := (w.lon - t.lon) * 111320 * math.Cos(t.lat*math.Pi/180)
+		if math.Hypot(north, east) > *demoRadius {
+			w.heading = math.Atan2(-east, -north) + (t.rand.Float64()-0.5)*math.Pi/4
+		}
+		w.heading += (t.rand.Float64() - 0.5) * 0.6
+
+		meters := w.speed * demoStep.Seconds()
+		w.lat += meters * math.Cos(w.heading) / 111320
+		w.lon += meters * math.Sin(w.heading) / (111320 * math.Cos(w.lat*math.Pi/180))
+		w.alt = math.Max(0, w.alt+(t.rand.Float64()-0.5)*meters*0.1)
+
+		// a day and a half on a charge, then back to full
+		w.charge -= 100 * demoStep.Seconds() / (36 * time.Hour).Seconds()
+		if w.charge < 5 {
+			w.charge = 100
+		}
+		w.battery = int(w.charge)
+
+		speed := w.speed
+		w.track = append(w.track, historyPoint{Time: w.time, LatLong: [2]float64{w.lat, w.lon}, Speed: &speed, Alt: int(w.alt)})
+	}
+	for len(w.track) > 0 && w.track[0].Time < now-int64(demoHistory.Seconds()) {
+		w.track = w.track[1:]
+	}
+	return w
+}
+
+// RoundTrip ...
+func (t *demoTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("demo API doesn't know %s", r.URL.Path)
+	}
+	id, endpoint := parts[len(parts)-2], parts[len(parts)-1]
+	number, err := strconv.Atoi(strings.TrimPrefix(id, "demo"))
+	if err != nil || !strings.HasPrefix(id, "demo") {
+		body := []byte(`{"code":3555,"category":"PUBLIC SHARE","message":"The public share does not exist.","detail":null}`)
+		return chaosResponse(r, http.StatusOK, body), nil
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	w := t.walk(id)
+
+	var body interface{}
+	switch endpoint {
+	case "info":
+		name := fmt.Sprintf("Pet %d", number)
+		if number >= 1 && number <= len(demoNames) {
+			name = demoNames[number-1]
+		}
+		body = tractive.Info{Name: name, TrackerID: strings.ToUpper(id), OwnerName: "Demo"}
+	case "position":
+		body = tractive.Position{
+			Time:    w.time,
+			Lat:     w.lat,
+			Lon:     w.lon,
+			Speed:   w.speed,
+			Alt:     int(w.alt),
+			Live:    w.speed > 0,
+			Battery: w.battery,
+		}
+	case "positions":
+		from, _ := strconv.ParseInt(r.URL.Query().Get("time_from"), 10, 64)
+		to, _ := strconv.ParseInt(r.URL.Query().Get("time_to"), 10, 64)
+		segment := []historyPoint{}
+		for _, point := range w.track {
+			if point.Time >= from && point.Time <= to {
+				segment = append(segment, point)
+			}
+		}
+		body = [][]historyPoint{segment}
+	default:
+		return nil, fmt.Errorf("demo API doesn't know %s", r.URL.Path)
+	}
+
+	b, _ := json.Marshal(body)
+	return chaosResponse(r, http.StatusOK, b), nil
+}
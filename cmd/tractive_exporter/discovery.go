@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+var (
+
+	// A new collar shows up without a restart
+	discoverInterval = flag.Duration("tractive.discover-interval", time.Hour,
+		"How often an account without a -trackers.list (or a group without trackers) is asked for its trackers again, 0 only at start")
+)
+
+// discovery is an account whose trackers are all exported, whatever it
+// has at the time
+type discovery struct {
+	group    string
+	account  *tractiveAccount
+	trackers map[string]bool
+}
+
+// Accounts listed at start, set up in main and setupGroups
+var discoveries []*discovery
+
+// addDiscovery remembers what an account had at start
+func addDiscovery(group string, a *tractiveAccount, trackers []string) {
+	d := &discovery{group: group, account: a, trackers: make(map[string]bool)}
+	for _, id := range trackers {
+		d.trackers[id] = true
+	}
+	discoveries = append(discoveries, d)
+}
+
+// trackers is the list being exported, replaced as a whole and never
+// changed in place, so callers can range over it without a lock
+func (e *Exporter) trackers() []string {
+	e.shareMutex.RLock()
+	defer e.shareMutex.RUnlock()
+	return e.shareList
+}
+
+// runDiscovery lists the accounts every -tractive.discover-interval
+func (e *Exporter) runDiscovery(every time.Duration) {
+	ticks, _ := wakeEvery(every)
+	for range ticks {
+		e.discover()
+	}
+}
+
+// discover adds the trackers an account got since and drops those it lost.
+// An account that can't be listed keeps what it had.
+func (e *Exporter) discover() {
+	var added, removed []string
+	for _, d := range discoveries {
+		ctx, cancel := context.WithTimeout(context.Background(), fetchDeadline())
+		list, err := d.account.trackers(ctx)
+		cancel()
+		if err != nil {
+			slog.Warn("Could not list the account's trackers", "group", d.group, "err", err)
+			continue
+		}
+
+		now := make(map[string]bool)
+		for _, id := range list {
+			now[id] = true
+			if !d.trackers[id] {
+				added = append(added, id)
+				if d.group != "" {
+					groupsMutex.Lock()
+					trackerGroup[id] = d.group
+					groupsMutex.Unlock()
+				}
+			}
+		}
+		for id := range d.trackers {
+			if !now[id] {
+				removed = append(removed, id)
+			}
+		}
+		d.trackers = now
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+
+	gone := make(map[string]bool)
+	for _, id := range removed {
+		gone[id] = true
+	}
+	var list []string
+	listed := make(map[string]bool)
+	for _, id := range append(append([]string{}, e.trackers()...), added...) {
+		if !gone[id] && !listed[id] {
+			listed[id] = true
+			list = append(list, id)
+		}
+	}
+
+	e.shareMutex.Lock()
+	e.shareList = list
+	e.shareMutex.Unlock()
+	slog.Info("Trackers changed in the account", "added", strings.Join(added, ","), "removed", strings.Join(removed, ","), "count", len(list))
+}
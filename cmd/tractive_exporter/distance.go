@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// GPS wobble while the pet sleeps adds up to a lot of walking
+	distanceMinDisplacement = flag.Float64("distance.min-displacement", 0,
+		"Hops shorter than this many meters are GPS jitter and count as no movement")
+	distanceSmoothingWindow = flag.Int("distance.smoothing-window", 1,
+		"Number of recent positions averaged before measuring distance, 1 is off")
+
+	trackerDistanceRaw = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "distance_raw"),
+		"Distance from the previous reported location in meters before smoothing and minimum displacement, to tune them",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerDistanceSuppressed = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "distance_suppressed_total"),
+		"Hops shorter than the minimum displacement that were not counted as movement",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerOdometer = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "distance_meters_total"),
+		"Distance covered since the exporter started in meters, counted like tractive_distance",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// collectHop exposes the last hop. The first one is from 0,0 and a zero
+// time, there's nothing to say until the second, and so no v2 names either.
+func (m geoMemory) collectHop(ch chan<- prometheus.Metric, id string) {
+	if m.prevGeohash == "" {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(
+		trackerDistance, prometheus.GaugeValue, m.distance, id,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		trackerDistanceInterval, prometheus.GaugeValue, m.age.Seconds(), id,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		trackerDistanceRaw, prometheus.GaugeValue, m.rawDistance, id,
+	)
+
+	// this one was nanoseconds all along, kept as is for old dashboards
+	if *legacyMetrics {
+		ch <- prometheus.MustNewConstMetric(
+			trackerDistanceAge, prometheus.GaugeValue, float64(m.age), id,
+		)
+	}
+}
+
+// move works out the hop to a newly reported position. The smoothed
+// position only becomes the new anchor when it moved far enough, so lots
+// of tiny wobbles can't add up.
+func (m geoMemory) move(lat, lon float64, encoded string) (next geoMemory) {
+	rawLat, rawLon := m.lat, m.lon
+	if n := len(m.recent); n > 0 {
+		rawLat, rawLon = m.recent[n-1][0], m.recent[n-1][1]
+	}
+
+	recent := append(m.recent[:len(m.recent):len(m.recent)], [2]float64{lat, lon})
+	if window := *distanceSmoothingWindow; window > 0 && len(recent) > window {
+		recent = recent[len(recent)-window:]
+	}
+	var smoothLat, smoothLon float64
+	for _, r := range recent {
+		smoothLat += r[0] / float64(len(recent))
+		smoothLon += r[1] / float64(len(recent))
+	}
+
+	next = m
+	if next.created.IsZero() {
+		next.created = time.Now()
+	}
+	next.recent = recent
+	next.geohash = encoded
+	next.rawDistance = Distance(rawLat, rawLon, lat, lon)
+
+	distance := Distance(m.lat, m.lon, smoothLat, smoothLon)
+	if distance < *distanceMinDisplacement {
+		next.distance = 0
+		next.suppressed++
+		return next
+	}
+
+	next.prevLat, next.prevLon, next.prevGeohash = m.lat, m.lon, m.geohash
+	next.lat, next.lon = smoothLat, smoothLon
+	next.distance = distance
+	next.updateTime = time.Now()
+	next.age = time.Since(m.updateTime)
+	return next
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mmcloughlin/geohash"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collected runs collect and returns what it sent, by desc
+func collected(collect func(ch chan<- prometheus.Metric)) map[*prometheus.Desc]int {
+	ch := make(chan prometheus.Metric, 100)
+	collect(ch)
+	close(ch)
+	sent := make(map[*prometheus.Desc]int)
+	for m := range ch {
+		sent[m.Desc()]++
+	}
+	return sent
+}
+
+// The first position is a hop from 0,0, nothing to expose until the second
+func TestCollectHopSkipsTheFirst(t *testing.T) {
+	legacy := *legacyMetrics
+	defer func() { *legacyMetrics = legacy }()
+
+	vienna := [2]float64{48.2082, 16.3738}
+	nearby := [2]float64{48.2092, 16.3748}
+	for _, tc := range []struct {
+		name   string
+		moves  [][2]float64
+		legacy bool
+		want   map[*prometheus.Desc]int
+	}{
+		{"first", [][2]float64{vienna}, true, map[*prometheus.Desc]int{}},
+		{"second", [][2]float64{vienna, nearby}, false, map[*prometheus.Desc]int{
+			trackerDistance: 1, trackerDistanceInterval: 1, trackerDistanceRaw: 1,
+		}},
+		{"second with legacy", [][2]float64{vienna, nearby}, true, map[*prometheus.Desc]int{
+			trackerDistance: 1, trackerDistanceInterval: 1, trackerDistanceRaw: 1, trackerDistanceAge: 1,
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			*legacyMetrics = tc.legacy
+			var m geoMemory
+			for _, move := range tc.moves {
+				m = m.move(move[0], move[1], geohash.Encode(move[0], move[1]))
+			}
+			sent := collected(func(ch chan<- prometheus.Metric) { m.collectHop(ch, "rex") })
+			if len(sent) != len(tc.want) {
+				t.Fatalf("sent %d metrics, want %d", len(sent), len(tc.want))
+			}
+			for desc, n := range tc.want {
+				if sent[desc] != n {
+					t.Errorf("%s sent %d times, want %d", desc, sent[desc], n)
+				}
+			}
+		})
+	}
+}
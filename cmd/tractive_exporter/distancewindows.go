@@ -0,0 +1,165 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	trackerDistanceWindow = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "distance_window_meters"),
+		"Distance covered in the last window in meters, for those without recording rules",
+		[]string{"tracker", "window"}, nil,
+	)
+
+	trackerDistanceToday = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "distance_today_meters"),
+		"Distance covered since midnight in -timezone in meters",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerDistanceWeek = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "distance_week_meters"),
+		"Distance covered since Monday midnight in -timezone in meters",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// Rolling windows we total up, longest last
+var distanceWindows = []struct {
+	name   string
+	length time.Duration
+}{
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+// Hops are kept for a day more than the longest window. The week since
+// Monday midnight is 7d and 1h long at the end of a Sunday the clocks went
+// back on, and -timezone can change between restarts.
+const distanceRetention = 8 * 24 * time.Hour
+
+// hop ...
+type hop struct {
+	time     int64
+	distance float64
+}
+
+// distanceHistory keeps the hops of distanceRetention per tracker
+type distanceHistory struct {
+	sync.Mutex
+	hops map[string][]hop
+}
+
+func newDistanceHistory() *distanceHistory {
+	return &distanceHistory{hops: make(map[string][]hop)}
+}
+
+// add stores a hop and forgets the ones older than distanceRetention
+func (d *distanceHistory) add(tracker string, t int64, distance float64) {
+	d.Lock()
+	defer d.Unlock()
+
+	hops := append(d.hops[tracker], hop{time: t, distance: distance})
+	oldest := time.Now().Add(-distanceRetention).Unix()
+	for len(hops) > 0 && hops[0].time < oldest {
+		hops = hops[1:]
+	}
+	d.hops[tracker] = hops
+}
+
+// addHop puts a hop into the windows and the daily goal, and the WAL.
+// Callers hold the mutex.
+func (e *Exporter) addHop(tracker string, t int64, distance float64) {
+	e.distanceHistory.add(tracker, t, distance)
+	e.activityDays.add(tracker, t, distance)
+	stateWAL.hop(tracker, t, distance)
+}
+
+// collect sums the hops in every window
+func (d *distanceHistory) collect(ch chan<- prometheus.Metric, tracker string) {
+	d.Lock()
+	defer d.Unlock()
+
+	now := time.Now()
+	for _, window := range distanceWindows {
+		total := d.sum(tracker, now.Add(-window.length).Unix())
+		ch <- prometheus.MustNewConstMetric(trackerDistanceWindow, prometheus.GaugeValue, total, tracker, window.name)
+	}
+
+	// calendar days, the week is never longer than distanceRetention
+	today, monday := calendarStarts(localNow())
+	ch <- prometheus.MustNewConstMetric(trackerDistanceToday, prometheus.GaugeValue, d.sum(tracker, today.Unix()), tracker)
+	ch <- prometheus.MustNewConstMetric(trackerDistanceWeek, prometheus.GaugeValue, d.sum(tracker, monday.Unix()), tracker)
+}
+
+// calendarStarts are the last midnight and the last Monday midnight
+// before local, in its zone. time.Date sorts out DST days.
+func calendarStarts(local time.Time) (time.Time, time.Time) {
+	daysSinceMonday := (int(local.Weekday()) + 6) % 7
+	return startOfDay(local.Year(), local.Month(), local.Day(), local.Location()),
+		startOfDay(local.Year(), local.Month(), local.Day()-daysSinceMonday, local.Location())
+}
+
+// startOfDay is the day's first instant. Where the clocks skip midnight
+// time.Date lands on the evening before, the day starts when they jump.
+func startOfDay(year int, month time.Month, day int, location *time.Location) time.Time {
+	start := time.Date(year, month, day, 0, 0, 0, 0, location)
+	if _, _, d := start.Date(); d != time.Date(year, month, day, 12, 0, 0, 0, location).Day() {
+		_, start = start.ZoneBounds()
+	}
+	return start
+}
+
+// sum adds up the hops since a unix time, the caller holds the lock
+func (d *distanceHistory) sum(tracker string, since int64) float64 {
+	var total float64
+	for _, h := range d.hops[tracker] {
+		if h.time >= since {
+			total += h.distance
+		}
+	}
+	return total
+}
+
+// newest is the time of the tracker's last hop, 0 without one
+func (d *distanceHistory) newest(tracker string) int64 {
+	d.Lock()
+	defer d.Unlock()
+	if hops := d.hops[tracker]; len(hops) > 0 {
+		return hops[len(hops)-1].time
+	}
+	return 0
+}
+
+// saved is what goes into -state.path, [time, meters] per hop
+func (d *distanceHistory) saved() map[string][][2]float64 {
+	d.Lock()
+	defer d.Unlock()
+	saved := make(map[string][][2]float64)
+	for tracker, hops := range d.hops {
+		for _, h := range hops {
+			saved[tracker] = append(saved[tracker], [2]float64{float64(h.time), h.distance})
+		}
+	}
+	return saved
+}
+
+// load ...
+func (d *distanceHistory) load(saved map[string][][2]float64) {
+	for tracker, hops := range saved {
+		for _, h := range hops {
+			d.add(tracker, int64(h[0]), h[1])
+		}
+	}
+}
+
+// total is the distance walked since a unix time
+func (d *distanceHistory) total(tracker string, since int64) float64 {
+	d.Lock()
+	defer d.Unlock()
+	return d.sum(tracker, since)
+}
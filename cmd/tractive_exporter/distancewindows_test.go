@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Every window sums the hops in it, the ones older than all of them are
+// forgotten
+func TestDistanceWindows(t *testing.T) {
+	d := newDistanceHistory()
+	now := time.Now()
+	for _, h := range []struct {
+		ago      time.Duration
+		distance float64
+	}{
+		{10 * 24 * time.Hour, 80},
+		{3 * 24 * time.Hour, 40},
+		{5 * time.Hour, 20},
+		{30 * time.Minute, 10},
+	} {
+		d.add("rex", now.Add(-h.ago).Unix(), h.distance)
+	}
+	d.add("milo", now.Unix(), 1000)
+
+	ch := make(chan prometheus.Metric, 10)
+	d.collect(ch, "rex")
+	close(ch)
+	var got []string
+	for m := range ch {
+		if m.Desc() == trackerDistanceWindow {
+			got = append(got, exposed(t, m))
+		}
+	}
+	want := []string{
+		"tractive_distance_window_meters{tracker=rex,window=1h} 10",
+		"tractive_distance_window_meters{tracker=rex,window=24h} 30",
+		"tractive_distance_window_meters{tracker=rex,window=7d} 70",
+	}
+	if strings.Join(got, " | ") != strings.Join(want, " | ") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if n := len(d.hops["rex"]); n != 3 {
+		t.Errorf("%d hops kept, want 3", n)
+	}
+}
+
+// The week since Monday midnight has to be in the hops that are kept, the
+// end of a Sunday the clocks went back on included
+func TestWeekWithinRetention(t *testing.T) {
+	for _, tc := range []struct {
+		zone  string
+		local string
+	}{
+		{"Europe/Vienna", "2026-10-25T23:59:59"},
+		{"America/New_York", "2026-11-01T23:59:59"},
+		{"Australia/Sydney", "2026-04-05T23:59:59"},
+		{"UTC", "2026-10-25T23:59:59"},
+	} {
+		location, err := time.LoadLocation(tc.zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		local, err := time.ParseInLocation("2006-01-02T15:04:05", tc.local, location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, monday := calendarStarts(local)
+		if week := local.Sub(monday); week > distanceRetention {
+			t.Errorf("%s %s: the week is %s, hops are kept for %s", tc.zone, tc.local, week, distanceRetention)
+		}
+	}
+}
+
+// Hops older than the longest window but still in the week count for it
+func TestDistanceHistoryKeepsTheWeek(t *testing.T) {
+	d := newDistanceHistory()
+	now := time.Now()
+	d.add("rex", now.Add(-7*24*time.Hour-30*time.Minute).Unix(), 100)
+	d.add("rex", now.Add(-time.Minute).Unix(), 5)
+	if got := d.total("rex", now.Add(-7*24*time.Hour-time.Hour).Unix()); got != 105 {
+		t.Errorf("total = %g, want 105", got)
+	}
+}
+
+// Midnight and Monday midnight in the zone, on the days the clocks change
+// and around them
+func TestCalendarStarts(t *testing.T) {
+	for _, tc := range []struct {
+		zone   string
+		local  string
+		today  string
+		monday string
+	}{
+		{"Europe/Vienna", "2026-10-16T12:00:00", "2026-10-16T00:00:00+02:00", "2026-10-12T00:00:00+02:00"},
+		{"Europe/Vienna", "2026-10-19T00:00:00", "2026-10-19T00:00:00+02:00", "2026-10-19T00:00:00+02:00"},
+		{"Europe/Vienna", "2026-10-25T01:30:00", "2026-10-25T00:00:00+02:00", "2026-10-19T00:00:00+02:00"},
+		{"Europe/Vienna", "2026-10-25T23:59:59", "2026-10-25T00:00:00+02:00", "2026-10-19T00:00:00+02:00"},
+		{"Europe/Vienna", "2026-10-26T00:30:00", "2026-10-26T00:00:00+01:00", "2026-10-26T00:00:00+01:00"},
+		{"Europe/Vienna", "2026-03-29T12:00:00", "2026-03-29T00:00:00+01:00", "2026-03-23T00:00:00+01:00"},
+		{"Europe/Vienna", "2026-03-30T12:00:00", "2026-03-30T00:00:00+02:00", "2026-03-30T00:00:00+02:00"},
+		{"America/Sao_Paulo", "2018-11-04T12:00:00", "2018-11-04T01:00:00-02:00", "2018-10-29T00:00:00-03:00"},
+		{"UTC", "2026-10-18T23:59:59", "2026-10-18T00:00:00Z", "2026-10-12T00:00:00Z"},
+	} {
+		location, err := time.LoadLocation(tc.zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		local, err := time.ParseInLocation("2006-01-02T15:04:05", tc.local, location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		today, monday := calendarStarts(local)
+		if got := today.Format(time.RFC3339); got != tc.today {
+			t.Errorf("%s %s: today starts %s, want %s", tc.zone, tc.local, got, tc.today)
+		}
+		if got := monday.Format(time.RFC3339); got != tc.monday {
+			t.Errorf("%s %s: the week starts %s, want %s", tc.zone, tc.local, got, tc.monday)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+)
+
+// publishPosition is called once for every position the tracker reports
+func (e *Exporter) publishPosition(id string, p *Position) {
+	e.bus.publish("position", id, positionEvent{
+		Tracker:  id,
+		Geohash:  geohash.Encode(p.Lat, p.Lon),
+		Position: p,
+	})
+}
+
+// subscribe hooks up everything that cares about events, new features
+// subscribe here instead of going into Collect
+func (e *Exporter) subscribe() {
+
+	// metrics state, in the order the metrics expect it
+	e.bus.subscribe("position", func(event busEvent) {
+		p := event.Payload.(positionEvent).Position
+		e.updateZones(event.Tracker, p)
+		e.updateLive(event.Tracker, p)
+		e.updateAltitude(event.Tracker, p)
+		e.updateTrip(event.Tracker, p)
+		e.histograms(event.Tracker).speed.observe(p.Speed)
+	})
+
+	// history
+	e.bus.subscribe("position", func(event busEvent) {
+		p := event.Payload.(positionEvent).Position
+		e.tracks.add(event.Tracker, p)
+		learnedHomes.add(event.Tracker, p)
+		archive.add(event.Tracker, p)
+	})
+
+	// /api/v1/stream
+	e.bus.subscribe("position", func(event busEvent) {
+		if streams.listening() {
+			p := event.Payload.(positionEvent).Position
+			streams.publish(e.latestPosition(event.Tracker, p, time.Now().Unix()))
+		}
+	})
+
+	// shared walks, once the position that ended them is in the track
+	if e.trips != nil {
+		e.bus.subscribe("live_stop", func(event busEvent) {
+			e.trips.finished[event.Tracker] = event.Payload.(liveEvent)
+		})
+		e.bus.subscribe("position", func(event busEvent) {
+			if session, ok := e.trips.finished[event.Tracker]; ok {
+				delete(e.trips.finished, event.Tracker)
+				e.shareTrip(session)
+			}
+		})
+	}
+
+	// cat flaps, told apart by the crossing
+	if e.flaps != nil {
+		e.bus.subscribe("zone", func(event busEvent) {
+			e.crossed(event.Payload.(zoneEvent))
+		})
+	}
+
+	// outputs
+	if e.webhook != nil {
+		e.bus.subscribe("position", func(event busEvent) {
+			e.webhook.send(event.Payload.(positionEvent))
+		})
+	}
+	if e.mqtt != nil {
+		e.bus.subscribe("position", func(event busEvent) {
+			e.mqtt.send(event.Payload.(positionEvent), e.trackerName(event.Tracker))
+		})
+	}
+	if e.influx != nil {
+		e.bus.subscribe("position", func(event busEvent) {
+			e.influx.send(event.Payload.(positionEvent), e.trackerName(event.Tracker))
+		})
+	}
+	if e.execHook != nil {
+		for eventType := range e.execHook.events {
+			e.bus.subscribe(eventType, func(event busEvent) {
+				e.execHook.run(event.Type, event.Tracker, event.Payload, notifications.render(event.Type, event.Payload, e.trackerName))
+			})
+		}
+	}
+	if e.notifier != nil {
+		for eventType := range e.notifier.events {
+			e.bus.subscribe(eventType, func(event busEvent) {
+				e.notifier.send(event, notifications.render(event.Type, event.Payload, e.trackerName), e.trackerName)
+			})
+		}
+	}
+}
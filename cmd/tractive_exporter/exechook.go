@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+var (
+
+	// Escape hatch for shell-script integrations
+	execCommand = flag.String("exec.command", "",
+		"Command run through the shell on events, the event comes as JSON on stdin and as TRACTIVE_* env variables")
+	execEvents = flag.String("exec.events", "position",
+		"Comma separated events that run -exec.command: position, zone, alert, live_start, live_stop, trip, flap, pair, share")
+	execTimeout = flag.Duration("exec.timeout", 30*time.Second,
+		"How long -exec.command may run before it is killed")
+)
+
+// execHook runs a command for some event types
+type execHook struct {
+	events map[string]bool
+	queue  *sinkQueue
+}
+
+// newExecHook is nil when there is no command
+func newExecHook() *execHook {
+	if *execCommand == "" {
+		return nil
+	}
+
+	// commands have side effects, they don't get retried
+	h := &execHook{events: make(map[string]bool), queue: newSinkQueue("exec", execSink{command: *execCommand}, 0)}
+	for _, event := range deleteEmpty(strings.Split(*execEvents, ",")) {
+		h.events[strings.TrimSpace(event)] = true
+	}
+	return h
+}
+
+// run queues the command if it cares about the event, text is for people
+func (h *execHook) run(event, tracker string, payload interface{}, text string) {
+	if h == nil || !h.events[event] {
+		return
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Exec hook marshal error", "err", err)
+		return
+	}
+
+	h.queue.enqueue(sinkEvent{Event: event, Tracker: tracker, Text: text, Body: body})
+}
+
+// execSink runs the command once per event of a batch
+type execSink struct {
+	command string
+}
+
+// deliver ...
+func (s execSink) deliver(batch []sinkEvent) error {
+	for _, event := range batch {
+		if err := s.run(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run ...
+func (s execSink) run(event sinkEvent) error {
+
+	// top level fields as env too, TRACTIVE_LAT=48.2 and friends
+	env := append(os.Environ(), "TRACTIVE_EVENT="+event.Event, "TRACTIVE_TRACKER="+event.Tracker)
+	if event.Text != "" {
+		env = append(env, "TRACTIVE_TEXT="+event.Text)
+	}
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(event.Body))
+	decoder.UseNumber()
+	if decoder.Decode(&fields) == nil {
+		for k, v := range fields {
+			env = append(env, fmt.Sprintf("TRACTIVE_%s=%v", strings.ToUpper(k), v))
+		}
+		if n, ok := fields["time"].(json.Number); ok {
+			if t, err := n.Int64(); err == nil {
+				env = append(env, "TRACTIVE_LOCAL_TIME="+localTime(t).Format(time.RFC3339))
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *execTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", s.command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", s.command)
+	}
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(event.Body)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s event of %s: %v %s", event.Event, event.Tracker, err, out)
+	}
+	return nil
+}
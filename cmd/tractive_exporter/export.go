@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+
+	// Where the color scale tops out
+	exportMaxSpeed = flag.Float64("export.max-speed", 5,
+		"Speed in m/s that gets the hottest color in exported tracks, slower is greener")
+)
+
+// trackSegment is the way between two reported positions, what exports
+// color by pace
+type trackSegment struct {
+	From     trackPoint
+	To       trackPoint
+	Distance float64
+	Duration float64
+	Speed    float64
+}
+
+// trackSegments works out distance, duration and speed between every two
+// positions. Speed comes from the positions, the reported one is often 0.
+func trackSegments(points []trackPoint) []trackSegment {
+	var segments []trackSegment
+	for i := 1; i < len(points); i++ {
+		s := trackSegment{
+			From:     points[i-1],
+			To:       points[i],
+			Distance: Distance(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon),
+			Duration: float64(points[i].Time - points[i-1].Time),
+		}
+		if s.Duration > 0 {
+			s.Speed = s.Distance / s.Duration
+		}
+		segments = append(segments, s)
+	}
+	return segments
+}
+
+// speedColor goes from green (standing) over yellow to red (-export.max-speed and up)
+func speedColor(speed float64) string {
+	ratio := math.Max(0, math.Min(1, speed / *exportMaxSpeed))
+	red, green := 255.0, 255.0
+	if ratio < 0.5 {
+		red = 510 * ratio
+	} else {
+		green = 510 * (1 - ratio)
+	}
+	return fmt.Sprintf("#%02x%02x00", int(red), int(green))
+}
+
+// exportSince reads ?since= as a duration back from now, the whole
+// retention by default
+func exportSince(r *http.Request) (int64, error) {
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(since)
+	if err != nil {
+		return 0, err
+	}
+	return time.Now().Add(-d).Unix(), nil
+}
+
+// exportGeoJSON writes the track as one LineString Feature per segment,
+// with speed and a simplestyle stroke color, so viewers like geojson.io
+// color it by pace
+func (e *Exporter) exportGeoJSON(w http.ResponseWriter, r *http.Request, id string) {
+	since, err := exportSince(r)
+	if err != nil {
+		http.Error(w, "since must be a duration like 24h", http.StatusBadRequest)
+		return
+	}
+
+	units, err := apiUnits(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	features := []interface{}{}
+	for _, s := range trackSegments(e.tracks.points(id, since)) {
+		properties := map[string]interface{}{
+			"tracker":          id,
+			"start":            s.From.Time,
+			"end":              s.To.Time,
+			"distance_meters":  s.Distance,
+			"duration_seconds": s.Duration,
+			"speed":            s.Speed,
+			"stroke":           speedColor(s.Speed),
+			"stroke-width":     3,
+		}
+		if units != nil {
+			properties["distance"] = units.distance(s.Distance)
+			properties["speed"] = units.speed(s.Speed)
+			properties["units"] = units
+		}
+		features = append(features, map[string]interface{}{
+			"type": "Feature",
+			"geometry": map[string]interface{}{
+				"type":        "LineString",
+				"coordinates": [][2]float64{{s.From.Lon, s.From.Lat}, {s.To.Lon, s.To.Lat}},
+			},
+			"properties": properties,
+		})
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(id+".geojson"))
+	writeJSON(w, map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
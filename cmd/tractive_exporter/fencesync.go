@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/one1zero1one/tractive_exporter/pkg/tractive"
+)
+
+var (
+
+	// The fences drawn in the app shouldn't have to be drawn again
+	geofenceTractive = flag.Bool("geofence.tractive", false,
+		"Also use the virtual fences of the account's trackers in the Tractive app as zones, needs the account")
+	geofenceTractiveInterval = flag.Duration("geofence.tractive-interval", time.Hour,
+		"How often the fences are synced from the Tractive app, 0 only at start and on reload")
+)
+
+// tractiveFences are the active fences of every tracker with an account,
+// each fence once however many trackers it's for
+func tractiveFences(trackers []string) ([]geofence, error) {
+	var fences []geofence
+	seen := make(map[string]bool)
+	for _, id := range trackers {
+		a := accountOf(id)
+		if a == nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(withTracker(context.Background(), id), fetchDeadline())
+		list, err := a.api.Geofences(ctx, id)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("fences of %s: %v", id, err)
+		}
+		for _, f := range list {
+			if seen[f.ID] || !f.Active {
+				continue
+			}
+			seen[f.ID] = true
+			fence, err := fenceOf(f)
+			if err != nil {
+				slog.Warn("Skipping a fence from Tractive", "tracker", id, "zone", fence.name, "err", err)
+				continue
+			}
+			fences = append(fences, fence)
+		}
+	}
+	return fences, nil
+}
+
+// fenceOf turns a fence of the app into a zone, named like in the app
+func fenceOf(f tractive.Geofence) (geofence, error) {
+	fence := geofence{name: f.Name, source: "tractive"}
+	if fence.name == "" {
+		fence.name = f.ID
+	}
+
+	// the app has [lat, lon], zones are in GeoJSON order
+	var ring [][2]float64
+	for _, c := range f.Coords {
+		ring = append(ring, [2]float64{c[1], c[0]})
+	}
+	switch {
+	case f.Shape == "CIRCLE" && len(ring) > 0 && f.Radius > 0:
+		fence.center, fence.radius = ring[0], f.Radius
+	case f.Shape == "RECTANGLE" && len(ring) == 2:
+		a, b := ring[0], ring[1]
+		fence.polygons = [][][][2]float64{{{a, {b[0], a[1]}, b, {a[0], b[1]}, a}}}
+	case (f.Shape == "POLYGON" || f.Shape == "RECTANGLE") && len(ring) >= 3:
+		fence.polygons = [][][][2]float64{{ring}}
+	default:
+		return fence, fmt.Errorf("%s with %d points is not supported", f.Shape, len(f.Coords))
+	}
+	return fence, nil
+}
+
+// withTractiveFences adds the synced fences to the zones of the files, a
+// zone there wins over a fence of the same name
+func withTractiveFences(fences, synced []geofence) []geofence {
+	taken := make(map[string]bool)
+	for _, fence := range fences {
+		taken[fence.name] = true
+	}
+	for _, fence := range synced {
+		if taken[fence.name] {
+			slog.Warn("Zone is defined already, skipping the fence from Tractive", "zone", fence.name)
+			continue
+		}
+		taken[fence.name] = true
+		fences = append(fences, fence)
+	}
+	return fences
+}
+
+// syncFences puts the app's fences in place of the last ones, which stay
+// when Tractive can't be asked
+func (e *Exporter) syncFences() {
+	synced, err := tractiveFences(e.trackers())
+	if err != nil {
+		slog.Warn("Could not sync the fences from Tractive, keeping the last ones", "err", err)
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.tractiveFences = synced
+	var own []geofence
+	for _, fence := range e.geofences {
+		if fence.source != "tractive" {
+			own = append(own, fence)
+		}
+	}
+	e.geofences = withTractiveFences(own, synced)
+	slog.Info("Synced the fences from Tractive", "count", len(synced))
+}
+
+// runFenceSync syncs every -geofence.tractive-interval
+func (e *Exporter) runFenceSync(every time.Duration) {
+	ticks, _ := wakeEvery(every)
+	for range ticks {
+		e.syncFences()
+	}
+}
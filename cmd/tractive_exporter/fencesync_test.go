@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/one1zero1one/tractive_exporter/pkg/tractive"
+)
+
+// The app's shapes become zones, points given as [lat, lon]
+func TestFenceOf(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		fence   tractive.Geofence
+		inside  [][2]float64
+		outside [][2]float64
+		err     string
+	}{
+		{
+			name:    "circle",
+			fence:   tractive.Geofence{Name: "Home", Shape: "CIRCLE", Coords: [][2]float64{{52.52, 13.405}}, Radius: 100},
+			inside:  [][2]float64{{52.52, 13.405}, {52.5205, 13.405}},
+			outside: [][2]float64{{52.522, 13.405}},
+		},
+		{
+			name:    "rectangle by its corners",
+			fence:   tractive.Geofence{ID: "f2", Shape: "RECTANGLE", Coords: [][2]float64{{52.50, 13.40}, {52.51, 13.42}}},
+			inside:  [][2]float64{{52.505, 13.41}},
+			outside: [][2]float64{{52.515, 13.41}, {52.505, 13.43}},
+		},
+		{
+			name:    "polygon",
+			fence:   tractive.Geofence{Name: "Park", Shape: "POLYGON", Coords: [][2]float64{{52.50, 13.40}, {52.50, 13.42}, {52.52, 13.41}}},
+			inside:  [][2]float64{{52.505, 13.41}},
+			outside: [][2]float64{{52.515, 13.402}},
+		},
+		{name: "circle without radius", fence: tractive.Geofence{Shape: "CIRCLE", Coords: [][2]float64{{52.52, 13.405}}}, err: "CIRCLE with 1 points"},
+		{name: "polygon of two", fence: tractive.Geofence{Shape: "POLYGON", Coords: [][2]float64{{52.5, 13.4}, {52.6, 13.5}}}, err: "POLYGON with 2 points"},
+		{name: "unknown", fence: tractive.Geofence{Shape: "ELLIPSE"}, err: "ELLIPSE with 0 points"},
+	} {
+		fence, err := fenceOf(tc.fence)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: error %v, want one with %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if fence.source != "tractive" || (fence.name != tc.fence.Name && fence.name != tc.fence.ID) {
+			t.Errorf("%s: named %q from %q", tc.name, fence.name, fence.source)
+		}
+		for _, p := range tc.inside {
+			if !fence.contains(p[0], p[1]) {
+				t.Errorf("%s: %v should be inside", tc.name, p)
+			}
+		}
+		for _, p := range tc.outside {
+			if fence.contains(p[0], p[1]) {
+				t.Errorf("%s: %v should be outside", tc.name, p)
+			}
+		}
+	}
+}
+
+// A zone of the file or the config keeps its name, the app's fence with
+// the same name is left out
+func TestWithTractiveFences(t *testing.T) {
+	fences := []geofence{{name: "Home", source: "file"}}
+	synced := []geofence{{name: "Home", source: "tractive"}, {name: "Park", source: "tractive"}, {name: "Park", source: "tractive"}}
+
+	var got []string
+	for _, fence := range withTractiveFences(fences, synced) {
+		got = append(got, fence.name+"/"+fence.source)
+	}
+	if want := "Home/file Park/tractive"; strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"sync"
+	"time"
+)
+
+var (
+
+	// A dozen shares one after the other make for a slow scrape
+	tractiveConcurrency = flag.Int("tractive.concurrency", 4,
+		"How many trackers are fetched at the same time")
+	tractiveTimeout = flag.Duration("tractive.timeout", 10*time.Second,
+		"Timeout of a single attempt of a call to the Tractive API")
+)
+
+// fetchParallel runs fetch for every tracker, at most -tractive.concurrency
+// at a time, each with its own deadline that starts after the wait
+func fetchParallel(trackers []string, wait func() time.Duration, fetch func(ctx context.Context, id string)) {
+	workers := *tractiveConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	slots := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for _, id := range trackers {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if wait != nil {
+				time.Sleep(wait())
+			}
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), fetchDeadline())
+			defer cancel()
+			fetch(withTracker(ctx, id), id)
+		}(id)
+	}
+	wg.Wait()
+}
+
+// pollFetch is what a poll got from Tractive, fetched without the mutex so
+// the background poller doesn't hold up scrapes while it waits
+type pollFetch struct {
+	started   time.Time
+	reachable error
+	positions map[string]*Position
+	gaps      map[string]*filledGap
+	infos     map[string]fetchedInfo
+}
+
+// dueFetches are the trackers whose position is too old, and those whose
+// info is, with where a gap before their next report would start.
+// Callers hold the mutex.
+func (e *Exporter) dueFetches(trackers []string) (positions, infos []string, gaps map[string]int64) {
+	var polled []string
+	gaps = make(map[string]int64)
+	for _, id := range trackers {
+		if pauses.paused(id) {
+			continue
+		}
+		polled = append(polled, id)
+		poll := e.mapOfPollState[id]
+		if poll.lastPosition == nil || time.Since(poll.lastHit) >= e.dueInterval(id)-dueSlack() {
+			positions = append(positions, id)
+			gaps[id] = e.gapStart(id)
+		}
+	}
+	return positions, e.dueInfos(polled), gaps
+}
+
+// fetchDue fetches the positions, the gaps before new reports and the
+// infos, nothing when the API doesn't answer at all
+func fetchDue(positions, infos []string, gaps map[string]int64) pollFetch {
+	f := pollFetch{started: time.Now()}
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	if f.reachable = reachTractive(ctx); f.reachable != nil {
+		return f
+	}
+
+	// the due ones in parallel
+	var mutex sync.Mutex
+	f.positions = make(map[string]*Position)
+	f.gaps = make(map[string]*filledGap)
+	fetchParallel(positions, jitter, func(ctx context.Context, id string) {
+		p := fetchPosition(ctx, id)
+		gap := fetchGap(id, gaps[id], p)
+		mutex.Lock()
+		f.positions[id] = p
+		if gap != nil {
+			f.gaps[id] = gap
+		}
+		mutex.Unlock()
+	})
+	f.infos = fetchInfos(infos)
+	return f
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+
+	// Not everybody wants coordinates in their TSDB
+	metricsDisable = flag.String("metrics.disable", "",
+		"Comma separated metric names not to expose, e.g. tractive_latitude,tractive_longitude,tractive_geohash_total")
+	metricsEnable = flag.String("metrics.enable", "",
+		"Comma separated tractive_ metric names to expose, all others are dropped, empty is all of them")
+)
+
+// filteringGatherer drops metric families on the way out
+type filteringGatherer struct {
+	gatherer prometheus.Gatherer
+	disabled map[string]bool
+	enabled  map[string]bool
+}
+
+// Gather ...
+func (g filteringGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	kept := families[:0]
+	for _, family := range families {
+		name := family.GetName()
+		if g.disabled[name] {
+			continue
+		}
+		if len(g.enabled) > 0 && strings.HasPrefix(name, "tractive_") && !g.enabled[name] {
+			continue
+		}
+		kept = append(kept, family)
+	}
+	return kept, err
+}
+
+// newFilteringGatherer only wraps when there is something to drop
+func newFilteringGatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	disabled := nameSet(*metricsDisable)
+	enabled := nameSet(*metricsEnable)
+	if len(disabled) == 0 && len(enabled) == 0 {
+		return gatherer
+	}
+	return filteringGatherer{gatherer: gatherer, disabled: disabled, enabled: enabled}
+}
+
+// nameSet turns "a,b" into a set
+func nameSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, name := range deleteEmpty(strings.Split(s, ",")) {
+		set[strings.TrimSpace(name)] = true
+	}
+	return set
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// -metrics.disable drops by name, -metrics.enable keeps only its tractive_
+// metrics and leaves the others alone
+func TestFilteringGatherer(t *testing.T) {
+	disable, enable := *metricsDisable, *metricsEnable
+	defer func() { *metricsDisable, *metricsEnable = disable, enable }()
+
+	registry := prometheus.NewRegistry()
+	for _, name := range []string{"go_goroutines", "tractive_battery_level", "tractive_latitude", "tractive_longitude"} {
+		registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: name}))
+	}
+
+	for _, tc := range []struct {
+		disable string
+		enable  string
+		want    string
+	}{
+		{want: "go_goroutines tractive_battery_level tractive_latitude tractive_longitude"},
+		{disable: "tractive_latitude, tractive_longitude", want: "go_goroutines tractive_battery_level"},
+		{enable: "tractive_battery_level", want: "go_goroutines tractive_battery_level"},
+		{disable: "go_goroutines,tractive_battery_level", enable: "tractive_battery_level,tractive_latitude", want: "tractive_latitude"},
+	} {
+		*metricsDisable, *metricsEnable = tc.disable, tc.enable
+		families, err := newFilteringGatherer(registry).Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, family := range families {
+			got = append(got, family.GetName())
+		}
+		if strings.Join(got, " ") != tc.want {
+			t.Errorf("disable %q enable %q: got %v, want %s", tc.disable, tc.enable, got, tc.want)
+		}
+	}
+}
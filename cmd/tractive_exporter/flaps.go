@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// The cat flap knows the moment, the GPS knows who
+	sensorFlaps = flag.String("sensors.flaps", "",
+		"Cat flaps and doors that POST to /api/v1/sensors/<sensor>, each with the geofence it opens into, e.g. catflap=home,backdoor=garden")
+	sensorWindow = flag.Duration("sensors.window", 15*time.Minute,
+		"How far apart a flap opening and a tracker crossing its geofence may be to count as a way through the flap")
+
+	sensorEvents = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "sensor", "events_total"),
+		"Number of openings a sensor reported",
+		[]string{"sensor"}, nil,
+	)
+
+	sensorLastEvent = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "sensor", "last_event_timestamp_seconds"),
+		"Time of the last opening a sensor reported in seconds since epoch",
+		[]string{"sensor"}, nil,
+	)
+
+	flapPassages = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "flap", "passages_total"),
+		"Number of times the tracker went out (exit) or came in (entry) through the flap",
+		[]string{"tracker", "sensor", "direction"}, nil,
+	)
+)
+
+// flapEvent goes to the hooks when an opening and a geofence crossing
+// go together
+type flapEvent struct {
+	Tracker string `json:"tracker"`
+	Sensor  string `json:"sensor"`
+	Zone    string `json:"zone"`
+	Status  string `json:"status"`
+	Time    int64  `json:"time"`
+}
+
+// flapSensor is a flap and what it has seen
+type flapSensor struct {
+	zone      string
+	events    int64
+	lastEvent int64
+	created   time.Time
+
+	// openings nothing crossed for yet
+	openings []int64
+}
+
+// flapCorrelator pairs openings with geofence crossings, whichever comes
+// first. It's used under the exporter's mutex.
+type flapCorrelator struct {
+	sensors  map[string]*flapSensor
+	window   int64
+	passages map[[3]string]int64
+	created  time.Time
+
+	// crossings no opening came for yet
+	crossings []zoneEvent
+}
+
+// newFlapCorrelator is nil without -sensors.flaps
+func newFlapCorrelator() (*flapCorrelator, error) {
+	mapping := parseMapping(*sensorFlaps)
+	if len(mapping) == 0 {
+		return nil, nil
+	}
+	f := &flapCorrelator{
+		sensors:  make(map[string]*flapSensor),
+		window:   int64(sensorWindow.Seconds()),
+		passages: make(map[[3]string]int64),
+		created:  time.Now(),
+	}
+	for sensor, zone := range mapping {
+		if sensor == "" || zone == "" {
+			return nil, fmt.Errorf("-sensors.flaps wants sensor=geofence, not %q", *sensorFlaps)
+		}
+		f.sensors[sensor] = &flapSensor{zone: zone, created: time.Now()}
+		slog.Info("Flap sensor", "sensor", sensor, "zone", zone)
+	}
+	return f, nil
+}
+
+// prune forgets what is too old to be paired
+func (f *flapCorrelator) prune(now int64) {
+	for _, s := range f.sensors {
+		kept := s.openings[:0]
+		for _, t := range s.openings {
+			if now-t <= f.window {
+				kept = append(kept, t)
+			}
+		}
+		s.openings = kept
+	}
+	kept := f.crossings[:0]
+	for _, c := range f.crossings {
+		if now-c.Time <= f.window {
+			kept = append(kept, c)
+		}
+	}
+	f.crossings = kept
+}
+
+// pass counts a way through the flap and tells the hooks
+func (e *Exporter) pass(sensor string, crossing zoneEvent, opened int64) {
+	direction := "entry"
+	if crossing.Status == "left" {
+		direction = "exit"
+	}
+	e.flaps.passages[[3]string{crossing.Tracker, sensor, direction}]++
+	slog.Info("Through the flap", "tracker", crossing.Tracker, "sensor", sensor, "direction", direction)
+	e.bus.publish("flap", crossing.Tracker, flapEvent{
+		Tracker: crossing.Tracker, Sensor: sensor, Zone: crossing.Zone, Status: direction, Time: opened,
+	})
+}
+
+// opened is a sensor's opening, paired with the closest crossing of its
+// geofence already seen or kept for the next one. Callers hold the mutex.
+func (e *Exporter) opened(sensor string, at int64) {
+	f := e.flaps
+	s := f.sensors[sensor]
+	s.events++
+	s.lastEvent = max(s.lastEvent, at)
+	f.prune(time.Now().Unix())
+
+	best := -1
+	for i, c := range f.crossings {
+		if c.Zone != s.zone || abs64(c.Time-at) > f.window {
+			continue
+		}
+		if best < 0 || abs64(c.Time-at) < abs64(f.crossings[best].Time-at) {
+			best = i
+		}
+	}
+	if best < 0 {
+		s.openings = append(s.openings, at)
+		return
+	}
+	crossing := f.crossings[best]
+	f.crossings = append(f.crossings[:best], f.crossings[best+1:]...)
+	e.pass(sensor, crossing, at)
+}
+
+// crossed is a tracker entering or leaving a geofence, paired with the
+// closest opening of a flap into it. Callers hold the mutex.
+func (e *Exporter) crossed(crossing zoneEvent) {
+	f := e.flaps
+	f.prune(time.Now().Unix())
+
+	bestSensor, best := "", -1
+	for name, s := range f.sensors {
+		if s.zone != crossing.Zone {
+			continue
+		}
+		for i, t := range s.openings {
+			if abs64(crossing.Time-t) > f.window {
+				continue
+			}
+			if best < 0 || abs64(crossing.Time-t) < abs64(crossing.Time-f.sensors[bestSensor].openings[best]) {
+				bestSensor, best = name, i
+			}
+		}
+	}
+	if best < 0 {
+		f.crossings = append(f.crossings, crossing)
+		return
+	}
+	s := f.sensors[bestSensor]
+	at := s.openings[best]
+	s.openings = append(s.openings[:best], s.openings[best+1:]...)
+	e.pass(bestSensor, crossing, at)
+}
+
+// abs64 ...
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// sensorHandler serves POST /api/v1/sensors/{sensor}, behind basic auth.
+// Any body works, {"state": "off"} or "closed" is ignored, "time" in
+// seconds since epoch when the opening wasn't just now.
+func (e *Exporter) sensorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST an opening", http.StatusMethodNotAllowed)
+		return
+	}
+	if e.flaps == nil {
+		http.Error(w, "no sensors, see -sensors.flaps", http.StatusNotFound)
+		return
+	}
+	sensor := r.PathValue("sensor")
+	if _, ok := e.flaps.sensors[sensor]; !ok {
+		http.Error(w, "unknown sensor, see -sensors.flaps", http.StatusNotFound)
+		return
+	}
+	var body struct {
+		State string `json:"state"`
+		Time  int64  `json:"time"`
+	}
+	b, err := io.ReadAll(io.LimitReader(r.Body, 4096))
+	if err == nil && len(strings.TrimSpace(string(b))) > 0 {
+		err = json.Unmarshal(b, &body)
+	}
+	if err != nil {
+		http.Error(w, "body must be JSON like {\"state\": \"on\"}", http.StatusBadRequest)
+		return
+	}
+	switch strings.ToLower(body.State) {
+	case "off", "closed", "close", "false", "0":
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	at := body.Time
+	if at == 0 {
+		at = time.Now().Unix()
+	}
+
+	e.mutex.Lock()
+	e.opened(sensor, at)
+	e.mutex.Unlock()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// collect has the passages of the trackers in this scrape only, a probe or
+// a tenant doesn't see the others
+func (f *flapCorrelator) collect(ch chan<- prometheus.Metric, trackers []string) {
+	if f == nil {
+		return
+	}
+	scraped := make(map[string]bool)
+	for _, id := range trackers {
+		scraped[id] = true
+	}
+	for name, s := range f.sensors {
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(sensorEvents, prometheus.CounterValue, float64(s.events), s.created, name)
+		if s.lastEvent > 0 {
+			ch <- prometheus.MustNewConstMetric(sensorLastEvent, prometheus.GaugeValue, float64(s.lastEvent), name)
+		}
+	}
+	for key, passages := range f.passages {
+		if !scraped[key[0]] {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(flapPassages, prometheus.CounterValue, float64(passages), f.created, key[0], key[1], key[2])
+	}
+}
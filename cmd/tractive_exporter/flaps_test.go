@@ -0,0 +1,109 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Openings and crossings pair up whichever comes first, the closest in
+// time wins and the rest waits for a partner
+func TestFlapCorrelator(t *testing.T) {
+	flaps, window := *sensorFlaps, *sensorWindow
+	defer func() { *sensorFlaps, *sensorWindow = flaps, window }()
+	*sensorFlaps, *sensorWindow = "catflap=home,gate=garden", 15*time.Minute
+
+	now := time.Now().Unix()
+	opened := func(sensor string, at int64) func(e *Exporter) {
+		return func(e *Exporter) { e.opened(sensor, at) }
+	}
+	crossed := func(tracker, zone, status string, at int64) func(e *Exporter) {
+		return func(e *Exporter) { e.crossed(zoneEvent{Tracker: tracker, Zone: zone, Status: status, Time: at}) }
+	}
+
+	for _, tc := range []struct {
+		name  string
+		steps []func(e *Exporter)
+		want  map[[3]string]int64
+	}{
+		{name: "opening first", steps: []func(e *Exporter){
+			opened("catflap", now-60), crossed("rex", "home", "entered", now-30),
+		}, want: map[[3]string]int64{{"rex", "catflap", "entry"}: 1}},
+		{name: "crossing first", steps: []func(e *Exporter){
+			crossed("rex", "home", "left", now-30), opened("catflap", now-20),
+		}, want: map[[3]string]int64{{"rex", "catflap", "exit"}: 1}},
+		{name: "too far apart", steps: []func(e *Exporter){
+			opened("catflap", now-3600), crossed("rex", "home", "entered", now),
+		}, want: map[[3]string]int64{}},
+		{name: "other geofence", steps: []func(e *Exporter){
+			opened("gate", now-60), crossed("rex", "home", "entered", now-30),
+		}, want: map[[3]string]int64{}},
+		{name: "closest tracker", steps: []func(e *Exporter){
+			crossed("rex", "home", "left", now-600), crossed("milo", "home", "left", now-70), opened("catflap", now-60),
+		}, want: map[[3]string]int64{{"milo", "catflap", "exit"}: 1}},
+		{name: "one opening, one passage", steps: []func(e *Exporter){
+			opened("catflap", now-60), crossed("rex", "home", "entered", now-50), crossed("milo", "home", "entered", now-40),
+		}, want: map[[3]string]int64{{"rex", "catflap", "entry"}: 1}},
+	} {
+		e := NewExporter([]string{"rex", "milo"}, make(map[uniqueGeoStates]uniqueGeoStatesValue), make(map[string]geoMemory))
+		var err error
+		if e.flaps, err = newFlapCorrelator(); err != nil {
+			t.Fatal(err)
+		}
+		for _, step := range tc.steps {
+			step(e)
+		}
+		if len(e.flaps.passages) != len(tc.want) {
+			t.Errorf("%s: passages %v, want %v", tc.name, e.flaps.passages, tc.want)
+		}
+		for key, n := range tc.want {
+			if e.flaps.passages[key] != n {
+				t.Errorf("%s: %v passed %d times, want %d", tc.name, key, e.flaps.passages[key], n)
+			}
+		}
+	}
+}
+
+// A scrape of one tracker doesn't show another tracker's passages
+func TestFlapCollectScraped(t *testing.T) {
+	f := &flapCorrelator{
+		sensors: map[string]*flapSensor{"catflap": {zone: "home", events: 2, created: time.Now()}},
+		passages: map[[3]string]int64{
+			{"rex", "catflap", "entry"}: 1,
+			{"milo", "catflap", "exit"}: 1,
+		},
+		created: time.Now(),
+	}
+	for _, tc := range []struct {
+		trackers []string
+		want     []string
+	}{
+		{[]string{"rex", "milo"}, []string{
+			"tractive_flap_passages_total{direction=entry,sensor=catflap,tracker=rex} 1",
+			"tractive_flap_passages_total{direction=exit,sensor=catflap,tracker=milo} 1",
+			"tractive_sensor_events_total{sensor=catflap} 2",
+		}},
+		{[]string{"rex"}, []string{
+			"tractive_flap_passages_total{direction=entry,sensor=catflap,tracker=rex} 1",
+			"tractive_sensor_events_total{sensor=catflap} 2",
+		}},
+		{nil, []string{
+			"tractive_sensor_events_total{sensor=catflap} 2",
+		}},
+	} {
+		ch := make(chan prometheus.Metric, 10)
+		f.collect(ch, tc.trackers)
+		close(ch)
+		var got []string
+		for m := range ch {
+			got = append(got, exposed(t, m))
+		}
+		sort.Strings(got)
+		if strings.Join(got, " | ") != strings.Join(tc.want, " | ") {
+			t.Errorf("%v: got %q, want %q", tc.trackers, got, tc.want)
+		}
+	}
+}
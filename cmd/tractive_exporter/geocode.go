@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// "u2ed3" means nothing to the rest of the family
+	geocodeURL = flag.String("geocode.url", "",
+		"Reverse geocoding endpoint of a Nominatim or Photon server for tractive_location_info, e.g. https://nominatim.openstreetmap.org/reverse, off when empty")
+	geocodeProvider = flag.String("geocode.provider", "nominatim",
+		"What -geocode.url is: nominatim or photon")
+	geocodePrecision = flag.Uint("geocode.precision", 7,
+		"Geohash characters a place is looked up for, positions in the same cell share the lookup and only the cell's center is sent")
+	geocodeInterval = flag.Duration("geocode.interval", time.Second,
+		"Time between lookups at least, the public Nominatim allows one per second")
+	geocodeCacheSize = flag.Int("geocode.cache-size", 1000,
+		"Number of looked up cells kept")
+	geocodeLanguage = flag.String("geocode.language", "",
+		"Language of the place names, as in Accept-Language, the server's default when empty")
+
+	locationInfo = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "location_info"),
+		"Place the tracker is at as reverse geocoded by -geocode.url, always 1",
+		[]string{"tracker", "place", "suburb", "city"}, nil,
+	)
+
+	geocodeRequests = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "geocode_requests_total"),
+		"Number of reverse geocoding lookups, by result",
+		[]string{"result"}, nil,
+	)
+)
+
+// Failed cells are asked again after this long
+const geocodeRetry = 10 * time.Minute
+
+// place is what a cell was reverse geocoded to
+type place struct {
+	name, suburb, city string
+	failed             time.Time
+}
+
+// reverseGeocoder looks up cells one at a time in the background, the
+// scrapes only read the cache
+type reverseGeocoder struct {
+	sync.Mutex
+	client  *http.Client
+	cache   map[string]place
+	order   []string
+	pending map[string]bool
+	queue   chan string
+	results map[string]int64
+	created time.Time
+}
+
+// Set up in main with -geocode.url, nil otherwise
+var geocoder *reverseGeocoder
+
+// newReverseGeocoder starts the lookups, nil without -geocode.url
+func newReverseGeocoder() (*reverseGeocoder, error) {
+	if *geocodeURL == "" {
+		return nil, nil
+	}
+	if *geocodeProvider != "nominatim" && *geocodeProvider != "photon" {
+		return nil, fmt.Errorf("-geocode.provider must be nominatim or photon, not %q", *geocodeProvider)
+	}
+	if *geocodePrecision < 1 || *geocodePrecision > 12 {
+		return nil, fmt.Errorf("-geocode.precision must be between 1 and 12, not %d", *geocodePrecision)
+	}
+	g := &reverseGeocoder{
+		client:  &http.Client{Timeout: 10 * time.Second},
+		cache:   make(map[string]place),
+		pending: make(map[string]bool),
+		queue:   make(chan string, 64),
+		results: make(map[string]int64),
+		created: time.Now(),
+	}
+	go g.run()
+	return g, nil
+}
+
+// lookup is the place of a position, false until it's been looked up.
+// Unknown cells get queued, it never waits.
+func (g *reverseGeocoder) lookup(lat, lon float64) (place, bool) {
+	cell := geohash.EncodeWithPrecision(lat, lon, *geocodePrecision)
+	g.Lock()
+	defer g.Unlock()
+	if p, ok := g.cache[cell]; ok && (p.failed.IsZero() || time.Since(p.failed) < geocodeRetry) {
+		return p, p.failed.IsZero()
+	}
+	if !g.pending[cell] {
+		select {
+		case g.queue <- cell:
+			g.pending[cell] = true
+		default:
+		}
+	}
+	return place{}, false
+}
+
+// run ...
+func (g *reverseGeocoder) run() {
+	for cell := range g.queue {
+		p, err := g.fetch(cell)
+		if err != nil {
+			slog.Warn("Reverse geocoding failed", "url", redactURL(*geocodeURL), "err", err)
+			p = place{failed: time.Now()}
+		}
+
+		g.Lock()
+		delete(g.pending, cell)
+		if _, ok := g.cache[cell]; !ok {
+			g.order = append(g.order, cell)
+		}
+		g.cache[cell] = p
+		for len(g.order) > max(*geocodeCacheSize, 1) {
+			delete(g.cache, g.order[0])
+			g.order = g.order[1:]
+		}
+		if err != nil {
+			g.results["error"]++
+		} else {
+			g.results["ok"]++
+		}
+		g.Unlock()
+
+		time.Sleep(*geocodeInterval)
+	}
+}
+
+// fetch asks the server for the center of a cell
+func (g *reverseGeocoder) fetch(cell string) (place, error) {
+	lat, lon := geohash.DecodeCenter(cell)
+	url, err := neturl.Parse(*geocodeURL)
+	if err != nil {
+		return place{}, err
+	}
+	query := url.Query()
+	query.Set("lat", strconv.FormatFloat(lat, 'f', 6, 64))
+	query.Set("lon", strconv.FormatFloat(lon, 'f', 6, 64))
+	if *geocodeProvider == "nominatim" {
+		query.Set("format", "jsonv2")
+	}
+	url.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		return place{}, err
+	}
+	req.Header.Set("User-Agent", "tractive_prometheus_exporter")
+	if *geocodeLanguage != "" {
+		req.Header.Set("Accept-Language", *geocodeLanguage)
+	}
+	resp, err := g.client.Do(req)
+	if urlErr, ok := err.(*neturl.Error); ok {
+		return place{}, urlErr.Err
+	}
+	if err != nil {
+		return place{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return place{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return place{}, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	if *geocodeProvider == "photon" {
+		return photonPlace(body)
+	}
+	return nominatimPlace(body)
+}
+
+// nominatimPlace reads a jsonv2 answer
+func nominatimPlace(body []byte) (place, error) {
+	var answer struct {
+		Error   string            `json:"error"`
+		Name    string            `json:"name"`
+		Address map[string]string `json:"address"`
+	}
+	if err := json.Unmarshal(body, &answer); err != nil {
+		return place{}, err
+	}
+
+	// somewhere in the sea is still an answer
+	if answer.Error != "" {
+		return place{}, nil
+	}
+	a := answer.Address
+	return place{
+		name:   firstNonEmpty(answer.Name, a["road"], a["neighbourhood"], a["hamlet"]),
+		suburb: firstNonEmpty(a["suburb"], a["city_district"], a["quarter"], a["village"]),
+		city:   firstNonEmpty(a["city"], a["town"], a["village"], a["municipality"]),
+	}, nil
+}
+
+// photonPlace reads the first feature of a GeoJSON answer
+func photonPlace(body []byte) (place, error) {
+	var answer struct {
+		Features []struct {
+			Properties struct {
+				Name     string `json:"name"`
+				Street   string `json:"street"`
+				District string `json:"district"`
+				Locality string `json:"locality"`
+				City     string `json:"city"`
+				County   string `json:"county"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(body, &answer); err != nil {
+		return place{}, err
+	}
+	if len(answer.Features) == 0 {
+		return place{}, nil
+	}
+	props := answer.Features[0].Properties
+	return place{
+		name:   firstNonEmpty(props.Name, props.Street),
+		suburb: firstNonEmpty(props.District, props.Locality),
+		city:   firstNonEmpty(props.City, props.County),
+	}, nil
+}
+
+// collectPlace ...
+func (g *reverseGeocoder) collectPlace(ch chan<- prometheus.Metric, id string, p *Position) {
+	if g == nil {
+		return
+	}
+	if place, ok := g.lookup(p.Lat, p.Lon); ok {
+		ch <- prometheus.MustNewConstMetric(locationInfo, prometheus.GaugeValue, 1, id, place.name, place.suburb, place.city)
+	}
+}
+
+// collect ...
+func (g *reverseGeocoder) collect(ch chan<- prometheus.Metric) {
+	if g == nil {
+		return
+	}
+	g.Lock()
+	defer g.Unlock()
+	for _, result := range []string{"ok", "error"} {
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(geocodeRequests, prometheus.CounterValue, float64(g.results[result]), g.created, result)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Places worth knowing about, drawn on geojson.io or exported from anywhere
+	geofenceFile = flag.String("geofence.file", "",
+		"GeoJSON file with Polygon, MultiPolygon or Point (with a radius property in meters) features named by a name property")
+
+	geofenceInfo = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "geofence_info"),
+		"Geofences the exporter knows about, always 1",
+		[]string{"zone", "source"}, nil,
+	)
+)
+
+// geofence is a named area, polygons in GeoJSON order ([lon, lat]) or a circle
+type geofence struct {
+	name     string
+	source   string
+	polygons [][][][2]float64
+	center   [2]float64
+	radius   float64
+}
+
+// Just enough of GeoJSON
+type geoJSONFeature struct {
+	Geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONFeatureCollection struct {
+	Features []geoJSONFeature `json:"features"`
+}
+
+// loadGeofences reads a FeatureCollection, features without a name get one
+func loadGeofences(path string) ([]geofence, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(b, &collection); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	var fences []geofence
+	for i, feature := range collection.Features {
+		fence, err := feature.geofence(fmt.Sprintf("zone%d", i+1), "file")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", path, fence.name, err)
+		}
+		fences = append(fences, fence)
+	}
+	return fences, nil
+}
+
+// geofence turns a feature into a zone, named by its name property if it has one
+func (feature geoJSONFeature) geofence(name, source string) (geofence, error) {
+	fence := geofence{name: name, source: source}
+	if name, ok := feature.Properties["name"].(string); ok && name != "" {
+		fence.name = name
+	}
+
+	var err error
+	coordinates := feature.Geometry.Coordinates
+	switch feature.Geometry.Type {
+	case "Polygon":
+		var polygon [][][2]float64
+		err = json.Unmarshal(coordinates, &polygon)
+		fence.polygons = append(fence.polygons, polygon)
+	case "MultiPolygon":
+		err = json.Unmarshal(coordinates, &fence.polygons)
+	case "Point":
+		err = json.Unmarshal(coordinates, &fence.center)
+		fence.radius, _ = feature.Properties["radius"].(float64)
+		if err == nil && fence.radius <= 0 {
+			err = fmt.Errorf("point needs a radius property in meters")
+		}
+	default:
+		err = fmt.Errorf("%s geometry is not supported", feature.Geometry.Type)
+	}
+	return fence, err
+}
+
+// uniqueZoneNames makes sure a zone name means one place, files or config
+func uniqueZoneNames(fences []geofence) error {
+	seen := make(map[string]string)
+	for _, fence := range fences {
+		if source, ok := seen[fence.name]; ok {
+			return fmt.Errorf("zone %s is defined twice (%s and %s)", fence.name, source, fence.source)
+		}
+		seen[fence.name] = fence.source
+	}
+	return nil
+}
+
+// contains ...
+func (g geofence) contains(lat, lon float64) bool {
+	if g.radius > 0 {
+		return Distance(g.center[1], g.center[0], lat, lon) <= g.radius
+	}
+	for _, polygon := range g.polygons {
+		if len(polygon) == 0 || !ringContains(polygon[0], lat, lon) {
+			continue
+		}
+
+		// the other rings are holes
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if ringContains(hole, lat, lon) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// ringContains is the usual ray casting, fine for anything smaller than a country
+func ringContains(ring [][2]float64, lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// zonesAt lists the geofences a position is in
+func (e *Exporter) zonesAt(lat, lon float64) []string {
+	zones := []string{}
+	for _, fence := range e.geofences {
+		if fence.contains(lat, lon) {
+			zones = append(zones, fence.name)
+		}
+	}
+	return zones
+}
+
+// collectGeofences ...
+func (e *Exporter) collectGeofences(ch chan<- prometheus.Metric) {
+	for _, fence := range e.geofences {
+		ch <- prometheus.MustNewConstMetric(geofenceInfo, prometheus.GaugeValue, 1, fence.name, fence.source)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A yard with a pond in it, two fields, a park drawn as a circle and a
+// circle without a name
+const testGeofences = `{"type": "FeatureCollection", "features": [
+	{"type": "Feature", "properties": {"name": "yard"}, "geometry": {"type": "Polygon", "coordinates": [
+		[[16.0, 48.0], [16.1, 48.0], [16.1, 48.1], [16.0, 48.1], [16.0, 48.0]],
+		[[16.04, 48.04], [16.06, 48.04], [16.06, 48.06], [16.04, 48.06], [16.04, 48.04]]
+	]}},
+	{"type": "Feature", "properties": {"name": "fields"}, "geometry": {"type": "MultiPolygon", "coordinates": [
+		[[[17.0, 48.0], [17.1, 48.0], [17.05, 48.1], [17.0, 48.0]]],
+		[[[18.0, 48.0], [18.1, 48.0], [18.05, 48.1], [18.0, 48.0]]]
+	]}},
+	{"type": "Feature", "properties": {"name": "park", "radius": 100}, "geometry": {"type": "Point", "coordinates": [16.3738, 48.2082]}},
+	{"type": "Feature", "properties": {"radius": 5}, "geometry": {"type": "Point", "coordinates": [0, 0]}}
+]}`
+
+// Which zones a position is in, the hole of the yard isn't the yard
+func TestGeofenceContains(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zones.geojson")
+	if err := os.WriteFile(path, []byte(testGeofences), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fences, err := loadGeofences(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fences) != 4 || fences[3].name != "zone4" || fences[0].source != "file" {
+		t.Fatalf("loaded %+v, want yard, fields, park and zone4 from the file", fences)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		lat, lon float64
+		zones    []string
+	}{
+		{"in the yard", 48.02, 16.02, []string{"yard"}},
+		{"in the pond", 48.05, 16.05, nil},
+		{"outside", 48.2, 16.2, nil},
+		{"first field", 48.02, 17.05, []string{"fields"}},
+		{"second field", 48.02, 18.05, []string{"fields"}},
+		{"beside a field", 48.09, 17.01, nil},
+		{"park center", 48.2082, 16.3738, []string{"park"}},
+		{"edge of the park", 48.2090, 16.3738, []string{"park"}},
+		{"past the park", 48.2092, 16.3738, nil},
+	} {
+		var zones []string
+		for _, fence := range fences {
+			if fence.contains(tc.lat, tc.lon) {
+				zones = append(zones, fence.name)
+			}
+		}
+		if !reflect.DeepEqual(zones, tc.zones) {
+			t.Errorf("%s: in %v, want %v", tc.name, zones, tc.zones)
+		}
+	}
+}
+
+// Features the zones can't be made of
+func TestLoadGeofencesRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		geojson string
+		err     string
+	}{
+		{"point without radius", `{"features": [{"properties": {"name": "x"}, "geometry": {"type": "Point", "coordinates": [16, 48]}}]}`, "radius"},
+		{"line", `{"features": [{"properties": {"name": "x"}, "geometry": {"type": "LineString", "coordinates": [[16, 48], [17, 48]]}}]}`, "LineString geometry is not supported"},
+		{"not JSON", `{"features": [`, "zones.geojson"},
+	} {
+		path := filepath.Join(t.TempDir(), "zones.geojson")
+		if err := os.WriteFile(path, []byte(tc.geojson), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadGeofences(path); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: error %v, want one with %q", tc.name, err, tc.err)
+		}
+	}
+}
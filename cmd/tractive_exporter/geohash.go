@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"sort"
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// 12 characters is a few centimeters, one series per nap spot
+	geohashPrecision = flag.Uint("geohash.precision", 7,
+		"Characters of the geohash label, 7 is about 150m, 12 is full precision, geohash_precision in -config.file wins")
+	geohashAreaPrecision = flag.Uint("geohash.area-precision", 0,
+		"Also count reports per geohash of this many characters as tractive_geohash_area_total, e.g. 5 for heatmaps, 0 is off")
+
+	// A roaming pet shouldn't grow the exporter forever
+	geohashTTL = flag.Duration("geohash.ttl", 0,
+		"Forget the count of a geohash the tracker hasn't reported from in this long, 0 keeps them all")
+	geohashMaxPerTracker = flag.Int("geohash.max-per-tracker", 0,
+		"Geohashes counted per tracker at most, the ones reported from longest ago are forgotten first, 0 is no limit")
+
+	trackerGeohashArea = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "geohash", "area_total"),
+		"Number of reports per geohash at -geohash.area-precision",
+		[]string{"tracker", "geohash"}, nil,
+	)
+
+	geohashEvicted = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "geohash", "evicted_total"),
+		"Number of geohash counts forgotten for -geohash.ttl (ttl) or -geohash.max-per-tracker (cap)",
+		[]string{"reason"}, nil,
+	)
+)
+
+// geohashEvictions counts what -geohash.ttl and -geohash.max-per-tracker
+// made us forget, under the exporter's mutex
+var geohashEvictions = struct {
+	ttl, capped int64
+	created     time.Time
+}{created: time.Now()}
+
+// trackerGeohashPrecision ...
+func trackerGeohashPrecision(id string) uint {
+	precision := *geohashPrecision
+	if configured := trackerConfig[id].GeohashPrecision; configured > 0 {
+		precision = configured
+	}
+	if precision < 1 || precision > 12 {
+		precision = 12
+	}
+
+	// coarser under memory pressure, see -memory.budget
+	if geohashReduction > 0 && precision > minDegradedPrecision {
+		precision -= geohashReduction
+		if precision < minDegradedPrecision {
+			precision = minDegradedPrecision
+		}
+	}
+	return precision
+}
+
+// geohashLabel is the geohash exposed as a label, coarse enough that the
+// number of series stays sane
+func geohashLabel(id string, lat, lon float64) string {
+	return geohash.EncodeWithPrecision(lat, lon, trackerGeohashPrecision(id))
+}
+
+// countGeohash counts a report in a geohash when
+// (new geohashes) or (same geohashes but new timestamps)
+func countGeohash(states map[uniqueGeoStates]uniqueGeoStatesValue, id, encoded string, t int64, newLocation bool) (uniqueGeoStatesValue, bool) {
+	uniqueGeo := states[uniqueGeoStates{tracker: id, geohash: encoded}]
+	if uniqueGeo.lastTimestamp == t && !newLocation {
+		return uniqueGeo, false
+	}
+	uniqueGeo = uniqueGeoStatesValue{
+		counter:       uniqueGeo.counter + 1,
+		lastTimestamp: t,
+		created:       uniqueGeo.created,
+	}
+	if uniqueGeo.created.IsZero() {
+		uniqueGeo.created = time.Now()
+	}
+	states[uniqueGeoStates{tracker: id, geohash: encoded}] = uniqueGeo
+	return uniqueGeo, true
+}
+
+// collectGeohashArea ...
+func (e *Exporter) collectGeohashArea(ch chan<- prometheus.Metric, id string, p *Position, newLocation bool) {
+	if *geohashAreaPrecision == 0 {
+		return
+	}
+	area := geohash.EncodeWithPrecision(p.Lat, p.Lon, *geohashAreaPrecision)
+	if uniqueGeo, counted := countGeohash(e.mapOfAreaGeoStates, id, area, p.Time, newLocation); counted {
+		stateWAL.geohash("area", id, area, uniqueGeo)
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+			trackerGeohashArea, prometheus.CounterValue, float64(uniqueGeo.counter), uniqueGeo.created, id, area,
+		)
+	}
+}
+
+// expireGeohashes forgets geohashes past -geohash.ttl and over
+// -geohash.max-per-tracker. Like the memory budget's eviction, one that
+// comes back counts from 1 with a new created timestamp.
+func expireGeohashes(states map[uniqueGeoStates]uniqueGeoStatesValue, now time.Time) {
+	if *geohashTTL > 0 {
+		oldest := now.Add(-*geohashTTL).Unix()
+		for key, value := range states {
+			if value.lastTimestamp < oldest {
+				delete(states, key)
+				geohashEvictions.ttl++
+			}
+		}
+	}
+	if *geohashMaxPerTracker <= 0 {
+		return
+	}
+	perTracker := make(map[string][]uniqueGeoStates)
+	for key := range states {
+		perTracker[key.tracker] = append(perTracker[key.tracker], key)
+	}
+	for _, keys := range perTracker {
+		if len(keys) <= *geohashMaxPerTracker {
+			continue
+		}
+		sort.Slice(keys, func(i, j int) bool { return states[keys[i]].lastTimestamp < states[keys[j]].lastTimestamp })
+		for _, key := range keys[:len(keys)-*geohashMaxPerTracker] {
+			delete(states, key)
+			geohashEvictions.capped++
+		}
+	}
+}
+
+// collectGeohashEvictions ...
+func collectGeohashEvictions(ch chan<- prometheus.Metric) {
+	if *geohashTTL == 0 && *geohashMaxPerTracker <= 0 {
+		return
+	}
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(geohashEvicted, prometheus.CounterValue, float64(geohashEvictions.ttl), geohashEvictions.created, "ttl")
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(geohashEvicted, prometheus.CounterValue, float64(geohashEvictions.capped), geohashEvictions.created, "cap")
+}
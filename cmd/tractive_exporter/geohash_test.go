@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// The flag, unless the tracker's config says otherwise, 12 when silly
+func TestGeohashLabel(t *testing.T) {
+	precision, config := *geohashPrecision, trackerConfig
+	defer func() { *geohashPrecision, trackerConfig = precision, config }()
+
+	for _, tc := range []struct {
+		flag   uint
+		config uint
+		want   string
+	}{
+		{flag: 7, want: "u33dc0c"},
+		{flag: 5, want: "u33dc"},
+		{flag: 12, want: "u33dc0cppjs7"},
+		{flag: 0, want: "u33dc0cppjs7"},
+		{flag: 20, want: "u33dc0cppjs7"},
+		{flag: 7, config: 4, want: "u33d"},
+	} {
+		*geohashPrecision = tc.flag
+		trackerConfig = map[string]trackerSettings{"rex": {GeohashPrecision: tc.config}}
+		if got := geohashLabel("rex", 52.52, 13.405); got != tc.want {
+			t.Errorf("precision %d, config %d: %s, want %s", tc.flag, tc.config, got, tc.want)
+		}
+	}
+}
+
+// A report counts when it's new, the same one scraped again doesn't
+func TestCountGeohash(t *testing.T) {
+	states := make(map[uniqueGeoStates]uniqueGeoStatesValue)
+	for i, tc := range []struct {
+		t           int64
+		newLocation bool
+		counted     bool
+		counter     int
+	}{
+		{t: 100, newLocation: true, counted: true, counter: 1},
+		{t: 100, newLocation: false, counted: false, counter: 1},
+		{t: 200, newLocation: false, counted: true, counter: 2},
+		{t: 200, newLocation: true, counted: true, counter: 3},
+	} {
+		value, counted := countGeohash(states, "rex", "u33dc0c", tc.t, tc.newLocation)
+		if counted != tc.counted || int(value.counter) != tc.counter || value.created.IsZero() {
+			t.Errorf("report %d: counted %v to %v, want %v to %d", i, counted, value.counter, tc.counted, tc.counter)
+		}
+	}
+}
+
+// Old geohashes go after -geohash.ttl, the least recent over the cap
+func TestExpireGeohashes(t *testing.T) {
+	ttl, limit := *geohashTTL, *geohashMaxPerTracker
+	defer func() { *geohashTTL, *geohashMaxPerTracker = ttl, limit }()
+
+	now := time.Now()
+	for _, tc := range []struct {
+		name string
+		ttl  time.Duration
+		cap  int
+		want []string
+	}{
+		{name: "keep all", want: []string{"rex/a", "rex/b", "rex/c", "milo/a"}},
+		{name: "ttl", ttl: 2 * time.Hour, want: []string{"rex/b", "rex/c", "milo/a"}},
+		{name: "cap", cap: 2, want: []string{"rex/b", "rex/c", "milo/a"}},
+		{name: "both", ttl: 90 * time.Minute, cap: 1, want: []string{"rex/c", "milo/a"}},
+	} {
+		*geohashTTL, *geohashMaxPerTracker = tc.ttl, tc.cap
+		states := map[uniqueGeoStates]uniqueGeoStatesValue{
+			{tracker: "rex", geohash: "a"}:  {counter: 5, lastTimestamp: now.Add(-3 * time.Hour).Unix()},
+			{tracker: "rex", geohash: "b"}:  {counter: 5, lastTimestamp: now.Add(-time.Hour).Unix()},
+			{tracker: "rex", geohash: "c"}:  {counter: 5, lastTimestamp: now.Unix()},
+			{tracker: "milo", geohash: "a"}: {counter: 5, lastTimestamp: now.Add(-time.Minute).Unix()},
+		}
+		expireGeohashes(states, now)
+		if len(states) != len(tc.want) {
+			t.Errorf("%s: kept %v, want %v", tc.name, states, tc.want)
+		}
+		for _, key := range tc.want {
+			tracker, geohash, _ := strings.Cut(key, "/")
+			if _, ok := states[uniqueGeoStates{tracker: tracker, geohash: geohash}]; !ok {
+				t.Errorf("%s: %s was forgotten", tc.name, key)
+			}
+		}
+	}
+}
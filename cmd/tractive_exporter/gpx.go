@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gpxFile is GPX 1.1 with one track of one segment
+type gpxFile struct {
+	XMLName  xml.Name `xml:"gpx"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Version  string   `xml:"version,attr"`
+	Creator  string   `xml:"creator,attr"`
+	Metadata struct {
+		Name string `xml:"name"`
+		Time string `xml:"time"`
+	} `xml:"metadata"`
+	Track struct {
+		Name    string `xml:"name"`
+		Segment struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// gpxPoint ...
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Ele  int     `xml:"ele"`
+	Time string  `xml:"time"`
+}
+
+// kmlFile is a Placemark with the track as a LineString and when it was walked
+type kmlFile struct {
+	XMLName  xml.Name `xml:"kml"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Document struct {
+		Name      string `xml:"name"`
+		Placemark struct {
+			Name       string       `xml:"name"`
+			TimeSpan   *kmlTimeSpan `xml:"TimeSpan,omitempty"`
+			LineString struct {
+				Tessellate  int    `xml:"tessellate"`
+				Coordinates string `xml:"coordinates"`
+			} `xml:"LineString"`
+		} `xml:"Placemark"`
+	} `xml:"Document"`
+}
+
+// kmlTimeSpan ...
+type kmlTimeSpan struct {
+	Begin string `xml:"begin"`
+	End   string `xml:"end"`
+}
+
+// trackRange reads from and to like the positions API, or a whole day
+// in -timezone as day=2024-05-01
+func trackRange(r *http.Request) (from, to int64, err error) {
+	values := r.URL.Query()
+	to = time.Now().Unix()
+	if v := values.Get("day"); v != "" {
+		day, err := time.ParseInLocation("2006-01-02", v, homeLocation)
+		if err != nil {
+			return 0, 0, fmt.Errorf("day must be like 2024-05-01")
+		}
+		return day.Unix(), day.AddDate(0, 0, 1).Unix() - 1, nil
+	}
+	if v := values.Get("from"); v != "" {
+		if from, err = historyTime(v); err != nil {
+			return 0, 0, err
+		}
+	}
+	if v := values.Get("to"); v != "" {
+		if to, err = historyTime(v); err != nil {
+			return 0, 0, err
+		}
+	}
+	return from, to, nil
+}
+
+// Formats of /api/v1/tracks/, by file name ending
+var trackFormats = []string{".gpx", ".kml", ".tractive.json", ".tractive.csv"}
+
+// tracksHandler serves /api/v1/tracks/{tracker}.gpx and .kml for Strava,
+// Komoot, Google Earth and friends, and .tractive.json and .tractive.csv
+// to go with Tractive's own
+func (e *Exporter) tracksHandler(w http.ResponseWriter, r *http.Request) {
+	file := strings.TrimPrefix(r.URL.Path, "/api/v1/tracks/")
+	var id, format string
+	for _, f := range trackFormats {
+		if strings.HasSuffix(file, f) {
+			id, format = strings.TrimSuffix(file, f), f
+			break
+		}
+	}
+	if format == "" || !e.isConfigured(id) {
+		http.NotFound(w, r)
+		return
+	}
+	if *hideCoordinates {
+		http.Error(w, "coordinates are hidden", http.StatusForbidden)
+		return
+	}
+	from, to, err := trackRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var points []trackPoint
+	for _, point := range e.tracks.points(id, from) {
+		if point.Time > to {
+			break
+		}
+		points = append(points, point)
+	}
+	e.mutex.Lock()
+	name := e.trackerName(id)
+	e.mutex.Unlock()
+	if name == "" {
+		name = id
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(id+format))
+	switch format {
+	case ".tractive.json":
+		w.Header().Set("Content-Type", "application/json")
+		writeTractiveJSON(w, points)
+		return
+	case ".tractive.csv":
+		w.Header().Set("Content-Type", "text/csv")
+		writeTractiveCSV(w, points)
+		return
+	}
+
+	var doc interface{}
+	if format == ".gpx" {
+		doc = buildGPX(name, points)
+		w.Header().Set("Content-Type", "application/gpx+xml")
+	} else {
+		doc = buildKML(name, points)
+		w.Header().Set("Content-Type", "application/vnd.google-earth.kml+xml")
+	}
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", " ")
+	encoder.Encode(doc)
+}
+
+// gpxTime is what both formats want, UTC
+func gpxTime(t int64) string {
+	return time.Unix(t, 0).UTC().Format(time.RFC3339)
+}
+
+// buildGPX ...
+func buildGPX(name string, points []trackPoint) gpxFile {
+	var gpx gpxFile
+	gpx.Xmlns = "http://www.topografix.com/GPX/1/1"
+	gpx.Version = "1.1"
+	gpx.Creator = "tractive_exporter"
+	gpx.Metadata.Name = name
+	gpx.Metadata.Time = gpxTime(time.Now().Unix())
+	gpx.Track.Name = name
+	gpx.Track.Segment.Points = []gpxPoint{}
+	for _, point := range points {
+		gpx.Track.Segment.Points = append(gpx.Track.Segment.Points, gpxPoint{
+			Lat:  point.Lat,
+			Lon:  point.Lon,
+			Ele:  point.Alt,
+			Time: gpxTime(point.Time),
+		})
+	}
+	return gpx
+}
+
+// buildKML ...
+func buildKML(name string, points []trackPoint) kmlFile {
+	var kml kmlFile
+	kml.Xmlns = "http://www.opengis.net/kml/2.2"
+	kml.Document.Name = name
+	kml.Document.Placemark.Name = name
+	kml.Document.Placemark.LineString.Tessellate = 1
+	if len(points) > 0 {
+		kml.Document.Placemark.TimeSpan = &kmlTimeSpan{
+			Begin: gpxTime(points[0].Time),
+			End:   gpxTime(points[len(points)-1].Time),
+		}
+	}
+	coordinates := make([]string, len(points))
+	for i, point := range points {
+		coordinates[i] = fmt.Sprintf("%f,%f,%d", point.Lon, point.Lat, point.Alt)
+	}
+	kml.Document.Placemark.LineString.Coordinates = strings.Join(coordinates, " ")
+	return kml
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tracks come as GPX or KML, for the range asked for
+func TestTracksHandler(t *testing.T) {
+	e := NewExporter([]string{"rex"}, make(map[uniqueGeoStates]uniqueGeoStatesValue), make(map[string]geoMemory))
+	now := time.Now().Unix()
+	for i, lat := range []float64{52.520, 52.521, 52.522} {
+		p := &Position{}
+		p.Time, p.Lat, p.Lon, p.Alt = now-int64(600*(2-i)), lat, 13.405, 34
+		e.tracks.add("rex", p)
+	}
+
+	for _, tc := range []struct {
+		path   string
+		status int
+		has    []string
+		points int
+	}{
+		{path: "/api/v1/tracks/rex.gpx", status: 200, has: []string{`<gpx xmlns="http://www.topografix.com/GPX/1/1"`, `<trkpt lat="52.52" lon="13.405">`, "<ele>34</ele>"}, points: 3},
+		{path: "/api/v1/tracks/rex.kml", status: 200, has: []string{"13.405000,52.520000,34 13.405000,52.521000,34 13.405000,52.522000,34"}},
+		{path: "/api/v1/tracks/rex.gpx?from=" + time.Unix(now-900, 0).Format(time.RFC3339), status: 200, points: 2},
+		{path: "/api/v1/tracks/rex.gpx?day=2020-01-01", status: 200, points: 0},
+		{path: "/api/v1/tracks/rex.gpx?day=yesterday", status: 400},
+		{path: "/api/v1/tracks/rex.shp", status: 404},
+		{path: "/api/v1/tracks/milo.gpx", status: 404},
+	} {
+		w := httptest.NewRecorder()
+		e.tracksHandler(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		body := w.Body.String()
+		if w.Code != tc.status {
+			t.Errorf("%s: HTTP %d, want %d: %s", tc.path, w.Code, tc.status, body)
+			continue
+		}
+		for _, s := range tc.has {
+			if !strings.Contains(body, s) {
+				t.Errorf("%s: no %s in %s", tc.path, s, body)
+			}
+		}
+		if n := strings.Count(body, "<trkpt "); tc.status == 200 && strings.Contains(tc.path, ".gpx") && n != tc.points {
+			t.Errorf("%s: %d points, want %d", tc.path, n, tc.points)
+		}
+	}
+}
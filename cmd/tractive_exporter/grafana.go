@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Grafana dashboards are a big pile of JSON, maps keep it readable enough
+type dashboardPanel map[string]interface{}
+
+// grafanaTarget ...
+func grafanaTarget(refID, expr, legend string, instant bool) map[string]interface{} {
+	target := map[string]interface{}{
+		"refId":        refID,
+		"expr":         expr,
+		"legendFormat": legend,
+		"datasource":   grafanaDatasource,
+	}
+	if instant {
+		target["instant"] = true
+		target["format"] = "table"
+	}
+	return target
+}
+
+// Filled by Grafana provisioning or picked on import
+var grafanaDatasource = map[string]interface{}{
+	"type": "prometheus",
+	"uid":  "${datasource}",
+}
+
+// grafanaStat ...
+func grafanaStat(title, expr, unit string, x, y int) dashboardPanel {
+	return dashboardPanel{
+		"type":       "stat",
+		"title":      title,
+		"datasource": grafanaDatasource,
+		"gridPos":    map[string]int{"x": x, "y": y, "w": 6, "h": 4},
+		"targets":    []interface{}{grafanaTarget("A", expr, "", false)},
+		"fieldConfig": map[string]interface{}{
+			"defaults": map[string]interface{}{"unit": unit},
+		},
+	}
+}
+
+// grafanaMap shows every tracker's position on one geomap
+func grafanaMap(y int) dashboardPanel {
+	return dashboardPanel{
+		"type":       "geomap",
+		"title":      "Where are they",
+		"datasource": grafanaDatasource,
+		"gridPos":    map[string]int{"x": 0, "y": y, "w": 24, "h": 12},
+		"targets": []interface{}{
+			grafanaTarget("A", namespaced("tractive_latitude"), "{{tracker}}", true),
+			grafanaTarget("B", namespaced("tractive_longitude"), "{{tracker}}", true),
+		},
+		"transformations": []interface{}{
+			map[string]interface{}{
+				"id":      "joinByField",
+				"options": map[string]interface{}{"byField": "tracker", "mode": "outer"},
+			},
+		},
+		"options": map[string]interface{}{
+			"view": map[string]interface{}{"id": "fit"},
+			"layers": []interface{}{
+				map[string]interface{}{
+					"type": "markers",
+					"name": "Trackers",
+					"location": map[string]interface{}{
+						"mode":      "coords",
+						"latitude":  "Value #A",
+						"longitude": "Value #B",
+					},
+					"tooltip": true,
+				},
+			},
+		},
+	}
+}
+
+// grafanaDashboard builds the dashboard for the configured trackers
+func (e *Exporter) grafanaDashboard() map[string]interface{} {
+	var panels []dashboardPanel
+	y := 0
+
+	if !*hideCoordinates {
+		panels = append(panels, grafanaMap(y))
+		y += 12
+	}
+
+	for _, id := range e.trackers() {
+		panels = append(panels, dashboardPanel{
+			"type":      "row",
+			"title":     id,
+			"collapsed": false,
+			"gridPos":   map[string]int{"x": 0, "y": y, "w": 24, "h": 1},
+		})
+		y++
+
+		selector := fmt.Sprintf(`{tracker=%q}`, id)
+		panels = append(panels,
+			grafanaStat("Last report", namespaced("tractive_age_seconds")+selector, "s", 0, y),
+			grafanaStat("Battery", namespaced("tractive_battery_level")+selector, "percent", 6, y),
+			grafanaStat("Speed", namespaced("tractive_speed")+selector, "velocityms", 12, y),
+			grafanaStat("Live tracking", namespaced("tractive_live")+selector, "bool_on_off", 18, y),
+		)
+		y += 4
+
+		panels = append(panels, dashboardPanel{
+			"type":       "timeseries",
+			"title":      "Distance per hop",
+			"datasource": grafanaDatasource,
+			"gridPos":    map[string]int{"x": 0, "y": y, "w": 24, "h": 6},
+			"targets": []interface{}{
+				grafanaTarget("A", namespaced("tractive_distance")+selector, "{{tracker}}", false),
+			},
+			"fieldConfig": map[string]interface{}{
+				"defaults": map[string]interface{}{"unit": "lengthm"},
+			},
+		})
+		y += 6
+	}
+
+	// grafana wants ids
+	for i := range panels {
+		panels[i]["id"] = i + 1
+	}
+
+	return map[string]interface{}{
+		"uid":           "tractive",
+		"title":         "Tractive",
+		"tags":          []string{"tractive"},
+		"schemaVersion": 39,
+		"refresh":       "1m",
+		"time":          map[string]string{"from": "now-24h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []interface{}{
+				map[string]interface{}{
+					"name":  "datasource",
+					"label": "Prometheus",
+					"type":  "datasource",
+					"query": "prometheus",
+				},
+			},
+		},
+		"panels": panels,
+	}
+}
+
+// grafanaHandler serves /grafana/dashboard.json
+func (e *Exporter) grafanaHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, e.grafanaDashboard())
+}
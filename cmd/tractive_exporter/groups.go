@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+
+	// Several family members, several shares or accounts
+	trackerGroups = flag.String("trackers.groups", "",
+		"Public shares by group, e.g. anna:id1,id2;ben:id3, every per-tracker metric gets a group label. Groups with their own account go in -config.file.")
+)
+
+// groupSettings is a group in -config.file, public shares or, with an
+// email, the trackers of an account
+//
+//	groups:
+//	  - name: anna
+//	    trackers: [6a7235da65]
+//	  - name: ben
+//	    email: ben@example.com
+//	    password_env: BEN_TRACTIVE_PASSWORD
+type groupSettings struct {
+	Name        string   `yaml:"name"`
+	Trackers    []string `yaml:"trackers"`
+	Email       string   `yaml:"email"`
+	Password    string   `yaml:"password"`
+	PasswordEnv string   `yaml:"password_env"`
+}
+
+// Groups from -config.file, set up next to -trackers.groups in main
+var configGroups []groupSettings
+
+// trackerGroup is the group of a tracker, empty without groups. Discovery
+// adds to it, so past setup it's read with groupsMutex.
+var (
+	groupsMutex  sync.RWMutex
+	trackerGroup = make(map[string]string)
+)
+
+// groupAccounts are the logins of groups with an email, trackers in other
+// groups are public shares even when there is a -tractive.email
+var groupAccounts = make(map[string]*tractiveAccount)
+
+// accountOf is the account a tracker is fetched with, nil for a public share
+func accountOf(id string) *tractiveAccount {
+	if group, ok := groupOf(id); ok {
+		return groupAccounts[group]
+	}
+	return account
+}
+
+// groupOf ...
+func groupOf(id string) (string, bool) {
+	groupsMutex.RLock()
+	defer groupsMutex.RUnlock()
+	group, ok := trackerGroup[id]
+	return group, ok
+}
+
+// setupGroups logs into the groups with an account and returns the trackers
+// of all groups, the account's all when the group doesn't list them
+func setupGroups() ([]string, error) {
+	groups := configGroups
+	flagGroups := parseTenants(*trackerGroups)
+	var names []string
+	for name := range flagGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		groups = append(groups, groupSettings{Name: name, Trackers: flagGroups[name]})
+	}
+
+	var ids []string
+	for _, group := range groups {
+		if group.Name == "" {
+			return nil, fmt.Errorf("a group without a name")
+		}
+		trackers := group.Trackers
+		if group.Email != "" {
+			password := firstNonEmpty(group.Password, os.Getenv(group.PasswordEnv))
+			a, err := newAccountWith(group.Email, password, "", "")
+			if err != nil {
+				return nil, fmt.Errorf("group %s: %v", group.Name, err)
+			}
+			groupAccounts[group.Name] = a
+			if len(trackers) == 0 {
+				ctx, cancel := context.WithTimeout(context.Background(), fetchDeadline())
+				trackers, err = a.trackers(ctx)
+				cancel()
+				if err != nil {
+					return nil, fmt.Errorf("group %s: %v", group.Name, err)
+				}
+				addDiscovery(group.Name, a, trackers)
+			}
+		}
+		for _, id := range trackers {
+			id = strings.TrimSpace(id)
+			if previous, ok := trackerGroup[id]; ok && previous != group.Name {
+				return nil, fmt.Errorf("tracker %s is in groups %s and %s", id, previous, group.Name)
+			}
+			trackerGroup[id] = group.Name
+			ids = append(ids, id)
+		}
+		slog.Info("Tracker group", "group", group.Name, "trackers", strings.Join(trackers, ","), "account", group.Email != "")
+	}
+	return ids, nil
+}
+
+// publicShares are the trackers fetched through public shares
+func publicShares(trackers []string) []string {
+	var shares []string
+	for _, id := range trackers {
+		if accountOf(id) == nil {
+			shares = append(shares, id)
+		}
+	}
+	return shares
+}
+
+// groupingGatherer adds the group label to every metric with a tracker label
+type groupingGatherer struct {
+	gatherer prometheus.Gatherer
+}
+
+// Gather ...
+func (g groupingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	name := "group"
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			id, ok := labelValue(metric, "tracker")
+			if !ok {
+				continue
+			}
+			group, _ := groupOf(id)
+
+			// labels stay sorted by name
+			at := sort.Search(len(metric.Label), func(i int) bool { return metric.Label[i].GetName() > name })
+			metric.Label = append(metric.Label[:at], append([]*dto.LabelPair{{Name: &name, Value: &group}}, metric.Label[at:]...)...)
+		}
+	}
+	return families, err
+}
+
+// labelValue ...
+func labelValue(metric *dto.Metric, name string) (string, bool) {
+	for _, label := range metric.Label {
+		if label.GetName() == name {
+			return label.GetValue(), true
+		}
+	}
+	return "", false
+}
+
+// newGroupingGatherer only wraps when there are groups
+func newGroupingGatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	if len(trackerGroup) == 0 {
+		return gatherer
+	}
+	return groupingGatherer{gatherer: gatherer}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	trackerBatteryPercent = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "battery_percent"),
+		"Battery level from the hardware report in percent, authenticated API only",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerCharging = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "charging"),
+		"Whether the tracker is charging (1) or not (0)",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerHardwareInfo = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "hardware_info"),
+		"Battery and hardware state as the tracker reports them, always 1",
+		[]string{"tracker", "battery_state", "charging_state", "hw_status"}, nil,
+	)
+
+	trackerGSMSignal = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "gsm_signal_strength"),
+		"GSM signal strength from the hardware report, when the tracker sends it",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerGPSSignal = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "gps_signal_strength"),
+		"GPS signal strength from the hardware report, when the tracker sends it",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// collectHardware exposes the last hardware report, safe to call on nil
+func (a *tractiveAccount) collectHardware(ch chan<- prometheus.Metric, id string) {
+	if a == nil {
+		return
+	}
+	a.Lock()
+	hw, ok := a.hardware[id]
+	a.Unlock()
+	if !ok {
+		return
+	}
+
+	var charging float64
+	if hw.ChargingState == "CHARGING" {
+		charging = 1
+	}
+	ch <- prometheus.MustNewConstMetric(trackerBatteryPercent, prometheus.GaugeValue, float64(hw.BatteryLevel), id)
+	ch <- prometheus.MustNewConstMetric(trackerCharging, prometheus.GaugeValue, charging, id)
+	ch <- prometheus.MustNewConstMetric(
+		trackerHardwareInfo, prometheus.GaugeValue, 1, id, hw.BatteryState, hw.ChargingState, hw.HwStatus,
+	)
+	if hw.GSMSignal != nil {
+		ch <- prometheus.MustNewConstMetric(trackerGSMSignal, prometheus.GaugeValue, *hw.GSMSignal, id)
+	}
+	if hw.GPSSignal != nil {
+		ch <- prometheus.MustNewConstMetric(trackerGPSSignal, prometheus.GaugeValue, *hw.GPSSignal, id)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+var (
+
+	// For docker HEALTHCHECK without curl in the image
+	healthcheck = flag.Bool("healthcheck", false,
+		"Query the /-/healthy endpoint of a running exporter on -web.port and exit 0 if healthy, 1 otherwise")
+)
+
+// healthyHandler answers as long as the process serves http
+func healthyHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "Healthy.")
+}
+
+// runHealthcheck exits the process with the result
+func runHealthcheck() {
+	client, url, err := localExporter("/-/healthy")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintln(os.Stderr, "unhealthy:", resp.Status)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// localExporter is a client for the exporter running on -web.port and
+// the URL of a path on it
+func localExporter(path string) (*http.Client, string, error) {
+	host, port, err := net.SplitHostPort(*listenAddress)
+	if err != nil {
+		return nil, "", err
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+
+	// it's us on localhost, the certificate is for the public name
+	scheme := "http"
+	if *tlsCertFile != "" || *acmeDomains != "" || webConfigWantsTLS() {
+		scheme = "https"
+	}
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	return client, scheme + "://" + net.JoinHostPort(host, port) + webRoutePrefix() + path, nil
+}
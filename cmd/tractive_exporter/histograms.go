@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// A gauge only shows what the pet did right at the scrape
+	speedBuckets = flag.String("histogram.speed-buckets", "0.5,1,2,3,5,8,13",
+		"Comma separated upper bounds in m/s of the tractive_speed_mps histogram")
+	hopBuckets = flag.String("histogram.hop-buckets", "5,10,25,50,100,250,500,1000",
+		"Comma separated upper bounds in meters of the tractive_hop_length_meters histogram")
+
+	trackerSpeedHistogram = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "speed_mps"),
+		"Speed of every new report in meters per second",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerHopHistogram = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "hop_length_meters"),
+		"Distance of every hop between reports in meters, after jitter filtering",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// parseBuckets reads a comma separated list of upper bounds
+func parseBuckets(s string) ([]float64, error) {
+	var buckets []float64
+	for _, field := range deleteEmpty(strings.Split(s, ",")) {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bucket %q is not a number", field)
+		}
+		buckets = append(buckets, bound)
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		return nil, fmt.Errorf("buckets %q are not in increasing order", s)
+	}
+	return buckets, nil
+}
+
+// histogram is a hand rolled one, so it can go out as a const metric
+// with everything else
+type histogram struct {
+	buckets []float64
+	counts  map[float64]uint64
+	sum     float64
+	count   uint64
+	created time.Time
+}
+
+// newHistogram ...
+func newHistogram(buckets []float64) *histogram {
+	h := &histogram{buckets: buckets, counts: make(map[float64]uint64), created: time.Now()}
+	for _, bound := range buckets {
+		h.counts[bound] = 0
+	}
+	return h
+}
+
+// observe ...
+func (h *histogram) observe(v float64) {
+	for _, bound := range h.buckets {
+		if v <= bound {
+			h.counts[bound]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// metric ...
+func (h *histogram) metric(desc *prometheus.Desc, id string) prometheus.Metric {
+	return prometheus.MustNewConstHistogramWithCreatedTimestamp(desc, h.count, h.sum, h.counts, h.created, id)
+}
+
+// trackerHistograms ...
+type trackerHistograms struct {
+	speed *histogram
+	hop   *histogram
+}
+
+// histograms of a tracker, made on first use
+func (e *Exporter) histograms(id string) trackerHistograms {
+	h, ok := e.mapOfHistograms[id]
+	if !ok {
+		h = trackerHistograms{
+			speed: newHistogram(e.speedBuckets),
+			hop:   newHistogram(e.hopBuckets),
+		}
+		e.mapOfHistograms[id] = h
+	}
+	return h
+}
+
+// collectHistograms ...
+func (e *Exporter) collectHistograms(ch chan<- prometheus.Metric, id string) {
+	h, ok := e.mapOfHistograms[id]
+	if !ok {
+		return
+	}
+	ch <- h.speed.metric(trackerSpeedHistogram, id)
+	ch <- h.hop.metric(trackerHopHistogram, id)
+}
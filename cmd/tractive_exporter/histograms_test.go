@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Bounds from the flags, which have to go up
+func TestParseBuckets(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []float64
+		err  bool
+	}{
+		{in: "0.5,1,2,3,5,8,13", want: []float64{0.5, 1, 2, 3, 5, 8, 13}},
+		{in: " 5, 10 ,,25", want: []float64{5, 10, 25}},
+		{in: "", want: nil},
+		{in: "1,fast", err: true},
+		{in: "10,5", err: true},
+	} {
+		got, err := parseBuckets(tc.in)
+		if (err != nil) != tc.err || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: %v %v, want %v", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+// Buckets count what's at most their bound
+func TestHistogramObserve(t *testing.T) {
+	h := newHistogram([]float64{1, 2, 5})
+	for _, v := range []float64{0.5, 1, 1.5, 4, 9} {
+		h.observe(v)
+	}
+	if want := map[float64]uint64{1: 2, 2: 3, 5: 4}; !reflect.DeepEqual(h.counts, want) {
+		t.Errorf("counts %v, want %v", h.counts, want)
+	}
+	if h.count != 5 || h.sum != 16 {
+		t.Errorf("count %d sum %g, want 5 and 16", h.count, h.sum)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	historyPageSize    = 100
+	historyMaxPageSize = 1000
+)
+
+// Fields of a trackPoint by their JSON name
+var historyFields = map[string]func(trackPoint) interface{}{
+	"time":  func(p trackPoint) interface{} { return p.Time },
+	"lat":   func(p trackPoint) interface{} { return p.Lat },
+	"lon":   func(p trackPoint) interface{} { return p.Lon },
+	"speed": func(p trackPoint) interface{} { return p.Speed },
+	"alt":   func(p trackPoint) interface{} { return p.Alt },
+}
+
+// historyPage is what /api/v1/trackers/{id}/positions answers with
+type historyPage struct {
+	Positions  []map[string]interface{} `json:"positions"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+	Units      *displayUnits            `json:"units,omitempty"`
+}
+
+// historyTime reads a unix timestamp, an RFC 3339 time or a duration
+// like 6h for that long ago
+func historyTime(s string) (int64, error) {
+	if t, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return time.Now().Add(-d).Unix(), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither a unix timestamp, RFC 3339 nor a duration ago", s)
+	}
+	return t.Unix(), nil
+}
+
+// historyQuery is a parsed request for positions
+type historyQuery struct {
+	from, to int64
+	after    int64
+	limit    int
+	fields   []string
+	units    *displayUnits
+}
+
+// parseHistoryQuery reads from, to, cursor, limit and fields
+func parseHistoryQuery(r *http.Request) (q historyQuery, err error) {
+	values := r.URL.Query()
+	q.to = time.Now().Unix()
+	q.limit = historyPageSize
+
+	if v := values.Get("from"); v != "" {
+		if q.from, err = historyTime(v); err != nil {
+			return q, err
+		}
+	}
+	if v := values.Get("to"); v != "" {
+		if q.to, err = historyTime(v); err != nil {
+			return q, err
+		}
+	}
+
+	// the cursor is opaque to clients, it's the time of the last position sent
+	q.after = q.from - 1
+	if v := values.Get("cursor"); v != "" {
+		after, err := strconv.ParseInt(v, 36, 64)
+		if err != nil {
+			return q, fmt.Errorf("bad cursor")
+		}
+		if after > q.after {
+			q.after = after
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		q.limit, err = strconv.Atoi(v)
+		if err != nil || q.limit < 1 || q.limit > historyMaxPageSize {
+			return q, fmt.Errorf("limit must be between 1 and %d", historyMaxPageSize)
+		}
+	}
+
+	if q.units, err = apiUnits(r); err != nil {
+		return q, err
+	}
+
+	q.fields = []string{"time", "lat", "lon", "speed", "alt"}
+	if v := values.Get("fields"); v != "" {
+		q.fields = deleteEmpty(strings.Split(v, ","))
+	}
+	for _, field := range q.fields {
+		if _, ok := historyFields[field]; !ok {
+			return q, fmt.Errorf("unknown field %s", field)
+		}
+		if *hideCoordinates && (field == "lat" || field == "lon") {
+			return q, fmt.Errorf("coordinates are hidden")
+		}
+	}
+	if *hideCoordinates && values.Get("fields") == "" {
+		q.fields = []string{"time", "speed", "alt"}
+	}
+	return q, nil
+}
+
+// historyTrackerHandler serves /api/v1/history/{tracker}, the same as
+// /api/v1/trackers/{id}/positions
+func (e *Exporter) historyTrackerHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("tracker")
+	if !e.isConfigured(id) {
+		http.NotFound(w, r)
+		return
+	}
+	e.historyHandler(w, r, id)
+}
+
+// historyHandler pages through the positions kept in memory, oldest first
+func (e *Exporter) historyHandler(w http.ResponseWriter, r *http.Request, id string) {
+	q, err := parseHistoryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page := historyPage{Positions: []map[string]interface{}{}, Units: q.units}
+	var last int64
+	for _, point := range e.tracks.points(id, q.after+1) {
+		if point.Time > q.to {
+			break
+		}
+		if len(page.Positions) == q.limit {
+			page.NextCursor = strconv.FormatInt(last, 36)
+			break
+		}
+		last = point.Time
+		if q.units != nil {
+			point.Speed = q.units.speed(point.Speed)
+			point.Alt = int(math.Round(q.units.altitude(float64(point.Alt))))
+		}
+		position := make(map[string]interface{}, len(q.fields))
+		for _, field := range q.fields {
+			position[field] = historyFields[field](point)
+		}
+		page.Positions = append(page.Positions, position)
+	}
+	writeJSON(w, page)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Where they sleep is home, most of the time
+	homeLearnWindow = flag.Duration("home.learn-window", 0,
+		"Learn the home of trackers without one in -config.file from overnight positions over this window, 0 is off")
+	homeNight = flag.String("home.night", "22-6",
+		"Hours in -timezone counted as overnight for -home.learn-window, from-to")
+
+	trackerDistanceFromHome = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "distance_from_home_meters"),
+		"Distance of the last reported position from the tracker's home in meters",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// Nil unless -home.learn-window is set
+var learnedHomes *homeLearner
+
+// Cells of about 150m, a garden and a bit
+const homeCellPrecision = 7
+
+// homeSample is an overnight position
+type homeSample struct {
+	time     int64
+	lat, lon float64
+	cell     string
+}
+
+// homeLearner keeps overnight positions and picks the cell seen most
+type homeLearner struct {
+	sync.Mutex
+	window   time.Duration
+	from, to int
+	samples  map[string][]homeSample
+}
+
+// newHomeLearner ...
+func newHomeLearner(window time.Duration, night string) (*homeLearner, error) {
+	h := &homeLearner{window: window, samples: make(map[string][]homeSample)}
+	if _, err := fmt.Sscanf(night, "%d-%d", &h.from, &h.to); err != nil ||
+		h.from < 0 || h.from > 23 || h.to < 0 || h.to > 23 {
+		return nil, fmt.Errorf("-home.night must be hours like 22-6, not %q", night)
+	}
+	return h, nil
+}
+
+// overnight ...
+func (h *homeLearner) overnight(t time.Time) bool {
+	hour := t.Hour()
+	if h.from <= h.to {
+		return hour >= h.from && hour < h.to
+	}
+	return hour >= h.from || hour < h.to
+}
+
+// add keeps the position if it was reported overnight
+func (h *homeLearner) add(id string, p *Position) {
+	if h == nil || !h.overnight(localTime(p.Time)) {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+
+	samples := append(h.samples[id], homeSample{
+		time: p.Time,
+		lat:  p.Lat,
+		lon:  p.Lon,
+		cell: geohash.EncodeWithPrecision(p.Lat, p.Lon, homeCellPrecision),
+	})
+	oldest := time.Now().Add(-h.window).Unix()
+	for len(samples) > 0 && samples[0].time < oldest {
+		samples = samples[1:]
+	}
+	h.samples[id] = samples
+}
+
+// home is the middle of the cell most overnight positions fall in, with
+// how many that was
+func (h *homeLearner) home(id string) (latLon, int, bool) {
+	if h == nil {
+		return latLon{}, 0, false
+	}
+	h.Lock()
+	defer h.Unlock()
+
+	counts := make(map[string]int)
+	best := ""
+	for _, sample := range h.samples[id] {
+		counts[sample.cell]++
+		if best == "" || counts[sample.cell] > counts[best] {
+			best = sample.cell
+		}
+	}
+	if best == "" {
+		return latLon{}, 0, false
+	}
+
+	var home latLon
+	for _, sample := range h.samples[id] {
+		if sample.cell == best {
+			home.Lat += sample.lat
+			home.Lon += sample.lon
+		}
+	}
+	home.Lat /= float64(counts[best])
+	home.Lon /= float64(counts[best])
+	return home, counts[best], true
+}
+
+// trackerHome is the configured home of a tracker, or the learned one
+func trackerHome(id string) (latLon, bool) {
+	if home := trackerConfig[id].Home; home != nil {
+		return *home, true
+	}
+	home, _, ok := learnedHomes.home(id)
+	return home, ok
+}
+
+// homeHandler serves /api/v1/trackers/{id}/home
+func homeHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if home := trackerConfig[id].Home; home != nil {
+		writeJSON(w, map[string]interface{}{"lat": home.Lat, "lon": home.Lon, "source": "config"})
+		return
+	}
+	home, samples, ok := learnedHomes.home(id)
+	if !ok {
+		http.Error(w, "no home configured or learned yet", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"lat": home.Lat, "lon": home.Lon, "source": "learned", "samples": samples})
+}
+
+// distanceFromHome is -1 for trackers without a home
+func distanceFromHome(id string, p *Position) float64 {
+	home, ok := trackerHome(id)
+	if !ok {
+		return -1
+	}
+	return Distance(home.Lat, home.Lon, p.Lat, p.Lon)
+}
+
+// collectHome ...
+func collectHome(ch chan<- prometheus.Metric, id string, p *Position) {
+	if _, ok := trackerHome(id); !ok {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(
+		trackerDistanceFromHome, prometheus.GaugeValue, distanceFromHome(id, p), id,
+	)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Configured homes win, learned ones come next, -1 without either
+func TestDistanceFromHome(t *testing.T) {
+	config, learned := trackerConfig, learnedHomes
+	defer func() { trackerConfig, learnedHomes = config, learned }()
+
+	trackerConfig = map[string]trackerSettings{"rex": {Home: &latLon{Lat: 52.52, Lon: 13.405}}}
+	learnedHomes = &homeLearner{samples: map[string][]homeSample{
+		"milo": {{lat: 48.2082, lon: 16.3738, cell: "u2edk8u"}},
+	}}
+
+	p := &Position{}
+	p.Lat, p.Lon = 52.521, 13.405
+	for _, tc := range []struct {
+		tracker string
+		want    float64
+	}{
+		{"rex", 111},
+		{"milo", 523000},
+		{"luna", -1},
+	} {
+		if got := distanceFromHome(tc.tracker, p); math.Abs(got-tc.want) > tc.want/100+0.5 {
+			t.Errorf("%s: %.0fm from home, want about %.0fm", tc.tracker, got, tc.want)
+		}
+	}
+}
+
+// Overnight is from-to, across midnight or not
+func TestHomeNight(t *testing.T) {
+	for _, tc := range []struct {
+		night     string
+		overnight []int
+		day       []int
+		err       bool
+	}{
+		{night: "22-6", overnight: []int{22, 23, 0, 5}, day: []int{6, 12, 21}},
+		{night: "1-5", overnight: []int{1, 4}, day: []int{0, 5, 23}},
+		{night: "22", err: true},
+		{night: "22-25", err: true},
+		{night: "evening", err: true},
+	} {
+		h, err := newHomeLearner(time.Hour, tc.night)
+		if (err != nil) != tc.err {
+			t.Errorf("%s: error %v", tc.night, err)
+			continue
+		}
+		if err != nil {
+			if !strings.Contains(err.Error(), "-home.night") {
+				t.Errorf("%s: error %v doesn't say which flag", tc.night, err)
+			}
+			continue
+		}
+		for _, hour := range tc.overnight {
+			if !h.overnight(time.Date(2026, 10, 16, hour, 30, 0, 0, time.UTC)) {
+				t.Errorf("%s: %d:30 should be overnight", tc.night, hour)
+			}
+		}
+		for _, hour := range tc.day {
+			if h.overnight(time.Date(2026, 10, 16, hour, 30, 0, 0, time.UTC)) {
+				t.Errorf("%s: %d:30 shouldn't be overnight", tc.night, hour)
+			}
+		}
+	}
+}
+
+// Home is the middle of the cell with most nights, a night at the vet
+// doesn't move it
+func TestLearnedHome(t *testing.T) {
+	h := &homeLearner{samples: map[string][]homeSample{
+		"rex": {
+			{lat: 52.5200, lon: 13.4050, cell: "u33dc0c"},
+			{lat: 52.4000, lon: 13.1000, cell: "u336xps"},
+			{lat: 52.5202, lon: 13.4052, cell: "u33dc0c"},
+			{lat: 52.5201, lon: 13.4048, cell: "u33dc0c"},
+		},
+	}}
+	home, samples, ok := h.home("rex")
+	if !ok || samples != 3 || math.Abs(home.Lat-52.5201) > 1e-9 || math.Abs(home.Lon-13.405) > 1e-9 {
+		t.Errorf("home %+v from %d samples, want 52.5201,13.405 from 3", home, samples)
+	}
+	if _, _, ok := h.home("milo"); ok {
+		t.Errorf("milo has no nights, and a home")
+	}
+	var none *homeLearner
+	if _, _, ok := none.home("rex"); ok {
+		t.Errorf("a home without -home.learn-window")
+	}
+}
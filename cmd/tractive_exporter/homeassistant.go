@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"path"
+)
+
+var (
+
+	// A drop-in bridge, no YAML on the Home Assistant side
+	homeAssistant = flag.Bool("mqtt.homeassistant", false,
+		"Announce every tracker to Home Assistant through MQTT discovery, as a device_tracker with a battery sensor")
+	homeAssistantPrefix = flag.String("mqtt.homeassistant.prefix", "homeassistant",
+		"Discovery prefix Home Assistant listens on")
+)
+
+// haDevice groups the entities of a tracker
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// haDiscovery is the config message of an entity
+type haDiscovery struct {
+	Name                *string  `json:"name"`
+	UniqueID            string   `json:"unique_id"`
+	StateTopic          string   `json:"state_topic,omitempty"`
+	JSONAttributesTopic string   `json:"json_attributes_topic,omitempty"`
+	SourceType          string   `json:"source_type,omitempty"`
+	DeviceClass         string   `json:"device_class,omitempty"`
+	StateClass          string   `json:"state_class,omitempty"`
+	UnitOfMeasurement   string   `json:"unit_of_measurement,omitempty"`
+	ValueTemplate       string   `json:"value_template,omitempty"`
+	Device              haDevice `json:"device"`
+}
+
+// haAttributes is what the device_tracker reads its position from
+type haAttributes struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  int     `json:"altitude"`
+	Speed     float64 `json:"speed"`
+	Battery   int     `json:"battery_level"`
+	Live      bool    `json:"live"`
+	Time      int64   `json:"time"`
+}
+
+// homeAssistantTopic is next to the tracker's position topic
+func homeAssistantTopic(positionTopic string) string {
+	return path.Join(path.Dir(positionTopic), "homeassistant")
+}
+
+// announce queues the discovery messages of a tracker the first time and
+// when its name changes, they're retained so Home Assistant finds them
+// after its own restarts
+func (m *mqttOutput) announce(id, name, topic string) {
+	if name == "" {
+		name = id
+	}
+	m.Lock()
+	defer m.Unlock()
+	if m.announced[id] == name {
+		return
+	}
+	m.announced[id] = name
+
+	device := haDevice{
+		Identifiers:  []string{"tractive_" + id},
+		Name:         name,
+		Manufacturer: "Tractive",
+		Model:        "GPS tracker",
+	}
+	battery := "Battery"
+	configs := map[string]haDiscovery{
+		"device_tracker": {
+			UniqueID:            "tractive_" + id,
+			JSONAttributesTopic: topic,
+			SourceType:          "gps",
+			Device:              device,
+		},
+		"sensor": {
+			Name:              &battery,
+			UniqueID:          "tractive_" + id + "_battery",
+			StateTopic:        topic,
+			DeviceClass:       "battery",
+			StateClass:        "measurement",
+			UnitOfMeasurement: "%",
+			ValueTemplate:     "{{ value_json.battery_level }}",
+			Device:            device,
+		},
+	}
+	for component, config := range configs {
+		body, err := json.Marshal(config)
+		if err != nil {
+			slog.Error("Home Assistant discovery marshal error", "err", err)
+			continue
+		}
+		m.queue.enqueue(sinkEvent{
+			Event:   "discovery",
+			Tracker: id,
+			Topic:   path.Join(*homeAssistantPrefix, component, "tractive_"+id, "config"),
+			Body:    body,
+		})
+	}
+}
+
+// forget what was announced, a broker without persistence may have lost
+// it, the next positions announce again
+func (m *mqttOutput) forget() {
+	m.Lock()
+	defer m.Unlock()
+	m.announced = make(map[string]string)
+}
+
+// sendHomeAssistant announces the tracker if needed and updates its state
+func (m *mqttOutput) sendHomeAssistant(event positionEvent, name, positionTopic string) {
+	topic := homeAssistantTopic(positionTopic)
+	m.announce(event.Tracker, name, topic)
+
+	p := event.Position
+	body, err := json.Marshal(haAttributes{
+		Latitude:  p.Lat,
+		Longitude: p.Lon,
+		Altitude:  p.Alt,
+		Speed:     p.Speed,
+		Battery:   p.Battery,
+		Live:      p.Live,
+		Time:      p.Time,
+	})
+	if err != nil {
+		slog.Error("Home Assistant state marshal error", "err", err)
+		return
+	}
+	m.queue.enqueue(sinkEvent{Event: "position", Tracker: event.Tracker, Topic: topic, Body: body})
+}
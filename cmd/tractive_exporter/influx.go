@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mmcloughlin/geohash"
+)
+
+var (
+
+	// For the InfluxDB and Grafana crowd
+	influxURL = flag.String("influx.url", "",
+		"InfluxDB 2 (or 1.8+) URL new positions are written to, e.g. http://localhost:8086")
+	influxOrg = flag.String("influx.org", "",
+		"InfluxDB organization")
+	influxBucket = flag.String("influx.bucket", "tractive",
+		"InfluxDB bucket, database/retention-policy on 1.8")
+	influxToken = flag.String("influx.token", os.Getenv("INFLUX_TOKEN"),
+		"InfluxDB API token, better as INFLUX_TOKEN, user:password on 1.8")
+	influxMeasurement = flag.String("influx.measurement", "tractive_position",
+		"Measurement the positions are written to")
+)
+
+// influxOutput writes every new position as a line, with the hop from
+// the previous one
+type influxOutput struct {
+	sync.Mutex
+	queue *sinkQueue
+	last  map[string]*Position
+}
+
+// newInfluxOutput is nil without a URL
+func newInfluxOutput() (*influxOutput, error) {
+	if *influxURL == "" {
+		return nil, nil
+	}
+	u, err := neturl.Parse(strings.TrimRight(*influxURL, "/") + "/api/v2/write")
+	if err != nil {
+		return nil, fmt.Errorf("-influx.url: %v", err)
+	}
+	query := u.Query()
+	query.Set("org", *influxOrg)
+	query.Set("bucket", *influxBucket)
+	query.Set("precision", "s")
+	u.RawQuery = query.Encode()
+
+	slog.Info("Output writes to InfluxDB", "output", "influx", "url", redactURL(*influxURL), "bucket", *influxBucket)
+	sink := influxSink{url: u.String(), token: *influxToken, client: &http.Client{Timeout: *webhookTimeout}}
+	return &influxOutput{
+		queue: newSinkQueue("influx", sink, *sinkRetries),
+		last:  make(map[string]*Position),
+	}, nil
+}
+
+// send queues the line of a new position, name is the tracker's
+func (o *influxOutput) send(event positionEvent, name string) {
+	p := event.Position
+	o.Lock()
+	previous := o.last[event.Tracker]
+	o.last[event.Tracker] = p
+	o.Unlock()
+
+	tags := "tracker=" + influxTag(event.Tracker)
+	if name != "" {
+		tags += ",name=" + influxTag(name)
+	}
+	fields := []string{
+		"lat=" + strconv.FormatFloat(p.Lat, 'f', -1, 64),
+		"lon=" + strconv.FormatFloat(p.Lon, 'f', -1, 64),
+		"speed=" + strconv.FormatFloat(p.Speed, 'f', -1, 64),
+		"alt=" + strconv.Itoa(p.Alt) + "i",
+		"battery=" + strconv.Itoa(p.Battery) + "i",
+		"live=" + strconv.FormatBool(p.Live),
+		"geohash=" + strconv.Quote(geohash.Encode(p.Lat, p.Lon)),
+	}
+
+	// derived from the hop, the reported speed is often 0
+	if previous != nil && p.Time > previous.Time {
+		distance := Distance(previous.Lat, previous.Lon, p.Lat, p.Lon)
+		fields = append(fields,
+			"distance="+strconv.FormatFloat(distance, 'f', 2, 64),
+			"hop_speed="+strconv.FormatFloat(distance/float64(p.Time-previous.Time), 'f', 3, 64),
+		)
+	}
+
+	line := fmt.Sprintf("%s,%s %s %d", influxTag(*influxMeasurement), tags, strings.Join(fields, ","), p.Time)
+	o.queue.enqueue(sinkEvent{Event: "position", Tracker: event.Tracker, Body: []byte(line)})
+}
+
+// influxTag escapes what line protocol wants escaped in tags and names
+func influxTag(s string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(s)
+}
+
+// influxSink writes a whole batch in one request
+type influxSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// deliver ...
+func (s influxSink) deliver(batch []sinkEvent) error {
+	var body bytes.Buffer
+	for _, event := range batch {
+		body.Write(event.Body)
+		body.WriteByte('\n')
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if urlErr, ok := err.(*neturl.Error); ok {
+		return urlErr.Err
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Names change about never
+	infoRefresh = flag.Duration("tractive.info-refresh", 6*time.Hour,
+		"How often the pet name and owner are fetched again from the share's /info")
+
+	trackerInfo = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "tracker_info"),
+		"Pet name and owner of the tracker, always 1, join on tracker for readable dashboards",
+		[]string{"tracker", "name", "owner", "tracker_id"}, nil,
+	)
+)
+
+// infoState is the cached /info of a share
+type infoState struct {
+	info    *Info
+	fetched time.Time
+}
+
+// fetchInfo ...
+func fetchInfo(ctx context.Context, id string) (*Info, error) {
+	path := "public_share/" + id + "/info"
+	status, body, err := api.Get(ctx, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	capture.add(api.URL(path), status, body)
+	if status >= 500 || status == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("HTTP %d %s", status, http.StatusText(status))
+	}
+
+	info := new(Info)
+	if err := decodeTolerant("info", body, info); err != nil {
+		apiStats.fail(id, "decode")
+		return nil, err
+	}
+	if info.Code != 0 {
+		apiStats.fail(id, describeAPIError(&Position{Code: info.Code, Category: info.Category, Message: info.Message}).category)
+		return nil, apiCodeError{code: info.Code, message: info.Message}
+	}
+	return info, nil
+}
+
+// fetchedInfo is one info call, for refreshInfo
+type fetchedInfo struct {
+	info    *Info
+	err     error
+	fetched time.Time
+}
+
+// dueInfos are the trackers whose info is due. Callers hold the mutex.
+func (e *Exporter) dueInfos(trackers []string) []string {
+
+	// shares only, the account API has no such thing
+	var due []string
+	for _, id := range publicShares(trackers) {
+		if time.Since(e.mapOfInfo[id].fetched) > *infoRefresh {
+			due = append(due, id)
+		}
+	}
+	return due
+}
+
+// fetchInfos ...
+func fetchInfos(trackers []string) map[string]fetchedInfo {
+	var mutex sync.Mutex
+	infos := make(map[string]fetchedInfo)
+	fetchParallel(trackers, nil, func(ctx context.Context, id string) {
+		info, err := fetchInfo(ctx, id)
+		mutex.Lock()
+		infos[id] = fetchedInfo{info: info, err: err, fetched: time.Now()}
+		mutex.Unlock()
+	})
+	return infos
+}
+
+// refreshInfo keeps the infos that were fetched, a failed refresh keeps
+// the old one around. Callers hold the mutex.
+func (e *Exporter) refreshInfo(infos map[string]fetchedInfo) {
+	for id, fetched := range infos {
+		state := e.mapOfInfo[id]
+		state.fetched = fetched.fetched
+		var codeErr apiCodeError
+		switch err := fetched.err; {
+		case err == nil:
+			state.info = fetched.info
+			e.updateShare(id, 0, "")
+		case errors.As(err, &codeErr):
+			e.updateShare(id, codeErr.code, codeErr.message)
+			fallthrough
+		default:
+			slog.Warn("Info request failed", "tracker", id, "err", err)
+		}
+		e.mapOfInfo[id] = state
+	}
+}
+
+// collectInfo ...
+func (e *Exporter) collectInfo(ch chan<- prometheus.Metric, id string) {
+	if state := e.mapOfInfo[id]; state.info != nil {
+		ch <- prometheus.MustNewConstMetric(
+			trackerInfo, prometheus.GaugeValue, 1, id, state.info.Name, state.info.OwnerName, state.info.TrackerID,
+		)
+	}
+}
+
+// trackerName is the name from the config file, else from -trackers.names,
+// else the one the share has. Callers hold the mutex.
+func (e *Exporter) trackerName(id string) string {
+	if name := trackerConfig[id].Name; name != "" {
+		return name
+	}
+	if name := flagNames[id]; name != "" {
+		return name
+	}
+	if state := e.mapOfInfo[id]; state.info != nil {
+		return state.info.Name
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+var (
+
+	// The indoor cat doesn't need what the escape artist needs
+	trackerIntervals = flag.String("tractive.intervals", "",
+		"Comma separated tracker=interval overrides of -tractive.poll-interval (or -tractive.min-interval), e.g. 6a7235da65=5m,b6a2d3e8f1=30s, with /live for -tractive.live-interval too, e.g. 6a7235da65=5m/10s")
+	liveInterval = flag.Duration("tractive.live-interval", 0,
+		"Poll interval of trackers while they are in live tracking, when shorter than their own, 0 is off")
+	pollJitter = flag.Duration("tractive.poll-jitter", 0,
+		"Each call for a position waits a random time up to this long, so the trackers aren't all hit at once")
+)
+
+// pollIntervals are the trackers' own intervals, the rest go by the flags
+type pollIntervals struct {
+	trackers map[string]trackerInterval
+	live     time.Duration
+}
+
+// trackerInterval is how often a tracker is polled, and while it's live
+// when live isn't 0
+type trackerInterval struct {
+	every time.Duration
+	live  time.Duration
+}
+
+// parseIntervals reads -tractive.intervals, on top of the intervals from
+// -config.file, and -tractive.live-interval. An interval is 5m, or 5m/10s
+// with the live one.
+func parseIntervals(s string) (pollIntervals, error) {
+	if *liveInterval < 0 {
+		return pollIntervals{}, fmt.Errorf("-tractive.live-interval must not be negative, not %s", *liveInterval)
+	}
+	values := make(map[string]string)
+	for id, t := range trackerConfig {
+		if t.Interval != "" {
+			values[id] = t.Interval
+		}
+	}
+	for id, value := range parseMapping(s) {
+		values[id] = value
+	}
+
+	intervals := pollIntervals{trackers: make(map[string]trackerInterval), live: *liveInterval}
+	for id, value := range values {
+		every, live, hasLive := strings.Cut(value, "/")
+		d, err := time.ParseDuration(every)
+		if err != nil {
+			return pollIntervals{}, fmt.Errorf("interval of %s: %v", id, err)
+		}
+		if d <= 0 {
+			return pollIntervals{}, fmt.Errorf("interval of %s must be more than 0, not %s", id, value)
+		}
+		t := trackerInterval{every: d, live: intervals.live}
+		if hasLive {
+			t.live, err = time.ParseDuration(live)
+			if err != nil {
+				return pollIntervals{}, fmt.Errorf("live interval of %s: %v", id, err)
+			}
+			if t.live <= 0 {
+				return pollIntervals{}, fmt.Errorf("live interval of %s must be more than 0, not %s", id, value)
+			}
+		}
+		intervals.trackers[id] = t
+	}
+	return intervals, nil
+}
+
+// shortest is the shortest interval set, or longest when that's shorter
+func (p pollIntervals) shortest(longest time.Duration) time.Duration {
+	for _, t := range p.trackers {
+		for _, d := range []time.Duration{t.every, t.live} {
+			if d > 0 && d < longest {
+				longest = d
+			}
+		}
+	}
+	if p.live > 0 && p.live < longest {
+		longest = p.live
+	}
+	return longest
+}
+
+// liveOf is the tracker's live interval, 0 when it hasn't one
+func (p pollIntervals) liveOf(id string) time.Duration {
+	if t, ok := p.trackers[id]; ok {
+		return t.live
+	}
+	return p.live
+}
+
+// pollInterval is how long the tracker's last response stays good,
+// longer when the API budget runs low
+func (e *Exporter) pollInterval(id string) time.Duration {
+	return time.Duration(float64(e.baseInterval(id)) * apiBudget.stretch())
+}
+
+// baseInterval ...
+func (e *Exporter) baseInterval(id string) time.Duration {
+	if t, ok := e.intervals.trackers[id]; ok {
+		return t.every
+	}
+	if *pollEvery > 0 {
+		return *pollEvery
+	}
+	return *minInterval
+}
+
+// dueInterval is pollInterval, shorter while the tracker is live and has
+// a live interval. Callers hold the mutex.
+func (e *Exporter) dueInterval(id string) time.Duration {
+	interval := e.pollInterval(id)
+	live := e.intervals.liveOf(id)
+	if p := e.mapOfPollState[id].lastGood; live > 0 && live < interval && p != nil && p.Live {
+		return live
+	}
+	return interval
+}
+
+// jitter is how long a call waits with -tractive.poll-jitter
+func jitter() time.Duration {
+	if *pollJitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(*pollJitter)))
+}
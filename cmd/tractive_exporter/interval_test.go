@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// What -tractive.intervals, interval: in -config.file and
+// -tractive.live-interval take, and what they turn down
+func TestParseIntervals(t *testing.T) {
+	config, live := trackerConfig, *liveInterval
+	defer func() { trackerConfig, *liveInterval = config, live }()
+
+	for _, tc := range []struct {
+		name     string
+		flag     string
+		config   string
+		live     time.Duration
+		want     trackerInterval
+		rejected string
+	}{
+		{name: "every", flag: "abc=5m", want: trackerInterval{every: 5 * time.Minute}},
+		{name: "every and live", flag: "abc=5m/10s", want: trackerInterval{every: 5 * time.Minute, live: 10 * time.Second}},
+		{name: "live from the flag", flag: "abc=5m", live: 30 * time.Second, want: trackerInterval{every: 5 * time.Minute, live: 30 * time.Second}},
+		{name: "own live wins", flag: "abc=5m/10s", live: 30 * time.Second, want: trackerInterval{every: 5 * time.Minute, live: 10 * time.Second}},
+		{name: "from the config", config: "2m", want: trackerInterval{every: 2 * time.Minute}},
+		{name: "flag over the config", flag: "abc=1m", config: "2m", want: trackerInterval{every: time.Minute}},
+		{name: "zero", flag: "abc=0s", rejected: "interval of abc must be more than 0"},
+		{name: "negative", flag: "abc=-1m", rejected: "interval of abc must be more than 0"},
+		{name: "zero in the config", config: "0s", rejected: "interval of abc must be more than 0"},
+		{name: "not a duration", flag: "abc=often", rejected: "interval of abc"},
+		{name: "zero live", flag: "abc=1m/0s", rejected: "live interval of abc must be more than 0"},
+		{name: "live not a duration", flag: "abc=1m/x", rejected: "live interval of abc"},
+		{name: "negative live flag", flag: "abc=1m", live: -time.Second, rejected: "-tractive.live-interval must not be negative"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			trackerConfig = map[string]trackerSettings{"abc": {ID: "abc", Interval: tc.config}}
+			*liveInterval = tc.live
+			intervals, err := parseIntervals(tc.flag)
+			if tc.rejected != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.rejected) {
+					t.Fatalf("error %v, want one with %q", err, tc.rejected)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := intervals.trackers["abc"]; got != tc.want {
+				t.Errorf("interval %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
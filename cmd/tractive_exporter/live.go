@@ -0,0 +1,103 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	liveSessions = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "live", "sessions_total"),
+		"Number of live tracking sessions started",
+		[]string{"tracker"}, nil,
+	)
+
+	liveSessionSeconds = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "live", "session_seconds_total"),
+		"Seconds spent in finished live tracking sessions, they drain the battery",
+		[]string{"tracker"}, nil,
+	)
+
+	liveSessionDuration = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "live", "session_duration_seconds"),
+		"Seconds the current live tracking session has been going, 0 when there is none",
+		[]string{"tracker"}, nil,
+	)
+
+	liveTransitions = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "live", "transitions_total"),
+		"Number of times live tracking was switched on (start) or off (stop)",
+		[]string{"tracker", "direction"}, nil,
+	)
+)
+
+// liveSession follows lt_active of a tracker
+type liveSession struct {
+	active   bool
+	started  int64
+	sessions int64
+	stops    int64
+	seconds  float64
+	created  time.Time
+}
+
+// liveEvent goes to the hooks when a session starts or stops
+type liveEvent struct {
+	Tracker  string `json:"tracker"`
+	Started  int64  `json:"started"`
+	Stopped  int64  `json:"stopped,omitempty"`
+	Duration int64  `json:"duration_seconds,omitempty"`
+}
+
+// updateLive is called with every new report of a tracker, report times
+// are what sessions are measured in
+func (e *Exporter) updateLive(id string, p *Position) {
+	session := e.mapOfLive[id]
+	if session.created.IsZero() {
+		session.created = time.Now()
+	}
+
+	switch {
+	case p.Live && !session.active:
+		session.active = true
+		session.started = p.Time
+		session.sessions++
+		slog.Info("Live tracking started", "tracker", id)
+		e.bus.publish("live_start", id, liveEvent{Tracker: id, Started: p.Time})
+	case !p.Live && session.active:
+		session.active = false
+		duration := p.Time - session.started
+		session.seconds += float64(duration)
+		session.stops++
+		slog.Info("Live tracking stopped", "tracker", id, "after", time.Duration(duration)*time.Second)
+		e.bus.publish("live_stop", id, liveEvent{Tracker: id, Started: session.started, Stopped: p.Time, Duration: duration})
+	}
+	e.mapOfLive[id] = session
+}
+
+// collectLive ...
+func (e *Exporter) collectLive(ch chan<- prometheus.Metric, id string) {
+	session, ok := e.mapOfLive[id]
+	if !ok {
+		return
+	}
+	var current float64
+	if session.active {
+		current = float64(time.Now().Unix() - session.started)
+	}
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+		liveSessions, prometheus.CounterValue, float64(session.sessions), session.created, id,
+	)
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+		liveSessionSeconds, prometheus.CounterValue, session.seconds, session.created, id,
+	)
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+		liveTransitions, prometheus.CounterValue, float64(session.sessions), session.created, id, "start",
+	)
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+		liveTransitions, prometheus.CounterValue, float64(session.stops), session.created, id, "stop",
+	)
+	ch <- prometheus.MustNewConstMetric(liveSessionDuration, prometheus.GaugeValue, current, id)
+}
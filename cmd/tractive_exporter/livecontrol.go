@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+var (
+
+	// Read-only unless asked, this drains batteries
+	enableWrites = flag.Bool("tractive.enable-writes", false,
+		"Allow changing things at Tractive with an account: POST /api/v1/trackers/{id}/live and -live.start-on-leave")
+	liveStartOnLeave = flag.String("live.start-on-leave", "",
+		"Comma separated zones, a tracker leaving one of them gets live tracking switched on, needs -tractive.enable-writes")
+)
+
+// setLive switches live tracking on or off, Tractive says pending until
+// the tracker picked it up
+func (a *tractiveAccount) setLive(ctx context.Context, id string, on bool) (bool, error) {
+	state := "off"
+	if on {
+		state = "on"
+	}
+	var answer struct {
+		Pending bool `json:"pending"`
+		apiErrorFields
+	}
+	if err := a.get(ctx, "live_tracking", "tracker/"+id+"/command/live_tracking/"+state, &answer); err != nil {
+		return false, err
+	}
+	if answer.Code != 0 {
+		return false, apiCodeError{code: answer.Code, message: answer.Message}
+	}
+	slog.Info("Switched live tracking", "tracker", id, "live", on, "pending", answer.Pending)
+	return answer.Pending, nil
+}
+
+// liveRequest is the body of POST /api/v1/trackers/{id}/live
+type liveRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// liveHandler serves POST /api/v1/trackers/{id}/live with {"enabled": true}
+func (e *Exporter) liveHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST {\"enabled\": true} or false", http.StatusMethodNotAllowed)
+		return
+	}
+	if !*enableWrites {
+		http.Error(w, "write operations are off, see -tractive.enable-writes", http.StatusForbidden)
+		return
+	}
+	if !e.isConfigured(id) {
+		http.NotFound(w, r)
+		return
+	}
+	a := accountOf(id)
+	if a == nil {
+		http.Error(w, "switching live tracking needs a Tractive account, not a public share", http.StatusConflict)
+		return
+	}
+
+	var req liveRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil || req.Enabled == nil {
+		http.Error(w, "want {\"enabled\": true} or false", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(withTracker(r.Context(), id), fetchDeadline())
+	defer cancel()
+	pending, err := a.setLive(ctx, id, *req.Enabled)
+	entry := auditRequest(r, "live_tracking", id)
+	entry.Detail = fmt.Sprintf("enabled=%t", *req.Enabled)
+	entry.Result = auditResult(err)
+	audit.record(entry)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Tractive said no: %v", err), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"tracker": id, "live": *req.Enabled, "pending": pending})
+}
+
+// subscribeLiveControl switches live tracking on when a tracker with an
+// account leaves one of -live.start-on-leave, the answer isn't waited for
+func (e *Exporter) subscribeLiveControl() error {
+	zones := deleteEmpty(strings.Split(*liveStartOnLeave, ","))
+	if len(zones) == 0 {
+		return nil
+	}
+	if !*enableWrites {
+		return fmt.Errorf("-live.start-on-leave needs -tractive.enable-writes")
+	}
+	watched := make(map[string]bool)
+	for _, zone := range zones {
+		watched[strings.TrimSpace(zone)] = true
+	}
+	e.bus.subscribe("zone", func(event busEvent) {
+		zone := event.Payload.(zoneEvent)
+		a := accountOf(zone.Tracker)
+		if zone.Status != "left" || !watched[zone.Zone] || a == nil {
+			return
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(withTracker(context.Background(), zone.Tracker), fetchDeadline())
+			defer cancel()
+			_, err := a.setLive(ctx, zone.Tracker, true)
+			audit.record(auditEntry{Actor: "live.start-on-leave", Action: "live_tracking", Tracker: zone.Tracker,
+				Detail: "enabled=true, left " + zone.Zone, Result: auditResult(err)})
+			if err != nil {
+				slog.Error("Could not switch live tracking on", "tracker", zone.Tracker, "zone", zone.Zone, "err", err)
+			}
+		}()
+	})
+	return nil
+}
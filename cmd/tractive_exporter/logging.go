@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+var (
+
+	// journald doesn't need every position twice a minute
+	logLevel = flag.String("log.level", "info",
+		"Only log messages with this level or above: debug, info, warn or error. Response bodies are logged at debug")
+	logFormat = flag.String("log.format", "text",
+		"Log format: text or json")
+)
+
+// setupLogging makes slog the default, the standard log package ends up
+// there too at info
+func setupLogging() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return fmt.Errorf("-log.level: %v", err)
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch *logFormat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		return fmt.Errorf("-log.format must be text or json, not %q", *logFormat)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
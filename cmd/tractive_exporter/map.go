@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"time"
+)
+
+var (
+
+	// Where is the dog right now, without Grafana
+	webMap = flag.Bool("web.map", false,
+		"Serve a map of the current positions and recent trails at /map")
+	mapTileURL = flag.String("web.map.tile-url", "https://tile.openstreetmap.org/{z}/{x}/{y}.png",
+		"Tile server of the map, in Leaflet's {z}/{x}/{y} form")
+	mapAttribution = flag.String("web.map.attribution", `&copy; <a href="https://www.openstreetmap.org/copyright">OpenStreetMap</a> contributors`,
+		"Attribution the tile server asks for, HTML")
+	mapTrail = flag.Duration("web.map.trail", 24*time.Hour,
+		"How far back the trails on the map go, 0 for none")
+	mapRefresh = flag.Duration("web.map.refresh", 30*time.Second,
+		"How often the map reloads the positions")
+)
+
+// mapPage is what map.html gets
+type mapPage struct {
+	ExternalPath string
+	TileURL      string
+	Attribution  string
+	Trail        string
+	Refresh      int64
+	Units        displayUnits
+}
+
+// mapHandler serves /map, a Leaflet page fed by /api/v1/positions.geojson
+func (e *Exporter) mapHandler(w http.ResponseWriter, r *http.Request) {
+	if *hideCoordinates {
+		http.Error(w, "coordinates are hidden", http.StatusForbidden)
+		return
+	}
+	page := mapPage{
+		ExternalPath: webExternalPath(),
+		TileURL:      *mapTileURL,
+		Attribution:  *mapAttribution,
+		Refresh:      mapRefresh.Milliseconds(),
+		Units:        pageUnits(w, r),
+	}
+	if *mapTrail > 0 {
+		page.Trail = mapTrail.String()
+	}
+	renderTemplate(w, "map.html", page)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// For the Pi Zero in the cupboard
+	memoryBudget = flag.Int("memory.budget", 0,
+		"MiB the exporter tries to stay under by keeping less history, forgetting old geohash series and coarsening geohashes, 0 is no budget")
+	memoryCheckInterval = flag.Duration("memory.check-interval", 30*time.Second,
+		"How often collections check memory against -memory.budget")
+
+	memoryBudgetBytes = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "memory", "budget_bytes"),
+		"The -memory.budget in bytes",
+		nil, nil,
+	)
+
+	memoryUsedBytes = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "memory", "used_bytes"),
+		"Memory the Go runtime holds from the OS in bytes, what counts against the budget",
+		nil, nil,
+	)
+
+	memoryDegradations = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "memory", "degradations_total"),
+		"Number of steps taken to stay under the budget, by what was given up",
+		[]string{"step"}, nil,
+	)
+
+	memoryGeohashReduction = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "memory", "geohash_precision_reduction"),
+		"Characters taken off every geohash label to stay under the budget",
+		nil, nil,
+	)
+)
+
+// Above this share of the budget a step is taken, one per check
+const memoryHighWater = 0.8
+
+// Least a degraded exporter keeps
+const (
+	minDegradedRetention = time.Hour
+	minDegradedPrecision = 4
+)
+
+// memoryGuard gives things up, one step per check, while the exporter is
+// over the budget. There's no way back short of a restart, what was
+// dropped is gone anyway.
+type memoryGuard struct {
+	budget       uint64
+	used         uint64
+	checked      time.Time
+	degradations map[string]int64
+	created      time.Time
+}
+
+// Set up in main, no budget while nil
+var memory *memoryGuard
+
+// Subtracted from every geohash precision, raised by the memory guard
+var geohashReduction uint
+
+// newMemoryGuard is nil without a budget, with one the GC knows it too
+func newMemoryGuard() *memoryGuard {
+	if *memoryBudget <= 0 {
+		return nil
+	}
+	budget := uint64(*memoryBudget) << 20
+	debug.SetMemoryLimit(int64(budget * 9 / 10))
+	slog.Info("Memory budget", "mib", *memoryBudget)
+	return &memoryGuard{budget: budget, degradations: make(map[string]int64), created: time.Now()}
+}
+
+// memoryInUse is what the runtime has from the OS and not given back
+func memoryInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.Sys - stats.HeapReleased
+}
+
+// enforceMemoryBudget takes the next step when over the budget, at most
+// every -memory.check-interval. Callers hold the mutex.
+func (e *Exporter) enforceMemoryBudget() {
+	m := memory
+	if m == nil || time.Since(m.checked) < *memoryCheckInterval {
+		return
+	}
+	m.checked = time.Now()
+	m.used = memoryInUse()
+	if float64(m.used) < memoryHighWater*float64(m.budget) {
+		return
+	}
+
+	// cheapest to give up first: old history, old geohashes, then detail
+	var step string
+	switch {
+	case e.tracks.retention > minDegradedRetention:
+		retention := e.tracks.retention / 2
+		if retention < minDegradedRetention {
+			retention = minDegradedRetention
+		}
+		e.tracks.shrink(retention)
+		step = "history"
+		slog.Warn("Memory budget: keeping less history", "used_mib", m.used>>20, "retention", retention)
+	case len(e.mapOfUniqueGeoStates)+len(e.mapOfAreaGeoStates) > 2*len(e.trackers()):
+		evicted := evictGeohashes(e.mapOfUniqueGeoStates, len(e.mapOfUniqueGeoStates)/2) +
+			evictGeohashes(e.mapOfAreaGeoStates, len(e.mapOfAreaGeoStates)/2)
+		step = "geohashes"
+		slog.Warn("Memory budget: forgot the oldest geohash series", "used_mib", m.used>>20, "evicted", evicted)
+	case e.geohashesAboveMinimum():
+		geohashReduction++
+		step = "precision"
+		slog.Warn("Memory budget: coarser geohashes", "used_mib", m.used>>20, "characters_less", geohashReduction)
+	default:
+		slog.Warn("Memory budget: over it with nothing left to give up", "used_mib", m.used>>20, "budget_mib", m.budget>>20)
+		return
+	}
+	m.degradations[step]++
+	debug.FreeOSMemory()
+}
+
+// geohashesAboveMinimum is whether any tracker's geohash can get coarser
+func (e *Exporter) geohashesAboveMinimum() bool {
+	for _, id := range e.trackers() {
+		if trackerGeohashPrecision(id) > minDegradedPrecision {
+			return true
+		}
+	}
+	return false
+}
+
+// evictGeohashes forgets the geohashes reported longest ago until keep
+// are left and returns how many went. A forgotten one counts from 1 with
+// a new created timestamp if the tracker comes back.
+func evictGeohashes(states map[uniqueGeoStates]uniqueGeoStatesValue, keep int) int {
+	if len(states) <= keep {
+		return 0
+	}
+	keys := make([]uniqueGeoStates, 0, len(states))
+	for key := range states {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return states[keys[i]].lastTimestamp < states[keys[j]].lastTimestamp })
+	evicted := len(keys) - keep
+	for _, key := range keys[:evicted] {
+		delete(states, key)
+	}
+	return evicted
+}
+
+// collect ...
+func (m *memoryGuard) collect(ch chan<- prometheus.Metric) {
+	if m == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(memoryBudgetBytes, prometheus.GaugeValue, float64(m.budget))
+	ch <- prometheus.MustNewConstMetric(memoryUsedBytes, prometheus.GaugeValue, float64(m.used))
+	for _, step := range []string{"history", "geohashes", "precision"} {
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(memoryDegradations, prometheus.CounterValue, float64(m.degradations[step]), m.created, step)
+	}
+	ch <- prometheus.MustNewConstMetric(memoryGeohashReduction, prometheus.GaugeValue, float64(geohashReduction))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+
+	// Migration to the names Prometheus folks would approve of
+	metricsSet = flag.String("metrics.set", "both",
+		"Which metric names to expose: v1 (original), v2 (base units and suffixes) or both")
+)
+
+// v2Metric is the new name for an original metric, none when desc is nil
+type v2Metric struct {
+	desc   *prometheus.Desc
+	labels []string
+	scale  float64
+}
+
+// newV2Metric ...
+func newV2Metric(name, help string, labels []string, scale float64) v2Metric {
+	return v2Metric{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName("tractive", "", name), help, labels, nil,
+		),
+		labels: labels,
+		scale:  scale,
+	}
+}
+
+// Original desc to its v2 counterpart, anything not in here is fine as is
+var metricsV2 = map[*prometheus.Desc]v2Metric{
+	lastReceivedTime: newV2Metric("last_report_timestamp_seconds",
+		"Timestamp of the last reported position in seconds since epoch", []string{"tracker"}, 1),
+	lastReceivedAgeSeconds: newV2Metric("last_report_age_seconds",
+		"Age of the last reported position in seconds", []string{"tracker"}, 1),
+	trackerLatitude: newV2Metric("latitude_degrees",
+		"Latitude of the tracker in degrees", []string{"tracker"}, 1),
+	trackerLongitude: newV2Metric("longitude_degrees",
+		"Longitude of the tracker in degrees", []string{"tracker"}, 1),
+	trackerGeohash: newV2Metric("geohash_reports_total",
+		"Number of reports per geohash", []string{"tracker", "geohash"}, 1),
+	trackerDistance: newV2Metric("hop_distance_meters",
+		"Distance from the previous location in meters", []string{"tracker"}, 1),
+	trackerDistanceInterval: newV2Metric("hop_interval_seconds",
+		"Seconds in which the distance from the previous location was done", []string{"tracker"}, 1),
+	trackerDistanceRaw: newV2Metric("hop_raw_distance_meters",
+		"Distance from the previous location before jitter filtering in meters", []string{"tracker"}, 1),
+	trackerSpeed: newV2Metric("speed_meters_per_second",
+		"Speed of the tracker in meters per second", []string{"tracker"}, 1),
+	trackerAltitude: newV2Metric("altitude_meters",
+		"Altitude of the tracker in meters", []string{"tracker"}, 1),
+	trackerIsLive: newV2Metric("live_tracking_active",
+		"Whether live tracking is on (1) or off (0)", []string{"tracker"}, 1),
+
+	// a gauge of the last code hides every error between two scrapes
+	apiIsPissed: {},
+	trackerBattery: newV2Metric("battery_ratio",
+		"Battery level of the tracker, 0 to 1", []string{"tracker"}, 0.01),
+	trackerLastSuccessfulPoll: newV2Metric("last_successful_poll_timestamp_seconds",
+		"Timestamp of the last poll that returned a position in seconds since epoch", []string{"tracker"}, 1),
+}
+
+// describeV2 ...
+func describeV2(ch chan<- *prometheus.Desc) {
+	for _, m := range metricsV2 {
+		if m.desc != nil {
+			ch <- m.desc
+		}
+	}
+}
+
+// translateMetric returns what should be exposed for m given the selected set
+func translateMetric(m prometheus.Metric) []prometheus.Metric {
+	v2, ok := metricsV2[m.Desc()]
+	if !ok || *metricsSet == "v1" {
+		return []prometheus.Metric{m}
+	}
+
+	var out []prometheus.Metric
+	if *metricsSet != "v2" {
+		out = append(out, m)
+	}
+	if v2.desc == nil {
+		return out
+	}
+
+	// read it back, labels come sorted by name so match them up
+	pb := &dto.Metric{}
+	if err := m.Write(pb); err != nil {
+		slog.Error("Could not translate metric", "err", err)
+		return out
+	}
+	byName := make(map[string]string)
+	for _, l := range pb.Label {
+		byName[l.GetName()] = l.GetValue()
+	}
+	values := make([]string, len(v2.labels))
+	for i, l := range v2.labels {
+		values[i] = byName[l]
+	}
+
+	if pb.Counter != nil && pb.Counter.CreatedTimestamp != nil {
+		return append(out, prometheus.MustNewConstMetricWithCreatedTimestamp(v2.desc, prometheus.CounterValue,
+			pb.GetCounter().GetValue()*v2.scale, pb.Counter.CreatedTimestamp.AsTime(), values...))
+	}
+
+	valueType, value := prometheus.GaugeValue, pb.GetGauge().GetValue()
+	if pb.Counter != nil {
+		valueType, value = prometheus.CounterValue, pb.GetCounter().GetValue()
+	}
+	return append(out, prometheus.MustNewConstMetric(v2.desc, valueType, value*v2.scale, values...))
+}
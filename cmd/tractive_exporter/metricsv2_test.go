@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// exposed is a metric as a scrape would show it, name{labels} value
+func exposed(t *testing.T, m prometheus.Metric) string {
+	pb := &dto.Metric{}
+	if err := m.Write(pb); err != nil {
+		t.Fatal(err)
+	}
+	desc := m.Desc().String()
+	name := desc[strings.Index(desc, `fqName: "`)+9:]
+	name = name[:strings.Index(name, `"`)]
+
+	var labels []string
+	for _, l := range pb.Label {
+		labels = append(labels, l.GetName()+"="+l.GetValue())
+	}
+	value := pb.GetGauge().GetValue()
+	if pb.Counter != nil {
+		value = pb.GetCounter().GetValue()
+	}
+	return name + "{" + strings.Join(labels, ",") + "} " + strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Which names each set exposes, v2 scales to base units and keeps labels
+// matched up by name
+func TestTranslateMetric(t *testing.T) {
+	set := *metricsSet
+	defer func() { *metricsSet = set }()
+
+	other := prometheus.NewDesc("tractive_other", "Not translated", []string{"tracker"}, nil)
+	for _, tc := range []struct {
+		set    string
+		metric prometheus.Metric
+		want   []string
+	}{
+		{"v1", prometheus.MustNewConstMetric(trackerBattery, prometheus.GaugeValue, 80, "rex"),
+			[]string{"tractive_battery_level{tracker=rex} 80"}},
+		{"v2", prometheus.MustNewConstMetric(trackerBattery, prometheus.GaugeValue, 80, "rex"),
+			[]string{"tractive_battery_ratio{tracker=rex} 0.8"}},
+		{"both", prometheus.MustNewConstMetric(trackerBattery, prometheus.GaugeValue, 80, "rex"),
+			[]string{"tractive_battery_level{tracker=rex} 80", "tractive_battery_ratio{tracker=rex} 0.8"}},
+		{"v2", prometheus.MustNewConstMetric(trackerGeohash, prometheus.CounterValue, 3, "rex", "u2edk"),
+			[]string{"tractive_geohash_reports_total{geohash=u2edk,tracker=rex} 3"}},
+		{"v2", prometheus.MustNewConstMetric(other, prometheus.GaugeValue, 1, "rex"),
+			[]string{"tractive_other{tracker=rex} 1"}},
+	} {
+		*metricsSet = tc.set
+		var got []string
+		for _, m := range translateMetric(tc.metric) {
+			got = append(got, exposed(t, m))
+		}
+		if strings.Join(got, " | ") != strings.Join(tc.want, " | ") {
+			t.Errorf("%s %s: got %q, want %q", tc.set, tc.metric.Desc(), got, tc.want)
+		}
+	}
+}
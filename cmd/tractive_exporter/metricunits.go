@@ -0,0 +1,144 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// metricUnit is a unit a metric name can end in and how HELP says it
+type metricUnit struct {
+	unit  string
+	words string
+}
+
+// metricUnits is the one table of units, longest first so
+// meters_per_second isn't taken for seconds. A family ending in one of
+// them (before _total) gets it as OpenMetrics unit metadata.
+var metricUnits = []metricUnit{
+	{unit: "meters_per_second", words: "meters per second"},
+	{unit: "kmh", words: "km/h"},
+	{unit: "mph", words: "mph"},
+	{unit: "kilometers", words: "kilometers"},
+	{unit: "miles", words: "miles"},
+	{unit: "seconds", words: "seconds"},
+	{unit: "meters", words: "meters"},
+	{unit: "degrees", words: "degrees"},
+	{unit: "bytes", words: "bytes"},
+	{unit: "ratio", words: "0 to 1"},
+}
+
+// v1Units are the original names from before unit suffixes. OpenMetrics
+// only takes a unit that ends the name, so these only say it in HELP.
+var v1Units = map[string]string{
+	"tractive_age":                            "seconds",
+	"tractive_altitude":                       "meters",
+	"tractive_altitude_raw":                   "meters",
+	"tractive_distance":                       "meters",
+	"tractive_distance_raw":                   "meters",
+	"tractive_latitude":                       "degrees",
+	"tractive_longitude":                      "degrees",
+	"tractive_speed":                          "meters per second",
+	"tractive_last_time":                      "seconds",
+	"tractive_last_successful_poll_timestamp": "seconds",
+}
+
+// unitOf is the unit a family's name ends in
+func unitOf(family *dto.MetricFamily) (metricUnit, bool) {
+	name := family.GetName()
+	if family.GetType() == dto.MetricType_COUNTER {
+		name = strings.TrimSuffix(name, "_total")
+	}
+	for _, u := range metricUnits {
+		if strings.HasSuffix(name, "_"+u.unit) {
+			return u, true
+		}
+	}
+	return metricUnit{}, false
+}
+
+// Families already warned about, once is enough
+var unitWarned sync.Map
+
+// unitGatherer sets the unit of every family from its name and warns
+// about a HELP that doesn't say it. It goes last, after renaming, so it
+// judges the names that are exposed.
+type unitGatherer struct {
+	gatherer prometheus.Gatherer
+}
+
+// Gather ...
+func (g unitGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	for _, family := range families {
+		words := v1Units[family.GetName()]
+		if u, ok := unitOf(family); ok {
+			unit := u.unit
+			family.Unit = &unit
+			words = u.words
+		}
+		if words == "" || !strings.HasPrefix(family.GetName(), "tractive_") {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(family.GetHelp()), words) {
+			if _, warned := unitWarned.LoadOrStore(family.GetName(), true); !warned {
+				slog.Warn("Metric help doesn't say its unit", "metric", family.GetName(), "unit", words)
+			}
+		}
+	}
+	return families, err
+}
+
+// exposedGatherer is what every endpoint and push serves: filtered,
+// grouped, renamed, with units and then in -metrics.namespace
+func exposedGatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	return newStaticGatherer(unitGatherer{gatherer: newRenamingGatherer(newNamingGatherer(newGroupingGatherer(newFilteringGatherer(gatherer))))})
+}
+
+// metricsHandler is promhttp's handler, except that OpenMetrics scrapes
+// get the # UNIT lines promhttp leaves out
+func metricsHandler(gatherer prometheus.Gatherer) http.Handler {
+	fallback := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics:                   true,
+		EnableOpenMetricsTextCreatedSamples: true,
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		if format.FormatType() != expfmt.TypeOpenMetrics {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		families, err := gatherer.Gather()
+		if err != nil {
+			http.Error(w, "An error has occurred while serving metrics:\n\n"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", string(format))
+		var body io.Writer = w
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			body = gz
+		}
+		encoder := expfmt.NewEncoder(body, format, expfmt.WithCreatedLines(), expfmt.WithUnit())
+		for _, family := range families {
+			if err := encoder.Encode(family); err != nil {
+				slog.Error("Could not write the metrics", "err", err)
+				return
+			}
+		}
+		if closer, ok := encoder.(expfmt.Closer); ok {
+			closer.Close()
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"text/template"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+var (
+
+	// Node-RED and Home Assistant speak MQTT, not PromQL
+	mqttBroker = flag.String("mqtt.broker", "",
+		"MQTT broker new positions are published to, e.g. tcp://localhost:1883, ssl://... or ws://...")
+	mqttTopic = flag.String("mqtt.topic", "tractive/{{ .Tracker }}/position",
+		"Topic per tracker as a text/template with .Tracker and .Name")
+	mqttUsername = flag.String("mqtt.username", "",
+		"MQTT user name")
+	mqttPassword = flag.String("mqtt.password", os.Getenv("MQTT_PASSWORD"),
+		"MQTT password, better as MQTT_PASSWORD")
+	mqttClientID = flag.String("mqtt.client-id", "tractive_exporter",
+		"MQTT client ID, unique per broker")
+	mqttQoS = flag.Int("mqtt.qos", 1,
+		"MQTT QoS of the positions: 0, 1 or 2")
+	mqttRetain = flag.Bool("mqtt.retain", true,
+		"Publish retained, so new subscribers get the latest position right away")
+	mqttTimeout = flag.Duration("mqtt.timeout", 10*time.Second,
+		"How long connecting and a publish may take")
+)
+
+// mqttTopicData is what -mqtt.topic gets
+type mqttTopicData struct {
+	Tracker string
+	Name    string
+}
+
+// mqttOutput publishes every new position, as JSON like the webhook's
+type mqttOutput struct {
+	sync.Mutex
+	topic     *template.Template
+	body      *template.Template
+	queue     *sinkQueue
+	client    mqtt.Client
+	announced map[string]string
+}
+
+// newMQTTOutput is nil without a broker. The broker doesn't have to be up,
+// the client keeps trying and the queue holds the positions meanwhile.
+func newMQTTOutput() (*mqttOutput, error) {
+	if *mqttBroker == "" {
+		return nil, nil
+	}
+	if *mqttQoS < 0 || *mqttQoS > 2 {
+		return nil, fmt.Errorf("-mqtt.qos must be 0, 1 or 2, not %d", *mqttQoS)
+	}
+	topic, err := template.New("topic").Parse(*mqttTopic)
+	if err != nil {
+		return nil, fmt.Errorf("-mqtt.topic: %v", err)
+	}
+	body, err := parseEventTemplate("mqtt", defaultWebhookTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &mqttOutput{topic: topic, body: body, announced: make(map[string]string)}
+	options := mqtt.NewClientOptions().
+		AddBroker(*mqttBroker).
+		SetClientID(*mqttClientID).
+		SetUsername(*mqttUsername).
+		SetPassword(*mqttPassword).
+		SetConnectTimeout(*mqttTimeout).
+		SetWriteTimeout(*mqttTimeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(func(mqtt.Client) {
+			slog.Info("Connected to the MQTT broker", "broker", redactURL(*mqttBroker))
+			m.forget()
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			slog.Warn("Lost the MQTT broker", "broker", redactURL(*mqttBroker), "err", err)
+		})
+	m.client = mqtt.NewClient(options)
+	m.queue = newSinkQueue("mqtt", mqttSink{client: m.client, qos: byte(*mqttQoS), retain: *mqttRetain}, *sinkRetries)
+	m.client.Connect()
+	return m, nil
+}
+
+// selftest waits for the broker and publishes the text, not retained,
+// next to where the positions of a tracker named selftest would go
+func (m *mqttOutput) selftest(text string) error {
+	for deadline := time.Now().Add(*mqttTimeout); !m.client.IsConnectionOpen(); time.Sleep(100 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			return errors.New("not connected to the broker")
+		}
+	}
+	var topic bytes.Buffer
+	if err := m.topic.Execute(&topic, mqttTopicData{Tracker: "selftest", Name: "selftest"}); err != nil {
+		return err
+	}
+	body, _ := json.Marshal(map[string]interface{}{"tracker": "selftest", "text": text, "time": time.Now().Unix()})
+	return mqttSink{client: m.client, qos: byte(*mqttQoS)}.deliver([]sinkEvent{{Event: "selftest", Topic: topic.String(), Body: body}})
+}
+
+// send renders topic and body and queues them, name is the tracker's
+func (m *mqttOutput) send(event positionEvent, name string) {
+	var topic, body bytes.Buffer
+	if err := m.topic.Execute(&topic, mqttTopicData{Tracker: event.Tracker, Name: name}); err != nil {
+		slog.Error("MQTT topic template error", "err", err)
+		return
+	}
+	if err := m.body.Execute(&body, event); err != nil {
+		slog.Error("MQTT template error", "err", err)
+		return
+	}
+	m.queue.enqueue(sinkEvent{Event: "position", Tracker: event.Tracker, Topic: topic.String(), Body: body.Bytes()})
+	if *homeAssistant {
+		m.sendHomeAssistant(event, name, topic.String())
+	}
+}
+
+// mqttSink publishes every event of a batch to its topic, discovery
+// messages always retained
+type mqttSink struct {
+	client mqtt.Client
+	qos    byte
+	retain bool
+}
+
+// deliver ...
+func (s mqttSink) deliver(batch []sinkEvent) error {
+	if !s.client.IsConnectionOpen() {
+		return errors.New("not connected to the broker")
+	}
+	for _, event := range batch {
+		token := s.client.Publish(event.Topic, s.qos, s.retain || event.Event == "discovery", event.Body)
+		if !token.WaitTimeout(*mqttTimeout) {
+			return errors.New("publish timed out")
+		}
+		if err := token.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
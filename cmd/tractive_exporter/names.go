@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+
+	// Share IDs mean nothing on a dashboard
+	trackersNames = flag.String("trackers.names", "",
+		"Names for the trackers, e.g. 6a7235da65=Rex,2d1b273ec8=Milo, every per-tracker metric gets a name label. Notifications and pages use them too, unless -config.file names the tracker.")
+)
+
+// flagNames are -trackers.names, set up in main
+var flagNames map[string]string
+
+// namingGatherer adds the name label to every metric with a tracker label
+// and without a name already
+type namingGatherer struct {
+	gatherer prometheus.Gatherer
+}
+
+// Gather ...
+func (g namingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	name := "name"
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			id, ok := labelValue(metric, "tracker")
+			if !ok {
+				continue
+			}
+			if _, ok := labelValue(metric, name); ok {
+				continue
+			}
+			value := flagNames[id]
+
+			// labels stay sorted by name
+			at := sort.Search(len(metric.Label), func(i int) bool { return metric.Label[i].GetName() > name })
+			metric.Label = append(metric.Label[:at], append([]*dto.LabelPair{{Name: &name, Value: &value}}, metric.Label[at:]...)...)
+		}
+	}
+	return families, err
+}
+
+// newNamingGatherer only wraps with -trackers.names
+func newNamingGatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	if len(flagNames) == 0 {
+		return gatherer
+	}
+	return namingGatherer{gatherer: gatherer}
+}
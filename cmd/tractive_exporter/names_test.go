@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics of a tracker get its name, one that has a name already keeps it
+func TestNamingGatherer(t *testing.T) {
+	names := flagNames
+	defer func() { flagNames = names }()
+	flagNames = map[string]string{"rex": "Rex"}
+
+	registry := prometheus.NewRegistry()
+	latitude := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tractive_latitude", Help: "Latitude"}, []string{"tracker"})
+	latitude.WithLabelValues("rex").Set(52.5)
+	latitude.WithLabelValues("milo").Set(48.2)
+	info := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tractive_info", Help: "Info"}, []string{"name", "tracker"})
+	info.WithLabelValues("Rexy", "rex").Set(1)
+	calls := prometheus.NewGauge(prometheus.GaugeOpts{Name: "tractive_api_calls", Help: "Calls"})
+	registry.MustRegister(latitude, info, calls)
+
+	families, err := newNamingGatherer(registry).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			var labels []string
+			for _, l := range metric.Label {
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+			got = append(got, family.GetName()+"{"+strings.Join(labels, ",")+"}")
+		}
+	}
+	want := "tractive_api_calls{} tractive_info{name=Rexy,tracker=rex} tractive_latitude{name=,tracker=milo} tractive_latitude{name=Rex,tracker=rex}"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+
+	// Several exporters into one Prometheus, the cabin's Rex isn't the city's
+	metricsNamespace = flag.String("metrics.namespace", "tractive",
+		"Prefix of the metric names instead of tractive, e.g. cabin gives cabin_latitude")
+	metricsLabels = flag.String("metrics.labels", "",
+		"Comma separated labels every metric gets, e.g. site=cabin,household=smith, next to labels: in -config.file")
+)
+
+// Labels from labels: in -config.file, -metrics.labels wins
+var configLabels map[string]string
+
+// staticLabels are what every metric gets, set up in main
+var staticLabels map[string]string
+
+// Labels the exporter sets itself, a static one would hide them
+var ownLabels = map[string]bool{"tracker": true, "name": true, "job": true, "instance": true}
+
+var metricName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// setupStaticLabels checks -metrics.namespace and merges the labels
+func setupStaticLabels() error {
+	if !metricName.MatchString(*metricsNamespace) {
+		return fmt.Errorf("-metrics.namespace %q isn't a valid metric name prefix", *metricsNamespace)
+	}
+	labels := make(map[string]string)
+	for name, value := range configLabels {
+		labels[name] = value
+	}
+	for name, value := range parseMapping(*metricsLabels) {
+		labels[name] = value
+	}
+	for name := range labels {
+		if !metricName.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("%q isn't a valid label name", name)
+		}
+		if ownLabels[name] {
+			return fmt.Errorf("label %s is the exporter's own, pick another name", name)
+		}
+	}
+	staticLabels = labels
+	return nil
+}
+
+// namespaced is name with -metrics.namespace instead of tractive
+func namespaced(name string) string {
+	if rest, ok := strings.CutPrefix(name, "tractive_"); ok && *metricsNamespace != "tractive" {
+		return *metricsNamespace + "_" + rest
+	}
+	return name
+}
+
+// staticGatherer renames to -metrics.namespace and adds the static labels,
+// a metric that has one of them already keeps its own
+type staticGatherer struct {
+	gatherer prometheus.Gatherer
+	labels   []*dto.LabelPair
+}
+
+// Gather ...
+func (g staticGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	for _, family := range families {
+		name := namespaced(family.GetName())
+		family.Name = &name
+		for _, metric := range family.Metric {
+			for _, static := range g.labels {
+				if _, ok := labelValue(metric, static.GetName()); ok {
+					continue
+				}
+
+				// labels stay sorted by name
+				at := sort.Search(len(metric.Label), func(i int) bool { return metric.Label[i].GetName() > static.GetName() })
+				metric.Label = append(metric.Label[:at], append([]*dto.LabelPair{static}, metric.Label[at:]...)...)
+			}
+		}
+	}
+	return families, err
+}
+
+// newStaticGatherer only wraps with -metrics.namespace or static labels
+func newStaticGatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	if *metricsNamespace == "tractive" && len(staticLabels) == 0 {
+		return gatherer
+	}
+	g := staticGatherer{gatherer: gatherer}
+	for name, value := range staticLabels {
+		g.labels = append(g.labels, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	return g
+}
+
+// staticPairs are the static labels for series built by hand
+func staticPairs() [][2]string {
+	var pairs [][2]string
+	for name, value := range staticLabels {
+		pairs = append(pairs, [2]string{name, value})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return pairs
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Bad prefixes and labels stop the start, good ones end up on every metric
+func TestSetupStaticLabels(t *testing.T) {
+	namespace, labels, fromConfig, static := *metricsNamespace, *metricsLabels, configLabels, staticLabels
+	defer func() {
+		*metricsNamespace, *metricsLabels, configLabels, staticLabels = namespace, labels, fromConfig, static
+	}()
+
+	for _, tc := range []struct {
+		namespace string
+		labels    string
+		config    map[string]string
+		want      string
+		err       string
+	}{
+		{namespace: "tractive", want: ""},
+		{namespace: "cabin", labels: "site=cabin", config: map[string]string{"site": "city", "household": "smith"}, want: "household=smith,site=cabin"},
+		{namespace: "9lives", err: "isn't a valid metric name prefix"},
+		{namespace: "tractive", labels: "my-site=cabin", err: `"my-site" isn't a valid label name`},
+		{namespace: "tractive", labels: "__name__=x", err: `"__name__" isn't a valid label name`},
+		{namespace: "tractive", config: map[string]string{"tracker": "rex"}, err: "label tracker is the exporter's own"},
+	} {
+		*metricsNamespace, *metricsLabels, configLabels, staticLabels = tc.namespace, tc.labels, tc.config, nil
+		err := setupStaticLabels()
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s %s %v: error %v, want one with %q", tc.namespace, tc.labels, tc.config, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, pair := range staticPairs() {
+			got = append(got, pair[0]+"="+pair[1])
+		}
+		if strings.Join(got, ",") != tc.want {
+			t.Errorf("%s %s %v: labels %v, want %s", tc.namespace, tc.labels, tc.config, got, tc.want)
+		}
+	}
+}
+
+// Renamed families, static labels in sorted order, a metric's own label
+// stays as it is
+func TestStaticGatherer(t *testing.T) {
+	namespace, static := *metricsNamespace, staticLabels
+	defer func() { *metricsNamespace, staticLabels = namespace, static }()
+
+	registry := prometheus.NewRegistry()
+	latitude := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tractive_latitude", Help: "Latitude"}, []string{"tracker"})
+	latitude.WithLabelValues("rex").Set(52.5)
+	info := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tractive_build_info", Help: "Build"}, []string{"site", "version"})
+	info.WithLabelValues("lab", "1.0").Set(1)
+	other := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_goroutines", Help: "Goroutines"})
+	registry.MustRegister(latitude, info, other)
+
+	for _, tc := range []struct {
+		name      string
+		namespace string
+		labels    map[string]string
+		want      []string
+	}{
+		{name: "nothing to do", namespace: "tractive", want: []string{
+			"go_goroutines{}", "tractive_build_info{site=lab,version=1.0}", "tractive_latitude{tracker=rex}",
+		}},
+		{name: "namespace", namespace: "cabin", want: []string{
+			"go_goroutines{}", "cabin_build_info{site=lab,version=1.0}", "cabin_latitude{tracker=rex}",
+		}},
+		{name: "labels", namespace: "tractive", labels: map[string]string{"site": "cabin", "household": "smith"}, want: []string{
+			"go_goroutines{household=smith,site=cabin}",
+			"tractive_build_info{household=smith,site=lab,version=1.0}",
+			"tractive_latitude{household=smith,site=cabin,tracker=rex}",
+		}},
+	} {
+		*metricsNamespace, staticLabels = tc.namespace, tc.labels
+		families, err := newStaticGatherer(registry).Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, family := range families {
+			var labels []string
+			for _, l := range family.Metric[0].Label {
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+			got = append(got, family.GetName()+"{"+strings.Join(labels, ",")+"}")
+		}
+		if strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"text/template"
+)
+
+var (
+
+	// For people, not databases: a chat, a push service, a phone
+	notifyURLs = flag.String("notify.urls", "",
+		"Comma separated URLs notified on events, a POST of the event as JSON with its text, or a GET when the URL is a template like https://example.com/send?msg={{ urlquery .text }}")
+	notifyEvents = flag.String("notify.events", "alert,zone,share,pair",
+		"Comma separated events that go to -notify.urls: position, zone, alert, live_start, live_stop, trip, flap, pair, share")
+)
+
+// notifier calls some URLs for some event types
+type notifier struct {
+	events  map[string]bool
+	targets []notifyTarget
+	queues  []*sinkQueue
+}
+
+// notifyTarget is a URL, or a template of one
+type notifyTarget struct {
+	url      string
+	template *template.Template
+}
+
+// newNotifier is nil without URLs
+func newNotifier() (*notifier, error) {
+	urls := deleteEmpty(strings.Split(*notifyURLs, ","))
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	n := &notifier{events: make(map[string]bool)}
+	for _, event := range deleteEmpty(strings.Split(*notifyEvents, ",")) {
+		n.events[strings.TrimSpace(event)] = true
+	}
+	client := &http.Client{Timeout: *webhookTimeout}
+	for i, url := range urls {
+		url = strings.TrimSpace(url)
+		target := notifyTarget{url: url}
+		if strings.Contains(url, "{{") {
+			t, err := template.New("notify").Option("missingkey=zero").Parse(url)
+			if err != nil {
+				return nil, fmt.Errorf("-notify.urls: %v", err)
+			}
+			target.template = t
+		}
+
+		// like webhooks, by number because of the tokens
+		name := fmt.Sprintf("notify%d", i+1)
+		slog.Info("Output notifies a URL", "output", name, "url", redactURL(url), "events", *notifyEvents)
+		n.targets = append(n.targets, target)
+		n.queues = append(n.queues, newSinkQueue(name, notifySink{url: url, client: client}, *sinkRetries))
+	}
+	return n, nil
+}
+
+// send queues the event for every URL if it cares about it, text is what
+// -notify.locale says about it
+func (n *notifier) send(event busEvent, text string, names func(string) string) {
+	if n == nil || !n.events[event.Type] {
+		return
+	}
+	data := eventData(event.Payload, names)
+	if data == nil {
+		slog.Error("Notifier marshal error", "event", event.Type)
+		return
+	}
+	data["event"] = event.Type
+	data["text"] = text
+
+	for i, target := range n.targets {
+		request, err := target.request(event.Tracker, data)
+		if err != nil {
+			slog.Error("Notifier template error", "output", fmt.Sprintf("notify%d", i+1), "err", err)
+			continue
+		}
+		n.queues[i].enqueue(request)
+	}
+}
+
+// request is a GET of the rendered URL or a POST of the data
+func (t notifyTarget) request(tracker string, data map[string]interface{}) (sinkEvent, error) {
+	event := sinkEvent{Event: data["event"].(string), Tracker: tracker}
+	if t.template != nil {
+		var url bytes.Buffer
+		err := t.template.Execute(&url, data)
+		event.URL = url.String()
+		return event, err
+	}
+	body, err := json.Marshal(data)
+	event.Body = body
+	return event, err
+}
+
+// notifySink calls its URL, or the one rendered for the event, for every
+// event of a batch
+type notifySink struct {
+	url    string
+	client *http.Client
+}
+
+// deliver ...
+func (s notifySink) deliver(batch []sinkEvent) error {
+	for _, event := range batch {
+		var resp *http.Response
+		var err error
+		if event.URL != "" {
+			resp, err = s.client.Get(event.URL)
+		} else {
+			resp, err = s.client.Post(s.url, "application/json", bytes.NewReader(event.Body))
+		}
+		if urlErr, ok := err.(*neturl.Error); ok {
+			return urlErr.Err
+		}
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s", resp.Status)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+
+	// Not every household reads English
+	notifyLocale = flag.String("notify.locale", "en",
+		"Language of notification texts: en, de, fr or any with a messages/<locale>.yaml in -web.templates")
+	notifyMapLink = flag.String("notify.map-link", "osm",
+		"Where maplink in notification texts goes: osm, google, apple, exporter (its /map, needs -web.map and -web.external-url) or a URL with {lat} and {lon}")
+)
+
+// Map links by provider
+var mapLinks = map[string]string{
+	"osm":    "https://www.openstreetmap.org/?mlat={lat}&mlon={lon}#map=17/{lat}/{lon}",
+	"google": "https://www.google.com/maps/search/?api=1&query={lat},{lon}",
+	"apple":  "https://maps.apple.com/?q={lat},{lon}&ll={lat},{lon}",
+}
+
+// mapLinkFormat resolves -notify.map-link
+func mapLinkFormat(provider string) (string, error) {
+	if format, ok := mapLinks[provider]; ok {
+		return format, nil
+	}
+	if provider == "exporter" {
+		if !*webMap || *externalURL == "" {
+			return "", fmt.Errorf("-notify.map-link=exporter needs -web.map and -web.external-url")
+		}
+		return strings.TrimRight(*externalURL, "/") + "/map?lat={lat}&lon={lon}", nil
+	}
+	if strings.Contains(provider, "{lat}") && strings.Contains(provider, "{lon}") {
+		return provider, nil
+	}
+	return "", fmt.Errorf("-notify.map-link must be osm, google, apple, exporter or a URL with {lat} and {lon}, not %q", provider)
+}
+
+// notificationTexts renders a short text for people per event, next to
+// the JSON machines get
+type notificationTexts struct {
+	templates map[string]*template.Template
+	units     displayUnits
+	mapLink   string
+}
+
+// Read and set up in main, rendering nothing while nil
+var notifications *notificationTexts
+
+// readMessages is the key to text map of a locale, from -web.templates if
+// it has the file, built in otherwise, nil when neither has it
+func readMessages(locale string) (map[string]string, error) {
+	name := filepath.Join("messages", locale+".yaml")
+	var b []byte
+	var err error
+	if *templatesDir != "" {
+		b, err = os.ReadFile(filepath.Join(*templatesDir, name))
+	}
+	if *templatesDir == "" || os.IsNotExist(err) {
+		b, err = fs.ReadFile(embeddedTemplates, "templates/messages/"+locale+".yaml")
+	}
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages map[string]string
+	if err := yaml.Unmarshal(b, &messages); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return messages, nil
+}
+
+// newNotificationTexts takes English for whatever the locale leaves out
+func newNotificationTexts(locale string) (*notificationTexts, error) {
+	texts := make(map[string]string)
+	for _, l := range []string{"en", locale} {
+		messages, err := readMessages(l)
+		if err != nil {
+			return nil, err
+		}
+		if messages == nil {
+			return nil, fmt.Errorf("-notify.locale: no messages for %q", l)
+		}
+		for key, text := range messages {
+			texts[key] = text
+		}
+	}
+
+	mapLink, err := mapLinkFormat(*notifyMapLink)
+	if err != nil {
+		return nil, err
+	}
+	n := &notificationTexts{templates: make(map[string]*template.Template), units: unitProfiles["metric"], mapLink: mapLink}
+	if units, ok := unitProfiles[*webUnits]; ok {
+		n.units = units
+	}
+	for key, text := range texts {
+		t, err := template.New(key).Funcs(n.funcs()).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("messages/%s.yaml: %v", locale, err)
+		}
+		n.templates[key] = t
+	}
+	return n, nil
+}
+
+// funcs for the texts, numbers come from JSON so they're all float64
+func (n *notificationTexts) funcs() template.FuncMap {
+	return template.FuncMap{
+		"speed": func(metersPerSecond float64) string {
+			return fmt.Sprintf("%.1f %s", n.units.speed(metersPerSecond), n.units.Speed)
+		},
+		"distance": func(meters float64) string {
+			return fmt.Sprintf("%.2f %s", n.units.distance(meters), n.units.Distance)
+		},
+		"altitude": func(meters float64) string {
+			return fmt.Sprintf("%.0f %s", n.units.altitude(meters), n.units.Altitude)
+		},
+		"maplink": func(lat, lon float64) string {
+			return strings.NewReplacer(
+				"{lat}", strconv.FormatFloat(lat, 'f', 5, 64),
+				"{lon}", strconv.FormatFloat(lon, 'f', 5, 64),
+			).Replace(n.mapLink)
+		},
+		"localtime": func(t float64) string {
+			return localTime(int64(t)).Format("15:04")
+		},
+		"duration": func(seconds float64) string {
+			return (time.Duration(seconds) * time.Second).String()
+		},
+	}
+}
+
+// eventData is the payload's JSON fields plus name and other_name, what
+// texts and notifier templates see
+func eventData(payload interface{}, names func(string) string) map[string]interface{} {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+	var data map[string]interface{}
+	if json.Unmarshal(b, &data) != nil {
+		return nil
+	}
+	for field, nameField := range map[string]string{"tracker": "name", "other": "other_name"} {
+		if id, ok := data[field].(string); ok {
+			data[nameField] = id
+			if name := names(id); name != "" {
+				data[nameField] = name
+			}
+		}
+	}
+	return data
+}
+
+// render picks the template by event type and status, "" when there is
+// none. names gives the name of a tracker.
+func (n *notificationTexts) render(eventType string, payload interface{}, names func(string) string) string {
+	if n == nil {
+		return ""
+	}
+	data := eventData(payload, names)
+	if data == nil {
+		return ""
+	}
+
+	t := n.templates[eventType]
+	if status, ok := data["status"].(string); ok && n.templates[eventType+"."+status] != nil {
+		t = n.templates[eventType+"."+status]
+	}
+	if t == nil {
+		return ""
+	}
+	var text bytes.Buffer
+	if err := t.Execute(&text, data); err != nil {
+		slog.Warn("Notification text failed", "event", eventType, "err", err)
+		return ""
+	}
+	return text.String()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// runCheckConfig leaves with what's wrong with the setup. Flags and config
+// made it this far, what's left is whether the rest loads and Tractive
+// knows the trackers.
+func runCheckConfig(e *Exporter) {
+	results := []selftestResult{{fmt.Sprintf("config, %d trackers and %d zones", len(e.trackers()), len(e.geofences)), nil}}
+	if *webConfigFile != "" {
+		_, err := loadWebConfig(*webConfigFile)
+		results = append(results, selftestResult{"web config " + *webConfigFile, err})
+	}
+	if *scriptFile != "" {
+		_, err := newScriptCollector(e, *scriptFile)
+		results = append(results, selftestResult{"script " + *scriptFile, err})
+	}
+	printResults(append(results, e.checkTrackers()...))
+}
+
+// runOnce polls every tracker, prints the metrics in the text format and
+// leaves. Without the Go and process metrics, node_exporter's textfile
+// collector has those already.
+func runOnce(e *Exporter) {
+	*pollEvery = 0
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+	if *scriptFile != "" {
+		script, err := newScriptCollector(e, *scriptFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		registry.MustRegister(script)
+	}
+
+	families, err := exposedGatherer(registry).Gather()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(os.Stdout, family); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// what happened on the way gets saved and sent before leaving
+	if *statePath != "" {
+		if err := e.saveState(*statePath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	closeSinkQueues(ctx)
+	archive.close(ctx)
+	cancel()
+	if e.mqtt != nil {
+		e.mqtt.client.Disconnect(250)
+	}
+	os.Exit(0)
+}
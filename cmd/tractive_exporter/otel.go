@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	otelprometheus "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// For an OTel collector instead of, or next to, a scraping Prometheus
+	otelEndpoint = flag.String("otel.endpoint", "",
+		"OTLP/HTTP endpoint the metrics are pushed to, e.g. http://localhost:4318, off when empty")
+	otelInterval = flag.Duration("otel.interval", time.Minute,
+		"How often the metrics are pushed to -otel.endpoint")
+	otelHeaders = flag.String("otel.headers", "",
+		"Comma separated key=value headers for -otel.endpoint, e.g. an Authorization for a hosted collector")
+)
+
+// startOTel pushes what the gatherer has every -otel.interval, the same
+// collection /metrics serves translated by the bridge
+func startOTel(gatherer prometheus.Gatherer) error {
+	if *otelEndpoint == "" {
+		return nil
+	}
+
+	options := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpointURL(*otelEndpoint)}
+	if *otelHeaders != "" {
+		headers := make(map[string]string)
+		for _, header := range deleteEmpty(strings.Split(*otelHeaders, ",")) {
+			kv := strings.SplitN(header, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("-otel.headers: want key=value, not %q", header)
+			}
+			headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+		options = append(options, otlpmetrichttp.WithHeaders(headers))
+	}
+	exporter, err := otlpmetrichttp.New(context.Background(), options...)
+	if err != nil {
+		return fmt.Errorf("-otel.endpoint: %v", err)
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithInterval(*otelInterval),
+		sdkmetric.WithProducer(otelprometheus.NewMetricProducer(otelprometheus.WithGatherer(gatherer))),
+	)
+	sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(resource.NewSchemaless(attribute.String("service.name", "tractive_exporter"))),
+	)
+	slog.Info("Pushing metrics over OTLP", "endpoint", redactURL(*otelEndpoint), "every", *otelInterval)
+	return nil
+}
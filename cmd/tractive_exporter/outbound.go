@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"time"
+)
+
+var (
+
+	// Multi-homed routers and LTE failover boxes
+	ipFamily = flag.String("tractive.ip-family", "",
+		"Use only IPv4 (4) or IPv6 (6) for API calls, both when empty")
+	sourceIP = flag.String("tractive.source-ip", "",
+		"Local address to send API calls from")
+	outboundInterface = flag.String("tractive.interface", "",
+		"Network interface to send API calls from, its first address of the -tractive.ip-family is used")
+
+	// Corporate proxies and SSH tunnels (ssh -D)
+	proxyURL = flag.String("tractive.proxy-url", "",
+		"Proxy for API calls, http://, https:// or socks5://, with user:password@ if it wants them. HTTPS_PROXY and NO_PROXY when empty")
+)
+
+// Everything going to Tractive dials through this one
+var outboundDialer = &net.Dialer{
+	Timeout:   30 * time.Second,
+	KeepAlive: 30 * time.Second,
+}
+
+// reachTractive tells whether the API answers at all, the benchmark
+// swaps it for its synthetic API
+var reachTractive = func(ctx context.Context) error {
+	conn, err := dialOutbound(ctx, "tcp", "graph.tractive.com:443")
+	if err == nil {
+		conn.Close()
+	}
+	return err
+}
+
+// configureOutbound applies the flags to the dialer and the http client
+func configureOutbound() error {
+	switch *ipFamily {
+	case "", "4", "6":
+	default:
+		return fmt.Errorf("-tractive.ip-family must be 4 or 6, not %q", *ipFamily)
+	}
+
+	local, err := outboundLocalIP()
+	if err != nil {
+		return err
+	}
+	if local != nil {
+		outboundDialer.LocalAddr = &net.TCPAddr{IP: local}
+	}
+	tr.DialContext = dialOutbound
+	if err := configureProxy(); err != nil {
+		return err
+	}
+	tr.TLSClientConfig.InsecureSkipVerify = *tlsInsecure
+	if *tlsInsecure {
+		slog.Warn("Not verifying the Tractive API certificate, -tls.insecure is on")
+	}
+	return nil
+}
+
+// outboundLocalIP is the source address asked for, if any
+func outboundLocalIP() (net.IP, error) {
+	if *sourceIP != "" {
+		ip := net.ParseIP(*sourceIP)
+		if ip == nil {
+			return nil, fmt.Errorf("-tractive.source-ip %q is not an IP address", *sourceIP)
+		}
+		return ip, nil
+	}
+	if *outboundInterface == "" {
+		return nil, nil
+	}
+
+	iface, err := net.InterfaceByName(*outboundInterface)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		isV4 := ipNet.IP.To4() != nil
+		if (*ipFamily == "4" && !isV4) || (*ipFamily == "6" && isV4) {
+			continue
+		}
+		return ipNet.IP, nil
+	}
+	return nil, fmt.Errorf("interface %s has no usable address", *outboundInterface)
+}
+
+// dialOutbound sticks to the family of the source address or the flag
+func dialOutbound(ctx context.Context, network, addr string) (net.Conn, error) {
+	family := *ipFamily
+	if local, ok := outboundDialer.LocalAddr.(*net.TCPAddr); ok {
+		family = "6"
+		if local.IP.To4() != nil {
+			family = "4"
+		}
+	}
+	if family != "" {
+		network = "tcp" + family
+	}
+	return outboundDialer.DialContext(ctx, network, addr)
+}
+
+// configureProxy sends the API calls through -tractive.proxy-url or the
+// environment's proxy, and checks the proxy is reachable instead of the API
+func configureProxy() error {
+	tr.Proxy = http.ProxyFromEnvironment
+	if *proxyURL != "" {
+		u, err := neturl.Parse(*proxyURL)
+		if err != nil {
+			return fmt.Errorf("-tractive.proxy-url: %v", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("-tractive.proxy-url must be http://, https:// or socks5://, not %q", u.Scheme)
+		}
+		tr.Proxy = http.ProxyURL(u)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://graph.tractive.com/", nil)
+	proxy, err := tr.Proxy(req)
+	if err != nil {
+		return fmt.Errorf("proxy: %v", err)
+	}
+	if proxy == nil {
+		return nil
+	}
+	addr := proxy.Host
+	if proxy.Port() == "" {
+		port := map[string]string{"http": "80", "https": "443", "socks5": "1080", "socks5h": "1080"}[proxy.Scheme]
+		addr = net.JoinHostPort(proxy.Hostname(), port)
+	}
+	reachTractive = func(ctx context.Context) error {
+		conn, err := dialOutbound(ctx, "tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+	slog.Info("API calls go through a proxy", "proxy", proxy.Scheme+"://"+addr)
+	return nil
+}
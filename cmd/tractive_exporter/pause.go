@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	trackerPaused = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "paused"),
+		"Whether the tracker is paused (1) and not polled, its other metrics are as of the last poll before",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// pausedTrackers are the collars in the drawer. It has its own lock so the
+// readiness probe can ask without the exporter's mutex.
+type pausedTrackers struct {
+	sync.Mutex
+
+	// paused: true in -config.file
+	config map[string]bool
+
+	// set over the API, wins over the config and is kept in -state.path
+	api map[string]bool
+}
+
+var pauses = &pausedTrackers{
+	config: make(map[string]bool),
+	api:    make(map[string]bool),
+}
+
+// configure takes the paused trackers of the config, at start and reload
+func (p *pausedTrackers) configure(trackers map[string]trackerSettings) {
+	config := make(map[string]bool)
+	for id, t := range trackers {
+		if t.Paused {
+			config[id] = true
+		}
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.config = config
+}
+
+// paused ...
+func (p *pausedTrackers) paused(id string) bool {
+	p.Lock()
+	defer p.Unlock()
+	if paused, ok := p.api[id]; ok {
+		return paused
+	}
+	return p.config[id]
+}
+
+// set pauses or resumes a tracker over the API
+func (p *pausedTrackers) set(id string, paused bool) {
+	p.Lock()
+	defer p.Unlock()
+	p.api[id] = paused
+}
+
+// saved is what -state.path keeps
+func (p *pausedTrackers) saved() map[string]bool {
+	p.Lock()
+	defer p.Unlock()
+	saved := make(map[string]bool, len(p.api))
+	for id, paused := range p.api {
+		saved[id] = paused
+	}
+	return saved
+}
+
+// load ...
+func (p *pausedTrackers) load(saved map[string]bool) {
+	p.Lock()
+	defer p.Unlock()
+	for id, paused := range saved {
+		p.api[id] = paused
+	}
+}
+
+// collectPaused ...
+func collectPaused(ch chan<- prometheus.Metric, id string) {
+	var paused float64
+	if pauses.paused(id) {
+		paused = 1
+	}
+	ch <- prometheus.MustNewConstMetric(trackerPaused, prometheus.GaugeValue, paused, id)
+}
+
+// pauseRequest is the body of POST /api/v1/trackers/{id}/pause
+type pauseRequest struct {
+	Paused *bool `json:"paused"`
+}
+
+// pauseHandler serves /api/v1/trackers/{id}/pause, GET says whether the
+// tracker is paused and POST {"paused": true} or false changes it
+func (e *Exporter) pauseHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !e.isConfigured(id) {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]interface{}{"tracker": id, "paused": pauses.paused(id)})
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "POST {\"paused\": true} or false", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req pauseRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil || req.Paused == nil {
+		http.Error(w, "want {\"paused\": true} or false", http.StatusBadRequest)
+		return
+	}
+	pauses.set(id, *req.Paused)
+	entry := auditRequest(r, "pause", id)
+	entry.Detail = fmt.Sprintf("paused=%t", *req.Paused)
+	entry.Result = auditResult(nil)
+	audit.record(entry)
+	slog.Info("Paused tracker", "tracker", id, "paused", *req.Paused)
+	writeJSON(w, map[string]interface{}{"tracker": id, "paused": *req.Paused})
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+// The API wins over the config until it's told otherwise, a reload keeps it
+func TestPausedTrackers(t *testing.T) {
+	p := &pausedTrackers{api: make(map[string]bool)}
+	p.configure(map[string]trackerSettings{"rex": {ID: "rex", Paused: true}, "milo": {ID: "milo"}})
+	for _, tc := range []struct {
+		step   func()
+		rex    bool
+		milo   bool
+		reason string
+	}{
+		{func() {}, true, false, "config"},
+		{func() { p.set("rex", false) }, false, false, "resumed over the API"},
+		{func() { p.set("milo", true) }, false, true, "paused over the API"},
+		{func() { p.configure(map[string]trackerSettings{"rex": {ID: "rex", Paused: true}}) }, false, true, "reloaded"},
+		{func() { p.load(map[string]bool{"rex": true}) }, true, true, "restarted"},
+	} {
+		tc.step()
+		if p.paused("rex") != tc.rex || p.paused("milo") != tc.milo {
+			t.Errorf("%s: rex %v milo %v, want %v and %v", tc.reason, p.paused("rex"), p.paused("milo"), tc.rex, tc.milo)
+		}
+	}
+	if saved := p.saved(); len(saved) != 2 || !saved["rex"] || !saved["milo"] {
+		t.Errorf("saved %v", saved)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"time"
+)
+
+var (
+
+	// Counters that start over on every restart make for odd graphs
+	statePath = flag.String("state.path", "",
+		"JSON file the geohash counters, distance memory, odometers, distance windows and daily goal days are kept in across restarts, empty is off")
+	stateFlushInterval = flag.Duration("state.flush-interval", time.Minute,
+		"How often -state.path is written, it is also written on shutdown")
+)
+
+// savedState is what goes into -state.path
+type savedState struct {
+	Saved     time.Time                 `json:"saved"`
+	Geohashes []savedGeohash            `json:"geohashes"`
+	Areas     []savedGeohash            `json:"areas,omitempty"`
+	Memory    map[string]savedGeoMemory `json:"memory"`
+	Paused    map[string]bool           `json:"paused,omitempty"`
+
+	// the distance windows and today/week as [time, meters] hops, and the
+	// meters per day of -activity.daily-goal
+	Hops     map[string][][2]float64       `json:"hops,omitempty"`
+	Activity map[string]map[string]float64 `json:"activity,omitempty"`
+}
+
+// savedGeohash is an entry of mapOfUniqueGeoStates
+type savedGeohash struct {
+	Tracker       string    `json:"tracker"`
+	Geohash       string    `json:"geohash"`
+	Counter       int32     `json:"counter"`
+	LastTimestamp int64     `json:"last_timestamp"`
+	Created       time.Time `json:"created"`
+}
+
+// savedGeoMemory is a geoMemory with its fields out in the open
+type savedGeoMemory struct {
+	PrevLat     float64       `json:"prev_lat"`
+	PrevLon     float64       `json:"prev_lon"`
+	PrevGeohash string        `json:"prev_geohash"`
+	Lat         float64       `json:"lat"`
+	Lon         float64       `json:"lon"`
+	Geohash     string        `json:"geohash"`
+	Distance    float64       `json:"distance"`
+	UpdateTime  time.Time     `json:"update_time"`
+	Age         time.Duration `json:"age"`
+	Recent      [][2]float64  `json:"recent"`
+	RawDistance float64       `json:"raw_distance"`
+	Suppressed  int64         `json:"suppressed"`
+	Odometer    float64       `json:"odometer"`
+	Created     time.Time     `json:"created"`
+}
+
+// saveGeohashes ...
+func saveGeohashes(states map[uniqueGeoStates]uniqueGeoStatesValue) []savedGeohash {
+	var saved []savedGeohash
+	for key, value := range states {
+		saved = append(saved, savedGeohash{
+			Tracker:       key.tracker,
+			Geohash:       key.geohash,
+			Counter:       value.counter,
+			LastTimestamp: value.lastTimestamp,
+			Created:       value.created,
+		})
+	}
+	return saved
+}
+
+// loadGeohashes ...
+func loadGeohashes(states map[uniqueGeoStates]uniqueGeoStatesValue, saved []savedGeohash) {
+	for _, g := range saved {
+		states[uniqueGeoStates{tracker: g.Tracker, geohash: g.Geohash}] = uniqueGeoStatesValue{
+			counter:       g.Counter,
+			lastTimestamp: g.LastTimestamp,
+			created:       g.Created,
+		}
+	}
+}
+
+// saveState writes the file next to the old one and swaps them, a crash
+// halfway leaves the old one. The WAL is emptied once the state has it,
+// holding the mutex throughout so no change falls in between.
+func (e *Exporter) saveState(path string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	state := savedState{Saved: time.Now(), Memory: make(map[string]savedGeoMemory)}
+	state.Geohashes = saveGeohashes(e.mapOfUniqueGeoStates)
+	state.Areas = saveGeohashes(e.mapOfAreaGeoStates)
+	state.Paused = pauses.saved()
+	state.Hops = e.distanceHistory.saved()
+	state.Activity = e.activityDays.saved()
+	for id, m := range e.mapOfTrackerGeoMemory {
+		state.Memory[id] = savedGeoMemory{
+			PrevLat:     m.prevLat,
+			PrevLon:     m.prevLon,
+			PrevGeohash: m.prevGeohash,
+			Lat:         m.lat,
+			Lon:         m.lon,
+			Geohash:     m.geohash,
+			Distance:    m.distance,
+			UpdateTime:  m.updateTime,
+			Age:         m.age,
+			Recent:      m.recent,
+			RawDistance: m.rawDistance,
+			Suppressed:  m.suppressed,
+			Odometer:    m.odometer,
+			Created:     m.created,
+		}
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileSynced(path+".tmp", b); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	return stateWAL.truncate()
+}
+
+// writeFileSynced is ioutil.WriteFile that waits for the disk, or a
+// power cut right after the rename could leave an empty file
+func writeFileSynced(path string, b []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(b); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// loadState picks up where the last run left off, no file is a first run
+func (e *Exporter) loadState(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state savedState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	loadGeohashes(e.mapOfUniqueGeoStates, state.Geohashes)
+	loadGeohashes(e.mapOfAreaGeoStates, state.Areas)
+	pauses.load(state.Paused)
+	e.distanceHistory.load(state.Hops)
+	e.activityDays.load(state.Activity)
+	for id, m := range state.Memory {
+		e.mapOfTrackerGeoMemory[id] = geoMemory{
+			prevLat:     m.PrevLat,
+			prevLon:     m.PrevLon,
+			prevGeohash: m.PrevGeohash,
+			lat:         m.Lat,
+			lon:         m.Lon,
+			geohash:     m.Geohash,
+			distance:    m.Distance,
+			updateTime:  m.UpdateTime,
+			age:         m.Age,
+			recent:      m.Recent,
+			rawDistance: m.RawDistance,
+			suppressed:  m.Suppressed,
+			odometer:    m.Odometer,
+			created:     m.Created,
+		}
+	}
+	slog.Info("Loaded state", "trackers", len(state.Memory), "saved", state.Saved.Format(time.RFC3339))
+	return nil
+}
+
+// runStateFlusher saves every so often, shutdown saves once more
+func (e *Exporter) runStateFlusher(path string, every time.Duration) {
+	ticks, _ := wakeEvery(every)
+	for range ticks {
+		if err := e.saveState(path); err != nil {
+			slog.Error("Could not save state", "err", err)
+		}
+	}
+}
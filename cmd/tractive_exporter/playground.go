@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Zones are a few hundred bytes, a drawn one maybe a few kB
+const maxPreviewBody = 1 << 20
+
+// zonePreview is how the history would have looked with the zone
+type zonePreview struct {
+	Zone     string               `json:"zone"`
+	From     int64                `json:"from"`
+	To       int64                `json:"to"`
+	Points   int                  `json:"points"`
+	Inside   int                  `json:"inside"`
+	Trackers []trackerZonePreview `json:"trackers"`
+}
+
+// trackerZonePreview ...
+type trackerZonePreview struct {
+	Tracker     string  `json:"tracker"`
+	Points      int     `json:"points"`
+	Inside      int     `json:"inside"`
+	Share       float64 `json:"share"`
+	FirstInside int64   `json:"first_inside,omitempty"`
+	LastInside  int64   `json:"last_inside,omitempty"`
+}
+
+// parsePreviewZone takes a GeoJSON Feature or a zone the way -config.file
+// has them, in JSON or YAML
+func parsePreviewZone(b []byte) (geofence, error) {
+	var feature struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(b, &feature) == nil && feature.Type == "Feature" {
+		var f geoJSONFeature
+		if err := json.Unmarshal(b, &f); err != nil {
+			return geofence{}, err
+		}
+		return f.geofence("preview", "preview")
+	}
+
+	var z zoneSettings
+	if err := yaml.Unmarshal(b, &z); err != nil {
+		return geofence{}, err
+	}
+	if z.Name == "" {
+		z.Name = "preview"
+	}
+	return z.geofence()
+}
+
+// geofencePreviewHandler serves POST /api/v1/geofences/preview, counting
+// the kept positions a candidate zone would have had inside it between
+// ?from= and ?to=, for ?tracker= (comma separated) or all of them
+func (e *Exporter) geofencePreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST a zone", http.StatusMethodNotAllowed)
+		return
+	}
+	if *hideCoordinates {
+		http.Error(w, "coordinates are hidden", http.StatusForbidden)
+		return
+	}
+
+	values := r.URL.Query()
+	preview := zonePreview{To: time.Now().Unix(), Trackers: []trackerZonePreview{}}
+	var err error
+	if v := values.Get("from"); v != "" {
+		if preview.From, err = historyTime(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := values.Get("to"); v != "" {
+		if preview.To, err = historyTime(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	trackers := e.trackers()
+	if v := values.Get("tracker"); v != "" {
+		trackers = deleteEmpty(strings.Split(v, ","))
+		for _, id := range trackers {
+			if !e.isConfigured(id) {
+				http.Error(w, fmt.Sprintf("unknown tracker %s", id), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxPreviewBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fence, err := parsePreviewZone(b)
+	if err != nil {
+		http.Error(w, "zone: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	preview.Zone = fence.name
+
+	for _, id := range trackers {
+		t := trackerZonePreview{Tracker: id}
+		for _, point := range e.tracks.points(id, preview.From) {
+			if point.Time > preview.To {
+				break
+			}
+			t.Points++
+			if !fence.contains(point.Lat, point.Lon) {
+				continue
+			}
+			t.Inside++
+			if t.FirstInside == 0 {
+				t.FirstInside = point.Time
+			}
+			t.LastInside = point.Time
+		}
+		if t.Points > 0 {
+			t.Share = float64(t.Inside) / float64(t.Points)
+		}
+		preview.Points += t.Points
+		preview.Inside += t.Inside
+		preview.Trackers = append(preview.Trackers, t)
+	}
+	writeJSON(w, preview)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+
+	// Several Prometheis shouldn't mean several times the API calls
+	pollEvery = flag.Duration("tractive.poll-interval", 0,
+		"Poll Tractive in the background this often and serve scrapes from the last result, 0 polls on scrape")
+
+	lastPoll = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "last_poll_timestamp"),
+		"Timestamp of the last background poll, the metrics are as of then",
+		nil, nil,
+	)
+)
+
+// A tick may come a little before the interval is over, that still counts
+const pollSlack = time.Second
+
+// runPoller collects in the background, Collect hands out the result.
+// It ticks as often as the most impatient tracker wants, the others reuse
+// their last response until they're due.
+func (e *Exporter) runPoller() {
+	tick := e.intervals.shortest(*pollEvery)
+	slog.Info("Polling in the background", "every", tick)
+
+	ticks, _ := wakeEvery(tick)
+	for {
+		e.mutex.Lock()
+		if e.stopped {
+			e.mutex.Unlock()
+			return
+		}
+		positions, infos, gaps := e.dueFetches(e.trackers())
+		e.mutex.Unlock()
+
+		// the waiting on Tractive without the mutex, scrapes get the last
+		// metrics meanwhile
+		f := fetchDue(positions, infos, gaps)
+
+		e.mutex.Lock()
+		if e.stopped {
+			e.mutex.Unlock()
+			return
+		}
+		e.lastMetrics = e.collectFetched(nil, e.trackers(), f)
+		e.lastCollect = time.Now()
+		e.mutex.Unlock()
+
+		<-ticks
+	}
+}
+
+// collectCached sends what the last poll collected, only for some trackers
+// if asked. Callers hold the mutex.
+func (e *Exporter) collectCached(ch chan<- prometheus.Metric, trackers []string) {
+	if !e.lastCollect.IsZero() {
+		ch <- prometheus.MustNewConstMetric(lastPoll, prometheus.GaugeValue, float64(e.lastCollect.Unix()))
+	}
+
+	wanted := make(map[string]bool)
+	for _, id := range trackers {
+		wanted[id] = true
+	}
+	for _, m := range e.lastMetrics {
+		if trackers == nil || wanted[trackerOf(m)] || trackerOf(m) == "" {
+			ch <- m
+		}
+	}
+}
+
+// trackerOf is the tracker label of a metric, empty for the global ones
+func trackerOf(m prometheus.Metric) string {
+	pb := &dto.Metric{}
+	if err := m.Write(pb); err != nil {
+		return ""
+	}
+	for _, l := range pb.Label {
+		if l.GetName() == "tracker" {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
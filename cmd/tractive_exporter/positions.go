@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+)
+
+// latestPosition is what /api/v1/positions says about a tracker
+type latestPosition struct {
+	Tracker          string   `json:"tracker"`
+	Name             string   `json:"name,omitempty"`
+	Time             int64    `json:"time"`
+	Age              int64    `json:"age_seconds"`
+	Lat              *float64 `json:"lat,omitempty"`
+	Lon              *float64 `json:"lon,omitempty"`
+	Geohash          string   `json:"geohash,omitempty"`
+	Speed            float64  `json:"speed"`
+	Alt              *float64 `json:"alt,omitempty"`
+	Live             bool     `json:"live"`
+	Battery          int      `json:"battery"`
+	Distance         float64  `json:"distance"`
+	DistanceInterval float64  `json:"distance_interval_seconds"`
+	LastPoll         int64    `json:"last_poll,omitempty"`
+	Error            string   `json:"error,omitempty"`
+}
+
+// positionsPage ...
+type positionsPage struct {
+	Trackers []latestPosition `json:"trackers"`
+	Units    *displayUnits    `json:"units,omitempty"`
+}
+
+// positionsHandler serves /api/v1/positions, the last good position of
+// every tracker from memory, no call to Tractive
+func (e *Exporter) positionsHandler(w http.ResponseWriter, r *http.Request) {
+	units, err := apiUnits(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, positionsPage{Trackers: e.latestPositions(units), Units: units})
+}
+
+// latestPositions of the trackers that reported anything yet, in units if given
+func (e *Exporter) latestPositions(units *displayUnits) []latestPosition {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	positions := []latestPosition{}
+	now := time.Now().Unix()
+	for _, id := range e.trackers() {
+		p := e.mapOfPollState[id].lastGood
+		if p == nil {
+			continue
+		}
+		positions = append(positions, e.latestPosition(id, p, now).in(units))
+	}
+	return positions
+}
+
+// latestPosition is what's known about a tracker as of p, callers hold the mutex
+func (e *Exporter) latestPosition(id string, p *Position, now int64) latestPosition {
+	poll := e.mapOfPollState[id]
+	memory := e.mapOfTrackerGeoMemory[id]
+	latest := latestPosition{
+		Tracker: id,
+		Name:    e.trackerName(id),
+		Time:    p.Time,
+		Age:     now - p.Time,
+		Speed:   p.Speed,
+		Live:    p.Live,
+		Battery: p.Battery,
+	}
+
+	// the very first hop is from 0,0
+	if memory.prevGeohash != "" {
+		latest.Distance = memory.distance
+		latest.DistanceInterval = memory.age.Seconds()
+	}
+	if !*hideCoordinates {
+		lat, lon := p.Lat, p.Lon
+		latest.Lat, latest.Lon = &lat, &lon
+		latest.Geohash = geohash.Encode(p.Lat, p.Lon)
+	}
+	if alt, ok := e.plausibleAltitude(id, p); ok {
+		latest.Alt = &alt
+	}
+	if !poll.lastSuccess.IsZero() {
+		latest.LastPoll = poll.lastSuccess.Unix()
+	}
+	if last := poll.lastPosition; last != nil && last.Code != 0 {
+		latest.Error = describeAPIError(last).explanation
+	}
+	return latest
+}
+
+// in converts to units, when given
+func (latest latestPosition) in(units *displayUnits) latestPosition {
+	if units == nil {
+		return latest
+	}
+	latest.Speed = units.speed(latest.Speed)
+	latest.Distance = units.distance(latest.Distance)
+	if latest.Alt != nil {
+		alt := math.Round(units.altitude(*latest.Alt))
+		latest.Alt = &alt
+	}
+	return latest
+}
+
+// positionsGeoJSONHandler serves /api/v1/positions.geojson, a Point per
+// tracker for Grafana's Geomap, Leaflet or QGIS, and with ?since=24h a
+// LineString of the way there too
+func (e *Exporter) positionsGeoJSONHandler(w http.ResponseWriter, r *http.Request) {
+	if *hideCoordinates {
+		http.Error(w, "coordinates are hidden", http.StatusForbidden)
+		return
+	}
+	units, err := apiUnits(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	since, err := exportSince(r)
+	if err != nil {
+		http.Error(w, "since must be a duration like 24h", http.StatusBadRequest)
+		return
+	}
+
+	features := []interface{}{}
+	for _, latest := range e.latestPositions(units) {
+		properties := map[string]interface{}{
+			"tracker":     latest.Tracker,
+			"name":        latest.Name,
+			"time":        latest.Time,
+			"age_seconds": latest.Age,
+			"speed":       latest.Speed,
+			"live":        latest.Live,
+			"battery":     latest.Battery,
+		}
+		if latest.Alt != nil {
+			properties["alt"] = *latest.Alt
+		}
+		if latest.Error != "" {
+			properties["error"] = latest.Error
+		}
+		if units != nil {
+			properties["units"] = units
+		}
+		features = append(features, map[string]interface{}{
+			"type": "Feature",
+			"id":   latest.Tracker,
+			"geometry": map[string]interface{}{
+				"type":        "Point",
+				"coordinates": [2]float64{*latest.Lon, *latest.Lat},
+			},
+			"properties": properties,
+		})
+
+		if since == 0 {
+			continue
+		}
+		var coordinates [][2]float64
+		for _, point := range e.tracks.points(latest.Tracker, since) {
+			coordinates = append(coordinates, [2]float64{point.Lon, point.Lat})
+		}
+		if len(coordinates) < 2 {
+			continue
+		}
+		features = append(features, map[string]interface{}{
+			"type": "Feature",
+			"id":   latest.Tracker + "-trace",
+			"geometry": map[string]interface{}{
+				"type":        "LineString",
+				"coordinates": coordinates,
+			},
+			"properties": map[string]interface{}{
+				"tracker": latest.Tracker,
+				"name":    latest.Name,
+				"since":   since,
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Keep scrape configs in sync with the tracker list
+	fileSDPath = flag.String("probe.file-sd-path", "",
+		"Write a Prometheus file_sd JSON with one /probe target per configured tracker to this path")
+)
+
+// subsetCollector collects some trackers out of the shared exporter state
+type subsetCollector struct {
+	exporter *Exporter
+	trackers []string
+}
+
+// Describe ...
+func (c subsetCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.exporter.Describe(ch)
+}
+
+// Collect ...
+func (c subsetCollector) Collect(ch chan<- prometheus.Metric) {
+	c.exporter.mutex.Lock()
+	defer c.exporter.mutex.Unlock()
+	if *pollEvery > 0 {
+		c.exporter.collectCached(ch, c.trackers)
+		return
+	}
+	c.exporter.collectTrackers(ch, c.trackers)
+}
+
+// subsetHandler serves the metrics of some trackers only
+func (e *Exporter) subsetHandler(trackers []string) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(subsetCollector{exporter: e, trackers: trackers})
+	return metricsHandler(exposedGatherer(registry))
+}
+
+// probeHandler serves /probe?target=<share id>, blackbox_exporter style
+func (e *Exporter) probeHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	if !e.isConfigured(target) {
+		http.Error(w, "unknown target "+target, http.StatusNotFound)
+		return
+	}
+
+	e.subsetHandler([]string{target}).ServeHTTP(w, r)
+}
+
+// fileSDGroup is one entry of a file_sd JSON
+type fileSDGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// writeFileSD writes the targets atomically, Prometheus watches the file
+func (e *Exporter) writeFileSD(path string) error {
+	groups := []fileSDGroup{}
+	for _, id := range e.trackers() {
+		groups = append(groups, fileSDGroup{
+			Targets: []string{id},
+			Labels:  map[string]string{"tracker": id},
+		})
+	}
+	b, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".file_sd")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
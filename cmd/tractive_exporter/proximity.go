@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Pets walked together should stay together
+	proximityPairs = flag.String("proximity.pairs", "",
+		"Comma separated tracker pairs that should stay close while away from home, e.g. 6a7235da65:2d1b273ec8")
+	proximityMaxDistance = flag.Float64("proximity.max-distance", 100,
+		"Meters a pair can be apart before they count as separated")
+	proximityHomeRadius = flag.Float64("proximity.home-radius", 200,
+		"Meters from home within which a tracker is home and can't be separated, trackers without a home are always away")
+
+	pairDistance = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "pair", "distance_meters"),
+		"Distance between the last good positions of two trackers in meters",
+		[]string{"tracker", "other"}, nil,
+	)
+
+	pairSeparated = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "pair", "separated"),
+		"Whether the pair is further apart than -proximity.max-distance while both are away from home (1) or not (0)",
+		[]string{"tracker", "other"}, nil,
+	)
+)
+
+// pairEvent goes to the hooks when a pair gets separated or back together
+type pairEvent struct {
+	Tracker  string  `json:"tracker"`
+	Other    string  `json:"other"`
+	Distance float64 `json:"distance"`
+	Status   string  `json:"status"`
+	Time     int64   `json:"time"`
+}
+
+// parsePairs reads -proximity.pairs
+func parsePairs(s string) ([][2]string, error) {
+	var pairs [][2]string
+	for _, pair := range deleteEmpty(strings.Split(s, ",")) {
+		ids := strings.Split(strings.TrimSpace(pair), ":")
+		if len(ids) != 2 || ids[0] == "" || ids[1] == "" || ids[0] == ids[1] {
+			return nil, fmt.Errorf("-proximity.pairs: %q is not tracker:other", pair)
+		}
+		pairs = append(pairs, [2]string{ids[0], ids[1]})
+	}
+	return pairs, nil
+}
+
+// awayFromHome ...
+func awayFromHome(id string, p *Position) bool {
+	if _, ok := trackerHome(id); !ok {
+		return true
+	}
+	return distanceFromHome(id, p) > *proximityHomeRadius
+}
+
+// collectPairs compares the last good positions of every pair in this
+// scrape, they can be a poll interval apart
+func (e *Exporter) collectPairs(ch chan<- prometheus.Metric, trackers []string) {
+	scraped := make(map[string]bool)
+	for _, id := range trackers {
+		scraped[id] = true
+	}
+
+	for _, pair := range e.pairs {
+		if !scraped[pair[0]] || !scraped[pair[1]] {
+			continue
+		}
+		a, b := e.mapOfPollState[pair[0]].lastGood, e.mapOfPollState[pair[1]].lastGood
+		if a == nil || b == nil {
+			continue
+		}
+
+		distance := Distance(a.Lat, a.Lon, b.Lat, b.Lon)
+		separated := distance > *proximityMaxDistance && awayFromHome(pair[0], a) && awayFromHome(pair[1], b)
+		var value float64
+		if separated {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(pairDistance, prometheus.GaugeValue, distance, pair[0], pair[1])
+		ch <- prometheus.MustNewConstMetric(pairSeparated, prometheus.GaugeValue, value, pair[0], pair[1])
+
+		if separated == e.mapOfPairs[pair] {
+			continue
+		}
+		e.mapOfPairs[pair] = separated
+
+		event := pairEvent{
+			Tracker:  pair[0],
+			Other:    pair[1],
+			Distance: distance,
+			Status:   "together",
+			Time:     time.Now().Unix(),
+		}
+		if separated {
+			event.Status = "separated"
+		}
+		slog.Info("Trackers are "+event.Status, "tracker", pair[0], "other", pair[1], "distance", fmt.Sprintf("%.0fm", distance))
+		e.bus.publish("pair", pair[0], event)
+	}
+}
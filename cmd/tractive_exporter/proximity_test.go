@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// -proximity.pairs is tracker:other, two different ones
+func TestParsePairs(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want [][2]string
+		err  bool
+	}{
+		{in: "", want: nil},
+		{in: "rex:milo", want: [][2]string{{"rex", "milo"}}},
+		{in: " rex:milo, luna:milo ,", want: [][2]string{{"rex", "milo"}, {"luna", "milo"}}},
+		{in: "rex", err: true},
+		{in: "rex:", err: true},
+		{in: "rex:rex", err: true},
+		{in: "rex:milo:luna", err: true},
+	} {
+		got, err := parsePairs(tc.in)
+		if (err != nil) != tc.err || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: %v %v, want %v", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+// A pair is separated when far apart with neither at home
+func TestCollectPairs(t *testing.T) {
+	config, maxDistance, homeRadius := trackerConfig, *proximityMaxDistance, *proximityHomeRadius
+	defer func() { trackerConfig, *proximityMaxDistance, *proximityHomeRadius = config, maxDistance, homeRadius }()
+	trackerConfig = map[string]trackerSettings{"rex": {Home: &latLon{Lat: 52.52, Lon: 13.405}}}
+	*proximityMaxDistance, *proximityHomeRadius = 100, 200
+
+	at := func(lat, lon float64) *Position {
+		p := &Position{}
+		p.Lat, p.Lon = lat, lon
+		return p
+	}
+	for _, tc := range []struct {
+		name      string
+		rex, milo *Position
+		trackers  []string
+		want      []string
+	}{
+		{name: "together", rex: at(52.60, 13.40), milo: at(52.6005, 13.40), trackers: []string{"rex", "milo"},
+			want: []string{"tractive_pair_separated{other=milo,tracker=rex} 0"}},
+		{name: "apart", rex: at(52.60, 13.40), milo: at(52.61, 13.40), trackers: []string{"rex", "milo"},
+			want: []string{"tractive_pair_separated{other=milo,tracker=rex} 1"}},
+		{name: "rex is home", rex: at(52.52, 13.405), milo: at(52.61, 13.40), trackers: []string{"rex", "milo"},
+			want: []string{"tractive_pair_separated{other=milo,tracker=rex} 0"}},
+		{name: "milo not scraped", rex: at(52.60, 13.40), milo: at(52.61, 13.40), trackers: []string{"rex"}},
+		{name: "no position for milo", rex: at(52.60, 13.40), trackers: []string{"rex", "milo"}},
+	} {
+		e := NewExporter([]string{"rex", "milo"}, make(map[uniqueGeoStates]uniqueGeoStatesValue), make(map[string]geoMemory))
+		e.pairs = [][2]string{{"rex", "milo"}}
+		e.mapOfPollState["rex"] = pollState{lastGood: tc.rex}
+		e.mapOfPollState["milo"] = pollState{lastGood: tc.milo}
+
+		ch := make(chan prometheus.Metric, 10)
+		e.collectPairs(ch, tc.trackers)
+		close(ch)
+		var got []string
+		for m := range ch {
+			if m.Desc() == pairSeparated {
+				got = append(got, exposed(t, m))
+			}
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+)
+
+var (
+
+	// For the pet sitter, who doesn't need the garden to the meter
+	webPublic = flag.Bool("web.public", false,
+		"Serve /public without authentication: name, photo, home or away, last seen and a coarse location, nothing else")
+	publicPrecision = flag.Uint("web.public.precision", 5,
+		"Geohash characters of the location on /public, 5 is about 5km, 0 shows no location")
+	publicTrackers = flag.String("web.public.trackers", "",
+		"Comma separated trackers shown on /public, all when empty")
+)
+
+// publicPage is what public.html gets
+type publicPage struct {
+	ExternalPath string
+	Pets         []publicPet
+}
+
+// publicPet is all /public tells about a tracker. No tracker ID, the
+// share ID is enough to follow the pet on Tractive's own page.
+type publicPet struct {
+	Name     string
+	Photo    string
+	Home     string
+	LastSeen string
+	Near     string
+	MapLink  string
+}
+
+// setupPublic lets /public past basic auth
+func setupPublic(web *webConfig) {
+	if !*webPublic {
+		return
+	}
+	unauthenticatedPaths["/public"] = true
+	if len(web.basicAuthUsers()) == 0 {
+		slog.Warn("-web.public without basic_auth_users in -web.config.file, everything else is public too")
+	}
+}
+
+// publicHandler serves /public
+func (e *Exporter) publicHandler(w http.ResponseWriter, r *http.Request) {
+	trackers := e.trackers()
+	if *publicTrackers != "" {
+		trackers = deleteEmpty(strings.Split(*publicTrackers, ","))
+	}
+
+	page := publicPage{ExternalPath: webExternalPath()}
+	e.mutex.Lock()
+	for _, id := range trackers {
+		if !e.isConfigured(id) {
+			continue
+		}
+		pet := publicPet{Name: e.trackerName(id)}
+		if pet.Name == "" {
+			pet.Name = fmt.Sprintf("Pet %d", len(page.Pets)+1)
+		}
+		if state := e.mapOfInfo[id]; state.info != nil {
+			pet.Photo = state.info.ImageURL
+		}
+		if p := e.mapOfPollState[id].lastGood; p != nil {
+			pet.LastSeen = publicAgo(time.Since(time.Unix(p.Time, 0)))
+			if _, ok := trackerHome(id); ok {
+				pet.Home = "home"
+				if awayFromHome(id, p) {
+					pet.Home = "away"
+				}
+			}
+			if *publicPrecision > 0 {
+				cell := geohash.EncodeWithPrecision(p.Lat, p.Lon, *publicPrecision)
+				lat, lon := geohash.DecodeCenter(cell)
+				pet.Near = fmt.Sprintf("%.2f, %.2f", lat, lon)
+				pet.MapLink = fmt.Sprintf("https://www.openstreetmap.org/?mlat=%.2f&mlon=%.2f#map=12/%.2f/%.2f", lat, lon, lat, lon)
+			}
+		}
+		page.Pets = append(page.Pets, pet)
+	}
+	e.mutex.Unlock()
+
+	w.Header().Set("Cache-Control", "no-store")
+	renderTemplate(w, "public.html", page)
+}
+
+// publicAgo is a rough age for people
+func publicAgo(d time.Duration) string {
+	switch {
+	case d < 2*time.Minute:
+		return "just now"
+	case d < 2*time.Hour:
+		return fmt.Sprintf("%d minutes ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%d hours ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d days ago", int(d.Hours()/24))
+}
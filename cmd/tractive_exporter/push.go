@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+
+	// A Pi behind NAT can't be scraped, it has to send
+	pushURL = flag.String("push.url", "",
+		"Pushgateway or remote_write URL the metrics are pushed to, off when empty")
+	pushMode = flag.String("push.mode", "pushgateway",
+		"How -push.url takes the metrics: pushgateway or remote_write")
+	pushInterval = flag.Duration("push.interval", time.Minute,
+		"How often the metrics are pushed to -push.url")
+	pushJob = flag.String("push.job", "tractive_exporter",
+		"Job the pushed metrics are grouped under in the Pushgateway, or their job label with remote_write")
+	pushUsername = flag.String("push.username", "",
+		"Basic auth user for -push.url")
+	pushPassword = flag.String("push.password", "",
+		"Basic auth password for -push.url (or TRACTIVE_PUSH_PASSWORD)")
+
+	pushes = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "pushes_total"),
+		"Number of pushes to -push.url, by result",
+		[]string{"result"}, nil,
+	)
+
+	pushLastSuccess = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "push_last_success_timestamp_seconds"),
+		"Time of the last push -push.url took in seconds since epoch",
+		nil, nil,
+	)
+)
+
+// pusher sends what the gatherer has every -push.interval
+type pusher struct {
+	sync.Mutex
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	password string
+	results  map[string]int64
+	success  time.Time
+	created  time.Time
+}
+
+// Set up in main with -push.url, nil otherwise
+var metricsPusher *pusher
+
+// startPush pushes the same collection /metrics serves every -push.interval
+func startPush(gatherer prometheus.Gatherer) error {
+	if *backfillPush && (*pushURL == "" || *pushMode != "remote_write") {
+		return errors.New("-backfill.push needs -push.url with -push.mode=remote_write")
+	}
+	if *pushURL == "" {
+		return nil
+	}
+	if *pushMode != "pushgateway" && *pushMode != "remote_write" {
+		return fmt.Errorf("-push.mode must be pushgateway or remote_write, not %q", *pushMode)
+	}
+	metricsPusher = &pusher{
+		gatherer: gatherer,
+		client:   &http.Client{Timeout: *webhookTimeout},
+		password: firstNonEmpty(*pushPassword, os.Getenv("TRACTIVE_PUSH_PASSWORD")),
+		results:  make(map[string]int64),
+		created:  time.Now(),
+	}
+	slog.Info("Pushing metrics", "mode", *pushMode, "url", redactURL(*pushURL), "every", *pushInterval)
+	go metricsPusher.run()
+	return nil
+}
+
+// run ...
+func (p *pusher) run() {
+	ticks, _ := wakeEvery(*pushInterval)
+	for {
+		err := p.push()
+		p.Lock()
+		if err != nil {
+			p.results["error"]++
+		} else {
+			p.results["ok"]++
+			p.success = time.Now()
+		}
+		p.Unlock()
+		if err != nil {
+			slog.Warn("Could not push the metrics", "url", redactURL(*pushURL), "err", err)
+		}
+		<-ticks
+	}
+}
+
+// push sends one collection, the exporter's metrics replace what the
+// Pushgateway had for the job
+func (p *pusher) push() error {
+	if *pushMode == "pushgateway" {
+		pg := push.New(*pushURL, *pushJob).Gatherer(p.gatherer).Client(p.client)
+		if *pushUsername != "" {
+			pg = pg.BasicAuth(*pushUsername, p.password)
+		}
+		err := pg.Push()
+		if urlErr, ok := err.(*neturl.Error); ok {
+			return urlErr.Err
+		}
+		return err
+	}
+
+	families, err := p.gatherer.Gather()
+	if err != nil && len(families) == 0 {
+		return err
+	}
+	return p.remoteWrite(remoteWriteRequest(families, time.Now()))
+}
+
+// remoteWrite sends a WriteRequest to -push.url
+func (p *pusher) remoteWrite(request []byte) error {
+	body := snappy.Encode(nil, request)
+	req, err := http.NewRequest(http.MethodPost, *pushURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "tractive_prometheus_exporter")
+	if *pushUsername != "" {
+		req.SetBasicAuth(*pushUsername, p.password)
+	}
+	resp, err := p.client.Do(req)
+	if urlErr, ok := err.(*neturl.Error); ok {
+		return urlErr.Err
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	return nil
+}
+
+// remoteWriteRequest is a remote_write WriteRequest, encoded by hand to
+// keep the Prometheus server's protobufs out of the binary
+func remoteWriteRequest(families []*dto.MetricFamily, now time.Time) []byte {
+	timestamp := now.UnixMilli()
+	var request []byte
+	series := func(name string, labels []*dto.LabelPair, value float64, extra ...string) {
+		pairs := [][2]string{{"__name__", name}, {"job", *pushJob}}
+		for _, l := range labels {
+			pairs = append(pairs, [2]string{l.GetName(), l.GetValue()})
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			pairs = append(pairs, [2]string{extra[i], extra[i+1]})
+		}
+		request = appendTimeSeries(request, pairs, value, timestamp)
+	}
+
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.Metric {
+			switch {
+			case m.Counter != nil:
+				series(name, m.Label, m.Counter.GetValue())
+			case m.Gauge != nil:
+				series(name, m.Label, m.Gauge.GetValue())
+			case m.Untyped != nil:
+				series(name, m.Label, m.Untyped.GetValue())
+			case m.Summary != nil:
+				for _, q := range m.Summary.Quantile {
+					series(name, m.Label, q.GetValue(), "quantile", strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64))
+				}
+				series(name+"_sum", m.Label, m.Summary.GetSampleSum())
+				series(name+"_count", m.Label, float64(m.Summary.GetSampleCount()))
+			case m.Histogram != nil:
+				for _, b := range m.Histogram.Bucket {
+					series(name+"_bucket", m.Label, float64(b.GetCumulativeCount()), "le", strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64))
+				}
+				series(name+"_bucket", m.Label, float64(m.Histogram.GetSampleCount()), "le", "+Inf")
+				series(name+"_sum", m.Label, m.Histogram.GetSampleSum())
+				series(name+"_count", m.Label, float64(m.Histogram.GetSampleCount()))
+			}
+		}
+	}
+	return request
+}
+
+// appendTimeSeries adds a TimeSeries of one sample to a WriteRequest
+func appendTimeSeries(request []byte, pairs [][2]string, value float64, timestamp int64) []byte {
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+
+	var ts []byte
+	for _, pair := range pairs {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, pair[0])
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, pair[1])
+		ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, label)
+	}
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(timestamp))
+	ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+	ts = protowire.AppendBytes(ts, sample)
+
+	request = protowire.AppendTag(request, 1, protowire.BytesType)
+	return protowire.AppendBytes(request, ts)
+}
+
+// collect ...
+func (p *pusher) collect(ch chan<- prometheus.Metric) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	for _, result := range []string{"ok", "error"} {
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(pushes, prometheus.CounterValue, float64(p.results[result]), p.created, result)
+	}
+	if !p.success.IsZero() {
+		ch <- prometheus.MustNewConstMetric(pushLastSuccess, prometheus.GaugeValue, float64(p.success.Unix()))
+	}
+}
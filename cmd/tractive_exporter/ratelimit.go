@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Too many scrapes shouldn't get the shares blocked
+	tractiveMaxRPS = flag.Float64("tractive.max-rps", 0,
+		"Calls per second to the Tractive API at most, shared by all trackers, 0 is no limit")
+
+	rateLimited = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "rate_limited_total"),
+		"Number of times Tractive said to slow down with HTTP 429",
+		nil, nil,
+	)
+
+	rateLimitRefused = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "rate_limit_refused_total"),
+		"Number of calls not made because Tractive asked to wait",
+		nil, nil,
+	)
+
+	rateLimitWait = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "rate_limit_wait_seconds_total"),
+		"Seconds calls waited for -tractive.max-rps",
+		nil, nil,
+	)
+
+	rateLimitedUntil = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "rate_limited_until_timestamp_seconds"),
+		"Until when no calls are made because Tractive asked to wait, in seconds since epoch",
+		nil, nil,
+	)
+)
+
+// How long to back off when Tractive doesn't say, doubled while it goes on
+const (
+	rateLimitBackoff    = 30 * time.Second
+	maxRateLimitBackoff = 15 * time.Minute
+)
+
+// rateLimiter is a token bucket of -tractive.max-rps and the back off
+// Tractive asked for, shared by every call
+type rateLimiter struct {
+	sync.Mutex
+	tokens  float64
+	filled  time.Time
+	until   time.Time
+	backoff time.Duration
+
+	limited int64
+	refused int64
+	waited  float64
+	created time.Time
+}
+
+var rateLimit = &rateLimiter{created: time.Now()}
+
+// rateLimitError is a call not made while backing off, not worth a retry
+type rateLimitError struct {
+	until time.Time
+}
+
+// Error ...
+func (e rateLimitError) Error() string {
+	return "rate limited by Tractive until " + e.until.Format(time.RFC3339)
+}
+
+// take waits for a token, or says no right away while backing off
+func (l *rateLimiter) take(r *http.Request) error {
+	for {
+		l.Lock()
+		now := time.Now()
+		if now.Before(l.until) {
+			l.refused++
+			until := l.until
+			l.Unlock()
+			return rateLimitError{until: until}
+		}
+		if *tractiveMaxRPS <= 0 {
+			l.Unlock()
+			return nil
+		}
+
+		burst := math.Max(1, *tractiveMaxRPS)
+		if l.filled.IsZero() {
+			l.tokens = burst
+		} else {
+			l.tokens = math.Min(burst, l.tokens+now.Sub(l.filled).Seconds()**tractiveMaxRPS)
+		}
+		l.filled = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / *tractiveMaxRPS * float64(time.Second))
+		l.waited += wait.Seconds()
+		l.Unlock()
+
+		select {
+		case <-time.After(wait):
+		case <-r.Context().Done():
+			return r.Context().Err()
+		}
+	}
+}
+
+// answered backs off when Tractive said to, for as long as Retry-After
+// says or twice as long as last time
+func (l *rateLimiter) answered(retryAfter time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+	l.limited++
+	if retryAfter <= 0 {
+		l.backoff = min(max(2*l.backoff, rateLimitBackoff), maxRateLimitBackoff)
+		retryAfter = l.backoff
+	}
+	l.until = time.Now().Add(retryAfter)
+	slog.Warn("Tractive asked to slow down", "wait", retryAfter)
+}
+
+// calm forgets the back off after a call that went through
+func (l *rateLimiter) calm() {
+	l.Lock()
+	defer l.Unlock()
+	l.backoff = 0
+}
+
+// collect ...
+func (l *rateLimiter) collect(ch chan<- prometheus.Metric) {
+	l.Lock()
+	defer l.Unlock()
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(rateLimited, prometheus.CounterValue, float64(l.limited), l.created)
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(rateLimitRefused, prometheus.CounterValue, float64(l.refused), l.created)
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(rateLimitWait, prometheus.CounterValue, l.waited, l.created)
+	if !l.until.IsZero() {
+		ch <- prometheus.MustNewConstMetric(rateLimitedUntil, prometheus.GaugeValue, float64(l.until.Unix()))
+	}
+}
+
+// retryAfter reads Retry-After in seconds or as a date, 0 when it's not there
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// rateLimitTransport makes calls wait for -tractive.max-rps and holds
+// them all back while Tractive asks to. It goes under retryTransport, so
+// every retry takes a token too.
+type rateLimitTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip ...
+func (t rateLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if err := rateLimit.take(r); err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		rateLimit.answered(retryAfter(resp))
+	case resp.StatusCode < 400:
+		rateLimit.calm()
+	}
+	return resp, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTractive answers with statuses in turn, the last one from then on
+type fakeTractive struct {
+	statuses []int
+	calls    int
+}
+
+// RoundTrip ...
+func (f *fakeTractive) RoundTrip(r *http.Request) (*http.Response, error) {
+	status := f.statuses[min(f.calls, len(f.statuses)-1)]
+	f.calls++
+	resp := &http.Response{StatusCode: status, Header: make(http.Header), Body: io.NopCloser(strings.NewReader("{}")), Request: r}
+	if status == http.StatusTooManyRequests {
+		resp.Header.Set("Retry-After", "60")
+	}
+	return resp, nil
+}
+
+// Every retry waits for its own token, a 429 isn't retried and holds back
+// the next call without asking Tractive
+func TestRateLimitWithRetries(t *testing.T) {
+	limiter, rps, retries, backoff := rateLimit, *tractiveMaxRPS, *tractiveRetries, *tractiveRetryBackoff
+	defer func() {
+		rateLimit, *tractiveMaxRPS, *tractiveRetries, *tractiveRetryBackoff = limiter, rps, retries, backoff
+	}()
+	*tractiveMaxRPS = 20
+	*tractiveRetries = 2
+	*tractiveRetryBackoff = time.Millisecond
+
+	for _, tc := range []struct {
+		name     string
+		statuses []int
+		calls    int
+		status   int
+		refused  bool
+	}{
+		{name: "answered", statuses: []int{200}, calls: 1, status: 200},
+		{name: "down", statuses: []int{503}, calls: 3, status: 503},
+		{name: "back on the retry", statuses: []int{502, 200}, calls: 2, status: 200},
+		{name: "slow down", statuses: []int{429}, calls: 1, status: 429, refused: true},
+		{name: "slow down on the retry", statuses: []int{503, 429}, calls: 2, status: 429, refused: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// an empty bucket, every call waits a twentieth of a second
+			rateLimit = &rateLimiter{created: time.Now(), filled: time.Now()}
+			fake := &fakeTractive{statuses: tc.statuses}
+			transport := retryTransport{next: rateLimitTransport{next: fake}}
+
+			r, _ := http.NewRequest(http.MethodGet, "https://graph.tractive.com/4/public_share/abc/position", nil)
+			resp, err := transport.RoundTrip(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.status || fake.calls != tc.calls {
+				t.Errorf("HTTP %d after %d calls, want HTTP %d after %d", resp.StatusCode, fake.calls, tc.status, tc.calls)
+			}
+			if least := 0.03 * float64(tc.calls); rateLimit.waited < least {
+				t.Errorf("waited %.3fs for %d calls, want at least %.3fs", rateLimit.waited, tc.calls, least)
+			}
+
+			_, err = transport.RoundTrip(r)
+			if refused := errors.As(err, new(rateLimitError)); refused != tc.refused {
+				t.Errorf("next call refused %v, want %v", refused, tc.refused)
+			}
+			if tc.refused && fake.calls != tc.calls {
+				t.Errorf("%d calls, the refused one went to Tractive", fake.calls)
+			}
+		})
+	}
+}
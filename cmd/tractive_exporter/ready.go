@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+
+	// Kubernetes probes, without a scrape of Tractive behind them
+	readyIntervals = flag.Int("web.ready-intervals", 3,
+		"/readyz fails once a tracker went this many poll intervals without a successful poll")
+)
+
+// readiness keeps its own copy of the poll times, so probes don't wait
+// for the exporter's mutex while a slow scrape holds it
+type readiness struct {
+	sync.Mutex
+	lastHit     map[string]time.Time
+	lastSuccess map[string]time.Time
+}
+
+var ready = &readiness{
+	lastHit:     make(map[string]time.Time),
+	lastSuccess: make(map[string]time.Time),
+}
+
+// polled ...
+func (r *readiness) polled(id string, hit, success time.Time) {
+	r.Lock()
+	defer r.Unlock()
+	r.lastHit[id] = hit
+	r.lastSuccess[id] = success
+}
+
+// late counts the trackers whose last success is too old. Polling on scrape
+// only happens when Prometheus comes by, so there it's measured from the
+// last attempt, otherwise a quiet Prometheus would make us unready and
+// never get scraped again.
+func (r *readiness) late(e *Exporter, now time.Time) int {
+	r.Lock()
+	defer r.Unlock()
+
+	late := 0
+	for _, id := range e.trackers() {
+		if pauses.paused(id) {
+			continue
+		}
+		since := now
+		if *pollEvery == 0 {
+			if r.lastHit[id].IsZero() {
+				continue
+			}
+			since = r.lastHit[id]
+		}
+		limit := time.Duration(*readyIntervals) * e.pollInterval(id)
+		if since.Sub(r.lastSuccess[id]) > limit {
+			late++
+		}
+	}
+	return late
+}
+
+// readyHandler serves /readyz, no tracker IDs in there since it skips basic auth
+func (e *Exporter) readyHandler(w http.ResponseWriter, r *http.Request) {
+	if late := ready.late(e, time.Now()); late > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "Not ready, %d of %d trackers without a successful poll in %d intervals.\n",
+			late, len(e.trackers()), *readyIntervals)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "Ready.")
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Like Prometheus, the endpoint is off until asked for
+	enableLifecycle = flag.Bool("web.enable-lifecycle", false,
+		"Reload -config.file and -geofence.file on POST /-/reload, SIGHUP always does")
+)
+
+var (
+	configReloadSuccessful = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "config", "last_reload_successful"),
+		"Whether the last reload of -config.file and -geofence.file worked, 1 or 0",
+		nil, nil,
+	)
+
+	configReloadTime = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "config", "last_reload_success_timestamp_seconds"),
+		"When the configuration was last loaded in seconds since epoch, at start or by a reload",
+		nil, nil,
+	)
+)
+
+// reloadState is for the two metrics above
+type reloadState struct {
+	failed    bool
+	succeeded time.Time
+}
+
+// reload rereads the trackers, names and zones of -config.file and the
+// -geofence.file. Trackers dropped from the file stop being polled unless
+// listed elsewhere, new ones start, and everything the running exporter
+// counted so far stays. Settings and groups still need a restart.
+func (e *Exporter) reload() error {
+	trackers := make(map[string]trackerSettings)
+	var zones []geofence
+	var ids []string
+	if *configFile != "" {
+		config, err := readConfig(*configFile)
+		if err == nil {
+			trackers, zones, ids, err = config.trackersAndZones(*configFile)
+		}
+		if err != nil {
+			e.reloadFailed(err)
+			return err
+		}
+	}
+	var fences []geofence
+	if *geofenceFile != "" {
+		var err error
+		if fences, err = loadGeofences(*geofenceFile); err != nil {
+			e.reloadFailed(err)
+			return err
+		}
+	}
+	fences = append(fences, zones...)
+	if err := uniqueZoneNames(fences); err != nil {
+		e.reloadFailed(err)
+		return err
+	}
+
+	// the app's fences are asked for again too
+	if *geofenceTractive {
+		e.syncFences()
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	previous := trackerConfig
+	trackerConfig = trackers
+	intervals, err := parseIntervals(*trackerIntervals)
+	if err != nil {
+		trackerConfig = previous
+		e.reloads.failed = true
+		slog.Error("Could not reload the configuration, keeping the old one", "err", err)
+		return err
+	}
+	e.intervals = intervals
+	pauses.configure(trackers)
+	e.geofences = withTractiveFences(fences, e.tractiveFences)
+
+	listed := make(map[string]bool)
+	for _, id := range listedTrackers() {
+		listed[id] = true
+	}
+	var list, added, removed []string
+	kept := make(map[string]bool)
+	for _, id := range e.trackers() {
+		_, grouped := groupOf(id)
+		_, was := previous[id]
+		_, is := trackers[id]
+		if was && !is && !listed[id] && !grouped {
+			removed = append(removed, id)
+			continue
+		}
+		kept[id] = true
+		list = append(list, id)
+	}
+	for _, id := range ids {
+		if !kept[id] {
+			kept[id] = true
+			added = append(added, id)
+			list = append(list, id)
+		}
+	}
+	e.shareMutex.Lock()
+	e.shareList = list
+	e.shareMutex.Unlock()
+
+	e.reloads = reloadState{succeeded: time.Now()}
+	slog.Info("Reloaded the configuration", "trackers", len(list), "added", strings.Join(added, ","),
+		"removed", strings.Join(removed, ","), "zones", len(fences))
+	return nil
+}
+
+// reloadFailed ...
+func (e *Exporter) reloadFailed(err error) {
+	slog.Error("Could not reload the configuration, keeping the old one", "err", err)
+	e.mutex.Lock()
+	e.reloads.failed = true
+	e.mutex.Unlock()
+}
+
+// collectReload ...
+func (e *Exporter) collectReload(ch chan<- prometheus.Metric) {
+	ok := 1.0
+	if e.reloads.failed {
+		ok = 0
+	}
+	ch <- prometheus.MustNewConstMetric(configReloadSuccessful, prometheus.GaugeValue, ok)
+	ch <- prometheus.MustNewConstMetric(configReloadTime, prometheus.GaugeValue, float64(e.reloads.succeeded.Unix()))
+}
+
+// reloadOnHUP reloads on every SIGHUP
+func (e *Exporter) reloadOnHUP() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		err := e.reload()
+		audit.record(auditEntry{Actor: "SIGHUP", Action: "reload", Result: auditResult(err)})
+	}
+}
+
+// reloadHandler serves POST /-/reload
+func (e *Exporter) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST to reload", http.StatusMethodNotAllowed)
+		return
+	}
+	err := e.reload()
+	entry := auditRequest(r, "reload", "")
+	entry.Result = auditResult(err)
+	audit.record(entry)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to reload config: %v", err), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+
+	// For dashboards built on other names
+	metricsRename = flag.String("metrics.rename", "",
+		"Comma separated old=new metric names, e.g. tractive_latitude=pet_latitude")
+	metricsRelabel = flag.String("metrics.relabel", "",
+		"Comma separated old=new label names, e.g. tracker=pet")
+)
+
+// renamingGatherer renames metric families and labels on the way out
+type renamingGatherer struct {
+	gatherer prometheus.Gatherer
+	names    map[string]string
+	labels   map[string]string
+}
+
+// Gather ...
+func (g renamingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	for _, family := range families {
+		if name, ok := g.names[family.GetName()]; ok {
+			family.Name = &name
+		}
+		if len(g.labels) == 0 {
+			continue
+		}
+		for _, metric := range family.Metric {
+			for _, label := range metric.Label {
+				if name, ok := g.labels[label.GetName()]; ok {
+					label.Name = &name
+				}
+			}
+		}
+	}
+	return families, err
+}
+
+// newRenamingGatherer only wraps when there is something to rename
+func newRenamingGatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	names := parseMapping(*metricsRename)
+	labels := parseMapping(*metricsRelabel)
+	if len(names) == 0 && len(labels) == 0 {
+		return gatherer
+	}
+	return renamingGatherer{gatherer: gatherer, names: names, labels: labels}
+}
+
+// parseMapping turns "a=b,c=d" into a map
+func parseMapping(s string) map[string]string {
+	m := make(map[string]string)
+	for _, pair := range deleteEmpty(strings.Split(s, ",")) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return m
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Names and labels in the mapping change, the rest goes out as it was
+func TestRenamingGatherer(t *testing.T) {
+	rename, relabel := *metricsRename, *metricsRelabel
+	defer func() { *metricsRename, *metricsRelabel = rename, relabel }()
+
+	registry := prometheus.NewRegistry()
+	latitude := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tractive_latitude", Help: "Latitude"}, []string{"tracker"})
+	latitude.WithLabelValues("rex").Set(52.5)
+	battery := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tractive_battery_level", Help: "Battery"}, []string{"tracker"})
+	battery.WithLabelValues("rex").Set(80)
+	registry.MustRegister(latitude, battery)
+
+	for _, tc := range []struct {
+		name    string
+		rename  string
+		relabel string
+		want    map[string]string
+	}{
+		{name: "nothing to do", want: map[string]string{"tractive_latitude": "tracker", "tractive_battery_level": "tracker"}},
+		{name: "metric", rename: "tractive_latitude=pet_latitude", want: map[string]string{"pet_latitude": "tracker", "tractive_battery_level": "tracker"}},
+		{name: "label", relabel: "tracker=pet", want: map[string]string{"tractive_latitude": "pet", "tractive_battery_level": "pet"}},
+		{name: "both with spaces", rename: " tractive_latitude = lat , ,broken", relabel: "tracker=pet", want: map[string]string{"lat": "pet", "tractive_battery_level": "pet"}},
+	} {
+		*metricsRename, *metricsRelabel = tc.rename, tc.relabel
+		families, err := newRenamingGatherer(registry).Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		for _, family := range families {
+			got[family.GetName()] = family.Metric[0].Label[0].GetName()
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+		for name, label := range tc.want {
+			if got[name] != label {
+				t.Errorf("%s: %s has label %q, want %q", tc.name, name, got[name], label)
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// The API has its bad minutes, most of them pass
+	tractiveRetries = flag.Int("tractive.retries", 2,
+		"How often a call to the Tractive API is retried after a timeout, network error or 5xx")
+	tractiveRetryBackoff = flag.Duration("tractive.retry-backoff", 500*time.Millisecond,
+		"Wait before the first retry of an API call, twice as long every time after")
+	tlsInsecure = flag.Bool("tls.insecure", false,
+		"Don't verify the certificate of the Tractive API, only for debugging proxies")
+
+	apiRetries = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "api", "retries_total"),
+		"Calls to the Tractive API that were retried, by reason",
+		[]string{"reason"}, nil,
+	)
+
+	apiFailures = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "api", "failures_total"),
+		"Calls to the Tractive API that failed for good, after retries, by reason",
+		[]string{"reason"}, nil,
+	)
+)
+
+// Why a call failed, as counted
+const (
+	failureTimeout  = "timeout"
+	failureNetwork  = "network"
+	failureServer   = "http_5xx"
+	failureCanceled = "canceled"
+)
+
+// callFailures counts retries and failures by reason
+type callFailures struct {
+	sync.Mutex
+	retries  map[string]int64
+	failures map[string]int64
+	created  time.Time
+}
+
+var apiCallFailures = &callFailures{
+	retries:  make(map[string]int64),
+	failures: make(map[string]int64),
+	created:  time.Now(),
+}
+
+// collect ...
+func (f *callFailures) collect(ch chan<- prometheus.Metric) {
+	f.Lock()
+	defer f.Unlock()
+	for _, reason := range []string{failureTimeout, failureNetwork, failureServer, failureCanceled} {
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(apiRetries, prometheus.CounterValue, float64(f.retries[reason]), f.created, reason)
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(apiFailures, prometheus.CounterValue, float64(f.failures[reason]), f.created, reason)
+	}
+}
+
+// retryTransport gives every attempt -tractive.timeout and retries the
+// ones worth retrying, GETs only
+type retryTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip ...
+func (t retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	retries := *tractiveRetries
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		retries = 0
+	}
+
+	backoff := *tractiveRetryBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(r.Context(), *tractiveTimeout)
+		resp, err := t.next.RoundTrip(r.WithContext(ctx))
+
+		reason := ""
+		switch {
+		case errors.As(err, new(rateLimitError)):
+
+			// backing off, a retry would only be refused again
+			cancel()
+			return nil, err
+		case errors.Is(r.Context().Err(), context.Canceled):
+			reason = failureCanceled
+		case err != nil && isTimeout(ctx, err):
+			reason = failureTimeout
+		case err != nil:
+			reason = failureNetwork
+		case resp.StatusCode >= 500:
+			reason = failureServer
+		}
+
+		// done, the attempt's context goes when the body is closed
+		if reason == "" || r.Context().Err() != nil || attempt >= retries {
+			if reason != "" {
+				apiCallFailures.Lock()
+				apiCallFailures.failures[reason]++
+				apiCallFailures.Unlock()
+			}
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+		apiCallFailures.Lock()
+		apiCallFailures.retries[reason]++
+		apiCallFailures.Unlock()
+
+		select {
+		case <-time.After(backoff):
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+		backoff *= 2
+	}
+}
+
+// isTimeout ...
+func isTimeout(ctx context.Context, err error) bool {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// cancelOnClose releases an attempt's context with its body
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close ...
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// fetchDeadline is how long a call can take with all its retries, and a
+// second on top so the last attempt runs out of its own time first
+func fetchDeadline() time.Duration {
+	deadline := *tractiveTimeout
+	backoff := *tractiveRetryBackoff
+	for i := 0; i < *tractiveRetries; i++ {
+		deadline += backoff + *tractiveTimeout
+		backoff *= 2
+	}
+	return deadline + time.Second
+}
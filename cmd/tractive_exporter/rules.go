@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+
+	// Thresholds for generate-rules
+	rulesOfflineAfter = flag.Duration("rules.offline-after", time.Hour,
+		"generate-rules: alert when a tracker hasn't reported for this long")
+	rulesBatteryBelow = flag.Int("rules.battery-below", 20,
+		"generate-rules: alert when the battery level in percent drops below this")
+	rulesFor = flag.Duration("rules.for", 5*time.Minute,
+		"generate-rules: how long a condition has to hold before the alert fires")
+)
+
+// Prometheus rule file layout, only what we fill in
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// exposedName is what a metric ends up called with -metrics.set,
+// -metrics.rename and -metrics.namespace
+func exposedName(v1, v2 string) string {
+	name := v1
+	if *metricsSet == "v2" {
+		name = v2
+	}
+	if renamed, ok := parseMapping(*metricsRename)[name]; ok {
+		name = renamed
+	}
+	return namespaced(name)
+}
+
+// exposedLabel is the tracker label after -metrics.relabel
+func exposedLabel(label string) string {
+	if renamed, ok := parseMapping(*metricsRelabel)[label]; ok {
+		return renamed
+	}
+	return label
+}
+
+// generateRules builds a rule group per tracker, so what Prometheus alerts on
+// matches what the exporter is configured with
+func generateRules(trackers []string, alerts *alertRules, pairs [][2]string) ruleFile {
+	age := exposedName("tractive_age_seconds", "tractive_last_report_age_seconds")
+	battery := exposedName("tractive_battery_level", "tractive_battery_ratio")
+	batteryBelow := float64(*rulesBatteryBelow)
+	if *metricsSet == "v2" {
+		batteryBelow /= 100
+	}
+	firing := exposedName("tractive_alert_firing", "tractive_alert_firing")
+	label := exposedLabel("tracker")
+
+	var file ruleFile
+	for _, id := range trackers {
+		selector := fmt.Sprintf(`{%s=%q}`, label, id)
+		labels := map[string]string{label: id}
+
+		group := ruleGroup{Name: "tractive-" + id}
+		group.Rules = append(group.Rules,
+			rule{
+				Alert:  "TractiveTrackerOffline",
+				Expr:   fmt.Sprintf("%s%s > %.0f or absent(%s%s)", age, selector, rulesOfflineAfter.Seconds(), age, selector),
+				For:    model.Duration(*rulesFor).String(),
+				Labels: labels,
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("Tracker %s hasn't reported for over %s", id, model.Duration(*rulesOfflineAfter)),
+				},
+			},
+			rule{
+				Alert:  "TractiveBatteryLow",
+				Expr:   fmt.Sprintf("%s%s < %g", battery, selector, batteryBelow),
+				For:    model.Duration(*rulesFor).String(),
+				Labels: labels,
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("Tracker %s battery is below %d%%", id, *rulesBatteryBelow),
+				},
+			},
+		)
+
+		// public shares can be switched off in the app
+		if accountOf(id) == nil {
+			group.Rules = append(group.Rules, rule{
+				Alert:  "TractiveShareRevoked",
+				Expr:   fmt.Sprintf("%s%s == 0", exposedName("tractive_share_valid", "tractive_share_valid"), selector),
+				Labels: labels,
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("The public share of tracker %s doesn't exist anymore", id),
+				},
+			})
+		}
+
+		// the exporter already evaluates these, Prometheus only has to route them,
+		// an escape rule is just a CEL condition named like that
+		if alerts != nil {
+			for _, r := range alerts.rules {
+				group.Rules = append(group.Rules, rule{
+					Alert:  "Tractive" + camelCase(r.name),
+					Expr:   fmt.Sprintf(`%s{%s=%q,alert=%q} == 1`, firing, label, id, r.name),
+					Labels: labels,
+					Annotations: map[string]string{
+						"summary": fmt.Sprintf("Tracker %s: %s", id, r.expression),
+					},
+				})
+			}
+		}
+
+		file.Groups = append(file.Groups, group)
+	}
+
+	if len(pairs) > 0 {
+		separated := exposedName("tractive_pair_separated", "tractive_pair_separated")
+		group := ruleGroup{Name: "tractive-pairs"}
+		for _, pair := range pairs {
+			group.Rules = append(group.Rules, rule{
+				Alert:  "TractivePetsSeparated",
+				Expr:   fmt.Sprintf(`%s{%s=%q,other=%q} == 1`, separated, label, pair[0], pair[1]),
+				Labels: map[string]string{label: pair[0], "other": pair[1]},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("Trackers %s and %s are over %gm apart away from home", pair[0], pair[1], *proximityMaxDistance),
+				},
+			})
+		}
+		file.Groups = append(file.Groups, group)
+	}
+	return file
+}
+
+// camelCase turns too_fast into TooFast
+func camelCase(s string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' || r == ' ' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// runGenerateRules prints the rules YAML and leaves
+func runGenerateRules(trackers []string) {
+	alerts, err := newAlertRules()
+	if err != nil {
+		log.Fatal(err)
+	}
+	pairs, err := parsePairs(*proximityPairs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(generateRules(trackers, alerts, pairs)); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(0)
+}
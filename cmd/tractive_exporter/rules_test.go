@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+// The rules use what the exporter exposes with the metric flags set
+func TestGenerateRules(t *testing.T) {
+	set, rename, relabel := *metricsSet, *metricsRename, *metricsRelabel
+	defer func() { *metricsSet, *metricsRename, *metricsRelabel = set, rename, relabel }()
+
+	useAlertsFile(t, "too_fast: speed > 10")
+	alerts, err := newAlertRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		set     string
+		rename  string
+		relabel string
+		alerts  *alertRules
+		want    map[string]string
+	}{
+		{name: "defaults", set: "both", want: map[string]string{
+			"TractiveTrackerOffline": `tractive_age_seconds{tracker="rex"} > 3600 or absent(tractive_age_seconds{tracker="rex"})`,
+			"TractiveBatteryLow":     `tractive_battery_level{tracker="rex"} < 20`,
+		}},
+		{name: "v2 has a ratio", set: "v2", want: map[string]string{
+			"TractiveTrackerOffline": `tractive_last_report_age_seconds{tracker="rex"} > 3600 or absent(tractive_last_report_age_seconds{tracker="rex"})`,
+			"TractiveBatteryLow":     `tractive_battery_ratio{tracker="rex"} < 0.2`,
+		}},
+		{name: "renamed", set: "v1", rename: "tractive_battery_level=pet_battery", relabel: "tracker=pet", want: map[string]string{
+			"TractiveBatteryLow": `pet_battery{pet="rex"} < 20`,
+		}},
+		{name: "CEL rules", set: "both", alerts: alerts, want: map[string]string{
+			"TractiveTooFast": `tractive_alert_firing{tracker="rex",alert="too_fast"} == 1`,
+		}},
+	} {
+		*metricsSet, *metricsRename, *metricsRelabel = tc.set, tc.rename, tc.relabel
+		file := generateRules([]string{"rex"}, tc.alerts, nil)
+		if len(file.Groups) != 1 || file.Groups[0].Name != "tractive-rex" {
+			t.Fatalf("%s: groups %+v, want one for rex", tc.name, file.Groups)
+		}
+		got := make(map[string]string)
+		for _, r := range file.Groups[0].Rules {
+			got[r.Alert] = r.Expr
+		}
+		for alert, expr := range tc.want {
+			if got[alert] != expr {
+				t.Errorf("%s: %s is %q, want %q", tc.name, alert, got[alert], expr)
+			}
+		}
+	}
+}
+
+// too_fast and escape-home are alert names too
+func TestCamelCase(t *testing.T) {
+	for in, want := range map[string]string{
+		"too_fast":    "TooFast",
+		"escape-home": "EscapeHome",
+		"Low battery": "LowBattery",
+		"x":           "X",
+	} {
+		if got := camelCase(in); got != want {
+			t.Errorf("camelCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
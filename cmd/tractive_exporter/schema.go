@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	apiUnknownFields = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "api", "unknown_fields_total"),
+		"Fields in API responses the exporter doesn't know about, an early warning that Tractive changed something",
+		[]string{"endpoint", "field"}, nil,
+	)
+)
+
+// schemaDrift counts fields we didn't expect, per endpoint
+type schemaDrift struct {
+	sync.Mutex
+	unknown map[[2]string]float64
+}
+
+// Filled by decodeTolerant, read by the exporter
+var drift = &schemaDrift{unknown: make(map[[2]string]float64)}
+
+// decodeTolerant unmarshals what it can into v and notes every top level
+// field (of every element, for lists) that v has no place for. A field of an unexpected type is logged
+// and left at its zero value, the rest still decodes.
+func decodeTolerant(endpoint string, body []byte, v interface{}) error {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// lists are checked element by element
+	var objects []map[string]json.RawMessage
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+		if err := json.Unmarshal(body, &objects); err != nil {
+			return err
+		}
+	} else {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return err
+		}
+		objects = append(objects, fields)
+	}
+
+	known := jsonFields(t)
+	for _, fields := range objects {
+		for name := range fields {
+			if known[name] {
+				continue
+			}
+			drift.Lock()
+			if drift.unknown[[2]string{endpoint, name}] == 0 {
+				slog.Info("New field in the API", "endpoint", endpoint, "field", name, "value", string(fields[name]))
+			}
+			drift.unknown[[2]string{endpoint, name}]++
+			drift.Unlock()
+		}
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		slog.Warn("Could not check the API schema", "endpoint", endpoint, "err", err)
+	}
+	return nil
+}
+
+// jsonFields are the names encoding/json would fill in a struct (pointer)
+func jsonFields(t reflect.Type) map[string]bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	names := make(map[string]bool)
+	if t.Kind() != reflect.Struct {
+		return names
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		switch {
+		case tag == "-":
+		case field.Anonymous && tag == "":
+			for name := range jsonFields(field.Type) {
+				names[name] = true
+			}
+		case tag != "":
+			names[tag] = true
+		default:
+			names[strings.ToLower(field.Name)] = true
+		}
+	}
+	return names
+}
+
+// collect ...
+func (d *schemaDrift) collect(ch chan<- prometheus.Metric) {
+	d.Lock()
+	defer d.Unlock()
+	for key, count := range d.unknown {
+		ch <- prometheus.MustNewConstMetric(apiUnknownFields, prometheus.CounterValue, count, key[0], key[1])
+	}
+}
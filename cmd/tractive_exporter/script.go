@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.starlark.net/starlark"
+)
+
+var (
+
+	// Users' own metrics, e.g. an adventure score
+	scriptFile = flag.String("script.file", "",
+		"Starlark file defining derive(tracker, state) that returns a dict of metric name to number or bool")
+	scriptMaxSteps = flag.Uint64("script.max-steps", 1000000,
+		"Steps a derive call may take before it's stopped and the tracker skipped, 0 is no limit")
+	scriptTimeout = flag.Duration("script.timeout", time.Second,
+		"How long the derive calls of one scrape may take together, the trackers not done by then are skipped")
+)
+
+// Anything else in a metric name gets replaced
+var invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// scriptCollector exposes whatever the script derives, as
+// tractive_script_<name>{tracker}. It has no fixed set of metrics, so it
+// describes nothing and registers as an unchecked collector.
+type scriptCollector struct {
+	exporter *Exporter
+	file     string
+	derive   starlark.Value
+}
+
+// newScriptCollector loads the script once, it's frozen afterwards
+func newScriptCollector(e *Exporter, file string) (*scriptCollector, error) {
+	thread := &starlark.Thread{Name: "load"}
+	thread.SetMaxExecutionSteps(*scriptMaxSteps)
+	globals, err := starlark.ExecFile(thread, file, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	derive, ok := globals["derive"]
+	if !ok {
+		return nil, fmt.Errorf("%s does not define derive(tracker, state)", file)
+	}
+	return &scriptCollector{exporter: e, file: file, derive: derive}, nil
+}
+
+// Describe ...
+func (c *scriptCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+// Collect ...
+func (c *scriptCollector) Collect(ch chan<- prometheus.Metric) {
+	// a script stuck in a loop mustn't hang the scrape
+	deadline := time.Now().Add(*scriptTimeout)
+	for id, state := range c.exporter.scriptStates() {
+		thread := &starlark.Thread{Name: "derive"}
+		thread.SetMaxExecutionSteps(*scriptMaxSteps)
+		timer := time.AfterFunc(time.Until(deadline), func() { thread.Cancel("past -script.timeout") })
+		result, err := starlark.Call(thread, c.derive, starlark.Tuple{starlark.String(id), state}, nil)
+		timer.Stop()
+		if err != nil {
+			slog.Warn("Script failed", "file", c.file, "tracker", id, "err", err)
+			continue
+		}
+		metrics, ok := result.(*starlark.Dict)
+		if !ok {
+			slog.Warn("Script returned no dict", "file", c.file, "type", result.Type())
+			continue
+		}
+
+		for _, item := range metrics.Items() {
+			name, ok := starlark.AsString(item[0])
+			value, isNumber := starlark.AsFloat(item[1])
+			if b, isBool := item[1].(starlark.Bool); isBool {
+				value, isNumber = 0, true
+				if b {
+					value = 1
+				}
+			}
+			if !ok || !isNumber {
+				slog.Warn("Script result skipped, want string: number or bool", "file", c.file, "key", item[0], "value", item[1])
+				continue
+			}
+			desc := prometheus.NewDesc(
+				prometheus.BuildFQName("tractive", "script", invalidMetricChars.ReplaceAllString(name, "_")),
+				"Derived by "+c.file,
+				[]string{"tracker"}, nil,
+			)
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, id)
+		}
+	}
+}
+
+// scriptStates is what the script gets to see of every tracker with data
+func (e *Exporter) scriptStates() map[string]*starlark.Dict {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	states := make(map[string]*starlark.Dict)
+	for _, id := range e.trackers() {
+		p := e.mapOfPollState[id].lastGood
+		if p == nil {
+			continue
+		}
+
+		state := starlark.NewDict(16)
+		for k, v := range e.trackerState(id, p) {
+			state.SetKey(starlark.String(k), toStarlark(v))
+		}
+		state.Freeze()
+		states[id] = state
+	}
+	return states
+}
+
+// toStarlark covers the types trackerState uses
+func toStarlark(v interface{}) starlark.Value {
+	switch v := v.(type) {
+	case bool:
+		return starlark.Bool(v)
+	case int64:
+		return starlark.MakeInt64(v)
+	case float64:
+		return starlark.Float(v)
+	case string:
+		return starlark.String(v)
+	case []string:
+		list := make([]starlark.Value, len(v))
+		for i, s := range v {
+			list[i] = starlark.String(s)
+		}
+		return starlark.NewList(list)
+	}
+	return starlark.None
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// derived runs a script over rex's last position and says what came out
+func derived(t *testing.T, script string) []string {
+	file := filepath.Join(t.TempDir(), "derive.star")
+	if err := os.WriteFile(file, []byte(script), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewExporter([]string{"rex"}, make(map[uniqueGeoStates]uniqueGeoStatesValue), make(map[string]geoMemory))
+	p := &Position{}
+	p.Time, p.Lat, p.Lon, p.Speed, p.Battery = time.Now().Unix(), 52.52, 13.405, 3.5, 80
+	e.mapOfPollState["rex"] = pollState{lastGood: p}
+
+	c, err := newScriptCollector(e, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan prometheus.Metric, 100)
+	c.Collect(ch)
+	close(ch)
+	var got []string
+	for m := range ch {
+		got = append(got, exposed(t, m))
+	}
+	sort.Strings(got)
+	return got
+}
+
+// Numbers and bools become metrics, the rest is skipped, and a script that
+// doesn't finish loses the tracker instead of the scrape
+func TestScriptCollector(t *testing.T) {
+	steps, timeout := *scriptMaxSteps, *scriptTimeout
+	defer func() { *scriptMaxSteps, *scriptTimeout = steps, timeout }()
+
+	for _, tc := range []struct {
+		name     string
+		script   string
+		maxSteps uint64
+		timeout  time.Duration
+		want     []string
+	}{
+		{
+			name: "derived",
+			script: `
+def derive(tracker, state):
+    return {"adventure score": state["speed"] * 2, "charged": state["battery"] > 50, "name": tracker}
+`,
+			maxSteps: 1000000, timeout: time.Second,
+			want: []string{"tractive_script_adventure_score{tracker=rex} 7", "tractive_script_charged{tracker=rex} 1"},
+		},
+		{
+			name: "no dict",
+			script: `
+def derive(tracker, state):
+    return 1
+`,
+			maxSteps: 1000000, timeout: time.Second,
+		},
+		{
+			name: "too many steps",
+			script: `
+def derive(tracker, state):
+    for i in range(1 << 62):
+        pass
+    return {"never": 1}
+`,
+			maxSteps: 10000, timeout: time.Minute,
+		},
+		{
+			name: "too slow",
+			script: `
+def derive(tracker, state):
+    for i in range(1 << 62):
+        pass
+    return {"never": 1}
+`,
+			maxSteps: 0, timeout: 50 * time.Millisecond,
+		},
+	} {
+		*scriptMaxSteps, *scriptTimeout = tc.maxSteps, tc.timeout
+		started := time.Now()
+		got := derived(t, tc.script)
+		if strings.Join(got, " | ") != strings.Join(tc.want, " | ") {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+		if took := time.Since(started); took > 5*time.Second {
+			t.Errorf("%s: took %s", tc.name, took)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	scrapeDuration = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "scrape_duration_seconds"),
+		"How long the last collection took in seconds, the Tractive calls included",
+		nil, nil,
+	)
+
+	apiRequestsTotal = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "api", "requests_total"),
+		"Requests to the Tractive API by tracker and HTTP status, after retries, error when there was no answer",
+		[]string{"tracker", "status"}, nil,
+	)
+
+	apiErrorsTotal = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "api", "errors_total"),
+		"Failed requests to the Tractive API by tracker and reason: timeout, network, canceled, rate_limited, http_5xx, decode or the API's own error category",
+		[]string{"tracker", "reason"}, nil,
+	)
+
+	apiRequestEnds = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "api", "request_ends_total"),
+		"Requests to the Tractive API by why they ended: ok, timeout (one attempt ran out of -tractive.timeout), "+
+			"deadline (the retries ran out of time), canceled, rate_limited, network, http_4xx or http_5xx",
+		[]string{"tracker", "reason"}, nil,
+	)
+
+	apiResponses = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "api", "response_total"),
+		"Answers to position polls by tracker, the API's code and its category, 0 and ok for a position",
+		[]string{"tracker", "code", "category"}, nil,
+	)
+
+	apiRequestDeadline = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "api", "request_deadline_seconds"),
+		"Seconds a request gets, per attempt and per tracker with all its retries",
+		[]string{"scope"}, nil,
+	)
+)
+
+// Which tracker a request is for, the account's tracker list is for none
+type trackerContextKey struct{}
+
+// withTracker ...
+func withTracker(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, trackerContextKey{}, id)
+}
+
+// trackerFromContext ...
+func trackerFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(trackerContextKey{}).(string)
+	return id
+}
+
+// requestStats counts requests and errors per tracker
+type requestStats struct {
+	sync.Mutex
+	requests  map[[2]string]int64
+	errors    map[[2]string]int64
+	ends      map[[2]string]int64
+	responses map[[3]string]int64
+	created   time.Time
+}
+
+var apiStats = &requestStats{
+	requests:  make(map[[2]string]int64),
+	errors:    make(map[[2]string]int64),
+	ends:      make(map[[2]string]int64),
+	responses: make(map[[3]string]int64),
+	created:   time.Now(),
+}
+
+// request ...
+func (s *requestStats) request(tracker, status string) {
+	s.Lock()
+	defer s.Unlock()
+	s.requests[[2]string{tracker, status}]++
+}
+
+// fail ...
+func (s *requestStats) fail(tracker, reason string) {
+	s.Lock()
+	defer s.Unlock()
+	s.errors[[2]string{tracker, reason}]++
+}
+
+// end ...
+func (s *requestStats) end(tracker, reason string) {
+	s.Lock()
+	defer s.Unlock()
+	s.ends[[2]string{tracker, reason}]++
+}
+
+// response counts an answer to a poll, exporter errors have no code
+func (s *requestStats) response(tracker string, p *Position) {
+	if p.Code == -1 {
+		return
+	}
+	category := "ok"
+	if p.Code != 0 {
+		category = describeAPIError(p).category
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.responses[[3]string{tracker, strconv.Itoa(p.Code), category}]++
+}
+
+// collect ...
+func (s *requestStats) collect(ch chan<- prometheus.Metric, trackers []string) {
+	wanted := map[string]bool{"": true}
+	for _, id := range trackers {
+		wanted[id] = true
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	for key, n := range s.requests {
+		if wanted[key[0]] {
+			ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(apiRequestsTotal, prometheus.CounterValue, float64(n), s.created, key[0], key[1])
+		}
+	}
+	for key, n := range s.errors {
+		if wanted[key[0]] {
+			ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(apiErrorsTotal, prometheus.CounterValue, float64(n), s.created, key[0], key[1])
+		}
+	}
+	for key, n := range s.ends {
+		if wanted[key[0]] {
+			ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(apiRequestEnds, prometheus.CounterValue, float64(n), s.created, key[0], key[1])
+		}
+	}
+	for key, n := range s.responses {
+		if wanted[key[0]] {
+			ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(apiResponses, prometheus.CounterValue, float64(n), s.created, key[0], key[1], key[2])
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(apiRequestDeadline, prometheus.GaugeValue, tractiveTimeout.Seconds(), "attempt")
+	ch <- prometheus.MustNewConstMetric(apiRequestDeadline, prometheus.GaugeValue, fetchDeadline().Seconds(), "tracker")
+}
+
+// endReason is why a request ended, err and resp as the retries left them
+func endReason(ctx context.Context, resp *http.Response, err error) string {
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return failureCanceled
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return "deadline"
+	case errors.As(err, new(rateLimitError)):
+		return "rate_limited"
+	case err != nil && isTimeout(ctx, err):
+		return failureTimeout
+	case err != nil:
+		return failureNetwork
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return "rate_limited"
+	case resp.StatusCode >= 500:
+		return failureServer
+	case resp.StatusCode >= 400:
+		return "http_4xx"
+	}
+	return "ok"
+}
+
+// statsTransport sits on top of the retries and counts what came of a
+// request. 4xx answers come with an error in the body, they're counted
+// by what it says.
+type statsTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip ...
+func (t statsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	tracker := trackerFromContext(r.Context())
+	resp, err := t.next.RoundTrip(r)
+	apiStats.end(tracker, endReason(r.Context(), resp, err))
+	if err != nil {
+		apiStats.request(tracker, "error")
+		switch {
+		case errors.Is(r.Context().Err(), context.Canceled):
+			apiStats.fail(tracker, failureCanceled)
+		case errors.As(err, new(rateLimitError)):
+			apiStats.fail(tracker, "rate_limited")
+		case isTimeout(r.Context(), err):
+			apiStats.fail(tracker, failureTimeout)
+		default:
+			apiStats.fail(tracker, failureNetwork)
+		}
+		return nil, err
+	}
+	apiStats.request(tracker, strconv.Itoa(resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		apiStats.fail(tracker, failureServer)
+	}
+	return resp, nil
+}
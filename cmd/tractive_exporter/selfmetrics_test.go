@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// answerWith is a transport that always answers the same
+type answerWith struct {
+	status int
+	err    error
+}
+
+// RoundTrip ...
+func (a answerWith) RoundTrip(r *http.Request) (*http.Response, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &http.Response{StatusCode: a.status, Body: io.NopCloser(strings.NewReader("{}")), Request: r}, nil
+}
+
+// What a request is counted as, by status and by why it failed
+func TestStatsTransport(t *testing.T) {
+	stats := apiStats
+	defer func() { apiStats = stats }()
+
+	for _, tc := range []struct {
+		name     string
+		answer   answerWith
+		canceled bool
+		status   string
+		reason   string
+	}{
+		{name: "ok", answer: answerWith{status: 200}, status: "200"},
+		{name: "share gone", answer: answerWith{status: 404}, status: "404"},
+		{name: "down", answer: answerWith{status: 503}, status: "503", reason: "http_5xx"},
+		{name: "timeout", answer: answerWith{err: &net.DNSError{IsTimeout: true}}, status: "error", reason: "timeout"},
+		{name: "network", answer: answerWith{err: errors.New("connection refused")}, status: "error", reason: "network"},
+		{name: "canceled", answer: answerWith{err: context.Canceled}, canceled: true, status: "error", reason: "canceled"},
+		{name: "rate limited", answer: answerWith{err: rateLimitError{}}, status: "error", reason: "rate_limited"},
+	} {
+		apiStats = &requestStats{
+			requests:  make(map[[2]string]int64),
+			errors:    make(map[[2]string]int64),
+			ends:      make(map[[2]string]int64),
+			responses: make(map[[3]string]int64),
+			created:   time.Now(),
+		}
+		ctx, cancel := context.WithCancel(withTracker(context.Background(), "rex"))
+		if tc.canceled {
+			cancel()
+		}
+		r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://graph.tractive.com/4/public_share/rex", nil)
+		statsTransport{next: tc.answer}.RoundTrip(r)
+		cancel()
+
+		if n := apiStats.requests[[2]string{"rex", tc.status}]; n != 1 || len(apiStats.requests) != 1 {
+			t.Errorf("%s: requests %v, want one %s", tc.name, apiStats.requests, tc.status)
+		}
+		if tc.reason == "" && len(apiStats.errors) != 0 {
+			t.Errorf("%s: errors %v, want none", tc.name, apiStats.errors)
+		}
+		if n := apiStats.errors[[2]string{"rex", tc.reason}]; tc.reason != "" && (n != 1 || len(apiStats.errors) != 1) {
+			t.Errorf("%s: errors %v, want one %s", tc.name, apiStats.errors, tc.reason)
+		}
+	}
+}
+
+// Why a request ended, the deadline of all retries apart from one attempt
+// timing out
+func TestEndReason(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, tc := range []struct {
+		name   string
+		ctx    context.Context
+		status int
+		err    error
+		want   string
+	}{
+		{name: "ok", ctx: context.Background(), status: 200, want: "ok"},
+		{name: "not found", ctx: context.Background(), status: 404, want: "http_4xx"},
+		{name: "down", ctx: context.Background(), status: 502, want: "http_5xx"},
+		{name: "slow down", ctx: context.Background(), status: 429, want: "rate_limited"},
+		{name: "held back", ctx: context.Background(), err: rateLimitError{}, want: "rate_limited"},
+		{name: "attempt timed out", ctx: context.Background(), err: &net.DNSError{IsTimeout: true}, want: "timeout"},
+		{name: "network", ctx: context.Background(), err: errors.New("connection reset"), want: "network"},
+		{name: "deadline", ctx: expired, err: context.DeadlineExceeded, want: "deadline"},
+		{name: "canceled", ctx: canceled, err: context.Canceled, want: "canceled"},
+	} {
+		var resp *http.Response
+		if tc.err == nil {
+			resp = &http.Response{StatusCode: tc.status}
+		}
+		if got := endReason(tc.ctx, resp, tc.err); got != tc.want {
+			t.Errorf("%s: %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// Answers are counted by the API's code, ours aren't answers
+func TestResponses(t *testing.T) {
+	s := &requestStats{responses: make(map[[3]string]int64)}
+	for _, code := range []int{0, 0, 3555, -1, 4000} {
+		p := &Position{}
+		p.Code = code
+		if code == 4000 {
+			p.Category = "Access Denied"
+		}
+		s.response("rex", p)
+	}
+	want := map[[3]string]int64{
+		{"rex", "0", "ok"}:                 2,
+		{"rex", "3555", "share_not_found"}: 1,
+		{"rex", "4000", "access_denied"}:   1,
+	}
+	if len(s.responses) != len(want) {
+		t.Errorf("responses %v, want %v", s.responses, want)
+	}
+	for key, n := range want {
+		if s.responses[key] != n {
+			t.Errorf("%v counted %d times, want %d", key, s.responses[key], n)
+		}
+	}
+}
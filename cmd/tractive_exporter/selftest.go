@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+
+	"github.com/one1zero1one/tractive_exporter/pkg/tractive"
+)
+
+// selftestResult is one check of the selftest command
+type selftestResult struct {
+	check string
+	err   error
+}
+
+// selftest asks Tractive for every tracker and sends a test event through
+// every configured output, right away and without the queues
+func (e *Exporter) selftest() []selftestResult {
+	results := e.checkTrackers()
+
+	now := time.Now().Unix()
+	position := positionEvent{Tracker: "selftest", Geohash: geohash.Encode(0, 0), Position: &Position{Position: tractive.Position{Time: now}}}
+	payload := map[string]interface{}{"tracker": "selftest", "time": now}
+	text := notifications.render("selftest", payload, func(string) string { return "" })
+
+	if e.webhook != nil {
+		body, renderErr := e.webhook.render(position)
+		for i, url := range e.webhook.urls {
+			err := renderErr
+			if err == nil {
+				err = webhookSink{url: url, client: e.webhook.client}.deliver([]sinkEvent{{Event: "selftest", Tracker: "selftest", Body: body}})
+			}
+			results = append(results, selftestResult{fmt.Sprintf("webhook%d %s", i+1, redactURL(url)), err})
+		}
+	}
+
+	if e.mqtt != nil {
+		results = append(results, selftestResult{"mqtt " + redactURL(*mqttBroker), e.mqtt.selftest(text)})
+	}
+
+	if e.influx != nil {
+		line := fmt.Sprintf("%s,tracker=selftest selftest=true %d", influxTag(*influxMeasurement), now)
+		err := e.influx.queue.sink.deliver([]sinkEvent{{Event: "selftest", Tracker: "selftest", Body: []byte(line)}})
+		results = append(results, selftestResult{"influx " + redactURL(*influxURL), err})
+	}
+	if e.execHook != nil {
+		body, _ := json.Marshal(payload)
+		err := execSink{command: *execCommand}.run(sinkEvent{Event: "selftest", Tracker: "selftest", Text: text, Body: body})
+		results = append(results, selftestResult{"exec " + *execCommand, err})
+	}
+	if e.notifier != nil {
+		data := eventData(payload, func(string) string { return "selftest" })
+		data["event"], data["text"] = "selftest", text
+		for i, target := range e.notifier.targets {
+			event, err := target.request("selftest", data)
+			if err == nil {
+				err = e.notifier.queues[i].sink.deliver([]sinkEvent{event})
+			}
+			results = append(results, selftestResult{fmt.Sprintf("notify%d %s", i+1, redactURL(target.url)), err})
+		}
+	}
+
+	if *statePath != "" {
+		results = append(results, selftestResult{"state " + filepath.Dir(*statePath), writable(filepath.Dir(*statePath))})
+	}
+	if *sinkBufferDir != "" {
+		results = append(results, selftestResult{"sink buffer " + *sinkBufferDir, writable(*sinkBufferDir)})
+	}
+	return results
+}
+
+// checkTrackers asks Tractive for every tracker once
+func (e *Exporter) checkTrackers() []selftestResult {
+	var results []selftestResult
+	for _, id := range e.trackers() {
+		ctx, cancel := context.WithTimeout(withTracker(context.Background(), id), fetchDeadline())
+		p := fetchPosition(ctx, id)
+		cancel()
+		var err error
+		if p.Code != 0 {
+			err = errors.New(describeAPIError(p).explanation)
+		}
+		results = append(results, selftestResult{"tractive " + id, err})
+	}
+	return results
+}
+
+// writable tries a file in dir
+func writable(dir string) error {
+	f, err := os.CreateTemp(dir, ".selftest-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// runSelftest ...
+func runSelftest(e *Exporter) {
+	printResults(e.selftest())
+}
+
+// printResults prints the checks and leaves, 1 if anything failed
+func printResults(results []selftestResult) {
+	failed := 0
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+			fmt.Printf("FAIL  %s: %v\n", result.check, result.err)
+			continue
+		}
+		fmt.Printf("ok    %s\n", result.check)
+	}
+	fmt.Printf("%d passed, %d failed\n", len(results)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+)
+
+// isService is only a thing on Windows
+func isService() bool {
+	return false
+}
+
+// runService ...
+func runService(serve func()) error {
+	return errors.New("running as a service is only supported on Windows")
+}
+
+// controlService ...
+func controlService(action string) error {
+	return errors.New("-service is only supported on Windows, use systemd or docker elsewhere")
+}
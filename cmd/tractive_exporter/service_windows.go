@@ -0,0 +1,112 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const serviceName = "tractive_exporter"
+
+// isService tells if we were started by the service manager
+func isService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// windowsService ...
+type windowsService struct {
+	serve func()
+}
+
+// Execute ...
+func (s *windowsService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	go s.serve()
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for c := range r {
+		switch c.Cmd {
+		case svc.Interrogate:
+			changes <- c.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			changes <- svc.Status{State: svc.StopPending}
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// runService blocks until the service manager stops us
+func runService(serve func()) error {
+	return svc.Run(serviceName, &windowsService{serve: serve})
+}
+
+// controlService does what -service asks
+func controlService(action string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if action == "install" {
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		s, err := m.CreateService(serviceName, exe, mgr.Config{
+			DisplayName: "Tractive Exporter",
+			Description: "Prometheus exporter for Tractive GPS trackers",
+			StartType:   mgr.StartAutomatic,
+		}, serviceArgs()...)
+		if err != nil {
+			return err
+		}
+		return s.Close()
+	}
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	switch action {
+	case "uninstall":
+		return s.Delete()
+	case "start":
+		return s.Start()
+	case "stop":
+		_, err = s.Control(svc.Stop)
+		return err
+	}
+	return fmt.Errorf("unknown service action %q, use install, uninstall, start or stop", action)
+}
+
+// serviceArgs are the flags we were installed with, minus -service itself
+func serviceArgs() []string {
+	var args []string
+	skipNext := false
+	for _, arg := range os.Args[1:] {
+		if skipNext {
+			skipNext = false
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == "service" {
+			skipNext = true
+			continue
+		}
+		if strings.HasPrefix(name, "service=") {
+			continue
+		}
+		args = append(args, arg)
+	}
+	return args
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Shares get switched off in the app and nobody tells the exporter
+	shareCheckInterval = flag.Duration("share.check-interval", time.Hour,
+		"How often every public share is checked to still exist, besides what polling finds out, 0 only goes by polling")
+
+	shareValid = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "share", "valid"),
+		"Whether the public share still exists (1) or was revoked (0)",
+		[]string{"tracker"}, nil,
+	)
+
+	shareLastCheck = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "share", "last_check_timestamp_seconds"),
+		"When the public share last answered in seconds since epoch, with data or with an error",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// shareState is what we know about a public share
+type shareState struct {
+	valid   bool
+	code    int
+	checked time.Time
+	changed time.Time
+}
+
+// shareEvent goes to the outputs when a share goes away or comes back
+type shareEvent struct {
+	Tracker string `json:"tracker"`
+	Status  string `json:"status"`
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	Time    int64  `json:"time"`
+}
+
+// apiCodeError is the API answering with an error code instead of data
+type apiCodeError struct {
+	code    int
+	message string
+}
+
+func (e apiCodeError) Error() string {
+	return fmt.Sprintf("code %d: %s", e.code, e.message)
+}
+
+// shareGone tells the codes that mean the share is no more
+func shareGone(code int) bool {
+	return knownAPIErrors[code].category == "share_not_found"
+}
+
+// updateShare takes an answer of the share, 0 for data. The first answer
+// only notifies when the share is gone already. Callers hold the mutex.
+func (e *Exporter) updateShare(id string, code int, message string) {
+	if accountOf(id) != nil {
+		return
+	}
+	state, known := e.mapOfShares[id]
+	valid := !shareGone(code)
+	state.checked = time.Now()
+	state.code = code
+	if known && state.valid == valid {
+		e.mapOfShares[id] = state
+		return
+	}
+	state.valid = valid
+	state.changed = state.checked
+	e.mapOfShares[id] = state
+	if !known && valid {
+		return
+	}
+
+	event := shareEvent{Tracker: id, Status: "restored", Time: state.checked.Unix()}
+	if !valid {
+		event.Status = "revoked"
+		event.Code = code
+		event.Message = describeAPIError(&Position{Code: code, Message: message}).explanation
+		slog.Warn("Public share is gone", "tracker", id, "code", code)
+	} else {
+		slog.Info("Public share is back", "tracker", id)
+	}
+	e.bus.publish("share", id, event)
+}
+
+// runShareChecker asks every share's /info now and then, which is cheap and
+// catches revoked shares while positions are served from the cache
+func (e *Exporter) runShareChecker(every time.Duration) {
+	ticks, _ := wakeEvery(every)
+	for range ticks {
+		var mutex sync.Mutex
+		answers := make(map[string]error)
+		fetchParallel(publicShares(e.trackers()), nil, func(ctx context.Context, id string) {
+			_, err := fetchInfo(ctx, id)
+			mutex.Lock()
+			answers[id] = err
+			mutex.Unlock()
+		})
+
+		e.mutex.Lock()
+		for id, err := range answers {
+			var codeErr apiCodeError
+			switch {
+			case err == nil:
+				e.updateShare(id, 0, "")
+			case errors.As(err, &codeErr):
+				e.updateShare(id, codeErr.code, codeErr.message)
+			}
+		}
+		e.mutex.Unlock()
+	}
+}
+
+// collectShare ...
+func (e *Exporter) collectShare(ch chan<- prometheus.Metric, id string) {
+	state, ok := e.mapOfShares[id]
+	if !ok {
+		return
+	}
+	var valid float64
+	if state.valid {
+		valid = 1
+	}
+	ch <- prometheus.MustNewConstMetric(shareValid, prometheus.GaugeValue, valid, id)
+	ch <- prometheus.MustNewConstMetric(shareLastCheck, prometheus.GaugeValue, float64(state.checked.Unix()), id)
+}
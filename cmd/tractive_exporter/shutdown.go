@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var (
+
+	// docker stop gives 10s before it kills
+	shutdownTimeout = flag.Duration("web.shutdown-timeout", 8*time.Second,
+		"On SIGINT or SIGTERM, how long scrapes in flight and queued events get to finish")
+)
+
+// serveUntilShutdown serves until the server is shut down and then waits
+// for the rest of the shutdown, any other end is fatal
+func serveUntilShutdown(listen func() error, done <-chan struct{}) {
+	if err := listen(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-done
+}
+
+// shutdownOnSignal shuts down on the first SIGINT or SIGTERM and closes
+// done, a second one doesn't wait
+func (e *Exporter) shutdownOnSignal(server *http.Server, done chan<- struct{}) {
+	stop := make(chan os.Signal, 2)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	sig := <-stop
+	slog.Info("Shutting down", "signal", sig, "timeout", *shutdownTimeout)
+	go func() {
+		sig := <-stop
+		slog.Warn("Shutting down right away", "signal", sig)
+		os.Exit(1)
+	}()
+	e.shutdown(server)
+	close(done)
+}
+
+// shutdown lets the scrapes in flight finish, stops polling, saves the
+// state and gives the outputs what's left in their queues
+func (e *Exporter) shutdown(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("Scrapes still running at shutdown", "err", err)
+	}
+
+	// a poll that's running finishes, no new one starts
+	e.mutex.Lock()
+	e.stopped = true
+	e.mutex.Unlock()
+
+	if *statePath != "" {
+		if err := e.saveState(*statePath); err != nil {
+			slog.Error("Could not save state", "err", err)
+		}
+	}
+	stateWAL.sync()
+
+	closeSinkQueues(ctx)
+	archive.close(ctx)
+	if e.mqtt != nil {
+		e.mqtt.client.Disconnect(250)
+	}
+	slog.Info("Bye")
+}
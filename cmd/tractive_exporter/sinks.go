@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// One dead backend shouldn't take the others down with it
+	sinkQueueSize = flag.Int("sink.queue-size", 100,
+		"Events an output (webhook URL, MQTT broker, InfluxDB or exec command) can have waiting in memory")
+	sinkRetries = flag.Int("sink.retries", 3,
+		"How often a failed webhook, MQTT or InfluxDB delivery is retried, with the backoff doubling every time")
+	sinkRetryBackoff = flag.Duration("sink.retry-backoff", time.Second,
+		"Wait before the first retry of a failed delivery")
+	sinkBatchSize = flag.Int("sink.batch-size", 1,
+		"Events handed to an output at once, fewer when -sink.flush-interval passes first")
+	sinkFlushInterval = flag.Duration("sink.flush-interval", 5*time.Second,
+		"Longest an incomplete batch waits")
+	sinkBufferDir = flag.String("sink.buffer-dir", "",
+		"Directory events go to when an output's memory queue is full, instead of being dropped, and that survives restarts")
+
+	sinkDeliveries = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "sink", "deliveries_total"),
+		"Events delivered to an output by result: ok, failed (after retries) or dropped (queue full)",
+		[]string{"sink", "result"}, nil,
+	)
+
+	sinkRetriesTotal = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "sink", "retries_total"),
+		"Retried batches to an output",
+		[]string{"sink"}, nil,
+	)
+
+	sinkQueueLength = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "sink", "queue_length"),
+		"Events waiting for an output, in memory and on disk",
+		[]string{"sink"}, nil,
+	)
+)
+
+// sinkEvent is what outputs get, the body is already rendered
+type sinkEvent struct {
+	Event   string `json:"event"`
+	Tracker string `json:"tracker"`
+	Topic   string `json:"topic,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Text    string `json:"text,omitempty"`
+	Body    []byte `json:"body"`
+}
+
+// sink is an output, a new one only has to implement this and get a
+// sinkQueue with newSinkQueue
+type sink interface {
+	deliver(batch []sinkEvent) error
+}
+
+// sinkQueue buffers events for one sink and hands them over in batches
+// in its own goroutine, so a slow or broken one only backs up itself
+type sinkQueue struct {
+	sync.Mutex
+	name    string
+	sink    sink
+	retries int
+	queue   chan sinkEvent
+	spill   string
+	spilled int
+	ok      int64
+	failed  int64
+	dropped int64
+	retried int64
+	created time.Time
+	closing chan struct{}
+	closed  chan struct{}
+}
+
+// Every queue, for the metrics
+var (
+	sinkQueuesMutex sync.Mutex
+	sinkQueues      []*sinkQueue
+)
+
+// newSinkQueue starts the worker of a sink, picking up what it had
+// spilled to disk last time
+func newSinkQueue(name string, s sink, retries int) *sinkQueue {
+	q := &sinkQueue{
+		name:    name,
+		sink:    s,
+		retries: retries,
+		queue:   make(chan sinkEvent, *sinkQueueSize),
+		created: time.Now(),
+		closing: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	if *sinkBufferDir != "" {
+		q.spill = filepath.Join(*sinkBufferDir, name+".jsonl")
+		q.spilled = len(q.readSpill())
+	}
+	sinkQueuesMutex.Lock()
+	sinkQueues = append(sinkQueues, q)
+	sinkQueuesMutex.Unlock()
+	go q.work()
+	return q
+}
+
+// enqueue never blocks, a full queue spills to disk or drops the event
+func (q *sinkQueue) enqueue(event sinkEvent) {
+	select {
+	case q.queue <- event:
+		return
+	default:
+	}
+
+	q.Lock()
+	defer q.Unlock()
+	if q.spill != "" {
+		if err := q.appendSpill(event); err == nil {
+			q.spilled++
+			return
+		}
+	}
+	q.dropped++
+	slog.Warn("Output is backed up, dropped an event", "output", q.name)
+}
+
+// work collects batches, a timer flushes the incomplete ones and pulls
+// spilled events back in
+func (q *sinkQueue) work() {
+	batchSize := *sinkBatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	ticks, stop := wakeEvery(*sinkFlushInterval)
+	defer stop()
+
+	var batch []sinkEvent
+	for {
+		select {
+		case event := <-q.queue:
+			batch = append(batch, event)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticks:
+			if len(q.queue) == 0 {
+				batch = append(batch, q.unspill(batchSize-len(batch))...)
+			}
+			if len(batch) == 0 {
+				continue
+			}
+		case <-q.closing:
+			for len(q.queue) > 0 {
+				batch = append(batch, <-q.queue)
+			}
+			for len(batch) > 0 {
+				n := min(batchSize, len(batch))
+				q.flush(batch[:n])
+				batch = batch[n:]
+			}
+			close(q.closed)
+			return
+		}
+		q.flush(batch)
+		batch = nil
+	}
+}
+
+// closeSinkQueues delivers what's queued in memory, what's spilled to disk
+// stays for next time
+func closeSinkQueues(ctx context.Context) {
+	sinkQueuesMutex.Lock()
+	queues := append([]*sinkQueue{}, sinkQueues...)
+	sinkQueuesMutex.Unlock()
+
+	for _, q := range queues {
+		close(q.closing)
+	}
+	for _, q := range queues {
+		select {
+		case <-q.closed:
+		case <-ctx.Done():
+			slog.Warn("Output didn't finish its queue at shutdown", "output", q.name, "queued", len(q.queue))
+		}
+	}
+}
+
+// flush delivers a batch, retrying the whole of it
+func (q *sinkQueue) flush(batch []sinkEvent) {
+	backoff := *sinkRetryBackoff
+	err := q.sink.deliver(batch)
+	for attempt := 0; err != nil && attempt < q.retries; attempt++ {
+		q.Lock()
+		q.retried++
+		q.Unlock()
+		time.Sleep(backoff)
+		backoff *= 2
+		err = q.sink.deliver(batch)
+	}
+
+	q.Lock()
+	defer q.Unlock()
+	if err != nil {
+		q.failed += int64(len(batch))
+		slog.Warn("Output failed", "output", q.name, "err", err)
+		return
+	}
+	q.ok += int64(len(batch))
+}
+
+// appendSpill adds an event to the spill file, the caller holds the lock
+func (q *sinkQueue) appendSpill(event sinkEvent) error {
+	f, err := os.OpenFile(q.spill, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		slog.Error("Output can't spill to disk", "output", q.name, "err", err)
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(event)
+}
+
+// readSpill ...
+func (q *sinkQueue) readSpill() []sinkEvent {
+	f, err := os.Open(q.spill)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var events []sinkEvent
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var event sinkEvent
+		if json.Unmarshal(scanner.Bytes(), &event) == nil {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// unspill takes up to n events off the spill file
+func (q *sinkQueue) unspill(n int) []sinkEvent {
+	q.Lock()
+	defer q.Unlock()
+	if q.spilled == 0 || n <= 0 {
+		return nil
+	}
+
+	events := q.readSpill()
+	if n > len(events) {
+		n = len(events)
+	}
+	var rest []byte
+	for _, event := range events[n:] {
+		line, _ := json.Marshal(event)
+		rest = append(append(rest, line...), '\n')
+	}
+	if err := ioutil.WriteFile(q.spill, rest, 0600); err != nil {
+		slog.Error("Output can't rewrite its spill file", "output", q.name, "err", err)
+		return nil
+	}
+	q.spilled = len(events) - n
+	return events[:n]
+}
+
+// collectSinks ...
+func collectSinks(ch chan<- prometheus.Metric) {
+	sinkQueuesMutex.Lock()
+	defer sinkQueuesMutex.Unlock()
+
+	for _, q := range sinkQueues {
+		q.Lock()
+		for result, n := range map[string]int64{"ok": q.ok, "failed": q.failed, "dropped": q.dropped} {
+			ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(sinkDeliveries, prometheus.CounterValue, float64(n), q.created, q.name, result)
+		}
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(sinkRetriesTotal, prometheus.CounterValue, float64(q.retried), q.created, q.name)
+		ch <- prometheus.MustNewConstMetric(sinkQueueLength, prometheus.GaugeValue, float64(len(q.queue)+q.spilled), q.name)
+		q.Unlock()
+	}
+}
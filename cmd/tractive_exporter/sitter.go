@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+
+	// Vacation mode, no Tractive login or permanent share handed out
+	sitterKey = flag.String("web.sitter-key", os.Getenv("SITTER_KEY"),
+		"Secret that signs pet sitter links, better as SITTER_KEY, a new one revokes every link. Off when empty.")
+	sitterMaxValidity = flag.Duration("web.sitter-max-validity", 30*24*time.Hour,
+		"Longest a pet sitter link may be valid")
+)
+
+// sitterPage is what sitter.html gets
+type sitterPage struct {
+	ExternalPath string
+	Token        string
+	Name         string
+	Expires      string
+	TileURL      string
+	Attribution  string
+	Refresh      int64
+}
+
+// signSitterToken is tracker and expiry, signed
+func signSitterToken(id string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(id + ":" + strconv.FormatInt(expires.Unix(), 10)))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sitterMAC(payload))
+}
+
+// sitterMAC is HMAC-SHA256 with -web.sitter-key
+func sitterMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(*sitterKey))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// checkSitterToken returns the tracker of a token that is signed and not expired
+func checkSitterToken(token string) (string, time.Time, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", time.Time{}, errors.New("malformed link")
+	}
+	given, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(given, sitterMAC(payload)) {
+		return "", time.Time{}, errors.New("invalid link")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", time.Time{}, errors.New("malformed link")
+	}
+	id, expiry, _ := strings.Cut(string(b), ":")
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", time.Time{}, errors.New("malformed link")
+	}
+	expires := time.Unix(unix, 0)
+	if time.Now().After(expires) {
+		return "", expires, errors.New("this link expired")
+	}
+	return id, expires, nil
+}
+
+// setupSitters lets /sitter/ past basic auth, the signature is the password
+func setupSitters() {
+	if *sitterKey == "" {
+		return
+	}
+	unauthenticatedPrefixes = append(unauthenticatedPrefixes, "/sitter/")
+	if len(*sitterKey) < 16 {
+		slog.Warn("-web.sitter-key is short, links could be guessed", "length", len(*sitterKey))
+	}
+}
+
+// sittersEnabled answers for the handlers when links are off
+func sittersEnabled(w http.ResponseWriter) bool {
+	switch {
+	case *sitterKey == "":
+		http.Error(w, "pet sitter links are off, see -web.sitter-key", http.StatusNotFound)
+		return false
+	case *hideCoordinates:
+		http.Error(w, "coordinates are hidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// sitterLinkHandler serves POST /api/v1/trackers/{id}/sitter?valid=72h,
+// behind basic auth
+func (e *Exporter) sitterLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST to make a link", http.StatusMethodNotAllowed)
+		return
+	}
+	if !sittersEnabled(w) {
+		return
+	}
+	id := r.PathValue("id")
+	if !e.isConfigured(id) {
+		http.NotFound(w, r)
+		return
+	}
+	valid := 7 * 24 * time.Hour
+	if v := r.URL.Query().Get("valid"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "valid must be a duration like 72h", http.StatusBadRequest)
+			return
+		}
+		valid = d
+	}
+	if valid > *sitterMaxValidity {
+		http.Error(w, "longer than -web.sitter-max-validity", http.StatusBadRequest)
+		return
+	}
+
+	expires := time.Now().Add(valid)
+	link := webExternalPath() + "/sitter/" + signSitterToken(id, expires)
+	if *externalURL != "" {
+		link = strings.TrimRight(*externalURL, "/") + "/sitter/" + signSitterToken(id, expires)
+	}
+	entry := auditRequest(r, "sitter_link", id)
+	entry.Detail = "valid until " + expires.UTC().Format(time.RFC3339)
+	entry.Result = "ok"
+	audit.record(entry)
+	writeJSON(w, map[string]interface{}{"tracker": id, "url": link, "expires": expires.UTC().Format(time.RFC3339)})
+}
+
+// sitterHandler serves /sitter/{token}, a map of one tracker. Opening it
+// goes to the audit log, the position it polls doesn't.
+func (e *Exporter) sitterHandler(w http.ResponseWriter, r *http.Request) {
+	if !sittersEnabled(w) {
+		return
+	}
+	token := r.PathValue("token")
+	id, expires, err := checkSitterToken(token)
+	entry := auditRequest(r, "sitter_open", id)
+	entry.Actor = "sitter link"
+	entry.Result = auditResult(err)
+	audit.record(entry)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	e.mutex.Lock()
+	name := e.trackerName(id)
+	e.mutex.Unlock()
+	if name == "" {
+		name = "Your guest"
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	renderTemplate(w, "sitter.html", sitterPage{
+		ExternalPath: webExternalPath(),
+		Token:        token,
+		Name:         name,
+		Expires:      localTime(expires.Unix()).Format("2006-01-02 15:04"),
+		TileURL:      *mapTileURL,
+		Attribution:  *mapAttribution,
+		Refresh:      mapRefresh.Milliseconds(),
+	})
+}
+
+// sitterPositionHandler serves /sitter/{token}/position, the last good
+// position of the token's tracker
+func (e *Exporter) sitterPositionHandler(w http.ResponseWriter, r *http.Request) {
+	if !sittersEnabled(w) {
+		return
+	}
+	id, _, err := checkSitterToken(r.PathValue("token"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	e.mutex.Lock()
+	p := e.mapOfPollState[id].lastGood
+	e.mutex.Unlock()
+	w.Header().Set("Cache-Control", "no-store")
+	if p == nil {
+		writeJSON(w, map[string]interface{}{})
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"time":    p.Time,
+		"lat":     p.Lat,
+		"lon":     p.Lon,
+		"live":    p.Live,
+		"battery": p.Battery,
+		"speed":   p.Speed,
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// A link works for its tracker until it expires, with the key it was
+// signed with only
+func TestSitterToken(t *testing.T) {
+	key := *sitterKey
+	defer func() { *sitterKey = key }()
+	*sitterKey = "correct horse battery staple"
+
+	valid := signSitterToken("rex", time.Now().Add(time.Hour))
+	expired := signSitterToken("rex", time.Now().Add(-time.Minute))
+	payload, signature, _ := strings.Cut(valid, ".")
+	otherPayload, _, _ := strings.Cut(signSitterToken("milo", time.Now().Add(time.Hour)), ".")
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		key   string
+		id    string
+		err   string
+	}{
+		{name: "valid", token: valid, id: "rex"},
+		{name: "expired", token: expired, err: "this link expired"},
+		{name: "another key", token: valid, key: "hunter2hunter2hunter2", err: "invalid link"},
+		{name: "another tracker", token: otherPayload + "." + signature, err: "invalid link"},
+		{name: "no signature", token: payload, err: "malformed link"},
+		{name: "garbage", token: "!!!." + signature, err: "invalid link"},
+	} {
+		*sitterKey = "correct horse battery staple"
+		if tc.key != "" {
+			*sitterKey = tc.key
+		}
+		id, _, err := checkSitterToken(tc.token)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("%s: %q %v, want %q", tc.name, id, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || id != tc.id {
+			t.Errorf("%s: %q %v, want %q", tc.name, id, err, tc.id)
+		}
+	}
+}
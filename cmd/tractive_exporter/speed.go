@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// Base units are for PromQL, km/h and mph are for people reading a graph
+	metricsUnits = flag.String("metrics.units", "",
+		"Also expose speeds and the distance walked in metric (km/h, kilometers) or imperial (mph, miles) units, empty for base units only")
+
+	trackerComputedSpeed = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "computed_speed_meters_per_second"),
+		"Speed between the last two reported positions in meters per second, for when the reported speed stays 0",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// unitMetrics are the descs in one -metrics.units
+type unitMetrics struct {
+	units         displayUnits
+	speed         *prometheus.Desc
+	computedSpeed *prometheus.Desc
+	distance      *prometheus.Desc
+}
+
+// newUnitMetrics names the metrics after the units, e.g. tractive_speed_kmh
+func newUnitMetrics(units displayUnits, speed, distance, distanceWords string) *unitMetrics {
+	return &unitMetrics{
+		units: units,
+		speed: prometheus.NewDesc(
+			prometheus.BuildFQName("tractive", "", "speed_"+speed),
+			fmt.Sprintf("Speed of the tracker in %s", units.Speed),
+			[]string{"tracker"}, nil,
+		),
+		computedSpeed: prometheus.NewDesc(
+			prometheus.BuildFQName("tractive", "", "computed_speed_"+speed),
+			fmt.Sprintf("Speed between the last two reported positions in %s", units.Speed),
+			[]string{"tracker"}, nil,
+		),
+		distance: prometheus.NewDesc(
+			prometheus.BuildFQName("tractive", "", "distance_"+distance+"_total"),
+			fmt.Sprintf("Distance walked since the exporter started in %s", distanceWords),
+			[]string{"tracker"}, nil,
+		),
+	}
+}
+
+// Per -metrics.units
+var unitMetricSets = map[string]*unitMetrics{
+	"metric":   newUnitMetrics(unitProfiles["metric"], "kmh", "kilometers", "kilometers"),
+	"imperial": newUnitMetrics(unitProfiles["imperial"], "mph", "miles", "miles"),
+}
+
+// Nil without -metrics.units, set up in main
+var unitMetricSet *unitMetrics
+
+// setupMetricsUnits checks -metrics.units
+func setupMetricsUnits() error {
+	if *metricsUnits == "" {
+		return nil
+	}
+	set, ok := unitMetricSets[*metricsUnits]
+	if !ok {
+		return fmt.Errorf("-metrics.units must be metric or imperial, not %q", *metricsUnits)
+	}
+	unitMetricSet = set
+	return nil
+}
+
+// describe ...
+func (m *unitMetrics) describe(ch chan<- *prometheus.Desc) {
+	if m == nil {
+		return
+	}
+	ch <- m.speed
+	ch <- m.computedSpeed
+	ch <- m.distance
+}
+
+// lastHop is the distance and the seconds between the last two positions
+func (s *trackStore) lastHop(tracker string) (float64, int64, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	points := s.tracks[tracker]
+	if len(points) < 2 {
+		return 0, 0, false
+	}
+	previous, last := points[len(points)-2], points[len(points)-1]
+	return Distance(previous.Lat, previous.Lon, last.Lat, last.Lon), last.Time - previous.Time, true
+}
+
+// collectSpeed is the reported speed and the one computed from the track,
+// plus both and the distance walked in -metrics.units. Callers hold the mutex.
+func (e *Exporter) collectSpeed(ch chan<- prometheus.Metric, id string, p *Position) {
+	ch <- prometheus.MustNewConstMetric(trackerSpeed, prometheus.GaugeValue, p.Speed, id)
+
+	computed, known := 0.0, false
+	if meters, seconds, ok := e.tracks.lastHop(id); ok && seconds > 0 {
+		computed, known = meters/float64(seconds), true
+		ch <- prometheus.MustNewConstMetric(trackerComputedSpeed, prometheus.GaugeValue, computed, id)
+	}
+
+	m := unitMetricSet
+	if m == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(m.speed, prometheus.GaugeValue, m.units.speed(p.Speed), id)
+	if known {
+		ch <- prometheus.MustNewConstMetric(m.computedSpeed, prometheus.GaugeValue, m.units.speed(computed), id)
+	}
+	memory := e.mapOfTrackerGeoMemory[id]
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+		m.distance, prometheus.CounterValue, m.units.distance(memory.odometer), memory.created, id,
+	)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The reported speed always, the computed one with two positions, both and
+// the odometer again in -metrics.units
+func TestCollectSpeed(t *testing.T) {
+	units, set := *metricsUnits, unitMetricSet
+	defer func() { *metricsUnits, unitMetricSet = units, set }()
+
+	now := time.Now().Unix()
+	for _, tc := range []struct {
+		name  string
+		units string
+		track [][3]float64
+		want  []string
+	}{
+		{name: "one position", track: [][3]float64{{0, 52.520, 13.405}}, want: []string{
+			"tractive_speed{tracker=rex} 2",
+		}},
+		{name: "two positions", track: [][3]float64{{0, 52.520, 13.405}, {100, 52.521, 13.405}}, want: []string{
+			"tractive_computed_speed_meters_per_second{tracker=rex} 1.11",
+			"tractive_speed{tracker=rex} 2",
+		}},
+		{name: "same second", track: [][3]float64{{0, 52.520, 13.405}, {0, 52.521, 13.405}}, want: []string{
+			"tractive_speed{tracker=rex} 2",
+		}},
+		{name: "km/h", units: "metric", track: [][3]float64{{0, 52.520, 13.405}, {100, 52.521, 13.405}}, want: []string{
+			"tractive_computed_speed_kmh{tracker=rex} 4.01",
+			"tractive_computed_speed_meters_per_second{tracker=rex} 1.11",
+			"tractive_distance_kilometers_total{tracker=rex} 1.5",
+			"tractive_speed_kmh{tracker=rex} 7.2",
+			"tractive_speed{tracker=rex} 2",
+		}},
+		{name: "mph", units: "imperial", track: [][3]float64{{0, 52.520, 13.405}, {100, 52.521, 13.405}}, want: []string{
+			"tractive_computed_speed_meters_per_second{tracker=rex} 1.11",
+			"tractive_computed_speed_mph{tracker=rex} 2.49",
+			"tractive_distance_miles_total{tracker=rex} 0.93",
+			"tractive_speed_mph{tracker=rex} 4.47",
+			"tractive_speed{tracker=rex} 2",
+		}},
+	} {
+		*metricsUnits, unitMetricSet = tc.units, nil
+		if err := setupMetricsUnits(); err != nil {
+			t.Fatal(err)
+		}
+		e := NewExporter([]string{"rex"}, make(map[uniqueGeoStates]uniqueGeoStatesValue), map[string]geoMemory{
+			"rex": {odometer: 1500, created: time.Now()},
+		})
+		var p *Position
+		for _, point := range tc.track {
+			p = &Position{}
+			p.Time, p.Lat, p.Lon, p.Speed = now+int64(point[0]), point[1], point[2], 2
+			e.tracks.add("rex", p)
+		}
+
+		ch := make(chan prometheus.Metric, 10)
+		e.collectSpeed(ch, "rex", p)
+		close(ch)
+		var got []string
+		for m := range ch {
+			got = append(got, roundedValue(exposed(t, m)))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	*metricsUnits = "furlongs"
+	if err := setupMetricsUnits(); err == nil {
+		t.Errorf("-metrics.units=furlongs went through")
+	}
+}
+
+// roundedValue keeps two decimals of an exposed metric
+func roundedValue(s string) string {
+	at := strings.LastIndex(s, " ")
+	value, _ := strconv.ParseFloat(s[at+1:], 64)
+	return s[:at+1] + strconv.FormatFloat(math.Round(value*100)/100, 'g', -1, 64)
+}
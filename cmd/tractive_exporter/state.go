@@ -0,0 +1,28 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+)
+
+// trackerState is a flat view of what we know about a tracker, for the
+// bits where users write their own logic (scripts, alert rules)
+func (e *Exporter) trackerState(id string, p *Position) map[string]interface{} {
+	memory := e.mapOfTrackerGeoMemory[id]
+	return map[string]interface{}{
+		"time":               p.Time,
+		"age":                float64(time.Now().Unix() - p.Time),
+		"lat":                p.Lat,
+		"lon":                p.Lon,
+		"speed":              p.Speed,
+		"alt":                e.altitude(id, p),
+		"live":               p.Live,
+		"battery":            int64(p.Battery),
+		"geohash":            geohash.Encode(p.Lat, p.Lon),
+		"distance":           memory.distance,
+		"distance_interval":  memory.age.Seconds(),
+		"zones":              e.zonesAt(p.Lat, p.Lon),
+		"distance_from_home": distanceFromHome(id, p),
+	}
+}
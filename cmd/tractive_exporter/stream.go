@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// a live map shouldn't poll the JSON API every second
+	streamMaxClients = flag.Int("stream.max-clients", 100,
+		"How many clients /api/v1/stream serves at once, 0 is off")
+
+	streamClients = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "stream", "clients"),
+		"Number of clients connected to /api/v1/stream",
+		nil, nil,
+	)
+
+	streamDropped = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "stream", "dropped_events_total"),
+		"Number of positions not sent to a /api/v1/stream client that was too slow to take them",
+		nil, nil,
+	)
+)
+
+// Proxies close connections that are quiet for a minute or so
+const streamKeepAlive = 30 * time.Second
+
+// streamHub hands new positions to the clients of /api/v1/stream
+type streamHub struct {
+	sync.Mutex
+	clients map[*streamClient]struct{}
+	dropped int64
+	created time.Time
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// streamClient is one connection, with the trackers it asked for, all
+// of them when none
+type streamClient struct {
+	trackers map[string]bool
+	events   chan latestPosition
+}
+
+var streams = &streamHub{
+	clients: make(map[*streamClient]struct{}),
+	created: time.Now(),
+	closed:  make(chan struct{}),
+}
+
+// listening is false without clients, so nobody builds events for no one
+func (h *streamHub) listening() bool {
+	h.Lock()
+	defer h.Unlock()
+	return len(h.clients) > 0
+}
+
+// publish never blocks the bus, a client that's behind misses positions
+func (h *streamHub) publish(latest latestPosition) {
+	h.Lock()
+	defer h.Unlock()
+	for c := range h.clients {
+		if c.trackers != nil && !c.trackers[latest.Tracker] {
+			continue
+		}
+		select {
+		case c.events <- latest:
+		default:
+			h.dropped++
+		}
+	}
+}
+
+// join is false when there are -stream.max-clients already
+func (h *streamHub) join(c *streamClient) bool {
+	h.Lock()
+	defer h.Unlock()
+	if len(h.clients) >= *streamMaxClients {
+		return false
+	}
+	h.clients[c] = struct{}{}
+	return true
+}
+
+// leave ...
+func (h *streamHub) leave(c *streamClient) {
+	h.Lock()
+	defer h.Unlock()
+	delete(h.clients, c)
+}
+
+// close ends all streams, server.Shutdown doesn't wait for them otherwise
+func (h *streamHub) close() {
+	h.once.Do(func() { close(h.closed) })
+}
+
+// streamHandler serves /api/v1/stream as server-sent events, a position
+// event with the JSON of /api/v1/positions for every new report, starting
+// with the latest ones. ?tracker=a,b picks trackers, ?units= like elsewhere.
+func (e *Exporter) streamHandler(w http.ResponseWriter, r *http.Request) {
+	if *streamMaxClients <= 0 {
+		http.Error(w, "the stream is off, see -stream.max-clients", http.StatusNotFound)
+		return
+	}
+	units, err := apiUnits(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subscriber := &streamClient{events: make(chan latestPosition, 16)}
+	for _, v := range r.URL.Query()["tracker"] {
+		for _, id := range deleteEmpty(strings.Split(v, ",")) {
+			if !e.isConfigured(id) {
+				http.Error(w, "unknown tracker "+id, http.StatusNotFound)
+				return
+			}
+			if subscriber.trackers == nil {
+				subscriber.trackers = make(map[string]bool)
+			}
+			subscriber.trackers[id] = true
+		}
+	}
+	if !streams.join(subscriber) {
+		http.Error(w, "too many clients, see -stream.max-clients", http.StatusServiceUnavailable)
+		return
+	}
+	defer streams.leave(subscriber)
+
+	flusher := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(latest latestPosition) error {
+		data, err := json.Marshal(latest.in(units))
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: position\ndata: %s\n\n", data); err != nil {
+			return err
+		}
+		return flusher.Flush()
+	}
+
+	// the map has something to show right away
+	for _, latest := range e.latestPositions(nil) {
+		if subscriber.trackers != nil && !subscriber.trackers[latest.Tracker] {
+			continue
+		}
+		if send(latest) != nil {
+			return
+		}
+	}
+	if flusher.Flush() != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case latest := <-subscriber.events:
+			if send(latest) != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil || flusher.Flush() != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-streams.closed:
+			return
+		}
+	}
+}
+
+// collect ...
+func (h *streamHub) collect(ch chan<- prometheus.Metric) {
+	h.Lock()
+	defer h.Unlock()
+	ch <- prometheus.MustNewConstMetric(streamClients, prometheus.GaugeValue, float64(len(h.clients)))
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(streamDropped, prometheus.CounterValue, float64(h.dropped), h.created)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+
+	// For the family members who live in spreadsheets
+	summaryURLs = flag.String("summary.urls", "",
+		"Comma separated URLs that get a POST with yesterday's row per tracker every day: date, tracker, name, distance, active minutes, zones visited")
+	summaryFormat = flag.String("summary.format", "csv",
+		"Body of the daily summary: csv (rows without a header, for CSV-append services) or json ({\"rows\": [...]}, for a Google Apps Script)")
+	summaryTime = flag.String("summary.time", "00:10",
+		"Time of day in -timezone the summary of the day before is sent")
+	summaryActiveSpeed = flag.Float64("summary.active-speed", 0.3,
+		"Meters per second between two positions that count as active minutes")
+)
+
+// Header of the rows, the columns /api/v1/summary.csv has too
+var summaryColumns = []string{"date", "tracker", "name", "distance_m", "active_minutes", "zones"}
+
+// Hops longer than this say nothing about how active the pet was between
+const summaryMaxHop = 10 * time.Minute
+
+// summaryRow is one tracker's day
+type summaryRow struct {
+	Date          string
+	Tracker       string
+	Name          string
+	Distance      float64
+	ActiveMinutes int
+	Zones         []string
+}
+
+// values is the row as the sheet gets it
+func (r summaryRow) values() []string {
+	return []string{r.Date, r.Tracker, r.Name, strconv.FormatFloat(r.Distance, 'f', 0, 64),
+		strconv.Itoa(r.ActiveMinutes), strings.Join(r.Zones, ";")}
+}
+
+// summaryOutput posts the rows once a day
+type summaryOutput struct {
+	queues []*sinkQueue
+	at     time.Duration
+}
+
+// newSummaryOutput is nil without URLs
+func newSummaryOutput() (*summaryOutput, error) {
+	urls := deleteEmpty(strings.Split(*summaryURLs, ","))
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	if *summaryFormat != "csv" && *summaryFormat != "json" {
+		return nil, fmt.Errorf("-summary.format must be csv or json, not %q", *summaryFormat)
+	}
+	at, err := time.Parse("15:04", *summaryTime)
+	if err != nil {
+		return nil, fmt.Errorf("-summary.time must be like 00:10, not %q", *summaryTime)
+	}
+
+	s := &summaryOutput{at: time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute}
+	contentType := "text/csv"
+	if *summaryFormat == "json" {
+		contentType = "application/json"
+	}
+	client := &http.Client{Timeout: *webhookTimeout}
+	for i, url := range urls {
+		name := fmt.Sprintf("summary%d", i+1)
+		slog.Info("Output posts a daily summary", "output", name, "url", redactURL(url), "format", *summaryFormat, "at", *summaryTime)
+		s.queues = append(s.queues, newSinkQueue(name, summarySink{url: strings.TrimSpace(url), contentType: contentType, client: client}, *sinkRetries))
+	}
+	return s, nil
+}
+
+// summarize is a day of every tracker, from the kept track
+func (e *Exporter) summarize(day time.Time) []summaryRow {
+	from := day.Unix()
+	to := day.AddDate(0, 0, 1).Unix()
+
+	var rows []summaryRow
+	for _, id := range e.trackers() {
+		row := summaryRow{Date: day.Format("2006-01-02"), Tracker: id}
+		zones := make(map[string]bool)
+		var active time.Duration
+		var previous *trackPoint
+
+		e.mutex.Lock()
+		row.Name = e.trackerName(id)
+		for _, point := range e.tracks.points(id, from) {
+			if point.Time >= to {
+				break
+			}
+			for _, fence := range e.geofences {
+				if fence.contains(point.Lat, point.Lon) {
+					zones[fence.name] = true
+				}
+			}
+			if previous != nil {
+				meters := Distance(previous.Lat, previous.Lon, point.Lat, point.Lon)
+				hop := time.Duration(point.Time-previous.Time) * time.Second
+				row.Distance += meters
+				if hop > 0 && hop <= summaryMaxHop && meters/hop.Seconds() >= *summaryActiveSpeed {
+					active += hop
+				}
+			}
+			point := point
+			previous = &point
+		}
+		e.mutex.Unlock()
+
+		row.ActiveMinutes = int(active.Minutes())
+		for zone := range zones {
+			row.Zones = append(row.Zones, zone)
+		}
+		sort.Strings(row.Zones)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// renderSummary is the body in -summary.format
+func renderSummary(rows []summaryRow, format string) ([]byte, error) {
+	var body bytes.Buffer
+	if format == "json" {
+		values := make([][]string, len(rows))
+		for i, row := range rows {
+			values[i] = row.values()
+		}
+		err := json.NewEncoder(&body).Encode(map[string]interface{}{"columns": summaryColumns, "rows": values})
+		return body.Bytes(), err
+	}
+	w := csv.NewWriter(&body)
+	for _, row := range rows {
+		w.Write(row.values())
+	}
+	w.Flush()
+	return body.Bytes(), w.Error()
+}
+
+// runSummary sends yesterday's rows every day at -summary.time
+func (e *Exporter) runSummary() {
+	s := e.summary
+	for {
+		now := localNow()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, homeLocation)
+		next := today.Add(s.at)
+		if !next.After(now) {
+			next = today.AddDate(0, 0, 1).Add(s.at)
+		}
+		time.Sleep(time.Until(next))
+
+		now = localNow()
+		yesterday := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, homeLocation)
+		body, err := renderSummary(e.summarize(yesterday), *summaryFormat)
+		if err != nil {
+			slog.Error("Could not render the daily summary", "err", err)
+			continue
+		}
+		for _, q := range s.queues {
+			q.enqueue(sinkEvent{Event: "summary", Body: body})
+		}
+	}
+}
+
+// summaryHandler serves /api/v1/summary.csv?day=2024-05-01, yesterday
+// by default, with a header for opening in a spreadsheet
+func (e *Exporter) summaryHandler(w http.ResponseWriter, r *http.Request) {
+	now := localNow()
+	day := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, homeLocation)
+	if v := r.URL.Query().Get("day"); v != "" {
+		var err error
+		if day, err = time.ParseInLocation("2006-01-02", v, homeLocation); err != nil {
+			http.Error(w, "day must be like 2024-05-01", http.StatusBadRequest)
+			return
+		}
+	}
+	body, err := renderSummary(e.summarize(day), "csv")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=tractive-%s.csv", day.Format("2006-01-02")))
+	fmt.Fprintln(w, strings.Join(summaryColumns, ","))
+	w.Write(body)
+}
+
+// summarySink posts every body of a batch to one URL
+type summarySink struct {
+	url         string
+	contentType string
+	client      *http.Client
+}
+
+// deliver ...
+func (s summarySink) deliver(batch []sinkEvent) error {
+	for _, event := range batch {
+		resp, err := s.client.Post(s.url, s.contentType, bytes.NewReader(event.Body))
+		if urlErr, ok := err.(*neturl.Error); ok {
+			return urlErr.Err
+		}
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s", resp.Status)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"embed"
+	"flag"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+var (
+
+	// Brand it without forking
+	templatesDir = flag.String("web.templates", "",
+		"Directory with templates overriding the embedded ones (e.g. index.html or messages/de.yaml)")
+)
+
+// landingPage is what index.html gets
+type landingPage struct {
+	ExternalPath string
+	MetricsPath  string
+	Map          bool
+	Public       bool
+	Trackers     []string
+	Names        map[string]string
+	Units        displayUnits
+	Summaries    map[string]trackerSummary
+}
+
+// trackerSummary is the last good position in the visitor's units
+type trackerSummary struct {
+	Speed    float64
+	Altitude float64
+	Last24h  float64
+}
+
+// loadTemplate prefers the one on disk, parsed on every call so edits
+// show up without a restart
+func loadTemplate(name string) (*template.Template, error) {
+	if *templatesDir != "" {
+		path := filepath.Join(*templatesDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return template.ParseFiles(path)
+		}
+	}
+	return template.ParseFS(embeddedTemplates, "templates/"+name)
+}
+
+// renderTemplate ...
+func renderTemplate(w http.ResponseWriter, name string, data interface{}) {
+	t, err := loadTemplate(name)
+	if err != nil {
+		slog.Error("Template error", "err", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		slog.Error("Template error", "err", err)
+	}
+}
+
+// landingHandler ...
+func (e *Exporter) landingHandler(w http.ResponseWriter, r *http.Request) {
+	units := pageUnits(w, r)
+	names := make(map[string]string)
+	summaries := make(map[string]trackerSummary)
+	since := time.Now().Add(-24 * time.Hour).Unix()
+	e.mutex.Lock()
+	for _, id := range e.trackers() {
+		if name := e.trackerName(id); name != "" {
+			names[id] = name
+		}
+	}
+	for id, poll := range e.mapOfPollState {
+		if p := poll.lastGood; p != nil {
+			summaries[id] = trackerSummary{
+				Speed:    units.speed(p.Speed),
+				Altitude: units.altitude(e.altitude(id, p)),
+				Last24h:  units.distance(e.distanceHistory.total(id, since)),
+			}
+		}
+	}
+	e.mutex.Unlock()
+
+	renderTemplate(w, "index.html", landingPage{
+		ExternalPath: webExternalPath(),
+		MetricsPath:  *metricsPath,
+		Map:          *webMap && !*hideCoordinates,
+		Public:       *webPublic,
+		Trackers:     e.trackers(),
+		Names:        names,
+		Units:        units,
+		Summaries:    summaries,
+	})
+}
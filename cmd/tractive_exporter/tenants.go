@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+var (
+
+	// One instance, several families
+	tenants = flag.String("web.tenants", "",
+		"Tracker groups served on their own endpoint under -web.path, e.g. household-a:id1,id2;household-b:id3")
+)
+
+// parseTenants turns "a:id1,id2;b:id3" into a map
+func parseTenants(s string) map[string][]string {
+	m := make(map[string][]string)
+	for _, group := range deleteEmpty(strings.Split(s, ";")) {
+		parts := strings.SplitN(group, ":", 2)
+		if len(parts) != 2 {
+			slog.Warn("Ignoring tenant without trackers", "tenant", group)
+			continue
+		}
+		m[strings.TrimSpace(parts[0])] = deleteEmpty(strings.Split(parts[1], ","))
+	}
+	return m
+}
+
+// handleTenants mounts one metrics endpoint per tenant, each only
+// seeing its own trackers
+func (e *Exporter) handleTenants(mux *http.ServeMux) {
+	for tenant, trackers := range parseTenants(*tenants) {
+		var known []string
+		for _, id := range trackers {
+			if !e.isConfigured(id) {
+				slog.Warn("Tracker is not configured, skipping it", "tenant", tenant, "tracker", id)
+				continue
+			}
+			known = append(known, id)
+		}
+		mux.Handle(strings.TrimRight(*metricsPath, "/")+"/"+tenant, e.subsetHandler(known))
+	}
+}
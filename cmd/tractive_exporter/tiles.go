@@ -0,0 +1,103 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Beyond this a pixel is smaller than the GPS error anyway
+const maxTileZoom = 20
+
+// simplifyTrack is Douglas-Peucker with a tolerance in degrees, first and
+// last point always stay
+func simplifyTrack(points []trackPoint, tolerance float64) []trackPoint {
+	if len(points) < 3 {
+		return points
+	}
+
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		farthest, distance := 0, 0.0
+		for i := span[0] + 1; i < span[1]; i++ {
+			if d := segmentDistance(points[i], points[span[0]], points[span[1]]); d > distance {
+				farthest, distance = i, d
+			}
+		}
+		if distance > tolerance {
+			keep[farthest] = true
+			stack = append(stack, [2]int{span[0], farthest}, [2]int{farthest, span[1]})
+		}
+	}
+
+	var out []trackPoint
+	for i, point := range points {
+		if keep[i] {
+			out = append(out, point)
+		}
+	}
+	return out
+}
+
+// segmentDistance of p from the segment a-b, in flat degrees, good enough
+// for deciding what to drop
+func segmentDistance(p, a, b trackPoint) float64 {
+	dx, dy := b.Lon-a.Lon, b.Lat-a.Lat
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p.Lon-a.Lon, p.Lat-a.Lat)
+	}
+	t := ((p.Lon-a.Lon)*dx + (p.Lat-a.Lat)*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(p.Lon-(a.Lon+t*dx), p.Lat-(a.Lat+t*dy))
+}
+
+// tileTolerance is a pixel of a 256px web map tile at the zoom level
+func tileTolerance(zoom int) float64 {
+	return 360 / (256 * math.Pow(2, float64(zoom)))
+}
+
+// tilesHandler serves /tiles/{tracker}/{zoom} as a GeoJSON LineString
+// simplified for that zoom level
+func (e *Exporter) tilesHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/tiles/"), "/"), "/")
+	if len(parts) != 2 || !e.isConfigured(parts[0]) {
+		http.NotFound(w, r)
+		return
+	}
+	if *hideCoordinates {
+		http.Error(w, "coordinates are hidden", http.StatusForbidden)
+		return
+	}
+	zoom, err := strconv.Atoi(strings.TrimSuffix(parts[1], ".geojson"))
+	if err != nil || zoom < 0 || zoom > maxTileZoom {
+		http.Error(w, "zoom must be 0 to 20", http.StatusBadRequest)
+		return
+	}
+
+	points := e.tracks.points(parts[0], 0)
+	simplified := simplifyTrack(points, tileTolerance(zoom))
+	coordinates := make([][2]float64, len(simplified))
+	for i, point := range simplified {
+		coordinates[i] = [2]float64{point.Lon, point.Lat}
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"type": "Feature",
+		"geometry": map[string]interface{}{
+			"type":        "LineString",
+			"coordinates": coordinates,
+		},
+		"properties": map[string]interface{}{
+			"tracker": parts[0],
+			"zoom":    zoom,
+			"points":  len(simplified),
+			"of":      len(points),
+		},
+	})
+}
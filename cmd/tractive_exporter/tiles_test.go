@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Points within the tolerance of the line between their neighbours go,
+// corners stay
+func TestSimplifyTrack(t *testing.T) {
+	point := func(lat, lon float64) trackPoint { return trackPoint{Lat: lat, Lon: lon} }
+	for _, tc := range []struct {
+		name      string
+		points    []trackPoint
+		tolerance float64
+		want      []trackPoint
+	}{
+		{name: "too short", points: []trackPoint{point(0, 0), point(1, 1)}, tolerance: 0.1,
+			want: []trackPoint{point(0, 0), point(1, 1)}},
+		{name: "straight", points: []trackPoint{point(0, 0), point(0, 1), point(0, 2), point(0, 3)}, tolerance: 0.1,
+			want: []trackPoint{point(0, 0), point(0, 3)}},
+		{name: "wobble", points: []trackPoint{point(0, 0), point(0.05, 1), point(-0.05, 2), point(0, 3)}, tolerance: 0.1,
+			want: []trackPoint{point(0, 0), point(0, 3)}},
+		{name: "corner", points: []trackPoint{point(0, 0), point(0, 1), point(0, 2), point(1, 2), point(2, 2)}, tolerance: 0.1,
+			want: []trackPoint{point(0, 0), point(0, 2), point(2, 2)}},
+		{name: "back where it started", points: []trackPoint{point(0, 0), point(0, 1), point(0, 0)}, tolerance: 0.1,
+			want: []trackPoint{point(0, 0), point(0, 1), point(0, 0)}},
+		{name: "zoomed in", points: []trackPoint{point(0, 0), point(0.05, 1), point(-0.05, 2), point(0, 3)}, tolerance: 0.01,
+			want: []trackPoint{point(0, 0), point(0.05, 1), point(-0.05, 2), point(0, 3)}},
+	} {
+		if got := simplifyTrack(tc.points, tc.tolerance); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// A pixel is half as many degrees a zoom level further in
+func TestTileTolerance(t *testing.T) {
+	if got := tileTolerance(0); got != 360.0/256 {
+		t.Errorf("zoom 0: %g degrees a pixel", got)
+	}
+	if tileTolerance(11) != tileTolerance(10)/2 {
+		t.Errorf("zoom 11 isn't twice as fine as 10")
+	}
+}
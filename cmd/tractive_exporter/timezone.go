@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	// the alpine image and Windows have no zoneinfo to speak of
+	_ "time/tzdata"
+)
+
+var (
+
+	// The server sits in UTC, the dog doesn't
+	timezone = flag.String("timezone", "",
+		"Home time zone like Europe/Vienna for days, nights and event times, the server's local zone by default")
+)
+
+// Where days start and nights are, set by setupTimezone
+var homeLocation = time.Local
+
+// setupTimezone loads -timezone
+func setupTimezone() error {
+	if *timezone == "" {
+		return nil
+	}
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		return fmt.Errorf("-timezone: %v", err)
+	}
+	homeLocation = location
+	return nil
+}
+
+// localTime is a unix time in the home time zone
+func localTime(t int64) time.Time {
+	return time.Unix(t, 0).In(homeLocation)
+}
+
+// localNow ...
+func localNow() time.Time {
+	return time.Now().In(homeLocation)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Days and nights are in -timezone, the server's zone without it
+func TestSetupTimezone(t *testing.T) {
+	zone, location := *timezone, homeLocation
+	defer func() { *timezone, homeLocation = zone, location }()
+
+	at := time.Date(2026, 10, 16, 22, 30, 0, 0, time.UTC).Unix()
+	for _, tc := range []struct {
+		zone string
+		want string
+		err  bool
+	}{
+		{zone: "Europe/Vienna", want: "2026-10-17 00:30"},
+		{zone: "America/New_York", want: "2026-10-16 18:30"},
+		{zone: "UTC", want: "2026-10-16 22:30"},
+		{zone: "Mars/Olympus_Mons", err: true},
+	} {
+		*timezone, homeLocation = tc.zone, time.UTC
+		err := setupTimezone()
+		if (err != nil) != tc.err {
+			t.Errorf("%s: error %v", tc.zone, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := localTime(at).Format("2006-01-02 15:04"); got != tc.want {
+			t.Errorf("%s: %s, want %s", tc.zone, got, tc.want)
+		}
+	}
+
+	*timezone, homeLocation = "", time.Local
+	if err := setupTimezone(); err != nil || homeLocation != time.Local {
+		t.Errorf("without -timezone: %v in %s", err, homeLocation)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// What the maps and exports draw from
+	trackRetention = flag.Duration("track.retention", 7*24*time.Hour,
+		"How long reported positions are kept in memory for tracks and exports")
+	trackMaxPositions = flag.Int("track.max-positions", 0,
+		"Most positions kept per tracker, the oldest go first, 0 for only -track.retention")
+
+	historyPositions = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "history", "positions"),
+		"Number of positions kept for the tracker",
+		[]string{"tracker"}, nil,
+	)
+
+	historyOldest = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "history", "oldest_timestamp_seconds"),
+		"Timestamp of the oldest position kept for the tracker in seconds since epoch",
+		[]string{"tracker"}, nil,
+	)
+
+	historyNewest = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "history", "newest_timestamp_seconds"),
+		"Timestamp of the newest position kept for the tracker in seconds since epoch",
+		[]string{"tracker"}, nil,
+	)
+
+	historyDays = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "history", "days"),
+		"Number of distinct days (UTC) with positions kept for the tracker",
+		[]string{"tracker"}, nil,
+	)
+
+	historySize = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "history", "size_bytes"),
+		"Approximate memory taken by the kept positions in bytes",
+		nil, nil,
+	)
+
+	historyPruned = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "history", "pruned_total"),
+		"Number of positions dropped for being older than -track.retention or past -track.max-positions",
+		nil, nil,
+	)
+)
+
+// trackPoint is one reported position
+type trackPoint struct {
+	Time  int64   `json:"time"`
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+	Speed float64 `json:"speed"`
+	Alt   int     `json:"alt"`
+}
+
+// trackStore keeps the recent positions per tracker, oldest first
+type trackStore struct {
+	sync.Mutex
+	retention time.Duration
+	tracks    map[string][]trackPoint
+	pruned    int64
+	created   time.Time
+}
+
+func newTrackStore(retention time.Duration) *trackStore {
+	return &trackStore{
+		retention: retention,
+		tracks:    make(map[string][]trackPoint),
+		created:   time.Now(),
+	}
+}
+
+// add appends a position and forgets what's past retention
+func (s *trackStore) add(tracker string, p *Position) {
+	s.Lock()
+	defer s.Unlock()
+
+	points := append(s.tracks[tracker], trackPoint{Time: p.Time, Lat: p.Lat, Lon: p.Lon, Speed: p.Speed, Alt: p.Alt})
+	oldest := time.Now().Add(-s.retention).Unix()
+	for len(points) > 0 && points[0].Time < oldest {
+		points = points[1:]
+		s.pruned++
+	}
+	if limit := *trackMaxPositions; limit > 0 && len(points) > limit {
+		s.pruned += int64(len(points) - limit)
+		points = points[len(points)-limit:]
+	}
+	s.tracks[tracker] = points
+}
+
+// shrink lowers the retention and prunes right away, into new slices so
+// the old arrays can go
+func (s *trackStore) shrink(retention time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.retention = retention
+	oldest := time.Now().Add(-retention).Unix()
+	for tracker, points := range s.tracks {
+		kept := 0
+		for kept < len(points) && points[kept].Time < oldest {
+			kept++
+		}
+		s.pruned += int64(kept)
+		s.tracks[tracker] = append([]trackPoint(nil), points[kept:]...)
+	}
+}
+
+// points returns a copy of the positions reported since a unix time
+func (s *trackStore) points(tracker string, since int64) []trackPoint {
+	s.Lock()
+	defer s.Unlock()
+
+	var out []trackPoint
+	for _, point := range s.tracks[tracker] {
+		if point.Time >= since {
+			out = append(out, point)
+		}
+	}
+	return out
+}
+
+// collect exposes how much history there is, for keeping an eye on the store itself
+func (s *trackStore) collect(ch chan<- prometheus.Metric, trackers []string) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, id := range trackers {
+		points := s.tracks[id]
+		ch <- prometheus.MustNewConstMetric(historyPositions, prometheus.GaugeValue, float64(len(points)), id)
+		if len(points) == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(historyOldest, prometheus.GaugeValue, float64(points[0].Time), id)
+		ch <- prometheus.MustNewConstMetric(historyNewest, prometheus.GaugeValue, float64(points[len(points)-1].Time), id)
+
+		days := make(map[int64]bool)
+		for _, point := range points {
+			days[point.Time/86400] = true
+		}
+		ch <- prometheus.MustNewConstMetric(historyDays, prometheus.GaugeValue, float64(len(days)), id)
+	}
+
+	size := 0
+	for _, points := range s.tracks {
+		size += cap(points) * int(unsafe.Sizeof(trackPoint{}))
+	}
+	ch <- prometheus.MustNewConstMetric(historySize, prometheus.GaugeValue, float64(size))
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(historyPruned, prometheus.CounterValue, float64(s.pruned), s.created)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+
+	// Next to the archive from Tractive, in the same shape
+	exportFormat = flag.String("export.format", "json",
+		"What the export command writes: json (Tractive's position segments) or csv (the same fields, one position per row)")
+	exportFrom = flag.String("export.from", "",
+		"Start of the export command's range as a unix timestamp, RFC 3339 or a duration ago, the whole -track.retention by default")
+	exportTo = flag.String("export.to", "",
+		"End of the export command's range, now by default")
+)
+
+// A pause longer than this starts a new segment, like the app's history
+const tractiveSegmentGap = 10 * time.Minute
+
+// tractivePosition is a position the way Tractive's position history has
+// it, in segments of positions
+type tractivePosition struct {
+	Time    int64      `json:"time"`
+	LatLong [2]float64 `json:"latlong"`
+	Alt     int        `json:"alt"`
+	Speed   float64    `json:"speed"`
+}
+
+// Columns of the CSV, the JSON fields with latlong split
+var tractiveColumns = []string{"segment", "time", "lat", "lon", "alt", "speed"}
+
+// tractiveSegments splits positions where they pause
+func tractiveSegments(points []trackPoint) [][]tractivePosition {
+	segments := [][]tractivePosition{}
+	var last int64
+	for _, point := range points {
+		if len(segments) == 0 || time.Duration(point.Time-last)*time.Second > tractiveSegmentGap {
+			segments = append(segments, []tractivePosition{})
+		}
+		segments[len(segments)-1] = append(segments[len(segments)-1], tractivePosition{
+			Time:    point.Time,
+			LatLong: [2]float64{point.Lat, point.Lon},
+			Alt:     point.Alt,
+			Speed:   point.Speed,
+		})
+		last = point.Time
+	}
+	return segments
+}
+
+// writeTractiveJSON ...
+func writeTractiveJSON(w io.Writer, points []trackPoint) error {
+	return json.NewEncoder(w).Encode(tractiveSegments(points))
+}
+
+// writeTractiveCSV ...
+func writeTractiveCSV(w io.Writer, points []trackPoint) error {
+	out := csv.NewWriter(w)
+	out.Write(tractiveColumns)
+	for i, segment := range tractiveSegments(points) {
+		for _, p := range segment {
+			out.Write([]string{
+				strconv.Itoa(i),
+				strconv.FormatInt(p.Time, 10),
+				strconv.FormatFloat(p.LatLong[0], 'f', -1, 64),
+				strconv.FormatFloat(p.LatLong[1], 'f', -1, 64),
+				strconv.Itoa(p.Alt),
+				strconv.FormatFloat(p.Speed, 'f', -1, 64),
+			})
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// runExport is the export command: the kept positions of a tracker from
+// the exporter running on -web.port, to stdout
+func runExport(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: tractive_exporter [-export.format=json|csv] [-export.from=24h] [-export.to=...] export <tracker>")
+		os.Exit(2)
+	}
+	if *exportFormat != "json" && *exportFormat != "csv" {
+		fmt.Fprintf(os.Stderr, "-export.format must be json or csv, not %q\n", *exportFormat)
+		os.Exit(2)
+	}
+	query := neturl.Values{}
+	if *exportFrom != "" {
+		query.Set("from", *exportFrom)
+	}
+	if *exportTo != "" {
+		query.Set("to", *exportTo)
+	}
+	client, url, err := localExporter("/api/v1/tracks/" + neturl.PathEscape(args[0]) + ".tractive." + *exportFormat + "?" + query.Encode())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	client.Timeout = time.Minute
+	resp, err := client.Get(url)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		fmt.Fprintf(os.Stderr, "%s: %s", resp.Status, body)
+		os.Exit(1)
+	}
+	if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// A walk is easier to count than to find in a pile of hops
+	tripStartSpeed = flag.Float64("trips.start-speed", 1,
+		"Speed in m/s a report needs for a trip to start, or keep going")
+	tripStartDistance = flag.Float64("trips.start-distance", 50,
+		"Meters away from where the tracker rested for a trip to start, or from where it last moved to keep going")
+	tripEndAfter = flag.Duration("trips.end-after", 10*time.Minute,
+		"Time without movement after which a trip is over")
+	tripDistanceBuckets = flag.String("trips.distance-buckets", "100,250,500,1000,2500,5000,10000",
+		"Comma separated upper bounds in meters of the tractive_trip_distance_meters histogram")
+	tripDurationBuckets = flag.String("trips.duration-buckets", "300,600,1200,1800,3600,7200",
+		"Comma separated upper bounds in seconds of the tractive_trip_duration_seconds histogram")
+
+	trackerTrips = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "trips_total"),
+		"Number of finished trips, from the first movement to -trips.end-after without any",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerTripDistance = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "trip_distance_meters"),
+		"Distance of every finished trip in meters, after jitter filtering",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerTripDuration = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "trip_duration_seconds"),
+		"Duration of every finished trip in seconds, up to the last movement",
+		[]string{"tracker"}, nil,
+	)
+
+	trackerTripActive = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "trip_active"),
+		"Whether the tracker is on a trip (1) or resting (0)",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// tripState is a tracker resting or on a trip, times are report times
+type tripState struct {
+	active  bool
+	started int64
+	moved   int64
+
+	// where it last moved to, resting it has to get away from there
+	anchorLat, anchorLon float64
+
+	// the last report, hops are measured from it
+	lastLat, lastLon float64
+	last             int64
+
+	distance float64
+	moving   float64
+	trips    int64
+	created  time.Time
+
+	distances *histogram
+	durations *histogram
+}
+
+// updateTrip is called with every new report of a tracker
+func (e *Exporter) updateTrip(id string, p *Position) {
+	trip, ok := e.mapOfTrips[id]
+	if !ok {
+		e.mapOfTrips[id] = tripState{
+			anchorLat: p.Lat, anchorLon: p.Lon,
+			lastLat: p.Lat, lastLon: p.Lon, last: p.Time,
+			created:   time.Now(),
+			distances: newHistogram(e.tripDistanceBuckets),
+			durations: newHistogram(e.tripDurationBuckets),
+		}
+		return
+	}
+
+	// a long gap ended the trip before this report came in
+	trip.end(p.Time)
+
+	away := Distance(trip.anchorLat, trip.anchorLon, p.Lat, p.Lon)
+	moved := p.Speed >= *tripStartSpeed || away >= *tripStartDistance
+	if moved && !trip.active {
+		trip.active = true
+		trip.started = trip.last
+		trip.distance, trip.moving = 0, 0
+	}
+	if trip.active {
+		if hop := Distance(trip.lastLat, trip.lastLon, p.Lat, p.Lon); hop >= *distanceMinDisplacement {
+			trip.distance += hop
+		}
+		if moved {
+			trip.moving = trip.distance
+			trip.moved = p.Time
+		}
+	}
+	if moved {
+		trip.anchorLat, trip.anchorLon = p.Lat, p.Lon
+	}
+	trip.lastLat, trip.lastLon, trip.last = p.Lat, p.Lon, p.Time
+	e.mapOfTrips[id] = trip
+}
+
+// end finishes the trip if it didn't move for -trips.end-after by now,
+// without what it jittered around after the last movement
+func (t *tripState) end(now int64) {
+	if !t.active || time.Duration(now-t.moved)*time.Second < *tripEndAfter {
+		return
+	}
+	t.active = false
+	t.trips++
+	t.distances.observe(t.moving)
+	t.durations.observe(float64(t.moved - t.started))
+}
+
+// collectTrips ends the trip of a tracker that stopped reporting, they
+// go quiet when they rest
+func (e *Exporter) collectTrips(ch chan<- prometheus.Metric, id string) {
+	trip, ok := e.mapOfTrips[id]
+	if !ok {
+		return
+	}
+	trip.end(time.Now().Unix())
+	e.mapOfTrips[id] = trip
+
+	var active float64
+	if trip.active {
+		active = 1
+	}
+	ch <- prometheus.MustNewConstMetric(trackerTripActive, prometheus.GaugeValue, active, id)
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+		trackerTrips, prometheus.CounterValue, float64(trip.trips), trip.created, id,
+	)
+	ch <- trip.distances.metric(trackerTripDistance, id)
+	ch <- trip.durations.metric(trackerTripDuration, id)
+}
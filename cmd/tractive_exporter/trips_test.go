@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// A trip starts with movement and ends -trips.end-after without any, the
+// jitter after the last movement isn't part of it
+func TestTrips(t *testing.T) {
+	speed, distance, endAfter := *tripStartSpeed, *tripStartDistance, *tripEndAfter
+	defer func() { *tripStartSpeed, *tripStartDistance, *tripEndAfter = speed, distance, endAfter }()
+	*tripStartSpeed, *tripStartDistance, *tripEndAfter = 1, 50, 10*time.Minute
+
+	start := time.Now().Unix() - 3600
+	for _, tc := range []struct {
+		name     string
+		reports  [][4]float64
+		active   bool
+		trips    int64
+		meters   float64
+		duration float64
+	}{
+		{name: "resting", reports: [][4]float64{
+			{0, 52.5200, 13.405, 0}, {60, 52.5201, 13.405, 0}, {120, 52.5200, 13.4051, 0.2},
+		}},
+		{name: "walking", reports: [][4]float64{
+			{0, 52.520, 13.405, 0}, {60, 52.521, 13.405, 1.5}, {120, 52.522, 13.405, 1.5},
+		}, active: true},
+		{name: "walked", reports: [][4]float64{
+			{0, 52.520, 13.405, 0}, {60, 52.521, 13.405, 1.5}, {120, 52.522, 13.405, 1.5},
+			{300, 52.52203, 13.405, 0}, {1000, 52.522, 13.405, 0},
+		}, trips: 1, meters: 222, duration: 120},
+		{name: "fast but not far", reports: [][4]float64{
+			{0, 52.520, 13.405, 0}, {60, 52.5201, 13.405, 3}, {1000, 52.5201, 13.405, 0},
+		}, trips: 1, meters: 11, duration: 60},
+	} {
+		e := NewExporter([]string{"rex"}, make(map[uniqueGeoStates]uniqueGeoStatesValue), make(map[string]geoMemory))
+		e.tripDistanceBuckets, e.tripDurationBuckets = []float64{100, 1000}, []float64{60, 600}
+		for _, report := range tc.reports {
+			p := &Position{}
+			p.Time, p.Lat, p.Lon, p.Speed = start+int64(report[0]), report[1], report[2], report[3]
+			e.updateTrip("rex", p)
+		}
+		trip := e.mapOfTrips["rex"]
+		if trip.active != tc.active || trip.trips != tc.trips {
+			t.Errorf("%s: active %v after %d trips, want %v after %d", tc.name, trip.active, trip.trips, tc.active, tc.trips)
+		}
+		if trip.distances.sum < tc.meters-1 || trip.distances.sum > tc.meters+1 || trip.durations.sum != tc.duration {
+			t.Errorf("%s: %.0fm in %gs, want %.0fm in %gs", tc.name, trip.distances.sum, trip.durations.sum, tc.meters, tc.duration)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+
+	// A nice walk is worth showing around, the tracker isn't
+	tripShareDir = flag.String("trips.share-dir", "",
+		"Directory that keeps a shareable page for every finished live tracking session, off when empty")
+	tripShareValidity = flag.Duration("trips.share-validity", 7*24*time.Hour,
+		"How long a shared trip page can be opened")
+	tripShareMinDistance = flag.Float64("trips.share-min-distance", 200,
+		"Meters a live tracking session must cover to get a page")
+)
+
+// Tokens are 16 random bytes, anything else never was one
+var tripTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{22}$`)
+
+// sharedTrip is what a trip page shows, kept as <token>.json. It's a copy,
+// so the page outlives -track.retention and knows nothing of the tracker.
+type sharedTrip struct {
+	Name            string       `json:"name"`
+	Started         int64        `json:"started"`
+	Stopped         int64        `json:"stopped"`
+	Expires         int64        `json:"expires"`
+	DistanceMeters  float64      `json:"distance_meters"`
+	DurationSeconds int64        `json:"duration_seconds"`
+	MaxSpeed        float64      `json:"max_speed"`
+	Points          [][2]float64 `json:"points"`
+}
+
+// tripEvent goes to the hooks when a trip got a page
+type tripEvent struct {
+	Tracker         string  `json:"tracker"`
+	Started         int64   `json:"started"`
+	Stopped         int64   `json:"stopped"`
+	DistanceMeters  float64 `json:"distance_meters"`
+	DurationSeconds int64   `json:"duration_seconds"`
+	URL             string  `json:"url"`
+	Expires         int64   `json:"expires"`
+}
+
+// tripPage is what trip.html gets
+type tripPage struct {
+	sharedTrip
+	Distance    string
+	Duration    string
+	Speed       string
+	MaxSpeed    string
+	Day         string
+	TileURL     string
+	Attribution string
+}
+
+// tripShares writes and serves the pages
+type tripShares struct {
+	dir string
+
+	// sessions that just stopped, under the exporter's mutex
+	finished map[string]liveEvent
+}
+
+// newTripShares is nil without -trips.share-dir
+func newTripShares() (*tripShares, error) {
+	if *tripShareDir == "" {
+		return nil, nil
+	}
+	if *hideCoordinates {
+		return nil, errors.New("-trips.share-dir shows where the pets went, not with -metrics.hide-coordinates")
+	}
+	if err := os.MkdirAll(*tripShareDir, 0o700); err != nil {
+		return nil, err
+	}
+	unauthenticatedPrefixes = append(unauthenticatedPrefixes, "/trips/")
+	t := &tripShares{dir: *tripShareDir, finished: make(map[string]liveEvent)}
+	t.sweep()
+	return t, nil
+}
+
+// shareTrip turns a finished live tracking session into a page and tells
+// the hooks, the file is written on the side. Callers hold the mutex.
+func (e *Exporter) shareTrip(session liveEvent) {
+	trip := sharedTrip{
+		Name:            e.trackerName(session.Tracker),
+		Started:         session.Started,
+		Stopped:         session.Stopped,
+		Expires:         time.Now().Add(*tripShareValidity).Unix(),
+		DurationSeconds: session.Duration,
+	}
+	var previous *trackPoint
+	for _, point := range e.tracks.points(session.Tracker, session.Started) {
+		if point.Time > session.Stopped {
+			break
+		}
+		if previous != nil {
+			meters := Distance(previous.Lat, previous.Lon, point.Lat, point.Lon)
+			trip.DistanceMeters += meters
+			if seconds := point.Time - previous.Time; seconds > 0 {
+				trip.MaxSpeed = max(trip.MaxSpeed, meters/float64(seconds))
+			}
+		}
+		trip.Points = append(trip.Points, [2]float64{point.Lat, point.Lon})
+		point := point
+		previous = &point
+	}
+	if len(trip.Points) < 2 || trip.DistanceMeters < *tripShareMinDistance {
+		slog.Debug("Trip too short to share", "tracker", session.Tracker, "meters", trip.DistanceMeters)
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		slog.Error("Could not share the trip", "tracker", session.Tracker, "err", err)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	link := webExternalPath() + "/trips/" + token
+	if *externalURL != "" {
+		link = strings.TrimRight(*externalURL, "/") + "/trips/" + token
+	}
+
+	go func() {
+		if err := e.trips.write(token, trip); err != nil {
+			slog.Error("Could not share the trip", "tracker", session.Tracker, "err", err)
+			return
+		}
+		slog.Info("Shared a trip", "tracker", session.Tracker, "meters", int(trip.DistanceMeters), "expires", time.Unix(trip.Expires, 0))
+	}()
+	e.bus.publish("trip", session.Tracker, tripEvent{
+		Tracker:         session.Tracker,
+		Started:         trip.Started,
+		Stopped:         trip.Stopped,
+		DistanceMeters:  trip.DistanceMeters,
+		DurationSeconds: trip.DurationSeconds,
+		URL:             link,
+		Expires:         trip.Expires,
+	})
+}
+
+// write keeps a trip and drops the expired ones
+func (t *tripShares) write(token string, trip sharedTrip) error {
+	b, err := json.Marshal(trip)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(t.dir, token+".json")
+	if err := os.WriteFile(path+".tmp", b, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	t.sweep()
+	return nil
+}
+
+// read is the trip of a token, nil when there's none or it expired
+func (t *tripShares) read(token string) (*sharedTrip, error) {
+	if !tripTokenPattern.MatchString(token) {
+		return nil, nil
+	}
+	b, err := os.ReadFile(filepath.Join(t.dir, token+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var trip sharedTrip
+	if err := json.Unmarshal(b, &trip); err != nil {
+		return nil, fmt.Errorf("%s: %v", token, err)
+	}
+	if time.Now().Unix() > trip.Expires {
+		return nil, nil
+	}
+	return &trip, nil
+}
+
+// sweep deletes the trips past their expiry
+func (t *tripShares) sweep() {
+	paths, _ := filepath.Glob(filepath.Join(t.dir, "*.json"))
+	for _, path := range paths {
+		token := strings.TrimSuffix(filepath.Base(path), ".json")
+		if trip, err := t.read(token); trip == nil && err == nil {
+			os.Remove(path)
+		}
+	}
+}
+
+// tripHandler serves /trips/{token}, a map of one finished trip without a
+// password, the token is the password
+func (e *Exporter) tripHandler(w http.ResponseWriter, r *http.Request) {
+	if e.trips == nil {
+		http.Error(w, "shared trips are off, see -trips.share-dir", http.StatusNotFound)
+		return
+	}
+	trip, err := e.trips.read(r.PathValue("token"))
+	if err != nil {
+		slog.Error("Could not read a shared trip", "err", err)
+		http.Error(w, "could not read the trip", http.StatusInternalServerError)
+		return
+	}
+	if trip == nil {
+		http.Error(w, "this trip doesn't exist or the link expired", http.StatusNotFound)
+		return
+	}
+
+	units := unitProfiles[*webUnits]
+	var speed float64
+	if trip.DurationSeconds > 0 {
+		speed = trip.DistanceMeters / float64(trip.DurationSeconds)
+	}
+	if trip.Name == "" {
+		trip.Name = "A pet"
+	}
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	renderTemplate(w, "trip.html", tripPage{
+		sharedTrip:  *trip,
+		Distance:    fmt.Sprintf("%.2f %s", units.distance(trip.DistanceMeters), units.Distance),
+		Duration:    (time.Duration(trip.DurationSeconds) * time.Second).String(),
+		Speed:       fmt.Sprintf("%.1f %s", units.speed(speed), units.Speed),
+		MaxSpeed:    fmt.Sprintf("%.1f %s", units.speed(trip.MaxSpeed), units.Speed),
+		Day:         localTime(trip.Started).Format("Monday, 2 January 2006, 15:04"),
+		TileURL:     *mapTileURL,
+		Attribution: *mapAttribution,
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+
+	// Metrics stay in base units, people don't have to
+	webUnits = flag.String("web.units", "metric",
+		"Units the landing page shows speeds and distances in until a visitor picks some: metric or imperial")
+)
+
+// displayUnits converts from meters and meters per second for people
+type displayUnits struct {
+	Name           string `json:"name"`
+	Speed          string `json:"speed"`
+	Distance       string `json:"distance"`
+	Altitude       string `json:"altitude"`
+	speedFactor    float64
+	distanceFactor float64
+	altitudeFactor float64
+}
+
+// Profiles by name
+var unitProfiles = map[string]displayUnits{
+	"metric": {
+		Name: "metric", Speed: "km/h", Distance: "km", Altitude: "m",
+		speedFactor: 3.6, distanceFactor: 0.001, altitudeFactor: 1,
+	},
+	"imperial": {
+		Name: "imperial", Speed: "mph", Distance: "mi", Altitude: "ft",
+		speedFactor: 2.236936, distanceFactor: 0.000621371, altitudeFactor: 3.28084,
+	},
+}
+
+func (u displayUnits) speed(metersPerSecond float64) float64 { return metersPerSecond * u.speedFactor }
+func (u displayUnits) distance(meters float64) float64       { return meters * u.distanceFactor }
+func (u displayUnits) altitude(meters float64) float64       { return meters * u.altitudeFactor }
+
+// apiUnits reads ?units= of API requests, without it everything stays in
+// meters and meters per second
+func apiUnits(r *http.Request) (*displayUnits, error) {
+	name := r.URL.Query().Get("units")
+	if name == "" {
+		return nil, nil
+	}
+	units, ok := unitProfiles[name]
+	if !ok {
+		return nil, fmt.Errorf("units must be metric or imperial")
+	}
+	return &units, nil
+}
+
+// pageUnits is what a visitor picked, ?units= sticks in a cookie
+func pageUnits(w http.ResponseWriter, r *http.Request) displayUnits {
+	if name := r.URL.Query().Get("units"); name != "" {
+		if units, ok := unitProfiles[name]; ok {
+			http.SetCookie(w, &http.Cookie{
+				Name:    "units",
+				Value:   name,
+				Path:    webExternalPath() + "/",
+				Expires: time.Now().Add(365 * 24 * time.Hour),
+			})
+			return units
+		}
+	}
+	if cookie, err := r.Cookie("units"); err == nil {
+		if units, ok := unitProfiles[cookie.Value]; ok {
+			return units
+		}
+	}
+	if units, ok := unitProfiles[*webUnits]; ok {
+		return units
+	}
+	return unitProfiles["metric"]
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// The query wins and sticks, then the cookie, then -web.units
+func TestPageUnits(t *testing.T) {
+	units := *webUnits
+	defer func() { *webUnits = units }()
+
+	for _, tc := range []struct {
+		query    string
+		cookie   string
+		flag     string
+		want     string
+		remember bool
+	}{
+		{flag: "metric", want: "metric"},
+		{flag: "imperial", want: "imperial"},
+		{flag: "nautical", want: "metric"},
+		{cookie: "imperial", flag: "metric", want: "imperial"},
+		{cookie: "nautical", flag: "metric", want: "metric"},
+		{query: "imperial", cookie: "metric", flag: "metric", want: "imperial", remember: true},
+		{query: "nautical", cookie: "imperial", flag: "metric", want: "imperial"},
+	} {
+		*webUnits = tc.flag
+		r := httptest.NewRequest(http.MethodGet, "/?units="+tc.query, nil)
+		if tc.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "units", Value: tc.cookie})
+		}
+		w := httptest.NewRecorder()
+		if got := pageUnits(w, r); got.Name != tc.want {
+			t.Errorf("%+v: %s, want %s", tc, got.Name, tc.want)
+		}
+		if remembered := len(w.Result().Cookies()) > 0; remembered != tc.remember {
+			t.Errorf("%+v: cookie set %v, want %v", tc, remembered, tc.remember)
+		}
+	}
+}
+
+// The API stays in base units unless asked
+func TestAPIUnits(t *testing.T) {
+	for _, tc := range []struct {
+		query    string
+		speed    float64
+		distance float64
+		err      bool
+	}{
+		{query: "", speed: 10, distance: 1000},
+		{query: "metric", speed: 36, distance: 1},
+		{query: "imperial", speed: 22.36936, distance: 0.621371},
+		{query: "nautical", err: true},
+	} {
+		units, err := apiUnits(httptest.NewRequest(http.MethodGet, "/api/v1/trackers?units="+tc.query, nil))
+		if (err != nil) != tc.err {
+			t.Errorf("%q: error %v", tc.query, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		speed, distance := 10.0, 1000.0
+		if units != nil {
+			speed, distance = units.speed(speed), units.distance(distance)
+		}
+		if math.Abs(speed-tc.speed) > 1e-6 || math.Abs(distance-tc.distance) > 1e-6 {
+			t.Errorf("%q: 10m/s and 1000m are %g and %g, want %g and %g", tc.query, speed, distance, tc.speed, tc.distance)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Set at build time, e.g.
+// go build -ldflags "-X main.version=v1.2.0 -X main.revision=$(git rev-parse HEAD)" ./cmd/tractive_exporter
+var (
+	version  = ""
+	revision = ""
+)
+
+var (
+
+	// Which site runs what, without ssh
+	showVersion = flag.Bool("version", false,
+		"Print the version and leave")
+
+	buildInfo = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "build_info"),
+		"Version of the exporter, always 1",
+		[]string{"version", "revision", "goversion"}, nil,
+	)
+)
+
+// buildVersion is what -ldflags set, or what Go knows about the build:
+// the module version with go install, the commit when built in a checkout
+func buildVersion() (string, string) {
+	v, rev := version, revision
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && rev == "" {
+				rev = setting.Value
+			}
+		}
+	}
+	if v == "" {
+		v = "dev"
+	}
+	return v, rev
+}
+
+// printVersion ...
+func printVersion() {
+	v, rev := buildVersion()
+	fmt.Printf("tractive_exporter %s (revision %s, %s %s/%s)\n", v, firstNonEmpty(rev, "unknown"), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	os.Exit(0)
+}
+
+// collectBuildInfo ...
+func collectBuildInfo(ch chan<- prometheus.Metric) {
+	v, rev := buildVersion()
+	ch <- prometheus.MustNewConstMetric(buildInfo, prometheus.GaugeValue, 1, v, rev, runtime.Version())
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+
+	// Every wakeup costs on a solar powered board
+	wakeInterval = flag.Duration("power.wake-interval", 0,
+		"Wake up only on multiples of this: background polls, share checks, state saves, WAL syncs, output flushes and certificate checks all run together on one timer, 0 gives each its own")
+)
+
+// wakeJob is something periodic waiting for a wakeup
+type wakeJob struct {
+	every   time.Duration
+	next    time.Time
+	c       chan time.Time
+	stopped bool
+}
+
+// wakeSchedule is the one timer everything shares with -power.wake-interval
+type wakeSchedule struct {
+	sync.Mutex
+	jobs    []*wakeJob
+	started bool
+}
+
+var wakes wakeSchedule
+
+// wakeEvery is a ticker, one of the shared wakeups with -power.wake-interval.
+// Call stop when done.
+func wakeEvery(every time.Duration) (ticks <-chan time.Time, stop func()) {
+	if *wakeInterval <= 0 {
+		ticker := time.NewTicker(every)
+		return ticker.C, ticker.Stop
+	}
+
+	job := &wakeJob{every: every, next: time.Now().Add(every), c: make(chan time.Time, 1)}
+	wakes.Lock()
+	defer wakes.Unlock()
+	wakes.jobs = append(wakes.jobs, job)
+	if !wakes.started {
+		wakes.started = true
+		go wakes.run()
+	}
+	return job.c, func() {
+		wakes.Lock()
+		job.stopped = true
+		wakes.Unlock()
+	}
+}
+
+// run wakes up on the wall clock grid of -power.wake-interval and ticks
+// every job that's due by then, give or take half a wakeup
+func (s *wakeSchedule) run() {
+	for {
+		now := time.Now()
+		time.Sleep(now.Truncate(*wakeInterval).Add(*wakeInterval).Sub(now))
+
+		now = time.Now()
+		s.Lock()
+		jobs := s.jobs[:0]
+		for _, job := range s.jobs {
+			if job.stopped {
+				continue
+			}
+			jobs = append(jobs, job)
+			if job.next.Sub(now) > *wakeInterval/2 {
+				continue
+			}
+			select {
+			case job.c <- now:
+			default:
+			}
+			job.next = now.Add(job.every)
+		}
+		s.jobs = jobs
+		s.Unlock()
+	}
+}
+
+// dueSlack is how early a tracker counts as due: the next wakeup may be
+// a while off
+func dueSlack() time.Duration {
+	if *wakeInterval/2 > pollSlack {
+		return *wakeInterval / 2
+	}
+	return pollSlack
+}
+
+// configureWake has all trackers share one HTTP/2 connection, and with
+// -power.wake-interval no keep-alive probes wake the radio in between
+func configureWake() {
+	tr.ForceAttemptHTTP2 = true
+	tr.MaxIdleConnsPerHost = *tractiveConcurrency
+	if *wakeInterval <= 0 {
+		return
+	}
+	outboundDialer.KeepAlive = -1
+	tr.IdleConnTimeout = 2 * *wakeInterval
+	slog.Info("Waking up on a shared timer", "every", *wakeInterval)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+
+	// A minute of walking is a lot to lose to a crash
+	stateWALSyncInterval = flag.Duration("state.wal-sync-interval", 5*time.Second,
+		"How often the log of counter changes next to -state.path is synced to disk, a crash loses at most about this much")
+)
+
+// walRecord is the new value of a counter. Values, not increments, so
+// replaying a record the saved state already has does no harm. A hop is
+// the exception, replay skips it when it isn't newer than the last one.
+type walRecord struct {
+	Kind     string    `json:"kind"`
+	Tracker  string    `json:"tracker"`
+	Geohash  string    `json:"geohash"`
+	Lat      float64   `json:"lat,omitempty"`
+	Lon      float64   `json:"lon,omitempty"`
+	Odometer float64   `json:"odometer,omitempty"`
+	Counter  int32     `json:"counter,omitempty"`
+	Distance float64   `json:"distance,omitempty"`
+	Time     int64     `json:"time,omitempty"`
+	Created  time.Time `json:"created"`
+}
+
+// counterWAL appends every odometer and geohash change to <state.path>.wal,
+// saving the state empties it
+type counterWAL struct {
+	sync.Mutex
+	file *os.File
+}
+
+// Nil when there's no -state.path
+var stateWAL *counterWAL
+
+// openWAL appends to what's there, replay it first
+func openWAL(path string) (*counterWAL, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &counterWAL{file: file}, nil
+}
+
+// append writes straight through, so it's in the OS's hands even if we crash
+func (w *counterWAL) append(r walRecord) {
+	if w == nil {
+		return
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		slog.Error("WAL marshal error", "err", err)
+		return
+	}
+	w.Lock()
+	defer w.Unlock()
+	if _, err := w.file.Write(append(b, '\n')); err != nil {
+		slog.Error("Could not write WAL", "err", err)
+	}
+}
+
+// move logs where the tracker went and the odometer
+func (w *counterWAL) move(id string, m geoMemory) {
+	w.append(walRecord{Kind: "move", Tracker: id, Geohash: m.geohash, Lat: m.lat, Lon: m.lon, Odometer: m.odometer, Created: m.created})
+}
+
+// hop logs a hop of the distance windows and daily goal
+func (w *counterWAL) hop(id string, t int64, distance float64) {
+	w.append(walRecord{Kind: "hop", Tracker: id, Time: t, Distance: distance})
+}
+
+// geohash logs a geohash or area counter
+func (w *counterWAL) geohash(kind, id, encoded string, value uniqueGeoStatesValue) {
+	w.append(walRecord{Kind: kind, Tracker: id, Geohash: encoded, Counter: value.counter, Time: value.lastTimestamp, Created: value.created})
+}
+
+// truncate is for after the state was saved, callers hold the exporter's
+// mutex so nothing gets logged in between
+func (w *counterWAL) truncate() error {
+	if w == nil {
+		return nil
+	}
+	w.Lock()
+	defer w.Unlock()
+	return w.file.Truncate(0)
+}
+
+// sync ...
+func (w *counterWAL) sync() {
+	if w == nil {
+		return
+	}
+	w.Lock()
+	defer w.Unlock()
+	if err := w.file.Sync(); err != nil {
+		slog.Error("Could not sync WAL", "err", err)
+	}
+}
+
+// runSync ...
+func (w *counterWAL) runSync(every time.Duration) {
+	ticks, _ := wakeEvery(every)
+	for range ticks {
+		w.sync()
+	}
+}
+
+// replayWAL applies what happened after the state was saved. A torn last
+// line from a crash is skipped.
+func (e *Exporter) replayWAL(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	replayed := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r walRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			slog.Warn("Skipping broken WAL record", "err", err)
+			continue
+		}
+		switch r.Kind {
+		case "move":
+			m := e.mapOfTrackerGeoMemory[r.Tracker]
+			m.prevLat, m.prevLon, m.prevGeohash = m.lat, m.lon, m.geohash
+			m.lat, m.lon, m.geohash = r.Lat, r.Lon, r.Geohash
+			m.odometer = r.Odometer
+			m.created = r.Created
+			e.mapOfTrackerGeoMemory[r.Tracker] = m
+		case "hop":
+
+			// the one increment, a hop the state has already is skipped
+			if r.Time > e.distanceHistory.newest(r.Tracker) {
+				e.distanceHistory.add(r.Tracker, r.Time, r.Distance)
+				e.activityDays.add(r.Tracker, r.Time, r.Distance)
+			}
+		case "geohash", "area":
+			states := e.mapOfUniqueGeoStates
+			if r.Kind == "area" {
+				states = e.mapOfAreaGeoStates
+			}
+			states[uniqueGeoStates{tracker: r.Tracker, geohash: r.Geohash}] = uniqueGeoStatesValue{
+				counter:       r.Counter,
+				lastTimestamp: r.Time,
+				created:       r.Created,
+			}
+		}
+		replayed++
+	}
+	if replayed > 0 {
+		slog.Info("Replayed counter changes", "count", replayed, "file", path)
+	}
+	return scanner.Err()
+}
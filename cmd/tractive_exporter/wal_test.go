@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A write cut short by a crash leaves half a line, the rest still replays
+func TestReplayWALAfterTruncatedWrite(t *testing.T) {
+	move := `{"kind":"move","tracker":"abc","geohash":"u2edk","lat":48.2,"lon":16.37,"odometer":1200,"created":"2026-10-16T08:00:00Z"}`
+	hour := time.Now().Add(-time.Hour).Unix()
+	geohash := fmt.Sprintf(`{"kind":"geohash","tracker":"abc","geohash":"u2edk","counter":3,"time":%d,"created":"2026-10-16T08:00:00Z"}`, hour)
+	hop := fmt.Sprintf(`{"kind":"hop","tracker":"abc","distance":150,"time":%d,"created":"2026-10-16T08:00:00Z"}`, hour)
+	later := fmt.Sprintf(`{"kind":"hop","tracker":"abc","distance":50,"time":%d,"created":"2026-10-16T08:00:00Z"}`, hour+100)
+	for _, tc := range []struct {
+		name     string
+		lines    []string
+		saved    [][2]float64
+		odometer float64
+		counter  int32
+		distance float64
+	}{
+		{"whole", []string{move, geohash, hop}, nil, 1200, 3, 150},
+		{"torn last line", []string{move, geohash, hop, later[:40]}, nil, 1200, 3, 150},
+		{"torn middle line", []string{move, geohash[:30], hop}, nil, 1200, 0, 150},
+		{"empty", nil, nil, 0, 0, 0},
+		{"hop already saved", []string{move, hop, later}, [][2]float64{{float64(hour), 150}}, 1200, 0, 200},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json.wal")
+			if err := os.WriteFile(path, []byte(strings.Join(tc.lines, "\n")), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			e := NewExporter(nil, make(map[uniqueGeoStates]uniqueGeoStatesValue), make(map[string]geoMemory))
+			if tc.saved != nil {
+				e.distanceHistory.load(map[string][][2]float64{"abc": tc.saved})
+			}
+			if err := e.replayWAL(path); err != nil {
+				t.Fatal(err)
+			}
+			if got := e.mapOfTrackerGeoMemory["abc"].odometer; got != tc.odometer {
+				t.Errorf("odometer %g, want %g", got, tc.odometer)
+			}
+			if got := e.mapOfUniqueGeoStates[uniqueGeoStates{tracker: "abc", geohash: "u2edk"}].counter; got != tc.counter {
+				t.Errorf("geohash counter %d, want %d", got, tc.counter)
+			}
+			e.distanceHistory.Lock()
+			distance := e.distanceHistory.sum("abc", 0)
+			e.distanceHistory.Unlock()
+			if distance != tc.distance {
+				t.Errorf("distance %g, want %g", distance, tc.distance)
+			}
+		})
+	}
+}
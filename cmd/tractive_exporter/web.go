@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+
+	// Behind a reverse proxy at a subpath
+	externalURL = flag.String("web.external-url", "",
+		"URL under which the exporter is reachable from outside, e.g. https://home.example/tractive/")
+	routePrefix = flag.String("web.route-prefix", "",
+		"Prefix for the internal routes, defaults to the path of -web.external-url")
+)
+
+// webRoutePrefix is where we serve, without trailing slash, "" for the root
+func webRoutePrefix() string {
+	prefix := *routePrefix
+	if prefix == "" && *externalURL != "" {
+		if u, err := url.Parse(*externalURL); err == nil {
+			prefix = u.Path
+		}
+	}
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+// webExternalPath is what links in our pages start with
+func webExternalPath() string {
+	if *externalURL != "" {
+		if u, err := url.Parse(*externalURL); err == nil {
+			return strings.TrimRight(u.Path, "/")
+		}
+	}
+	return webRoutePrefix()
+}
+
+// withRoutePrefix mounts the handler under the route prefix
+func withRoutePrefix(handler http.Handler) http.Handler {
+	prefix := webRoutePrefix()
+	if prefix == "" {
+		return handler
+	}
+	root := http.NewServeMux()
+	root.Handle(prefix+"/", http.StripPrefix(prefix, handler))
+	root.Handle(prefix, http.RedirectHandler(prefix+"/", http.StatusFound))
+	return root
+}
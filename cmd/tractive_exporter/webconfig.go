@@ -0,0 +1,237 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+
+	// Positions are nobody's business on the LAN, same file format as the other exporters
+	webConfigFile = flag.String("web.config.file", "",
+		"Path to an exporter-toolkit style web config file enabling TLS and/or basic auth")
+)
+
+// webConfig is the part of the exporter-toolkit web config we understand
+//
+//	tls_server_config:
+//	  cert_file: server.crt
+//	  key_file: server.key
+//	  client_auth_type: RequireAndVerifyClientCert
+//	  client_ca_file: ca.crt
+//	  min_version: TLS12
+//	basic_auth_users:
+//	  prometheus: $2y$10$...
+type webConfig struct {
+	TLSConfig      webTLSConfig      `yaml:"tls_server_config"`
+	BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+}
+
+// webTLSConfig ...
+type webTLSConfig struct {
+	CertFile       string `yaml:"cert_file"`
+	KeyFile        string `yaml:"key_file"`
+	ClientAuthType string `yaml:"client_auth_type"`
+	ClientCAFile   string `yaml:"client_ca_file"`
+	MinVersion     string `yaml:"min_version"`
+}
+
+// Same names the toolkit takes
+var webTLSVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+var webClientAuthTypes = map[string]tls.ClientAuthType{
+	"":                           tls.NoClientCert,
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+// loadWebConfig reads -web.config.file, relative paths in it are relative to the file
+func loadWebConfig(path string) (*webConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &webConfig{}
+	if err := yaml.Unmarshal(b, config); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, f := range []*string{&config.TLSConfig.CertFile, &config.TLSConfig.KeyFile, &config.TLSConfig.ClientCAFile} {
+		if *f != "" && !filepath.IsAbs(*f) {
+			*f = filepath.Join(dir, *f)
+		}
+	}
+
+	tlsConfig := config.TLSConfig
+	if (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+		return nil, fmt.Errorf("%s: cert_file and key_file go together", path)
+	}
+	if _, ok := webClientAuthTypes[tlsConfig.ClientAuthType]; !ok {
+		return nil, fmt.Errorf("%s: unknown client_auth_type %q", path, tlsConfig.ClientAuthType)
+	}
+	if _, ok := webTLSVersions[tlsConfig.MinVersion]; !ok && tlsConfig.MinVersion != "" {
+		return nil, fmt.Errorf("%s: unknown min_version %q", path, tlsConfig.MinVersion)
+	}
+	if !config.tls() && (tlsConfig.ClientCAFile != "" || tlsConfig.ClientAuthType != "") {
+		return nil, fmt.Errorf("%s: client certificates need cert_file and key_file", path)
+	}
+	for user, hash := range config.BasicAuthUsers {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("%s: password of %q is not a bcrypt hash: %v", path, user, err)
+		}
+	}
+	return config, nil
+}
+
+// tls tells if the config wants https
+func (c *webConfig) tls() bool {
+	return c != nil && c.TLSConfig.CertFile != ""
+}
+
+// webConfigWantsTLS is for -healthcheck, which has no business failing on a broken file
+func webConfigWantsTLS() bool {
+	if *webConfigFile == "" {
+		return false
+	}
+	config, err := loadWebConfig(*webConfigFile)
+	return err == nil && config.tls()
+}
+
+// basicAuthUsers ...
+func (c *webConfig) basicAuthUsers() map[string]string {
+	if c == nil {
+		return nil
+	}
+	return c.BasicAuthUsers
+}
+
+// requireBasicAuth is for endpoints that shouldn't be open even on a
+// trusted network, they answer 403 until there are basic_auth_users
+func (c *webConfig) requireBasicAuth(next http.HandlerFunc) http.HandlerFunc {
+	if len(c.basicAuthUsers()) > 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "needs basic_auth_users in -web.config.file", http.StatusForbidden)
+	}
+}
+
+// serverTLSConfig builds the server side, the certificate comes from the reloader
+func (c *webConfig) serverTLSConfig(reloader *certReloader) (*tls.Config, error) {
+	config := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+		ClientAuth:     webClientAuthTypes[c.TLSConfig.ClientAuthType],
+	}
+	if v, ok := webTLSVersions[c.TLSConfig.MinVersion]; ok {
+		config.MinVersion = v
+	}
+	if c.TLSConfig.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(c.TLSConfig.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", c.TLSConfig.ClientCAFile)
+		}
+		config.ClientCAs = pool
+	}
+	return config, nil
+}
+
+// basicAuth guards next when users are configured, bcrypt is slow so
+// passwords that were right once are remembered by their sha256
+type basicAuth struct {
+	sync.Mutex
+	users map[string]string
+	known map[[32]byte]bool
+	next  http.Handler
+}
+
+// Probes
+var unauthenticatedPaths = map[string]bool{
+	"/-/healthy": true,
+	"/healthz":   true,
+	"/readyz":    true,
+}
+
+// Pages that check something else, like a signed link
+var unauthenticatedPrefixes []string
+
+func newBasicAuth(users map[string]string, next http.Handler) http.Handler {
+	if len(users) == 0 {
+		return next
+	}
+	return &basicAuth{users: users, known: make(map[[32]byte]bool), next: next}
+}
+
+func (a *basicAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// docker's HEALTHCHECK and kubelet don't know the password and there's nothing to leak
+	if unauthenticatedPaths[r.URL.Path] {
+		a.next.ServeHTTP(w, r)
+		return
+	}
+	for _, prefix := range unauthenticatedPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			a.next.ServeHTTP(w, r)
+			return
+		}
+	}
+	user, pass, ok := r.BasicAuth()
+	if ok && a.check(user, pass) {
+		a.next.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="tractive_exporter"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// Compared against for users that don't exist
+var unknownUserHash, _ = bcrypt.GenerateFromPassword([]byte("nobody"), bcrypt.DefaultCost)
+
+// check ...
+func (a *basicAuth) check(user, pass string) bool {
+	hash, ok := a.users[user]
+	if !ok {
+		// same work for unknown users, so timing doesn't tell them apart
+		bcrypt.CompareHashAndPassword(unknownUserHash, []byte(pass))
+		return false
+	}
+	key := sha256.Sum256([]byte(user + "\x00" + pass + "\x00" + hash))
+
+	a.Lock()
+	known := a.known[key]
+	a.Unlock()
+	if known {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) != nil {
+		return false
+	}
+	a.Lock()
+	a.known[key] = true
+	a.Unlock()
+	return true
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Files that can't work are refused at the start, with what's wrong
+func TestLoadWebConfig(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("woof"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		file string
+		tls  bool
+		err  string
+	}{
+		{name: "users", file: "basic_auth_users:\n  rex: " + string(hash) + "\n"},
+		{name: "tls", file: "tls_server_config:\n  cert_file: tls.crt\n  key_file: tls.key\n  min_version: TLS13\n", tls: true},
+		{name: "cert without key", file: "tls_server_config:\n  cert_file: tls.crt\n", err: "cert_file and key_file go together"},
+		{name: "client auth", file: "tls_server_config:\n  cert_file: a\n  key_file: b\n  client_auth_type: Sometimes\n", err: `unknown client_auth_type "Sometimes"`},
+		{name: "old TLS", file: "tls_server_config:\n  cert_file: a\n  key_file: b\n  min_version: SSL3\n", err: `unknown min_version "SSL3"`},
+		{name: "client CA without TLS", file: "tls_server_config:\n  client_ca_file: ca.crt\n", err: "client certificates need cert_file and key_file"},
+		{name: "plain password", file: "basic_auth_users:\n  rex: woof\n", err: `password of "rex" is not a bcrypt hash`},
+		{name: "not YAML", file: "basic_auth_users: [", err: "web.yml: "},
+	} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "web.yml")
+		if err := os.WriteFile(path, []byte(tc.file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		config, err := loadWebConfig(path)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: error %v, want one with %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if config.tls() != tc.tls {
+			t.Errorf("%s: tls %v, want %v", tc.name, config.tls(), tc.tls)
+		}
+		if tc.tls && config.TLSConfig.CertFile != filepath.Join(dir, "tls.crt") {
+			t.Errorf("%s: cert_file %s isn't next to the file", tc.name, config.TLSConfig.CertFile)
+		}
+	}
+}
+
+// Only the right password gets through, probes need none
+func TestBasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("woof"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	auth := newBasicAuth(map[string]string{"rex": string(hash)}, ok)
+
+	for _, tc := range []struct {
+		path       string
+		user, pass string
+		status     int
+	}{
+		{path: "/metrics", user: "rex", pass: "woof", status: 200},
+		{path: "/metrics", user: "rex", pass: "woof", status: 200},
+		{path: "/metrics", user: "rex", pass: "meow", status: 401},
+		{path: "/metrics", user: "milo", pass: "woof", status: 401},
+		{path: "/metrics", status: 401},
+		{path: "/-/healthy", status: 200},
+	} {
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.user != "" {
+			r.SetBasicAuth(tc.user, tc.pass)
+		}
+		w := httptest.NewRecorder()
+		auth.ServeHTTP(w, r)
+		if w.Code != tc.status {
+			t.Errorf("%s as %s:%s: HTTP %d, want %d", tc.path, tc.user, tc.pass, w.Code, tc.status)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	newBasicAuth(nil, ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != 200 {
+		t.Errorf("without users: HTTP %d", w.Code)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+
+	// Push feed for lightweight consumers
+	webhookURLs = flag.String("webhook.urls", "",
+		"Comma separated URLs receiving a POST for every new position")
+	webhookTemplate = flag.String("webhook.template", "",
+		"File with a text/template for the webhook body, a JSON of the position by default")
+	webhookTimeout = flag.Duration("webhook.timeout", 10*time.Second,
+		"Timeout of a webhook call")
+)
+
+// Fields: .Tracker .Time .Lat .Lon .Speed .Alt .Live .Battery .Geohash,
+// {{ json .Tracker }} quotes a string, {{ localtime .Time }} is RFC 3339 in
+// -timezone
+const defaultWebhookTemplate = `{"tracker":{{ json .Tracker }},"time":{{ .Time }},"lat":{{ .Lat }},"lon":{{ .Lon }},` +
+	`"speed":{{ .Speed }},"alt":{{ .Alt }},"live":{{ .Live }},"geohash":{{ json .Geohash }}}`
+
+// parseEventTemplate knows json and localtime
+func parseEventTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(template.FuncMap{
+		"json": func(i interface{}) string {
+			b, _ := json.Marshal(i)
+			return string(b)
+		},
+		"localtime": func(t int64) string {
+			return localTime(t).Format(time.RFC3339)
+		},
+	}).Parse(text)
+}
+
+// positionEvent is a new position of a tracker
+type positionEvent struct {
+	Tracker string `json:"tracker"`
+	Geohash string `json:"geohash"`
+	*Position
+}
+
+// positionWebhook posts every new position to some URLs
+type positionWebhook struct {
+	urls     []string
+	queues   []*sinkQueue
+	template *template.Template
+	client   *http.Client
+}
+
+// newPositionWebhook is nil when no URL is configured
+func newPositionWebhook() (*positionWebhook, error) {
+	urls := deleteEmpty(strings.Split(*webhookURLs, ","))
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	text := defaultWebhookTemplate
+	if *webhookTemplate != "" {
+		b, err := ioutil.ReadFile(*webhookTemplate)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+	t, err := parseEventTemplate("webhook", text)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &positionWebhook{
+		urls:     urls,
+		template: t,
+		client:   &http.Client{Timeout: *webhookTimeout},
+	}
+
+	// URLs can carry tokens, so outputs go by number in logs and metrics
+	for i, url := range urls {
+		name := fmt.Sprintf("webhook%d", i+1)
+		slog.Info("Output posts to a webhook", "output", name, "url", redactURL(url))
+		w.queues = append(w.queues, newSinkQueue(name, webhookSink{url: url, client: w.client}, *sinkRetries))
+	}
+	return w, nil
+}
+
+// send renders the body once and queues it for every URL
+func (w *positionWebhook) send(event positionEvent) {
+	body, err := w.render(event)
+	if err != nil {
+		slog.Error("Webhook template error", "err", err)
+		return
+	}
+
+	for _, q := range w.queues {
+		q.enqueue(sinkEvent{Event: "position", Tracker: event.Tracker, Body: body})
+	}
+}
+
+// render ...
+func (w *positionWebhook) render(event positionEvent) ([]byte, error) {
+	var body bytes.Buffer
+	err := w.template.Execute(&body, event)
+	return body.Bytes(), err
+}
+
+// webhookSink posts every event of a batch to one URL
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// deliver ...
+func (s webhookSink) deliver(batch []sinkEvent) error {
+	for _, event := range batch {
+		resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(event.Body))
+		if urlErr, ok := err.(*neturl.Error); ok {
+			return urlErr.Err
+		}
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s", resp.Status)
+		}
+	}
+	return nil
+}
+
+// redactURL keeps scheme and host, enough to tell webhooks apart
+func redactURL(s string) string {
+	u, err := neturl.Parse(s)
+	if err != nil {
+		return "(unparsable URL)"
+	}
+	return u.Scheme + "://" + u.Host + "/..."
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+
+	// What the app shows as activity and sleep, GPS alone undercounts a lot
+	tractiveWellness = flag.Bool("tractive.wellness", false,
+		"Follow the account's event channel for the app's activity minutes, calories and sleep, authenticated API only")
+
+	wellnessActivityMinutes = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "activity_minutes_total"),
+		"Minutes the pet was active today as the Tractive app counts them, starts again every day",
+		[]string{"tracker"}, nil,
+	)
+
+	wellnessRestMinutes = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "rest_minutes_total"),
+		"Minutes the pet rested today as the Tractive app counts them, starts again every day",
+		[]string{"tracker"}, nil,
+	)
+
+	wellnessCalories = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "calories_total"),
+		"Kilocalories the pet burned today as the Tractive app counts them, starts again every day",
+		[]string{"tracker"}, nil,
+	)
+
+	wellnessSleepMinutes = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "sleep_minutes"),
+		"Minutes the pet slept today as the Tractive app counts them, by day or night",
+		[]string{"tracker", "period"}, nil,
+	)
+
+	wellnessGoalMinutes = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "activity_goal_minutes"),
+		"Daily activity goal set in the Tractive app in minutes",
+		[]string{"tracker"}, nil,
+	)
+
+	wellnessTimestamp = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "wellness_timestamp_seconds"),
+		"When the last wellness overview came in seconds since epoch",
+		[]string{"tracker"}, nil,
+	)
+)
+
+// Where the app gets pushed what changed, one long response of JSON lines
+const tractiveChannel = "https://channel.tractive.com/3/events"
+
+// wellnessOverview is the channel's message with the app's daily numbers
+type wellnessOverview struct {
+	Message  string `json:"message"`
+	PetID    string `json:"pet_id"`
+	Activity struct {
+		MinutesActive float64 `json:"minutes_active"`
+		MinutesRest   float64 `json:"minutes_rest"`
+		MinutesGoal   float64 `json:"minutes_goal"`
+		Calories      float64 `json:"calories"`
+	} `json:"activity"`
+	Sleep struct {
+		MinutesDaySleep   float64 `json:"minutes_day_sleep"`
+		MinutesNightSleep float64 `json:"minutes_night_sleep"`
+	} `json:"sleep"`
+
+	// when it came, the day's counters start at midnight before it
+	received time.Time
+}
+
+// startWellness follows the channel of every account, off without
+// -tractive.wellness
+func startWellness() error {
+	if !*tractiveWellness {
+		return nil
+	}
+	accounts := make([]*tractiveAccount, 0, len(groupAccounts)+1)
+	if account != nil {
+		accounts = append(accounts, account)
+	}
+	for _, a := range groupAccounts {
+		accounts = append(accounts, a)
+	}
+	if len(accounts) == 0 {
+		return errors.New("-tractive.wellness needs a Tractive account, public shares don't have it")
+	}
+	for _, a := range accounts {
+		go a.followChannel()
+	}
+	return nil
+}
+
+// followChannel reads the event channel and connects again when it ends,
+// waiting longer every time it fails right away
+func (a *tractiveAccount) followChannel() {
+	wait := 5 * time.Second
+	for {
+		started := time.Now()
+		err := a.readChannel(context.Background())
+		if time.Since(started) > 10*time.Minute {
+			wait = 5 * time.Second
+		}
+		slog.Warn("Tractive event channel closed", "err", err, "retry_in", wait)
+		time.Sleep(wait)
+		wait = min(2*wait, 5*time.Minute)
+	}
+}
+
+// readChannel reads one connection of the channel until it ends
+func (a *tractiveAccount) readChannel(ctx context.Context) error {
+	header, err := a.api.Header(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", tractiveChannel, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = header
+
+	// no client timeout, the response is meant to go on for hours
+	resp, err := (&http.Client{Transport: tr}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	slog.Info("Following the Tractive event channel", "user", a.api.UserID())
+
+	lines := bufio.NewScanner(resp.Body)
+	lines.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lines.Scan() {
+		var overview wellnessOverview
+		if err := json.Unmarshal(lines.Bytes(), &overview); err != nil || overview.Message != "wellness_overview" {
+			continue
+		}
+		overview.received = time.Now()
+		tracker, err := a.trackerOfPet(ctx, overview.PetID)
+		if err != nil {
+			slog.Warn("Wellness of an unknown pet", "pet", overview.PetID, "err", err)
+			continue
+		}
+		slog.Debug("Wellness overview", "tracker", tracker, "overview", lines.Text())
+		a.Lock()
+		a.wellness[tracker] = overview
+		a.Unlock()
+	}
+	if err := lines.Err(); err != nil {
+		return err
+	}
+	return errors.New("end of stream")
+}
+
+// trackerOfPet is the tracker a pet wears, the account's pets are asked
+// once and again when one is new
+func (a *tractiveAccount) trackerOfPet(ctx context.Context, pet string) (string, error) {
+	a.Lock()
+	tracker, ok := a.pets[pet]
+	userID := a.api.UserID()
+	a.Unlock()
+	if ok {
+		return tracker, nil
+	}
+
+	var list []struct {
+		ID   string `json:"_id"`
+		Type string `json:"_type"`
+	}
+	if err := a.get(ctx, "trackable_objects", "user/"+userID+"/trackable_objects", &list); err != nil {
+		return "", err
+	}
+	pets := make(map[string]string)
+	for _, item := range list {
+		var object struct {
+			ID       string `json:"_id"`
+			Type     string `json:"_type"`
+			Version  string `json:"_version"`
+			DeviceID string `json:"device_id"`
+		}
+		if err := a.get(ctx, "trackable_object", "trackable_object/"+item.ID, &object); err != nil {
+			return "", err
+		}
+		pets[item.ID] = object.DeviceID
+	}
+	a.Lock()
+	a.pets = pets
+	a.Unlock()
+	if tracker, ok := pets[pet]; ok {
+		return tracker, nil
+	}
+	return "", errors.New("not one of the account's pets")
+}
+
+// collectWellness exposes the last overview, safe to call on nil
+func (a *tractiveAccount) collectWellness(ch chan<- prometheus.Metric, id string) {
+	if a == nil {
+		return
+	}
+	a.Lock()
+	overview, ok := a.wellness[id]
+	a.Unlock()
+	if !ok {
+		return
+	}
+
+	local := overview.received.In(homeLocation)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, homeLocation)
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(wellnessActivityMinutes, prometheus.CounterValue, overview.Activity.MinutesActive, day, id)
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(wellnessRestMinutes, prometheus.CounterValue, overview.Activity.MinutesRest, day, id)
+	ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(wellnessCalories, prometheus.CounterValue, overview.Activity.Calories, day, id)
+	ch <- prometheus.MustNewConstMetric(wellnessSleepMinutes, prometheus.GaugeValue, overview.Sleep.MinutesDaySleep, id, "day")
+	ch <- prometheus.MustNewConstMetric(wellnessSleepMinutes, prometheus.GaugeValue, overview.Sleep.MinutesNightSleep, id, "night")
+	ch <- prometheus.MustNewConstMetric(wellnessGoalMinutes, prometheus.GaugeValue, overview.Activity.MinutesGoal, id)
+	ch <- prometheus.MustNewConstMetric(wellnessTimestamp, prometheus.GaugeValue, float64(overview.received.Unix()), id)
+}
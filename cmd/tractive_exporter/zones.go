@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	zoneVisits = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "zone", "visits_total"),
+		"Number of times the tracker entered the geofence",
+		[]string{"tracker", "zone"}, nil,
+	)
+
+	zoneLastVisit = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "zone", "last_visit_timestamp_seconds"),
+		"Timestamp of the last report from inside the geofence in seconds since epoch",
+		[]string{"tracker", "zone"}, nil,
+	)
+
+	zoneInside = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "in_zone"),
+		"Whether the last report was inside the geofence (1) or not (0)",
+		[]string{"tracker", "zone"}, nil,
+	)
+
+	zoneSeconds = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "zone", "seconds_total"),
+		"Seconds spent inside the geofence, counted between reports",
+		[]string{"tracker", "zone"}, nil,
+	)
+)
+
+// zoneVisit is what we remember of a tracker and a geofence
+type zoneVisit struct {
+	inside    bool
+	visits    int64
+	lastVisit int64
+	seconds   int64
+	created   time.Time
+}
+
+// zoneEvent goes to the outputs when a tracker enters or leaves a geofence
+type zoneEvent struct {
+	Tracker string  `json:"tracker"`
+	Zone    string  `json:"zone"`
+	Status  string  `json:"status"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Time    int64   `json:"time"`
+}
+
+// updateZones is called with every new report of a tracker
+func (e *Exporter) updateZones(id string, p *Position) {
+	if len(e.geofences) == 0 {
+		return
+	}
+	visits := e.mapOfZoneVisits[id]
+	if visits == nil {
+		visits = make(map[string]zoneVisit)
+		e.mapOfZoneVisits[id] = visits
+	}
+
+	for _, fence := range e.geofences {
+		visit, seen := visits[fence.name]
+		if visit.created.IsZero() {
+			visit.created = time.Now()
+		}
+		inside := fence.contains(p.Lat, p.Lon)
+
+		// the time since the last report counts if that one was inside
+		if visit.inside && visit.lastVisit != 0 && p.Time > visit.lastVisit {
+			visit.seconds += p.Time - visit.lastVisit
+		}
+		if inside && !visit.inside {
+			visit.visits++
+		}
+		if inside {
+			visit.lastVisit = p.Time
+		}
+
+		// the first report only says where it is, not that it moved
+		if seen && inside != visit.inside {
+			event := zoneEvent{Tracker: id, Zone: fence.name, Status: "left", Lat: p.Lat, Lon: p.Lon, Time: p.Time}
+			if inside {
+				event.Status = "entered"
+			}
+			slog.Info("Zone "+event.Status, "zone", fence.name, "tracker", id)
+			e.bus.publish("zone", id, event)
+		}
+		visit.inside = inside
+		visits[fence.name] = visit
+	}
+}
+
+// collectZones ...
+func (e *Exporter) collectZones(ch chan<- prometheus.Metric, id string, stale bool) {
+	if len(e.mapOfZoneVisits[id]) > 0 {
+		collectUnknown(ch, id, "in_zone", stale)
+	}
+	for _, fence := range e.geofences {
+		visit, ok := e.mapOfZoneVisits[id][fence.name]
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+			zoneVisits, prometheus.CounterValue, float64(visit.visits), visit.created, id, fence.name,
+		)
+		ch <- prometheus.MustNewConstMetricWithCreatedTimestamp(
+			zoneSeconds, prometheus.CounterValue, float64(visit.seconds), visit.created, id, fence.name,
+		)
+		if inside, ok := booleanValue(visit.inside, stale); ok {
+			ch <- prometheus.MustNewConstMetric(zoneInside, prometheus.GaugeValue, inside, id, fence.name)
+		}
+		if visit.lastVisit != 0 {
+			ch <- prometheus.MustNewConstMetric(
+				zoneLastVisit, prometheus.GaugeValue, float64(visit.lastVisit), id, fence.name,
+			)
+		}
+	}
+}
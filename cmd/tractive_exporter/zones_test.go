@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+// A visit starts when a report is inside, its time runs from report to
+// report while inside
+func TestUpdateZones(t *testing.T) {
+	e := NewExporter([]string{"rex"}, make(map[uniqueGeoStates]uniqueGeoStatesValue), make(map[string]geoMemory))
+	e.geofences = []geofence{{name: "park", center: [2]float64{13.405, 52.52}, radius: 100}}
+
+	for _, tc := range []struct {
+		at        int64
+		lat       float64
+		inside    bool
+		visits    int64
+		lastVisit int64
+		seconds   int64
+	}{
+		{at: 1000, lat: 52.53},
+		{at: 1100, lat: 52.52, inside: true, visits: 1, lastVisit: 1100},
+		{at: 1160, lat: 52.5201, inside: true, visits: 1, lastVisit: 1160, seconds: 60},
+		{at: 1400, lat: 52.53, visits: 1, lastVisit: 1160, seconds: 300},
+		{at: 1500, lat: 52.52, inside: true, visits: 2, lastVisit: 1500, seconds: 300},
+	} {
+		p := &Position{}
+		p.Time, p.Lat, p.Lon = tc.at, tc.lat, 13.405
+		e.updateZones("rex", p)
+		visit := e.mapOfZoneVisits["rex"]["park"]
+		if visit.inside != tc.inside || visit.visits != tc.visits || visit.lastVisit != tc.lastVisit || visit.seconds != tc.seconds {
+			t.Errorf("at %d: %+v, want inside %v after %d visits, last at %d, %ds in all",
+				tc.at, visit, tc.inside, tc.visits, tc.lastVisit, tc.seconds)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Package collector is a Prometheus collector for the positions of Tractive
+// trackers, with the exporter's core metric names, to register in your own
+// program:
+//
+//	client := tractive.NewClient(nil)
+//	prometheus.MustRegister(collector.New(collector.Shares(client), "6a7235da65"))
+//
+// It's a separate, minimal collector: no retries, caching or state, every
+// scrape asks Tractive. The exporter has its own collector with a lot more
+// (geofences, distance, alerts, ...) and only shares the metric
+// descriptions below, so the names match.
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/one1zero1one/tractive_exporter/pkg/tractive"
+)
+
+// The core metrics, the exporter describes its own with these too
+var (
+	Up = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "up"),
+		"Was the last Tractive query successful.",
+		nil, nil,
+	)
+
+	LastTime = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "last_time"),
+		"Timestamp of the last reported message in seconds since epoch",
+		[]string{"tracker"}, nil,
+	)
+
+	Age = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "age_seconds"),
+		"Age of the last reported message in seconds",
+		[]string{"tracker"}, nil,
+	)
+
+	Latitude = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "latitude"),
+		"Latitude of the tracker in degrees",
+		[]string{"tracker"}, nil,
+	)
+
+	Longitude = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "longitude"),
+		"Longitude of the tracker in degrees",
+		[]string{"tracker"}, nil,
+	)
+
+	Speed = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "speed"),
+		"Speed of the tracker in meters per second",
+		[]string{"tracker"}, nil,
+	)
+
+	Altitude = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "altitude"),
+		"Altitude of the tracker in meters",
+		[]string{"tracker"}, nil,
+	)
+
+	Live = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "live"),
+		"Is tracker live",
+		[]string{"tracker"}, nil,
+	)
+
+	Battery = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "battery_level"),
+		"Battery level of the tracker in percent",
+		[]string{"tracker"}, nil,
+	)
+
+	Code = prometheus.NewDesc(
+		prometheus.BuildFQName("tractive", "", "code"),
+		"API response code",
+		[]string{"tracker", "category", "message"}, nil,
+	)
+)
+
+// Source is where the positions come from, a public share or an account
+type Source func(ctx context.Context, tracker string) (*tractive.Position, error)
+
+// Shares reads the trackers as public share IDs
+func Shares(client *tractive.Client) Source {
+	return client.SharePosition
+}
+
+// Account reads the trackers as tracker IDs of a logged in account
+func Account(account *tractive.Account) Source {
+	return account.Position
+}
+
+// Collector asks the source for every tracker on every scrape
+type Collector struct {
+	source   Source
+	trackers []string
+
+	// Timeout of a scrape's calls, 10s when 0
+	Timeout time.Duration
+}
+
+// New ...
+func New(source Source, trackers ...string) *Collector {
+	return &Collector{source: source, trackers: trackers}
+}
+
+// Describe ...
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		Up, LastTime, Age, Latitude, Longitude,
+		Speed, Altitude, Live, Battery, Code,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect fetches the trackers in parallel, Up is 0 when none answered
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	positions := make([]*tractive.Position, len(c.trackers))
+	errs := make([]error, len(c.trackers))
+	var wg sync.WaitGroup
+	for i, id := range c.trackers {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			positions[i], errs[i] = c.source(ctx, id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	answered := 0
+	for i, id := range c.trackers {
+		if errs[i] != nil {
+			slog.Warn("Position request failed", "tracker", id, "err", errs[i])
+			if apiErr, ok := errs[i].(*tractive.Error); ok {
+				answered++
+				ch <- prometheus.MustNewConstMetric(Code, prometheus.GaugeValue, float64(apiErr.Code), id, apiErr.Category, apiErr.Message)
+			}
+			continue
+		}
+		answered++
+		p := positions[i]
+		ch <- prometheus.MustNewConstMetric(LastTime, prometheus.GaugeValue, float64(p.Time), id)
+		ch <- prometheus.MustNewConstMetric(Age, prometheus.GaugeValue, float64(time.Now().Unix()-p.Time), id)
+		ch <- prometheus.MustNewConstMetric(Latitude, prometheus.GaugeValue, p.Lat, id)
+		ch <- prometheus.MustNewConstMetric(Longitude, prometheus.GaugeValue, p.Lon, id)
+		ch <- prometheus.MustNewConstMetric(Speed, prometheus.GaugeValue, p.Speed, id)
+		ch <- prometheus.MustNewConstMetric(Altitude, prometheus.GaugeValue, float64(p.Alt), id)
+		var live float64
+		if p.Live {
+			live = 1
+		}
+		ch <- prometheus.MustNewConstMetric(Live, prometheus.GaugeValue, live, id)
+		if p.Battery > 0 {
+			ch <- prometheus.MustNewConstMetric(Battery, prometheus.GaugeValue, float64(p.Battery), id)
+		}
+	}
+
+	var value float64
+	if answered > 0 || len(c.trackers) == 0 {
+		value = 1
+	}
+	ch <- prometheus.MustNewConstMetric(Up, prometheus.GaugeValue, value)
+}
@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/one1zero1one/tractive_exporter/pkg/tractive"
+)
+
+// gathered is what a scrape of c has, by name{labels}
+func gathered(t *testing.T, c *Collector) map[string]float64 {
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(c)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.Metric {
+			var labels []string
+			for _, l := range m.Label {
+				labels = append(labels, fmt.Sprintf("%s=%q", l.GetName(), l.GetValue()))
+			}
+			sort.Strings(labels)
+			name := family.GetName()
+			if len(labels) > 0 {
+				name += "{" + strings.Join(labels, ",") + "}"
+			}
+			series[name] = m.GetGauge().GetValue()
+		}
+	}
+	return series
+}
+
+// A share with a position, one that's gone and one that doesn't answer
+func TestCollector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/3/public_share/rex/position":
+			fmt.Fprintf(w, `{"time":%d,"lat":48.2,"lon":16.37,"speed":1.5,"alt":180,"lt_active":true,"battery_level":80}`, time.Now().Unix()-60)
+		case "/3/public_share/gone/position":
+			fmt.Fprint(w, `{"code":3555,"category":"PUBLIC SHARE","message":"The public share does not exist.","detail":null}`)
+		default:
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	client := tractive.NewClient(server.Client())
+	client.BaseURL = server.URL + "/3/"
+
+	for _, tc := range []struct {
+		name     string
+		trackers []string
+		want     map[string]float64
+		missing  []string
+	}{
+		{
+			name:     "position",
+			trackers: []string{"rex"},
+			want: map[string]float64{
+				"tractive_up":                           1,
+				`tractive_latitude{tracker="rex"}`:      48.2,
+				`tractive_longitude{tracker="rex"}`:     16.37,
+				`tractive_speed{tracker="rex"}`:         1.5,
+				`tractive_altitude{tracker="rex"}`:      180,
+				`tractive_live{tracker="rex"}`:          1,
+				`tractive_battery_level{tracker="rex"}`: 80,
+			},
+		},
+		{
+			name:     "error code",
+			trackers: []string{"gone"},
+			want: map[string]float64{
+				"tractive_up": 1,
+				`tractive_code{category="PUBLIC SHARE",message="The public share does not exist.",tracker="gone"}`: 3555,
+			},
+			missing: []string{`tractive_latitude{tracker="gone"}`},
+		},
+		{
+			name:     "no answer",
+			trackers: []string{"down"},
+			want:     map[string]float64{"tractive_up": 0},
+			missing:  []string{`tractive_latitude{tracker="down"}`},
+		},
+		{
+			name:     "one of two answers",
+			trackers: []string{"rex", "down"},
+			want:     map[string]float64{"tractive_up": 1, `tractive_latitude{tracker="rex"}`: 48.2},
+			missing:  []string{`tractive_latitude{tracker="down"}`},
+		},
+		{
+			name: "no trackers",
+			want: map[string]float64{"tractive_up": 1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			series := gathered(t, New(Shares(client), tc.trackers...))
+			for name, want := range tc.want {
+				if got, ok := series[name]; !ok || got != want {
+					t.Errorf("%s is %g (there: %v), want %g", name, got, ok, want)
+				}
+			}
+			for _, name := range tc.missing {
+				if _, ok := series[name]; ok {
+					t.Errorf("%s is there, shouldn't be", name)
+				}
+			}
+			if age := series[`tractive_age_seconds{tracker="rex"}`]; age != 0 && (age < 60 || age > 70) {
+				t.Errorf("age %gs, want about 60s", age)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package tractive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// What the app sends, the API wants to know who's calling
+const clientID = "625e533dc3c3b41c28a669f0"
+
+// Account talks to the API as a logged in user. It logs in again when the
+// token is about to expire, unless it was made with a token only.
+type Account struct {
+	client *Client
+
+	mutex    sync.Mutex
+	email    string
+	password string
+	token    string
+	userID   string
+	expires  time.Time
+}
+
+// Hardware is a tracker's battery and what it's up to
+type Hardware struct {
+	Time          int64    `json:"time"`
+	BatteryLevel  int      `json:"battery_level"`
+	BatteryState  string   `json:"battery_state"`
+	ChargingState string   `json:"charging_state"`
+	HwStatus      string   `json:"hw_status"`
+	GSMSignal     *float64 `json:"gsm_signal_strength"`
+	GPSSignal     *float64 `json:"gps_signal_strength"`
+}
+
+// Geofence is a virtual fence drawn in the app. Coords are [lat, lon], the
+// center of a CIRCLE or the corners of a POLYGON or RECTANGLE.
+type Geofence struct {
+	ID        string       `json:"_id"`
+	Name      string       `json:"name"`
+	Shape     string       `json:"shape"`
+	FenceType string       `json:"fence_type"`
+	Coords    [][2]float64 `json:"coords"`
+	Radius    float64      `json:"radius"`
+	Active    bool         `json:"active"`
+}
+
+// Login logs into an account with the app's email and password
+func (c *Client) Login(ctx context.Context, email, password string) (*Account, error) {
+	if email == "" || password == "" {
+		return nil, errors.New("tractive: login needs an email and a password")
+	}
+	a := &Account{client: c, email: email, password: password}
+	if err := a.login(ctx); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// WithToken is an account for an access token, it can't be refreshed
+func (c *Client) WithToken(token, userID string) *Account {
+	return &Account{client: c, token: token, userID: userID}
+}
+
+// UserID ...
+func (a *Account) UserID() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.userID
+}
+
+// Expires is when the token runs out, zero for a token of WithToken
+func (a *Account) Expires() time.Time {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.expires
+}
+
+// login trades email and password for a bearer token, callers hold the
+// mutex or have the account to themselves
+func (a *Account) login(ctx context.Context) error {
+	body, _ := json.Marshal(map[string]string{
+		"grant_type":     "tractive",
+		"platform_email": a.email,
+		"platform_token": a.password,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.client.URL("auth/token"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json;charset=UTF-8")
+	req.Header.Set("X-Tractive-Client", clientID)
+	if a.client.UserAgent != "" {
+		req.Header.Set("User-Agent", a.client.UserAgent)
+	}
+
+	resp, err := a.client.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var t struct {
+		UserID      string `json:"user_id"`
+		ExpiresAt   int64  `json:"expires_at"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := decode(resp.StatusCode, b, &t); err != nil {
+		return err
+	}
+	a.token, a.userID = t.AccessToken, t.UserID
+	a.expires = time.Unix(t.ExpiresAt, 0)
+	return nil
+}
+
+// Header is what a call needs to be authorized, logging in again when the
+// token is about to expire. It's for calls that aren't a plain GET.
+func (a *Account) Header(ctx context.Context) (http.Header, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.password != "" && (a.token == "" || time.Until(a.expires) < time.Minute) {
+		if err := a.login(ctx); err != nil {
+			return nil, err
+		}
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+a.token)
+	header.Set("X-Tractive-Client", clientID)
+	header.Set("X-Tractive-User", a.userID)
+	return header, nil
+}
+
+// Get is Client.Get as the user, once more with a fresh token on a 401
+func (a *Account) Get(ctx context.Context, path string) (int, []byte, error) {
+	for attempt := 0; ; attempt++ {
+		header, err := a.Header(ctx)
+		if err != nil {
+			return 0, nil, err
+		}
+		status, body, err := a.client.Get(ctx, path, header)
+		if err == nil && status == http.StatusUnauthorized && attempt == 0 && a.password != "" {
+			a.mutex.Lock()
+			a.token = ""
+			a.mutex.Unlock()
+			continue
+		}
+		return status, body, err
+	}
+}
+
+// get fetches a path into v
+func (a *Account) get(ctx context.Context, path string, v interface{}) error {
+	status, body, err := a.Get(ctx, path)
+	if err != nil {
+		return err
+	}
+	return decode(status, body, v)
+}
+
+// Trackers lists the tracker IDs of the account
+func (a *Account) Trackers(ctx context.Context) ([]string, error) {
+	var list []struct {
+		ID string `json:"_id"`
+	}
+	if err := a.get(ctx, "user/"+a.UserID()+"/trackers", &list); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(list))
+	for _, t := range list {
+		ids = append(ids, t.ID)
+	}
+	return ids, nil
+}
+
+// Hardware is the tracker's last hardware report
+func (a *Account) Hardware(ctx context.Context, trackerID string) (*Hardware, error) {
+	var hw Hardware
+	if err := a.get(ctx, "device_hw_report/"+trackerID, &hw); err != nil {
+		return nil, err
+	}
+	return &hw, nil
+}
+
+// Live is whether the tracker is in live tracking
+func (a *Account) Live(ctx context.Context, trackerID string) (bool, error) {
+	var tracker struct {
+		Live bool `json:"lt_active"`
+	}
+	if err := a.get(ctx, "tracker/"+trackerID, &tracker); err != nil {
+		return false, err
+	}
+	return tracker.Live, nil
+}
+
+// Position is the tracker's last position, with the battery of its last
+// hardware report and whether it's live when those calls work
+func (a *Account) Position(ctx context.Context, trackerID string) (*Position, error) {
+	var report struct {
+		Time     int64      `json:"time"`
+		LatLong  [2]float64 `json:"latlong"`
+		Speed    float64    `json:"speed"`
+		Altitude int        `json:"altitude"`
+	}
+	if err := a.get(ctx, "device_pos_report/"+trackerID, &report); err != nil {
+		return nil, err
+	}
+	p := &Position{
+		Time:  report.Time,
+		Lat:   report.LatLong[0],
+		Lon:   report.LatLong[1],
+		Speed: report.Speed,
+		Alt:   report.Altitude,
+	}
+
+	// battery is nice to have, no reason to drop the position for it
+	if hw, err := a.Hardware(ctx, trackerID); err == nil {
+		p.Battery = hw.BatteryLevel
+	}
+	if live, err := a.Live(ctx, trackerID); err == nil {
+		p.Live = live
+	}
+	return p, nil
+}
+
+// Geofences are the virtual fences of a tracker
+func (a *Account) Geofences(ctx context.Context, trackerID string) ([]Geofence, error) {
+	var list []struct {
+		ID string `json:"_id"`
+	}
+	if err := a.get(ctx, "tracker/"+trackerID+"/geofences", &list); err != nil {
+		return nil, err
+	}
+	fences := make([]Geofence, 0, len(list))
+	for _, f := range list {
+		var fence Geofence
+		if err := a.get(ctx, "geofence/"+f.ID, &fence); err != nil {
+			return nil, err
+		}
+		fences = append(fences, fence)
+	}
+	return fences, nil
+}
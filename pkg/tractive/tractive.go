@@ -0,0 +1,163 @@
+// Package tractive is a small client for the Tractive API, the public
+// shares and the endpoints behind the app login, for programs that want
+// the positions without running the exporter.
+//
+//	client := tractive.NewClient(nil)
+//	p, err := client.SharePosition(ctx, "6a7235da65")
+package tractive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultBaseURL is where the API lives
+const DefaultBaseURL = "https://graph.tractive.com/3/"
+
+// Client makes the calls, shared by public shares and accounts
+type Client struct {
+	// HTTP does the requests, http.DefaultClient when nil
+	HTTP *http.Client
+
+	// BaseURL is DefaultBaseURL unless set, with a trailing slash
+	BaseURL string
+
+	// UserAgent goes with every call
+	UserAgent string
+}
+
+// NewClient is a Client with the defaults, on httpClient if not nil
+func NewClient(httpClient *http.Client) *Client {
+	return &Client{HTTP: httpClient, BaseURL: DefaultBaseURL, UserAgent: "tractive_prometheus_exporter"}
+}
+
+// Position is where a tracker was when it last reported
+type Position struct {
+	Time    int64   `json:"time"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Speed   float64 `json:"speed"`
+	Alt     int     `json:"alt"`
+	Live    bool    `json:"lt_active"`
+	Battery int     `json:"battery_level"`
+}
+
+// Info is what a public share says about its pet
+type Info struct {
+	Name      string `json:"name"`
+	TrackerID string `json:"tracker_id"`
+	ImageURL  string `json:"image_url"`
+	OwnerName string `json:"owner_name"`
+}
+
+// Error is an answer with an error code instead of the data, like 3555
+// for a share that doesn't exist (anymore)
+type Error struct {
+	Code     int    `json:"code"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+// Error ...
+func (e *Error) Error() string {
+	return fmt.Sprintf("tractive: %d %s: %s", e.Code, e.Category, e.Message)
+}
+
+// StatusError is an HTTP status the API shouldn't have answered with
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+// Error ...
+func (e *StatusError) Error() string {
+	return "tractive: HTTP " + e.Status
+}
+
+// SharePosition is the position of a public share
+func (c *Client) SharePosition(ctx context.Context, shareID string) (*Position, error) {
+	var p Position
+	if err := c.do(ctx, "public_share/"+shareID+"/position", nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ShareInfo is the pet of a public share
+func (c *Client) ShareInfo(ctx context.Context, shareID string) (*Info, error) {
+	var info Info
+	if err := c.do(ctx, "public_share/"+shareID+"/info", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// URL is the full URL of an API path
+func (c *Client) URL(path string) string {
+	if c.BaseURL == "" {
+		return DefaultBaseURL + path
+	}
+	return c.BaseURL + path
+}
+
+// Get GETs a path as it is, with the headers if any, and returns the
+// status and body whatever they are. It's for the calls this package has
+// no method for, and for decoding the answers your own way.
+func (c *Client) Get(ctx context.Context, path string, header http.Header) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL(path), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// httpClient ...
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP == nil {
+		return http.DefaultClient
+	}
+	return c.HTTP
+}
+
+// do GETs a path into v, with the headers if any
+func (c *Client) do(ctx context.Context, path string, header http.Header, v interface{}) error {
+	status, body, err := c.Get(ctx, path, header)
+	if err != nil {
+		return err
+	}
+	return decode(status, body, v)
+}
+
+// decode reads an answer into v, or the error it is
+func decode(status int, body []byte, v interface{}) error {
+	var apiErr Error
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Code != 0 {
+		return &apiErr
+	}
+	if status/100 != 2 {
+		return &StatusError{StatusCode: status, Status: fmt.Sprintf("%d %s", status, http.StatusText(status))}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("tractive: %v", err)
+	}
+	return nil
+}